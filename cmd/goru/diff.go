@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/anyproto/goru/internal/collector/file"
+	"github.com/anyproto/goru/internal/diff"
+	"github.com/anyproto/goru/internal/golden"
+	"github.com/anyproto/goru/internal/report"
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// diffOutput is what "goru diff --json" prints
+type diffOutput struct {
+	Old     string           `json:"old"`
+	New     string           `json:"new"`
+	Stats   diff.DiffStats   `json:"stats"`
+	Changes *model.ChangeSet `json:"changes"`
+}
+
+// runDiff implements "goru diff [--json] <old> <new>", which compares two
+// dump files or snapshots saved as JSON and prints what changed
+func runDiff(args []string, w io.Writer) error {
+	flags := pflag.NewFlagSet("diff", pflag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the changes as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: goru diff [--json] <old> <new>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("diff needs exactly two files, got %d", flags.NArg())
+	}
+
+	before, err := loadSnapshot(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := loadSnapshot(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	d := diff.New()
+	changes := d.Compare(before, after)
+	stats := d.Stats(changes)
+
+	if *asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diffOutput{
+			Old:     flags.Arg(0),
+			New:     flags.Arg(1),
+			Stats:   stats,
+			Changes: changes,
+		})
+	}
+	return report.WriteDiff(w, before, after, changes, stats)
+}
+
+// loadSnapshot reads a snapshot saved as JSON, or parses a goroutine dump
+func loadSnapshot(path string) (*model.Snapshot, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		snapshot, err := golden.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading snapshot %s: %w", path, err)
+		}
+		return snapshot, nil
+	}
+
+	snapshot, err := file.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading dump %s: %w", path, err)
+	}
+	return snapshot, nil
+}
@@ -2,21 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
 
+	"github.com/anyproto/goru/internal/alert"
 	"github.com/anyproto/goru/internal/collector"
 	"github.com/anyproto/goru/internal/collector/file"
 	"github.com/anyproto/goru/internal/collector/http"
 	"github.com/anyproto/goru/internal/config"
+	"github.com/anyproto/goru/internal/golden"
+	"github.com/anyproto/goru/internal/metrics"
 	"github.com/anyproto/goru/internal/orchestrator"
-	"github.com/anyproto/goru/internal/store"
+	"github.com/anyproto/goru/internal/parser"
+	"github.com/anyproto/goru/internal/record"
+	"github.com/anyproto/goru/internal/report"
 	"github.com/anyproto/goru/internal/telemetry"
 	"github.com/anyproto/goru/internal/tui"
+	"github.com/anyproto/goru/internal/web"
+	"github.com/anyproto/goru/pkg/store"
 )
 
 var (
@@ -38,14 +50,43 @@ func run() error {
 		return nil
 	}
 
+	// Compare two saved dumps instead of collecting
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		return runDiff(os.Args[2:], os.Stdout)
+	}
+
+	// Convert a dump to JSON instead of collecting
+	if len(os.Args) > 1 && os.Args[1] == "parse" {
+		return runParse(os.Args[2:], os.Stdin, os.Stdout)
+	}
+
+	// Step through archived dumps instead of collecting
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		return runReplay(os.Args[2:])
+	}
+
 	// Load configuration
 	cfg := config.New()
 	if err := cfg.Load(); err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
 
+	// Play back a recording instead of collecting
+	if cfg.LoadDir != "" {
+		return runLoad(cfg.LoadDir)
+	}
+
 	// Initialize logger
-	logger := telemetry.NewLogger(cfg.Log.Level, cfg.Log.JSON)
+	var logOpts []telemetry.LoggerOption
+	if cfg.Log.File != "" {
+		logFile, err := telemetry.OpenLogFile(cfg.Log.File, cfg.Log.MaxSize)
+		if err != nil {
+			return fmt.Errorf("opening log file: %w", err)
+		}
+		defer logFile.Close()
+		logOpts = append(logOpts, telemetry.WithOutput(logFile))
+	}
+	logger := telemetry.NewLogger(cfg.Log.Level, cfg.Log.JSON, logOpts...)
 	logger.Info("Starting goru",
 		telemetry.String("version", version),
 		telemetry.String("mode", string(cfg.Mode)),
@@ -64,34 +105,110 @@ func run() error {
 		cancel()
 	}()
 
-	// Start pprof if configured
-	if err := telemetry.StartPProf(ctx, cfg.PProf, logger); err != nil {
-		return fmt.Errorf("starting pprof: %w", err)
-	}
-
 	// Create store
 	s := store.New()
+	s.SetHistoryDepth(cfg.HistoryDepth)
+	// A memory budget is easier to keep with traces shared
+	s.SetInterning(cfg.InternTraces || cfg.MaxMemory > 0)
+	s.SetMaxMemory(cfg.MaxMemory)
+
+	// Post alerts for the configured rules as snapshots come in
+	if len(cfg.Alerts.Rules) > 0 {
+		engine, err := alert.New(cfg.Alerts.Rules, cfg.Alerts.Webhook, cfg.Alerts.Debounce, logger)
+		if err != nil {
+			return fmt.Errorf("creating alerts: %w", err)
+		}
+		engine.Start(ctx, s)
+		logger.Info("Alerting enabled", telemetry.Int("rules", len(cfg.Alerts.Rules)))
+	}
+
+	// Keep every snapshot for --load or goru replay later
+	if cfg.RecordDir != "" {
+		recorder, err := record.New(cfg.RecordDir, logger)
+		if err != nil {
+			return fmt.Errorf("creating record directory: %w", err)
+		}
+		recorder.Start(ctx, s)
+		logger.Info("Recording snapshots", telemetry.String("dir", cfg.RecordDir))
+	}
+
+	// Start pprof and metrics if configured. Metrics share the pprof server
+	// unless they have an address of their own.
+	var routes []telemetry.Route
+	if cfg.Metrics == "" || cfg.Metrics == cfg.PProf {
+		routes = append(routes, telemetry.Route{Pattern: "/metrics", Handler: metrics.Handler(s, logger)})
+	} else if err := telemetry.StartMetrics(ctx, cfg.Metrics, metrics.Handler(s, logger), logger); err != nil {
+		return fmt.Errorf("starting metrics: %w", err)
+	}
+	if err := telemetry.StartPProf(ctx, cfg.PProf, logger, routes...); err != nil {
+		return fmt.Errorf("starting pprof: %w", err)
+	}
 
 	// Create collectors
 	var sources []collector.Source
+	parserOpts := []parser.Option{
+		parser.WithMinWait(cfg.MinWait),
+		parser.WithGroupDepth(cfg.GroupDepth),
+		parser.WithNormalizeGenerics(cfg.NormalizeGenerics),
+		parser.WithGroupByLabels(cfg.GroupByLabels),
+		parser.WithGroupByArgs(cfg.GroupByArgs),
+		parser.WithMaxGoroutines(cfg.MaxGoroutines),
+	}
 
 	// HTTP sources
+	var httpSource *http.HTTPSource
 	if len(cfg.Targets) > 0 {
 		// Register all HTTP targets with the store so they appear in UI even if unreachable
 		s.RegisterHosts(cfg.Targets)
-		
-		httpSource := http.New(cfg.Targets, cfg.Timeout, 5) // 5 workers
+		for target, labels := range cfg.TargetLabels {
+			s.SetLabels(target, labels)
+		}
+
+		httpSource = http.New(cfg.Targets, cfg.Timeout, cfg.HTTP.Workers, parserOpts...)
+		httpSource.SetConnectionPool(cfg.HTTP.MaxIdleConns, cfg.HTTP.IdleConnTimeout)
+		httpSource.SetJitter(cfg.HTTP.Jitter)
+		httpSource.SetRequest(http.Request{Method: cfg.Method, Body: []byte(cfg.Body)})
+		httpSource.SetMemStats(cfg.MemStats)
+		httpSource.SetDebugLevel(cfg.HTTP.DebugLevel)
+		httpSource.SetMaxBodySize(cfg.HTTP.MaxBodySize)
+		httpSource.SetRetries(cfg.HTTP.Retries, cfg.HTTP.RetryBackoff)
+		userAgent := cfg.HTTP.UserAgent
+		if userAgent == "" {
+			userAgent = http.DefaultUserAgent + "/" + version
+		}
+		httpSource.SetUserAgent(userAgent)
+		if cfg.HTTP.InsecureSkipVerify {
+			httpSource.SetInsecureSkipVerify(true)
+		}
+		headers, err := cfg.HTTPHeaders()
+		if err != nil {
+			return fmt.Errorf("building HTTP headers: %w", err)
+		}
+		httpSource.SetHeaders(headers)
+		for target, req := range cfg.Requests {
+			httpSource.SetTargetRequest(target, http.Request{Method: req.Method, Body: []byte(req.Body)})
+		}
+		for target, interval := range cfg.Intervals {
+			httpSource.SetTargetInterval(target, interval)
+		}
+		for target, timeout := range cfg.Timeouts {
+			httpSource.SetTargetTimeout(target, timeout)
+		}
 		sources = append(sources, httpSource)
 		logger.Info("Added HTTP source",
 			telemetry.Int("targets", len(cfg.Targets)),
 			telemetry.Duration("interval", cfg.Interval),
 			telemetry.Duration("timeout", cfg.Timeout),
+			telemetry.Int("workers", cfg.HTTP.Workers),
 		)
 	}
 
 	// File sources
+	var fileSource *file.FileSource
 	if len(cfg.Files) > 0 {
-		fileSource := file.New(cfg.Files, cfg.Follow, cfg.Interval)
+		fileSource = file.New(cfg.Files, cfg.Follow, cfg.Interval, parserOpts...)
+		fileSource.SetTimestamp(file.Timestamp(cfg.FileTime))
+		fileSource.SetMulti(file.Multi(cfg.FilesMulti))
 		sources = append(sources, fileSource)
 		logger.Info("Added file source",
 			telemetry.Int("patterns", len(cfg.Files)),
@@ -105,41 +222,183 @@ func run() error {
 
 	// Create and start orchestrator
 	orch := orchestrator.New(s, cfg.Interval, sources...)
+	orch.SetStaleAfter(cfg.StaleAfter)
 
-	// Start orchestrator in background
+	// Reload targets and files from the config and targets files on SIGHUP
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	go func() {
+		current := cfg
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupCh:
+				next, err := reloadSources(current, s, orch, httpSource, fileSource, logger)
+				if err != nil {
+					logger.Error("Reloading config failed", telemetry.Error(err))
+					continue
+				}
+				current = next
+			}
+		}
+	}()
+
+	// Start orchestrator in background. Collection runs on a context of its
+	// own so a shutdown lets in-flight collections finish instead of
+	// aborting them, keeping the last complete state in the store.
+	collectCtx, stopCollecting := context.WithCancel(context.Background())
+	defer stopCollecting()
 	orchErrCh := make(chan error, 1)
 	go func() {
-		if err := orch.Start(ctx); err != nil {
+		if err := orch.Start(collectCtx); err != nil && !errors.Is(err, context.Canceled) {
 			orchErrCh <- fmt.Errorf("orchestrator error: %w", err)
 		}
 	}()
 
+	// One-shot collection for scripts and CI
+	if cfg.Once {
+		waitForFirstRound(ctx, s, cfg.CollectionTimeout())
+		if cfg.Golden != "" {
+			if err := checkGolden(s, cfg.Golden, cfg.UpdateGolden, cfg.MaxDrift); err != nil {
+				return err
+			}
+		} else if err := report.Write(os.Stdout, s, 10); err != nil {
+			return err
+		}
+		if cfg.MaxGoroutinesAlert > 0 {
+			if over := report.OverLimit(s, cfg.MaxGoroutinesAlert); len(over) > 0 {
+				return fmt.Errorf("%d hosts over %d goroutines: %s", len(over), cfg.MaxGoroutinesAlert, strings.Join(over, ", "))
+			}
+		}
+		return nil
+	}
+
+	// Start the web server alongside or instead of the TUI
+	if cfg.HasWeb() {
+		server := web.New(s, cfg.Web.Host, cfg.Web.Port, logger)
+		if cfg.Web.TLSCert != "" {
+			server.SetTLS(cfg.Web.TLSCert, cfg.Web.TLSKey)
+		}
+		if cfg.Web.AuthUser != "" {
+			server.SetBasicAuth(cfg.Web.AuthUser, cfg.Web.AuthPass)
+		}
+		if cfg.Web.AuthToken != "" {
+			server.SetToken(cfg.Web.AuthToken)
+		}
+		if (cfg.Web.AuthUser != "" || cfg.Web.AuthToken != "") && cfg.Web.TLSCert == "" {
+			logger.Warn("Web auth is enabled without TLS, credentials are sent in the clear")
+		}
+		url, err := server.Start(ctx)
+		if err != nil {
+			return fmt.Errorf("starting web server: %w", err)
+		}
+		// Without a terminal, e.g. under systemd, there's nobody to show a
+		// browser to
+		if !cfg.Web.NoOpen && isatty.IsTerminal(os.Stdout.Fd()) {
+			if err := web.OpenBrowser(url); err != nil {
+				logger.Warn("Failed to open browser", telemetry.Error(err))
+			}
+		}
+	}
+
 	// Start UI based on mode
 	var uiErr error
 
 	switch cfg.Mode {
 	case config.ModeTUI, config.ModeBoth:
+		// The full-screen TUI needs a terminal on both ends
+		if !isTerminal() {
+			// The web UI doesn't need one, so keep serving it
+			if cfg.Mode == config.ModeBoth {
+				logger.Info("No terminal detected, serving the web UI only")
+				<-ctx.Done()
+				break
+			}
+			if cfg.NoTTY == config.NoTTYFail {
+				return fmt.Errorf("%s mode requires a terminal (use --no-tty=report for plain-text output)", cfg.Mode)
+			}
+
+			logger.Info("No terminal detected, printing a plain-text report")
+			waitForFirstRound(ctx, s, cfg.CollectionTimeout())
+			uiErr = report.Write(os.Stdout, s, 10)
+			break
+		}
+
+		// Restore the view preferences of the last run
+		var statePath string
+		var state tui.State
+		if !cfg.NoState {
+			var err error
+			if statePath, err = tui.DefaultStatePath(); err != nil {
+				logger.Warn("Not saving TUI state", telemetry.Error(err))
+			} else if state, err = tui.LoadState(statePath); err != nil {
+				logger.Warn("Ignoring saved TUI state", telemetry.Error(err))
+			}
+		}
+
 		// Create TUI model
-		model := tui.New(s, orch, cfg.Interval)
+		opts := []tui.Option{
+			tui.WithSinceStart(cfg.DiffMode == config.DiffStart),
+			tui.WithCreatedByTop(cfg.CreatedByTop),
+			tui.WithAppFramePackages(cfg.PackageFrame == config.PackageFrameApp),
+			tui.WithHideAbsentPins(cfg.HideAbsentPins),
+			tui.WithFramesColumn(cfg.FramesColumn),
+			tui.WithAppPrefixes(cfg.AppPrefixes),
+			tui.WithSkipPackages(cfg.SkipPackages),
+			tui.WithIDTieBreak(cfg.SortTies == config.SortTiesID),
+			tui.WithHostLabel(cfg.HostLabel),
+			tui.WithKeyBindings(cfg.KeyBindings),
+			tui.WithStateColors(cfg.StateColors),
+			tui.WithCountThresholds(cfg.WarnCount, cfg.CritCount),
+			tui.WithState(state),
+			tui.WithFilter(cfg.Filter),
+			tui.WithStateFilter(cfg.FilterState),
+		}
+		// Targets can only be edited when goru collects from some
+		if httpSource != nil {
+			opts = append(opts, tui.WithTargetEditor(&targetEditor{store: s, orch: orch, httpSource: httpSource}))
+		}
+		model := tui.New(s, orch, cfg.Interval, opts...)
 
 		// Create tea program
-		p := tea.NewProgram(model, tea.WithAltScreen())
+		// Mouse reporting lets the wheel scroll the details view
+		p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
+
+		// Quit cleanly on SIGTERM and friends, restoring the terminal
+		go func() {
+			<-ctx.Done()
+			p.Quit()
+		}()
 
 		// Run TUI
 		logger.Info("Starting TUI")
-		if _, err := p.Run(); err != nil {
+		final, err := p.Run()
+		if err != nil {
 			uiErr = fmt.Errorf("TUI error: %w", err)
+		} else if m, ok := final.(tui.Model); ok && statePath != "" {
+			if err := tui.SaveState(statePath, m.State()); err != nil {
+				logger.Warn("Failed to save TUI state", telemetry.Error(err))
+			}
 		}
 
 	case config.ModeWeb:
-		// TODO: Implement web server
-		logger.Info("Web mode not yet implemented")
+		// The web server runs until shutdown
 		<-ctx.Done()
 
 	default:
 		return fmt.Errorf("invalid mode: %s", cfg.Mode)
 	}
 
+	// Let in-flight collections finish within the grace period, then abort
+	// whatever is left
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	if err := orch.Shutdown(drainCtx); err != nil {
+		logger.Warn("Aborting collections still in flight", telemetry.Error(err))
+	}
+	cancelDrain()
+	stopCollecting()
+
 	// Check for orchestrator errors
 	select {
 	case err := <-orchErrCh:
@@ -154,3 +413,113 @@ func run() error {
 	logger.Info("Shutdown complete")
 	return nil
 }
+
+// checkGolden compares every host's snapshot against the golden file and
+// fails if any drifted, or rewrites the golden file from the single host
+// collected when update is set
+func checkGolden(s *store.Store, path string, update bool, maxDrift int) error {
+	if update {
+		snapshots := s.GetAllSnapshots()
+		if len(snapshots) != 1 {
+			return fmt.Errorf("--update-golden needs exactly one host with data, got %d", len(snapshots))
+		}
+		for host, snapshot := range snapshots {
+			if err := golden.Save(path, snapshot); err != nil {
+				return fmt.Errorf("writing golden snapshot: %w", err)
+			}
+			fmt.Printf("Wrote golden snapshot of %s to %s\n", host, path)
+		}
+		return nil
+	}
+
+	expected, err := golden.Load(path)
+	if err != nil {
+		return fmt.Errorf("loading golden snapshot: %w", err)
+	}
+
+	hosts := s.GetAllHosts()
+	sort.Strings(hosts)
+	errors := s.GetErrors()
+
+	failed := 0
+	for _, host := range hosts {
+		snapshot := s.GetSnapshot(host)
+		if snapshot == nil {
+			failed++
+			if err, ok := errors[host]; ok {
+				fmt.Printf("%s: error: %v\n", host, err)
+			} else {
+				fmt.Printf("%s: no data\n", host)
+			}
+			continue
+		}
+
+		violations := golden.Check(expected, snapshot, maxDrift)
+		if len(violations) == 0 {
+			fmt.Printf("%s: ok\n", host)
+			continue
+		}
+		failed++
+		fmt.Printf("%s: %d violation(s)\n", host, len(violations))
+		for _, v := range violations {
+			fmt.Printf("  %s\n", v)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hosts drifted from %s", failed, len(hosts), path)
+	}
+	return nil
+}
+
+// isTerminal reports whether both stdin and stdout are attached to a terminal
+func isTerminal() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// waitForFirstRound blocks until every known host has finished its first
+// collection and updates have settled, or the timeout, which should cover a
+// collection's retries, expires
+func waitForFirstRound(ctx context.Context, s *store.Store, timeout time.Duration) {
+	const settle = 250 * time.Millisecond
+
+	updates := make(chan store.Update, 100)
+	s.Subscribe(updates)
+	defer s.Unsubscribe(updates)
+
+	// A collection taking the whole timeout still gets reported
+	deadline := time.After(timeout + settle)
+	quiet := time.NewTimer(settle)
+	defer quiet.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-updates:
+			quiet.Reset(settle)
+		case <-quiet.C:
+			if allHostsSettled(s) {
+				return
+			}
+			quiet.Reset(settle)
+		}
+	}
+}
+
+// allHostsSettled reports whether at least one host is known and none is
+// still waiting for or running its collection
+func allHostsSettled(s *store.Store) bool {
+	phases := s.GetPhases()
+	if len(phases) == 0 {
+		return false
+	}
+	for _, phase := range phases {
+		if phase != store.PhaseSucceeded && phase != store.PhaseFailed {
+			return false
+		}
+	}
+	return true
+}
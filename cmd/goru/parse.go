@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+
+	"github.com/anyproto/goru/internal/collector/file"
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// runParse implements "goru parse <file>", which parses a goroutine dump,
+// or stdin for "-", and prints the snapshot as indented JSON. The output
+// can be read back by "goru diff" and --golden.
+func runParse(args []string, stdin io.Reader, w io.Writer) error {
+	flags := pflag.NewFlagSet("parse", pflag.ContinueOnError)
+	host := flags.String("host", "", "Host recorded in the snapshot (default: derived from the file name, or stdin)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: goru parse [--host=<name>] <file|->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("parse needs exactly one file, got %d", flags.NArg())
+	}
+
+	var snapshot *model.Snapshot
+	var err error
+	if path := flags.Arg(0); path == "-" {
+		snapshot, err = file.Read(stdin, "stdin")
+	} else {
+		snapshot, err = file.Load(path)
+	}
+	if err != nil {
+		return fmt.Errorf("loading dump %s: %w", flags.Arg(0), err)
+	}
+	if *host != "" {
+		snapshot.Host = *host
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snapshot)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/anyproto/goru/internal/collector/file"
+	"github.com/anyproto/goru/internal/collector/http"
+	"github.com/anyproto/goru/internal/config"
+	"github.com/anyproto/goru/internal/orchestrator"
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// reloadSources re-reads the targets and files from the config and targets
+// files and hands the changes to the running sources: new targets are
+// registered and collected right away, and removed ones are dropped from the
+// store. It returns the reloaded config. Sources of a kind goru started
+// without need a restart.
+func reloadSources(cfg *config.Config, s *store.Store, orch *orchestrator.Orchestrator,
+	httpSource *http.HTTPSource, fileSource *file.FileSource, logger telemetry.Logger) (*config.Config, error) {
+	next, err := cfg.ReloadSources()
+	if err != nil {
+		return nil, err
+	}
+
+	// Targets added or removed in the TUI since count too, so a reload
+	// leaves exactly the configured ones
+	current := cfg.Targets
+	if httpSource != nil {
+		current = httpSource.GetTargets()
+	}
+	added := subtract(next.Targets, current)
+	removed := subtract(current, next.Targets)
+	switch {
+	case httpSource != nil:
+		requests := make(map[string]http.Request, len(next.Requests))
+		for target, req := range next.Requests {
+			requests[target] = http.Request{Method: req.Method, Body: []byte(req.Body)}
+		}
+		httpSource.SetTargets(next.Targets, requests, next.Intervals, next.Timeouts)
+		s.RegisterHosts(added)
+		s.RemoveHosts(removed)
+		for _, target := range next.Targets {
+			s.SetLabels(target, next.TargetLabels[target])
+		}
+		for _, target := range added {
+			orch.TriggerRefreshHost(target)
+		}
+	case len(next.Targets) > 0:
+		logger.Warn("Ignoring reloaded targets, goru was started without any and needs a restart")
+	}
+
+	var addedFiles, removedFiles []string
+	switch {
+	case fileSource != nil:
+		addedFiles, removedFiles, err = fileSource.SetPatterns(next.Files)
+		if err != nil {
+			return nil, fmt.Errorf("reloading files: %w", err)
+		}
+		// Registering the new files lets back ones removed before
+		s.RegisterHosts(addedFiles)
+		s.RemoveHosts(removedFiles)
+	case len(next.Files) > 0:
+		logger.Warn("Ignoring reloaded files, goru was started without any and needs a restart")
+	}
+
+	logger.Info("Reloaded sources",
+		telemetry.Int("targets_added", len(added)),
+		telemetry.Int("targets_removed", len(removed)),
+		telemetry.Int("files_added", len(addedFiles)),
+		telemetry.Int("files_removed", len(removedFiles)),
+	)
+	return next, nil
+}
+
+// targetEditor adds and removes HTTP targets from the TUI while running,
+// when goru collects from any. Changes last until the next SIGHUP reload,
+// which goes back to the configured targets.
+type targetEditor struct {
+	store      *store.Store
+	orch       *orchestrator.Orchestrator
+	httpSource *http.HTTPSource
+}
+
+// AddTarget registers a host:port target and collects from it right away
+func (e *targetEditor) AddTarget(target string) error {
+	if err := config.ValidateTarget(target); err != nil {
+		return err
+	}
+	if !e.httpSource.AddTarget(target) {
+		return fmt.Errorf("%s is already a target", target)
+	}
+	e.store.RegisterHosts([]string{target})
+	e.orch.TriggerRefreshHost(target)
+	return nil
+}
+
+// RemoveTarget stops collecting from a target and drops it from the store
+func (e *targetEditor) RemoveTarget(target string) error {
+	if !e.httpSource.RemoveTarget(target) {
+		return fmt.Errorf("%s isn't an HTTP target", target)
+	}
+	e.store.RemoveHost(target)
+	return nil
+}
+
+// subtract returns the items of a that aren't in b
+func subtract(a, b []string) []string {
+	var diff []string
+	for _, item := range a {
+		if !slices.Contains(b, item) {
+			diff = append(diff, item)
+		}
+	}
+	return diff
+}
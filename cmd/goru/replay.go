@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/pflag"
+
+	"github.com/anyproto/goru/internal/record"
+	"github.com/anyproto/goru/internal/replay"
+	"github.com/anyproto/goru/internal/tui"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// loadRate is how long each snapshot of a recording is shown for with --load
+const loadRate = time.Second
+
+// runReplay implements "goru replay [--rate d] [--order time|name] <dir>",
+// which steps through a directory of archived dumps, or of snapshots
+// recorded with --record, in the TUI
+func runReplay(args []string) error {
+	flags := pflag.NewFlagSet("replay", pflag.ContinueOnError)
+	rate := flags.Duration("rate", time.Second, "Time each dump is shown for while playing")
+	order := flags.String("order", string(replay.OrderTime), "Order of the dumps: time (timestamp in the file name, else modification time) or name")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: goru replay [--rate d] [--order time|name] <dir>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("replay needs exactly one directory, got %d", flags.NArg())
+	}
+	if *rate <= 0 {
+		return fmt.Errorf("rate must be positive")
+	}
+	if o := replay.Order(*order); o != replay.OrderTime && o != replay.OrderName {
+		return fmt.Errorf("invalid order: %s (must be time or name)", *order)
+	}
+
+	// Recorded snapshots keep their hosts and times, so they're always in
+	// time order
+	dir := flags.Arg(0)
+	frames, err := record.Load(dir)
+	if errors.Is(err, record.ErrNoSnapshots) {
+		frames, err = replay.Load(dir, "replay:"+filepath.Base(filepath.Clean(dir)), replay.Order(*order))
+	}
+	if err != nil {
+		return err
+	}
+	return play(frames, *rate)
+}
+
+// runLoad plays back the snapshots recorded in dir, for --load
+func runLoad(dir string) error {
+	frames, err := record.Load(dir)
+	if err != nil {
+		return err
+	}
+	return play(frames, loadRate)
+}
+
+// play steps through frames in the TUI, one per rate while playing
+func play(frames []*model.Snapshot, rate time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	s := store.New()
+	player := replay.New(s, frames, rate)
+	// The TUI subscribes to the store before the first frame is shown
+	m := tui.New(s, nil, rate, tui.WithReplay(player))
+	go player.Run(ctx)
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	go func() {
+		<-ctx.Done()
+		p.Quit()
+	}()
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("TUI error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+// Package alert evaluates threshold rules against each host's snapshots and
+// posts the ones that fire to a webhook
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyproto/goru/internal/analysis"
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// DefaultDebounce is how long a rule that keeps holding for a host waits
+// before firing again
+const DefaultDebounce = 10 * time.Minute
+
+// Metrics are the values conditions can test, besides <state>_goroutines
+// for each goroutine state
+var Metrics = []string{
+	"total_goroutines",   // goroutines on the host
+	"groups",             // number of groups
+	"max_group_count",    // goroutines in the largest group
+	"group_count_growth", // most goroutines any group gained since the previous snapshot
+	"leak_candidates",    // groups growing across the leak window
+	"stuck_groups",       // groups whose longest wait keeps growing
+}
+
+// states are the goroutine states with a <state>_goroutines metric
+var states = []model.GoroutineState{
+	model.StateRunning,
+	model.StateRunnable,
+	model.StateBlocked,
+	model.StateWaiting,
+	model.StateSyscall,
+	model.StateUnknown,
+}
+
+// operators are the comparisons conditions can use, longest first so ">="
+// isn't read as ">"
+var operators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// Rule is a named condition from the config's alerts section
+type Rule struct {
+	Name      string `yaml:"name"`
+	Condition string `yaml:"condition"`
+}
+
+// Condition compares a metric of a host's latest snapshot to a threshold
+type Condition struct {
+	Metric    string
+	Op        string
+	Threshold int
+}
+
+// ParseCondition parses a condition such as "total_goroutines > 5000". A
+// bare metric, such as "leak_candidates", means it is above 0.
+func ParseCondition(s string) (Condition, error) {
+	c := Condition{Metric: strings.TrimSpace(s), Op: ">"}
+	for _, op := range operators {
+		metric, threshold, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(threshold))
+		if err != nil {
+			return Condition{}, fmt.Errorf("threshold in %q is not a whole number", s)
+		}
+		c = Condition{Metric: strings.TrimSpace(metric), Op: op, Threshold: n}
+		break
+	}
+
+	if !knownMetric(c.Metric) {
+		return Condition{}, fmt.Errorf("unknown metric %q (valid: %s, or <state>_goroutines)", c.Metric, strings.Join(Metrics, ", "))
+	}
+	return c, nil
+}
+
+func knownMetric(metric string) bool {
+	if slices.Contains(Metrics, metric) {
+		return true
+	}
+	state, ok := strings.CutSuffix(metric, "_goroutines")
+	return ok && slices.Contains(states, model.GoroutineState(state))
+}
+
+func (c Condition) String() string {
+	return fmt.Sprintf("%s %s %d", c.Metric, c.Op, c.Threshold)
+}
+
+// holds reports whether value meets the condition
+func (c Condition) holds(value int) bool {
+	switch c.Op {
+	case ">":
+		return value > c.Threshold
+	case ">=":
+		return value >= c.Threshold
+	case "<":
+		return value < c.Threshold
+	case "<=":
+		return value <= c.Threshold
+	case "==":
+		return value == c.Threshold
+	default: // "!="
+		return value != c.Threshold
+	}
+}
+
+// Alert is a rule firing for a host, as posted to the webhook
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Condition string    `json:"condition"`
+	Host      string    `json:"host"`
+	Value     int       `json:"value"`
+	Threshold int       `json:"threshold"`
+	Time      time.Time `json:"time"`
+	// Text sums the alert up in a line, which Slack's incoming webhooks
+	// show as the message
+	Text string `json:"text"`
+}
+
+type rule struct {
+	name      string
+	condition Condition
+}
+
+type firing struct {
+	rule int
+	host string
+}
+
+// Engine evaluates rules on store updates and posts alerts to a webhook
+type Engine struct {
+	rules    []rule
+	webhook  string
+	debounce time.Duration
+	client   *http.Client
+	logger   telemetry.Logger
+	now      func() time.Time
+
+	mu    sync.Mutex
+	fired map[firing]time.Time
+}
+
+// New returns an engine for the rules, posting to webhook. A rule that keeps
+// holding for a host fires again once debounce has passed. Rules without a
+// name are named by their condition.
+func New(rules []Rule, webhook string, debounce time.Duration, logger telemetry.Logger) (*Engine, error) {
+	e := &Engine{
+		webhook:  webhook,
+		debounce: debounce,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+		now:      time.Now,
+		fired:    make(map[firing]time.Time),
+	}
+	for _, r := range rules {
+		c, err := ParseCondition(r.Condition)
+		if err != nil {
+			return nil, err
+		}
+		name := r.Name
+		if name == "" {
+			name = c.String()
+		}
+		e.rules = append(e.rules, rule{name: name, condition: c})
+	}
+	return e, nil
+}
+
+// Evaluate returns the alerts that fire for a host's latest snapshot, given
+// its changes since the previous one and its history (oldest first, may be
+// empty). Alerts still within their debounce are left out.
+func (e *Engine) Evaluate(snapshot *model.Snapshot, changes *model.ChangeSet, history []*model.Snapshot) []Alert {
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var alerts []Alert
+	for i, r := range e.rules {
+		value := metric(r.condition.Metric, snapshot, changes, history)
+		if !r.condition.holds(value) {
+			continue
+		}
+
+		key := firing{rule: i, host: snapshot.Host}
+		if last, ok := e.fired[key]; ok && now.Sub(last) < e.debounce {
+			continue
+		}
+		e.fired[key] = now
+
+		alerts = append(alerts, Alert{
+			Rule:      r.name,
+			Condition: r.condition.String(),
+			Host:      snapshot.Host,
+			Value:     value,
+			Threshold: r.condition.Threshold,
+			Time:      now,
+			Text:      fmt.Sprintf("goru: %s on %s (%s is %d)", r.name, snapshot.Host, r.condition.Metric, value),
+		})
+	}
+	return alerts
+}
+
+// metric returns the value of a condition's metric for a snapshot
+func metric(name string, snapshot *model.Snapshot, changes *model.ChangeSet, history []*model.Snapshot) int {
+	switch name {
+	case "total_goroutines":
+		return snapshot.TotalGoroutines()
+	case "groups":
+		return len(snapshot.Groups)
+	case "max_group_count":
+		largest := 0
+		for _, g := range snapshot.Groups {
+			largest = max(largest, g.Count)
+		}
+		return largest
+	case "group_count_growth":
+		growth := 0
+		if changes != nil {
+			for _, delta := range changes.Updated {
+				growth = max(growth, delta)
+			}
+			for _, g := range changes.Added {
+				growth = max(growth, g.Count)
+			}
+		}
+		return growth
+	case "leak_candidates":
+		return len(analysis.DetectLeaks(history))
+	case "stuck_groups":
+		stuck := 0
+		for _, trend := range analysis.WaitTrends(history) {
+			if trend.Stuck {
+				stuck++
+			}
+		}
+		return stuck
+	default:
+		state, _ := strings.CutSuffix(name, "_goroutines")
+		return snapshot.StateCount(model.GoroutineState(state))
+	}
+}
+
+// Start evaluates the rules on every snapshot the store takes in and posts
+// the alerts that fire, in the background until ctx is done. Failed posts
+// are logged.
+func (e *Engine) Start(ctx context.Context, s *store.Store) {
+	// Subscribe before returning so no snapshot collected after Start is
+	// missed
+	updates := make(chan store.Update, 100)
+	s.Subscribe(updates)
+
+	go func() {
+		defer s.Unsubscribe(updates)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case update := <-updates:
+				if update.Snapshot != nil {
+					e.handle(ctx, s, update)
+				}
+			}
+		}
+	}()
+}
+
+func (e *Engine) handle(ctx context.Context, s *store.Store, update store.Update) {
+	history := s.GetHistory(update.Host)
+	if len(history) == 0 {
+		history = []*model.Snapshot{update.Snapshot}
+	}
+	for _, a := range e.Evaluate(update.Snapshot, update.ChangeSet, history) {
+		e.logger.Warn("Alert fired",
+			telemetry.String("rule", a.Rule),
+			telemetry.String("host", a.Host),
+			telemetry.Int("value", a.Value),
+		)
+		if err := e.Post(ctx, a); err != nil {
+			e.logger.Error("Posting alert failed", telemetry.String("rule", a.Rule), telemetry.Error(err))
+		}
+	}
+}
+
+// Post sends an alert to the webhook as JSON
+func (e *Engine) Post(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("encoding alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
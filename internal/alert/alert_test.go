@@ -0,0 +1,142 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Condition
+		wantErr bool
+	}{
+		{input: "total_goroutines > 5000", want: Condition{"total_goroutines", ">", 5000}},
+		{input: "total_goroutines>=5000", want: Condition{"total_goroutines", ">=", 5000}},
+		{input: " blocked_goroutines != 0 ", want: Condition{"blocked_goroutines", "!=", 0}},
+		{input: "groups < 3", want: Condition{"groups", "<", 3}},
+		{input: "leak_candidates", want: Condition{"leak_candidates", ">", 0}},
+		{input: "group_count_growth > 100", want: Condition{"group_count_growth", ">", 100}},
+		{input: "total_goroutines > lots", wantErr: true},
+		{input: "goroutines > 5", wantErr: true},
+		{input: "sleeping_goroutines > 5", wantErr: true},
+		{input: "total_goroutines => 5", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseCondition(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCondition(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCondition(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func snapshotWith(host string, counts map[model.GroupID]int) *model.Snapshot {
+	s := model.NewSnapshot(host)
+	for id, count := range counts {
+		s.Groups[id] = &model.Group{ID: id, State: model.StateBlocked, Count: count, Trace: model.StackTrace{{Func: "main." + string(id)}}}
+	}
+	return s
+}
+
+func TestEvaluate(t *testing.T) {
+	logger := telemetry.NewLogger("error", false)
+	engine, err := New([]Rule{
+		{Name: "too many", Condition: "total_goroutines > 100"},
+		{Condition: "group_count_growth >= 50"},
+		{Name: "blocked", Condition: "blocked_goroutines > 500"},
+	}, "http://localhost", time.Minute, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+
+	snapshot := snapshotWith("host1", map[model.GroupID]int{"a": 80, "b": 40})
+	changes := &model.ChangeSet{Updated: map[model.GroupID]int{"a": 60, "b": -5}}
+
+	alerts := engine.Evaluate(snapshot, changes, nil)
+	if len(alerts) != 2 {
+		t.Fatalf("Expected 2 alerts, got %+v", alerts)
+	}
+	if a := alerts[0]; a.Rule != "too many" || a.Host != "host1" || a.Value != 120 || a.Threshold != 100 {
+		t.Errorf("Unexpected alert: %+v", a)
+	}
+	if a := alerts[1]; a.Rule != "group_count_growth >= 50" || a.Value != 60 {
+		t.Errorf("Unexpected alert: %+v", a)
+	}
+
+	// Repeats within the debounce are held back, per host
+	now = now.Add(30 * time.Second)
+	if alerts := engine.Evaluate(snapshot, changes, nil); len(alerts) != 0 {
+		t.Errorf("Expected debounced alerts to be held back, got %+v", alerts)
+	}
+	other := snapshotWith("host2", map[model.GroupID]int{"a": 200})
+	if alerts := engine.Evaluate(other, nil, nil); len(alerts) != 1 || alerts[0].Host != "host2" {
+		t.Errorf("Expected another host to alert on its own, got %+v", alerts)
+	}
+
+	now = now.Add(time.Minute)
+	if alerts := engine.Evaluate(snapshot, changes, nil); len(alerts) != 2 {
+		t.Errorf("Expected alerts again after the debounce, got %+v", alerts)
+	}
+}
+
+func TestEngineStart(t *testing.T) {
+	received := make(chan Alert, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- a
+	}))
+	defer server.Close()
+
+	engine, err := New([]Rule{{Name: "busy", Condition: "total_goroutines > 10"}}, server.URL, time.Hour, telemetry.NewLogger("error", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := store.New()
+	engine.Start(ctx, s)
+
+	s.Ingest(snapshotWith("host1", map[model.GroupID]int{"a": 5}))
+	s.Ingest(snapshotWith("host1", map[model.GroupID]int{"a": 50}))
+
+	select {
+	case a := <-received:
+		if a.Rule != "busy" || a.Host != "host1" || a.Value != 50 || a.Text == "" {
+			t.Errorf("Unexpected alert: %+v", a)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the alert")
+	}
+
+	select {
+	case a := <-received:
+		t.Errorf("Expected one alert, got another: %+v", a)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"slices"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// FrozenMinSnapshots is the fewest snapshots in a row a group must keep its
+// count in before it can be flagged as frozen
+const FrozenMinSnapshots = 3
+
+// Frozen is a group whose goroutines haven't moved: the same stack and count
+// for Snapshots snapshots in a row, up to the latest, while their longest
+// wait kept growing. It's the mark of goroutines blocked for good, such as
+// one stuck forever on a mutex, which leak detection misses as the count
+// never grows.
+type Frozen struct {
+	GroupID   model.GroupID
+	Count     int
+	Snapshots int           // snapshots in a row the group has been frozen in
+	Wait      time.Duration // longest wait in the latest snapshot
+}
+
+// FrozenGroups returns the groups of the latest snapshot of history (oldest
+// first) that are frozen. A group's run starts after the last snapshot it
+// was missing from, had another count in, had no wait times in, or had a
+// longer wait in than the one after.
+func FrozenGroups(history []*model.Snapshot) map[model.GroupID]Frozen {
+	if len(history) == 0 {
+		return nil
+	}
+	latest := history[len(history)-1]
+
+	frozen := make(map[model.GroupID]Frozen)
+	for id, g := range latest.Groups {
+		waits := g.WaitTimes()
+		if len(waits) == 0 {
+			continue
+		}
+
+		last := slices.Max(waits)
+		first, run := last, 1
+		for i := len(history) - 2; i >= 0; i-- {
+			prev, ok := history[i].Groups[id]
+			if !ok || prev.Count != g.Count || len(prev.WaitTimes()) == 0 {
+				break
+			}
+			longest := slices.Max(prev.WaitTimes())
+			if longest > first {
+				break
+			}
+			first = longest
+			run++
+		}
+
+		if run >= FrozenMinSnapshots && last > first {
+			frozen[id] = Frozen{GroupID: id, Count: g.Count, Snapshots: run, Wait: last}
+		}
+	}
+	return frozen
+}
@@ -0,0 +1,39 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+func TestFrozenGroups(t *testing.T) {
+	history := buildWaitHistory(map[model.GroupID][][]int{
+		"mutex":     {{1}, {1}, {2}, {3}, {4}},
+		"settled":   {{1, 1}, {2}, {2}, {3}, {4}},
+		"steady":    {{5}, {5}, {5}, {5}, {5}},
+		"growing":   {{1}, {1}, {1}, {1, 2}, {1, 3}},
+		"restarted": {{1}, {2}, {3}, {1}, {2}},
+		"returned":  {{1}, {2}, nil, {3}, {4}},
+		"busy":      {{}, {}, {}, {}, {}},
+	})
+
+	frozen := FrozenGroups(history)
+
+	want := map[model.GroupID]Frozen{
+		"mutex":   {GroupID: "mutex", Count: 1, Snapshots: 5, Wait: 4 * time.Minute},
+		"settled": {GroupID: "settled", Count: 1, Snapshots: 4, Wait: 4 * time.Minute},
+	}
+	if len(frozen) != len(want) {
+		t.Errorf("Expected %d frozen groups, got %d: %+v", len(want), len(frozen), frozen)
+	}
+	for id, w := range want {
+		if got := frozen[id]; got != w {
+			t.Errorf("%s: got %+v, want %+v", id, got, w)
+		}
+	}
+
+	if frozen := FrozenGroups(nil); frozen != nil {
+		t.Errorf("Expected nothing frozen without history, got %+v", frozen)
+	}
+}
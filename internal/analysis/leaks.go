@@ -0,0 +1,84 @@
+// Package analysis derives findings from a host's snapshot history
+package analysis
+
+import (
+	"sort"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// LeakWindow is the number of most recent snapshots a group's count must
+// strictly increase across to be flagged as a leak candidate
+const LeakWindow = 5
+
+// LeakCandidate is a group whose goroutine count keeps growing
+type LeakCandidate struct {
+	GroupID      model.GroupID
+	TopFrame     model.StackFrame // innermost non-runtime frame of the trace
+	StartCount   int              // count at the start of the window
+	CurrentCount int              // count in the latest snapshot
+	Rate         float64          // goroutines gained per minute over the window
+}
+
+// Growth returns how many goroutines the group gained over the window
+func (c LeakCandidate) Growth() int {
+	return c.CurrentCount - c.StartCount
+}
+
+// DetectLeaks flags groups present in each of the last LeakWindow snapshots
+// of history (oldest first) whose count strictly increased from each snapshot
+// to the next. Candidates are ordered by growth, largest first. Histories
+// shorter than the window yield no candidates.
+func DetectLeaks(history []*model.Snapshot) []LeakCandidate {
+	if len(history) < LeakWindow {
+		return nil
+	}
+	window := history[len(history)-LeakWindow:]
+	first, latest := window[0], window[len(window)-1]
+
+	minutes := latest.TakenAt.Sub(first.TakenAt).Minutes()
+
+	var candidates []LeakCandidate
+	for id, g := range latest.Groups {
+		if !growing(window, id) {
+			continue
+		}
+
+		candidate := LeakCandidate{
+			GroupID:      id,
+			StartCount:   first.Groups[id].Count,
+			CurrentCount: g.Count,
+		}
+		if frame := g.Trace.TopFrame(); frame != nil {
+			candidate.TopFrame = *frame
+		} else if len(g.Trace) > 0 {
+			candidate.TopFrame = g.Trace[0]
+		}
+		if minutes > 0 {
+			candidate.Rate = float64(candidate.Growth()) / minutes
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Growth() != candidates[j].Growth() {
+			return candidates[i].Growth() > candidates[j].Growth()
+		}
+		return candidates[i].GroupID < candidates[j].GroupID
+	})
+	return candidates
+}
+
+// growing reports whether the group is in every snapshot of the window with
+// a count that rises each time
+func growing(window []*model.Snapshot, id model.GroupID) bool {
+	previous := -1
+	for _, snapshot := range window {
+		g, ok := snapshot.Groups[id]
+		if !ok || g.Count <= previous {
+			return false
+		}
+		previous = g.Count
+	}
+	return true
+}
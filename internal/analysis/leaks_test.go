@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// buildHistory returns one snapshot per minute with the given counts for
+// each group; a negative count leaves the group out of that snapshot
+func buildHistory(counts map[model.GroupID][]int) []*model.Snapshot {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var n int
+	for _, c := range counts {
+		n = len(c)
+	}
+
+	history := make([]*model.Snapshot, n)
+	for i := range history {
+		history[i] = &model.Snapshot{
+			Host:    "test-host",
+			TakenAt: start.Add(time.Duration(i) * time.Minute),
+			Groups:  make(map[model.GroupID]*model.Group),
+		}
+		for id, c := range counts {
+			if c[i] < 0 {
+				continue
+			}
+			history[i].Groups[id] = &model.Group{
+				ID:    id,
+				Count: c[i],
+				Trace: model.StackTrace{
+					{Func: "runtime.gopark"},
+					{Func: "main." + string(id)},
+				},
+			}
+		}
+	}
+	return history
+}
+
+func TestDetectLeaks(t *testing.T) {
+	history := buildHistory(map[model.GroupID][]int{
+		"leak":    {1, 2, 5, 6, 9, 13},
+		"slow":    {9, 10, 11, 12, 13, 14},
+		"steady":  {4, 4, 4, 4, 4, 4},
+		"plateau": {1, 2, 3, 3, 4, 5},
+		"new":     {-1, -1, 1, 2, 3, 4},
+		"dip":     {1, 2, 3, 2, 3, 4},
+	})
+
+	candidates := DetectLeaks(history)
+	if len(candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %d: %+v", len(candidates), candidates)
+	}
+
+	leak := candidates[0]
+	if leak.GroupID != "leak" {
+		t.Errorf("Expected the fastest growing group first, got %s", leak.GroupID)
+	}
+	if leak.StartCount != 2 || leak.CurrentCount != 13 || leak.Growth() != 11 {
+		t.Errorf("Counts = %d -> %d, want 2 -> 13", leak.StartCount, leak.CurrentCount)
+	}
+	if leak.TopFrame.Func != "main.leak" {
+		t.Errorf("TopFrame = %s, want main.leak", leak.TopFrame.Func)
+	}
+	// 11 goroutines over the 4 minutes the window spans
+	if leak.Rate != 2.75 {
+		t.Errorf("Rate = %v, want 2.75", leak.Rate)
+	}
+
+	if candidates[1].GroupID != "slow" {
+		t.Errorf("Expected slow second, got %s", candidates[1].GroupID)
+	}
+}
+
+func TestDetectLeaksShortHistory(t *testing.T) {
+	history := buildHistory(map[model.GroupID][]int{
+		"leak": {1, 2, 3, 4},
+	})
+
+	if candidates := DetectLeaks(history); len(candidates) != 0 {
+		t.Errorf("Expected no candidates below the window size, got %+v", candidates)
+	}
+}
@@ -0,0 +1,105 @@
+package analysis
+
+import (
+	"sort"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// Orders SortGroups knows, the TUI's sort modes
+const (
+	SortCount    = "count"    // most goroutines first
+	SortState    = "state"    // by state, then count
+	SortFunction = "function" // by primary function, then count
+	SortWait     = "wait"     // longest wait first, then count
+	SortFrames   = "frames"   // deepest stack first, then count
+)
+
+// SortOrders lists the orders SortGroups knows
+var SortOrders = []string{SortCount, SortState, SortFunction, SortWait, SortFrames}
+
+// SortGroups sorts groups in place by one of the SortOrders, count for
+// anything else. Functions are each group's primary frame, looking past the
+// skip packages. Groups that still tie go by that frame's function, file
+// and line, then ID, or by ID alone when tiesByID is set, so the order is
+// stable from one snapshot to the next.
+func SortGroups(groups []*model.Group, by string, skip []string, tiesByID bool) {
+	tieLess := func(a, b *model.Group) bool {
+		if !tiesByID {
+			fa, fb := a.Trace.PrimaryFrame(skip), b.Trace.PrimaryFrame(skip)
+			if fa != nil && fb != nil {
+				if fa.Func != fb.Func {
+					return fa.Func < fb.Func
+				}
+				if fa.File != fb.File {
+					return fa.File < fb.File
+				}
+				if fa.Line != fb.Line {
+					return fa.Line < fb.Line
+				}
+			}
+		}
+		return a.ID < b.ID
+	}
+	// Every order but count falls back to count, then the tie-break
+	countLess := func(a, b *model.Group) bool {
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return tieLess(a, b)
+	}
+
+	var less func(a, b *model.Group) bool
+	switch by {
+	case SortState:
+		less = func(a, b *model.Group) bool {
+			if a.State != b.State {
+				return a.State < b.State
+			}
+			return countLess(a, b)
+		}
+	case SortFunction:
+		less = func(a, b *model.Group) bool {
+			if fa, fb := primaryFunc(a, skip), primaryFunc(b, skip); fa != fb {
+				return fa < fb
+			}
+			return countLess(a, b)
+		}
+	case SortWait:
+		less = func(a, b *model.Group) bool {
+			if wa, wb := MaxWait(a.WaitTimes()), MaxWait(b.WaitTimes()); wa != wb {
+				return wa > wb // Longer waits first
+			}
+			return countLess(a, b)
+		}
+	case SortFrames:
+		less = func(a, b *model.Group) bool {
+			if len(a.Trace) != len(b.Trace) {
+				return len(a.Trace) > len(b.Trace) // Deepest stacks first
+			}
+			return countLess(a, b)
+		}
+	default:
+		less = countLess
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return less(groups[i], groups[j])
+	})
+}
+
+// MaxWait returns the longest of waits, 0 for none
+func MaxWait(waits []time.Duration) time.Duration {
+	var longest time.Duration
+	for _, d := range waits {
+		longest = max(longest, d)
+	}
+	return longest
+}
+
+func primaryFunc(g *model.Group, skip []string) string {
+	if frame := g.Trace.PrimaryFrame(skip); frame != nil {
+		return frame.Func
+	}
+	return ""
+}
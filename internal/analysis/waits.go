@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"slices"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// StuckMinSnapshots is the fewest snapshots in a row a group must have wait
+// times in before its trend can flag it as stuck
+const StuckMinSnapshots = 3
+
+// WaitTrend is how a group's wait times moved over the snapshots it's been
+// waiting in, up to the latest
+type WaitTrend struct {
+	GroupID model.GroupID
+	Max     []time.Duration // longest wait per snapshot, oldest first
+	Median  []time.Duration // median wait per snapshot, oldest first
+	// Stuck is set when the longest wait never went down and has grown
+	// since the first snapshot: the group's oldest goroutines stay put
+	// while its count may not change at all
+	Stuck bool
+}
+
+// WaitTrends returns the wait trends of the groups in the latest snapshot of
+// history (oldest first) that carry wait times. Each trend covers the run of
+// snapshots up to the latest the group has wait times in, so a group that
+// came and went only counts since it last came back.
+func WaitTrends(history []*model.Snapshot) map[model.GroupID]WaitTrend {
+	if len(history) == 0 {
+		return nil
+	}
+	latest := history[len(history)-1]
+
+	trends := make(map[model.GroupID]WaitTrend)
+	for id, g := range latest.Groups {
+		if len(g.WaitTimes()) == 0 {
+			continue
+		}
+
+		trend := WaitTrend{GroupID: id}
+		for i := len(history) - 1; i >= 0; i-- {
+			g, ok := history[i].Groups[id]
+			if !ok {
+				break
+			}
+			waits := g.WaitTimes()
+			if len(waits) == 0 {
+				break
+			}
+			trend.Max = append(trend.Max, slices.Max(waits))
+			trend.Median = append(trend.Median, median(waits))
+		}
+		slices.Reverse(trend.Max)
+		slices.Reverse(trend.Median)
+		trend.Stuck = len(trend.Max) >= StuckMinSnapshots && rising(trend.Max)
+		trends[id] = trend
+	}
+	return trends
+}
+
+// rising reports whether the durations never decrease and end above where
+// they started. Waits are mostly reported in whole minutes, so they hold
+// steady between most snapshots.
+func rising(ds []time.Duration) bool {
+	for i := 1; i < len(ds); i++ {
+		if ds[i] < ds[i-1] {
+			return false
+		}
+	}
+	return ds[len(ds)-1] > ds[0]
+}
+
+// median returns the middle wait, or the mean of the middle two
+func median(waits []time.Duration) time.Duration {
+	sorted := slices.Clone(waits)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
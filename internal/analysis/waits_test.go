@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// buildWaitHistory returns one snapshot per minute with the given wait
+// times, in minutes, for each group; a nil entry leaves the group out of
+// that snapshot
+func buildWaitHistory(waits map[model.GroupID][][]int) []*model.Snapshot {
+	counts := make(map[model.GroupID][]int)
+	for id, w := range waits {
+		for _, minutes := range w {
+			if minutes == nil {
+				counts[id] = append(counts[id], -1)
+			} else {
+				counts[id] = append(counts[id], len(minutes))
+			}
+		}
+	}
+
+	history := buildHistory(counts)
+	for i, snapshot := range history {
+		for id, g := range snapshot.Groups {
+			for _, minutes := range waits[id][i] {
+				g.WaitDurations = append(g.WaitDurations, fmt.Sprintf("%d minutes", minutes))
+				g.Waits = append(g.Waits, time.Duration(minutes)*time.Minute)
+			}
+		}
+	}
+	return history
+}
+
+func TestWaitTrends(t *testing.T) {
+	history := buildWaitHistory(map[model.GroupID][][]int{
+		"wedged":   {{1}, {2}, {2}, {3}, {4}},
+		"churning": {{1, 3}, {2, 1}, {4, 1}, {1, 1}, {2, 2}},
+		"steady":   {{5}, {5}, {5}, {5}, {5}},
+		"returned": {{1}, {2}, nil, {1}, {2}},
+		"fresh":    {{1}, {2}, {3}, {}, {1}},
+		"busy":     {{}, {}, {}, {}, {}},
+	})
+
+	trends := WaitTrends(history)
+
+	want := map[model.GroupID]struct {
+		stuck     bool
+		snapshots int
+	}{
+		"wedged":   {true, 5},
+		"churning": {false, 5},
+		"steady":   {false, 5},
+		"returned": {false, 2},
+		"fresh":    {false, 1},
+	}
+	if len(trends) != len(want) {
+		t.Errorf("Expected %d trends, got %d: %+v", len(want), len(trends), trends)
+	}
+	for id, w := range want {
+		trend, ok := trends[id]
+		if !ok {
+			t.Errorf("Missing trend for %s", id)
+			continue
+		}
+		if trend.Stuck != w.stuck {
+			t.Errorf("%s: Stuck = %v, want %v", id, trend.Stuck, w.stuck)
+		}
+		if len(trend.Max) != w.snapshots || len(trend.Median) != w.snapshots {
+			t.Errorf("%s: trend covers %d snapshots, want %d", id, len(trend.Max), w.snapshots)
+		}
+	}
+
+	churning := trends["churning"]
+	wantMax := []time.Duration{3 * time.Minute, 2 * time.Minute, 4 * time.Minute, time.Minute, 2 * time.Minute}
+	wantMedian := []time.Duration{2 * time.Minute, 90 * time.Second, 150 * time.Second, time.Minute, 2 * time.Minute}
+	for i := range wantMax {
+		if churning.Max[i] != wantMax[i] || churning.Median[i] != wantMedian[i] {
+			t.Errorf("churning[%d] = max %v median %v, want %v and %v", i, churning.Max[i], churning.Median[i], wantMax[i], wantMedian[i])
+		}
+	}
+}
+
+func TestWaitTrendsEmpty(t *testing.T) {
+	if trends := WaitTrends(nil); trends != nil {
+		t.Errorf("Expected no trends, got %+v", trends)
+	}
+}
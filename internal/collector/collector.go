@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"time"
 
 	"github.com/anyproto/goru/pkg/model"
 )
@@ -16,6 +17,57 @@ type Source interface {
 	Collect(ctx context.Context, snapshots chan<- *model.Snapshot) error
 }
 
+// ErrorReporter is implemented by sources that track per-host collection
+// errors and can push them as they happen
+type ErrorReporter interface {
+	// SetErrorHandler registers a callback invoked after every collection
+	// attempt for a host, with a nil error on success. It must be called
+	// before Collect.
+	SetErrorHandler(handler func(host string, err error))
+}
+
+// AttemptReporter is implemented by sources that can announce when a
+// collection attempt for a host begins
+type AttemptReporter interface {
+	// SetAttemptHandler registers a callback invoked right before each
+	// collection attempt for a host. It must be called before Collect.
+	SetAttemptHandler(handler func(host string))
+}
+
+// HostRefresher is implemented by sources that can collect a single host on
+// demand instead of refreshing everything they manage
+type HostRefresher interface {
+	// TriggerRefreshHost requests a collection of host only. It reports
+	// false if the host isn't managed by this source.
+	TriggerRefreshHost(host string) bool
+}
+
+// Scheduler is implemented by sources whose hosts can be collected on
+// intervals of their own instead of on every refresh
+type Scheduler interface {
+	// MinInterval returns the shortest per-host interval, or 0 if no host
+	// has one
+	MinInterval() time.Duration
+
+	// TriggerDue requests a collection of the hosts whose interval has
+	// elapsed by now. Hosts without an interval of their own use
+	// defaultInterval, and are never due when it is 0.
+	TriggerDue(now time.Time, defaultInterval time.Duration)
+
+	// CollectionPeriod returns the longest a healthy host goes between
+	// successful collections, with defaultInterval for hosts without an
+	// interval of their own, or 0 if the host isn't collected on a schedule
+	CollectionPeriod(host string, defaultInterval time.Duration) time.Duration
+}
+
+// Drainer is implemented by sources that can stop gracefully, finishing the
+// collections already in flight instead of aborting them
+type Drainer interface {
+	// Drain stops the source from starting new collections. Collect returns
+	// once the in-flight ones have been sent.
+	Drain()
+}
+
 // Config holds common configuration for collectors
 type Config struct {
 	Workers int
@@ -0,0 +1,102 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// compression is a format dump files may be compressed with
+type compression string
+
+const (
+	compressionNone  compression = ""
+	compressionGzip  compression = "gzip"
+	compressionZstd  compression = "zstd"
+	compressionBzip2 compression = "bzip2"
+	compressionXz    compression = "xz"
+)
+
+// Compression formats by file extension
+var compressionExts = map[string]compression{
+	".gz":  compressionGzip,
+	".zst": compressionZstd,
+	".bz2": compressionBzip2,
+	".xz":  compressionXz,
+}
+
+// Magic bytes that start a compressed stream, for files whose extension
+// doesn't say they're compressed
+var compressionMagic = []struct {
+	magic []byte
+	kind  compression
+}{
+	{[]byte{0x1f, 0x8b}, compressionGzip},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, compressionZstd},
+	{[]byte("BZh"), compressionBzip2},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, compressionXz},
+}
+
+// Decompress wraps r in a reader for whichever supported format its first
+// bytes show it's compressed with, for streams that aren't files with an
+// extension. The returned reader must be closed.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	return decompress("", r)
+}
+
+// decompress wraps r in a reader for the file's compression format, chosen
+// by extension or else by sniffing the first bytes. Uncompressed files are
+// read as they are. The returned reader must be closed.
+func decompress(path string, r io.Reader) (io.ReadCloser, error) {
+	kind, ok := compressionExts[filepath.Ext(path)]
+	if !ok {
+		br := bufio.NewReader(r)
+		kind = sniffCompression(br)
+		r = br
+	}
+
+	switch kind {
+	case compressionGzip:
+		gzReader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("creating gzip reader: %w", err)
+		}
+		return gzReader, nil
+	case compressionZstd:
+		zstdReader, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("creating zstd reader: %w", err)
+		}
+		return zstdReader.IOReadCloser(), nil
+	case compressionBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case compressionXz:
+		xzReader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("creating xz reader: %w", err)
+		}
+		return io.NopCloser(xzReader), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+// sniffCompression identifies a compressed stream by its magic bytes without
+// consuming them
+func sniffCompression(br *bufio.Reader) compression {
+	// Peek returns what's available on short files along with an error
+	head, _ := br.Peek(6)
+	for _, m := range compressionMagic {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.kind
+		}
+	}
+	return compressionNone
+}
@@ -1,13 +1,14 @@
 package file
 
 import (
-	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
+	"regexp"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,33 +17,144 @@ import (
 	"github.com/anyproto/goru/pkg/model"
 )
 
+// Timestamp selects where a file snapshot's TakenAt comes from
+type Timestamp string
+
+const (
+	TimestampParse   Timestamp = "parse" // when the file was read
+	TimestampModTime Timestamp = "mtime" // the file's modification time
+	TimestampName    Timestamp = "name"  // a timestamp in the file name, else mtime
+)
+
+// File name timestamps: 20060102-150405 (also with T, _ or no separator) or
+// Unix seconds
+var (
+	nameDateTimeRe = regexp.MustCompile(`(\d{8})[-T_]?(\d{6})`)
+	nameUnixRe     = regexp.MustCompile(`(?:^|\D)(\d{10})(?:\D|$)`)
+)
+
 // FileSource collects goroutine dumps from files
 type FileSource struct {
-	patterns []string
-	follow   bool
-	interval time.Duration
-	parser   *parser.Parser
-
-	// Track file state for follow mode
+	patterns  []string
+	follow    bool
+	interval  time.Duration
+	parser    *parser.Parser
+	timestamp Timestamp
+	multi     Multi
+
+	// Track file state for follow mode; mu also guards patterns
 	mu         sync.Mutex
 	fileStates map[string]*fileState
+
+	// Closed to stop following once the current scan is done
+	drainCh   chan struct{}
+	drainOnce sync.Once
 }
 
 type fileState struct {
 	size    int64
 	modTime time.Time
 	offset  int64
+	dumps   int // dumps in the file when last read
 }
 
 // New creates a new file source
-func New(patterns []string, follow bool, interval time.Duration) *FileSource {
+func New(patterns []string, follow bool, interval time.Duration, parserOpts ...parser.Option) *FileSource {
 	return &FileSource{
 		patterns:   patterns,
 		follow:     follow,
 		interval:   interval,
-		parser:     parser.New(),
+		parser:     parser.New(parserOpts...),
+		timestamp:  TimestampParse,
+		multi:      MultiMerge,
 		fileStates: make(map[string]*fileState),
+		drainCh:    make(chan struct{}),
+	}
+}
+
+// Load parses a single dump file, compressed or not, into one snapshot the
+// way Collect reads it by default
+func Load(path string, parserOpts ...parser.Option) (*model.Snapshot, error) {
+	return LoadAt(path, TimestampParse, parserOpts...)
+}
+
+// LoadAt is Load with the snapshot's capture time taken from ts
+func LoadAt(path string, ts Timestamp, parserOpts ...parser.Option) (*model.Snapshot, error) {
+	source := New([]string{path}, false, 0, parserOpts...)
+	source.SetTimestamp(ts)
+	snapshots, err := source.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return snapshots[0], nil
+}
+
+// Read parses a single dump from r, such as stdin, into a snapshot of host.
+// Compressed dumps are recognized by their first bytes.
+func Read(r io.Reader, host string, parserOpts ...parser.Option) (*model.Snapshot, error) {
+	reader, err := decompress("", r)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	snapshot, err := parser.New(parserOpts...).Parse(reader, host)
+	if err != nil {
+		return nil, fmt.Errorf("parsing dump: %w", err)
+	}
+	return snapshot, nil
+}
+
+// SetTimestamp selects where snapshot capture times come from, so replayed
+// dumps are ordered by when they were taken rather than when they were read.
+// It must be called before Collect.
+func (f *FileSource) SetTimestamp(ts Timestamp) {
+	f.timestamp = ts
+}
+
+// SetMulti selects how files holding several concatenated dumps are read.
+// It must be called before Collect.
+func (f *FileSource) SetMulti(multi Multi) {
+	f.multi = multi
+}
+
+// SetPatterns replaces the file patterns, e.g. after a config reload, and
+// returns the hosts of matching files that aren't followed yet and of
+// followed files that no longer match. The state of the latter is
+// forgotten, so they're read in full if they match again later. In follow
+// mode the next scan picks up the new patterns; otherwise no file is added.
+func (f *FileSource) SetPatterns(patterns []string) (added, removed []string, err error) {
+	f.mu.Lock()
+	f.patterns = slices.Clone(patterns)
+	f.mu.Unlock()
+
+	files, err := f.findFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for path := range f.fileStates {
+		if !slices.Contains(files, path) {
+			delete(f.fileStates, path)
+			removed = append(removed, fileHost(path))
+		}
+	}
+	if f.follow {
+		for _, path := range files {
+			if _, ok := f.fileStates[path]; !ok {
+				added = append(added, fileHost(path))
+			}
+		}
 	}
+	return added, removed, nil
+}
+
+// Drain stops following files. Collect returns once the scan in progress,
+// if any, is done.
+func (f *FileSource) Drain() {
+	f.drainOnce.Do(func() { close(f.drainCh) })
 }
 
 // Name returns the name of this source
@@ -73,7 +185,11 @@ func (f *FileSource) collectOnce(ctx context.Context, snapshots chan<- *model.Sn
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			if snapshot, err := f.readFile(file); err == nil {
+			read, err := f.readFile(file)
+			if err != nil {
+				continue
+			}
+			for _, snapshot := range read {
 				select {
 				case snapshots <- snapshot:
 				case <-ctx.Done():
@@ -99,6 +215,8 @@ func (f *FileSource) collectWithFollow(ctx context.Context, snapshots chan<- *mo
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-f.drainCh:
+			return nil
 		case <-ticker.C:
 			if err := f.scanAndCollect(ctx, snapshots); err != nil {
 				return err
@@ -118,7 +236,11 @@ func (f *FileSource) scanAndCollect(ctx context.Context, snapshots chan<- *model
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			if snapshot, err := f.checkAndReadFile(file); err == nil && snapshot != nil {
+			read, err := f.checkAndReadFile(file)
+			if err != nil {
+				continue
+			}
+			for _, snapshot := range read {
 				select {
 				case snapshots <- snapshot:
 				case <-ctx.Done():
@@ -131,11 +253,20 @@ func (f *FileSource) scanAndCollect(ctx context.Context, snapshots chan<- *model
 	return nil
 }
 
+// fileHost names the host a file's snapshots belong to
+func fileHost(path string) string {
+	return fmt.Sprintf("file:%s", filepath.Base(path))
+}
+
 func (f *FileSource) findFiles() ([]string, error) {
 	var files []string
 	seen := make(map[string]bool)
 
-	for _, pattern := range f.patterns {
+	f.mu.Lock()
+	patterns := f.patterns
+	f.mu.Unlock()
+
+	for _, pattern := range patterns {
 		matches, err := filepath.Glob(pattern)
 		if err != nil {
 			return nil, fmt.Errorf("glob %q: %w", pattern, err)
@@ -156,7 +287,7 @@ func (f *FileSource) findFiles() ([]string, error) {
 	return files, nil
 }
 
-func (f *FileSource) checkAndReadFile(path string) (*model.Snapshot, error) {
+func (f *FileSource) checkAndReadFile(path string) ([]*model.Snapshot, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		return nil, err
@@ -180,37 +311,124 @@ func (f *FileSource) checkAndReadFile(path string) (*model.Snapshot, error) {
 	state.modTime = info.ModTime()
 	f.mu.Unlock()
 
-	return f.readFile(path)
+	snapshots, err := f.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dumps from earlier reads were already sent, except the last one,
+	// which may have still been growing
+	f.mu.Lock()
+	sent := state.dumps
+	state.dumps = len(snapshots)
+	f.mu.Unlock()
+	if sent > 1 && sent <= len(snapshots) {
+		snapshots = snapshots[sent-1:]
+	}
+
+	return snapshots, nil
 }
 
-func (f *FileSource) readFile(path string) (*model.Snapshot, error) {
+// readFile parses the dumps in a file into snapshots, in file order
+func (f *FileSource) readFile(path string) ([]*model.Snapshot, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("opening file: %w", err)
 	}
 	defer file.Close()
 
-	var reader io.Reader = file
+	// Handle compressed files
+	reader, err := decompress(path, file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// Generate host name from file path
+	host := fileHost(path)
 
-	// Handle gzip files
-	if strings.HasSuffix(path, ".gz") {
-		gzReader, err := gzip.NewReader(file)
+	// Snapshots are stamped when parsed unless the capture time comes from
+	// the file itself
+	var takenAt time.Time
+	if f.timestamp != TimestampParse {
+		takenAt, err = f.captureTime(file, path)
 		if err != nil {
-			return nil, fmt.Errorf("creating gzip reader: %w", err)
+			return nil, fmt.Errorf("reading capture time of %s: %w", path, err)
 		}
-		defer gzReader.Close()
-		reader = gzReader
 	}
 
-	// Generate host name from file path
-	host := fmt.Sprintf("file:%s", filepath.Base(path))
+	if f.multi == MultiMerge {
+		snapshot, err := f.parser.Parse(reader, host)
+		if err != nil {
+			return nil, fmt.Errorf("parsing file %s: %w", path, err)
+		}
+		if !takenAt.IsZero() {
+			snapshot.TakenAt = takenAt
+		}
+		return []*model.Snapshot{snapshot}, nil
+	}
 
-	snapshot, err := f.parser.Parse(reader, host)
+	dumps, err := splitDumps(reader)
 	if err != nil {
-		return nil, fmt.Errorf("parsing file %s: %w", path, err)
+		return nil, fmt.Errorf("reading file %s: %w", path, err)
+	}
+	if f.multi == MultiLatest {
+		dumps = dumps[len(dumps)-1:]
 	}
 
-	return snapshot, nil
+	snapshots := make([]*model.Snapshot, 0, len(dumps))
+	for _, d := range dumps {
+		snapshot, err := f.parser.ParseBytes(d.data, host)
+		if err != nil {
+			return nil, fmt.Errorf("parsing file %s: %w", path, err)
+		}
+		// A dump's own timestamp marker beats the file's capture time
+		switch {
+		case !d.takenAt.IsZero():
+			snapshot.TakenAt = d.takenAt
+		case !takenAt.IsZero():
+			snapshot.TakenAt = takenAt
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
 }
 
-var _ collector.Source = (*FileSource)(nil)
+// captureTime returns when the dump was taken according to the configured
+// timestamp source
+func (f *FileSource) captureTime(file *os.File, path string) (time.Time, error) {
+	if f.timestamp == TimestampName {
+		if t, ok := parseNameTimestamp(filepath.Base(path)); ok {
+			return t, nil
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// parseNameTimestamp extracts a capture time from a file name such as
+// "goroutines-20240131-154500.txt" or "dump.1706715900.gz". Date-times
+// without a zone are taken as local time.
+func parseNameTimestamp(name string) (time.Time, bool) {
+	if m := nameDateTimeRe.FindStringSubmatch(name); m != nil {
+		if t, err := time.ParseInLocation("20060102150405", m[1]+m[2], time.Local); err == nil {
+			return t, true
+		}
+	}
+	if m := nameUnixRe.FindStringSubmatch(name); m != nil {
+		if sec, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			return time.Unix(sec, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+var (
+	_ collector.Source  = (*FileSource)(nil)
+	_ collector.Drainer = (*FileSource)(nil)
+)
@@ -1,14 +1,20 @@
 package file
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
 	"github.com/anyproto/goru/pkg/model"
 )
 
@@ -31,10 +37,14 @@ main.worker()
 	}
 
 	source := New([]string{testFile}, false, time.Second)
-	snapshot, err := source.readFile(testFile)
+	snapshots, err := source.readFile(testFile)
 	if err != nil {
 		t.Fatalf("readFile failed: %v", err)
 	}
+	if len(snapshots) != 1 {
+		t.Fatalf("readFile returned %d snapshots, want 1", len(snapshots))
+	}
+	snapshot := snapshots[0]
 
 	expectedHost := "file:test.txt"
 	if snapshot.Host != expectedHost {
@@ -76,10 +86,14 @@ main.main()
 	}
 
 	source := New([]string{testFile}, false, time.Second)
-	snapshot, err := source.readFile(testFile)
+	snapshots, err := source.readFile(testFile)
 	if err != nil {
 		t.Fatalf("readFile failed: %v", err)
 	}
+	if len(snapshots) != 1 {
+		t.Fatalf("readFile returned %d snapshots, want 1", len(snapshots))
+	}
+	snapshot := snapshots[0]
 
 	expectedHost := "file:test.gz"
 	if snapshot.Host != expectedHost {
@@ -91,6 +105,104 @@ main.main()
 	}
 }
 
+func TestRead(t *testing.T) {
+	content := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 2 [chan receive]:
+main.worker()
+	/app/main.go:20 +0x30
+`
+	var gzipped bytes.Buffer
+	gzWriter := gzip.NewWriter(&gzipped)
+	if _, err := gzWriter.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, r := range map[string]io.Reader{
+		"plain": strings.NewReader(content),
+		"gzip":  &gzipped,
+	} {
+		snapshot, err := Read(r, "stdin")
+		if err != nil {
+			t.Fatalf("Read(%s) failed: %v", name, err)
+		}
+		if snapshot.Host != "stdin" {
+			t.Errorf("Read(%s) host = %q, want stdin", name, snapshot.Host)
+		}
+		if total := snapshot.TotalGoroutines(); total != 2 {
+			t.Errorf("Read(%s) TotalGoroutines = %d, want 2", name, total)
+		}
+	}
+}
+
+func TestFileSourceReadCompressedFiles(t *testing.T) {
+	content := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+`
+
+	writers := map[string]func(io.Writer) (io.WriteCloser, error){
+		"zstd": func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+		"xz":   func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) },
+		"gzip": func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+	}
+
+	tests := []struct {
+		name   string
+		file   string
+		format string
+	}{
+		{"zstd", "test.zst", "zstd"},
+		{"xz", "test.xz", "xz"},
+		// Compressed files without a telling extension are sniffed
+		{"sniffed zstd", "test.dump", "zstd"},
+		{"sniffed gzip", "test.txt", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), tt.file)
+
+			file, err := os.Create(testFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := writers[tt.format](file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := file.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			source := New([]string{testFile}, false, time.Second)
+			snapshots, err := source.readFile(testFile)
+			if err != nil {
+				t.Fatalf("readFile failed: %v", err)
+			}
+			if len(snapshots) != 1 {
+				t.Fatalf("readFile returned %d snapshots, want 1", len(snapshots))
+			}
+			snapshot := snapshots[0]
+
+			if total := snapshot.TotalGoroutines(); total != 1 {
+				t.Errorf("TotalGoroutines = %d, want 1", total)
+			}
+		})
+	}
+}
+
 func TestFileSourceGlobPattern(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -231,3 +343,171 @@ func TestFileSourceErrorHandling(t *testing.T) {
 		t.Errorf("Expected 0 snapshots, got %d", len(snapshots))
 	}
 }
+
+func TestFileSourceTimestamp(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n"
+
+	modTime := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	write := func(name string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name      string
+		file      string
+		timestamp Timestamp
+		expected  time.Time
+	}{
+		{"mtime", "dump.txt", TimestampModTime, modTime},
+		{"name with date-time", "dump-20240130-154500.txt", TimestampName, time.Date(2024, 1, 30, 15, 45, 0, 0, time.Local)},
+		{"name with unix seconds", "dump.1706715900.txt", TimestampName, time.Unix(1706715900, 0)},
+		{"name without timestamp", "dump-latest.txt", TimestampName, modTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := write(tt.file)
+			source := New([]string{path}, false, time.Second)
+			source.SetTimestamp(tt.timestamp)
+
+			snapshots, err := source.readFile(path)
+			if err != nil {
+				t.Fatalf("readFile failed: %v", err)
+			}
+			if len(snapshots) != 1 {
+				t.Fatalf("readFile returned %d snapshots, want 1", len(snapshots))
+			}
+			snapshot := snapshots[0]
+			if !snapshot.TakenAt.Equal(tt.expected) {
+				t.Errorf("TakenAt = %v, want %v", snapshot.TakenAt, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileSourceMultipleDumps(t *testing.T) {
+	content := `=== 2024-01-31 15:45:00 ===
+
+goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 2 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+
+=== 2024-01-31 15:50:00 ===
+
+goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 2 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+
+goroutine 3 [select]:
+main.poller()
+	/app/poller.go:5 +0x10
+
+goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+`
+
+	path := filepath.Join(t.TempDir(), "rotated.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first := time.Date(2024, 1, 31, 15, 45, 0, 0, time.Local)
+	second := time.Date(2024, 1, 31, 15, 50, 0, 0, time.Local)
+
+	tests := []struct {
+		multi  Multi
+		totals []int
+		times  []time.Time // zero when stamped at parse time
+	}{
+		{MultiMerge, []int{6}, []time.Time{{}}},
+		{MultiLatest, []int{1}, []time.Time{{}}},
+		{MultiAll, []int{2, 3, 1}, []time.Time{first, second, {}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.multi), func(t *testing.T) {
+			source := New([]string{path}, false, time.Second)
+			source.SetMulti(tt.multi)
+
+			snapshots, err := source.readFile(path)
+			if err != nil {
+				t.Fatalf("readFile failed: %v", err)
+			}
+			if len(snapshots) != len(tt.totals) {
+				t.Fatalf("readFile returned %d snapshots, want %d", len(snapshots), len(tt.totals))
+			}
+
+			for i, snapshot := range snapshots {
+				if total := snapshot.TotalGoroutines(); total != tt.totals[i] {
+					t.Errorf("snapshot %d: TotalGoroutines = %d, want %d", i, total, tt.totals[i])
+				}
+				if !tt.times[i].IsZero() && !snapshot.TakenAt.Equal(tt.times[i]) {
+					t.Errorf("snapshot %d: TakenAt = %v, want %v", i, snapshot.TakenAt, tt.times[i])
+				}
+				if tt.times[i].IsZero() && snapshot.TakenAt.Year() == 2024 {
+					t.Errorf("snapshot %d: TakenAt = %v, want the parse time", i, snapshot.TakenAt)
+				}
+			}
+		})
+	}
+}
+
+func TestFileSourceSetPatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n"
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := New([]string{filepath.Join(tmpDir, "*.txt")}, true, time.Second)
+	if err := source.scanAndCollect(context.Background(), make(chan *model.Snapshot, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	added, removed, err := source.SetPatterns([]string{filepath.Join(tmpDir, "b.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 0 {
+		t.Errorf("Added hosts = %v, want none", added)
+	}
+	if len(removed) != 1 || removed[0] != "file:a.txt" {
+		t.Errorf("Removed hosts = %v, want [file:a.txt]", removed)
+	}
+
+	files, err := source.findFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "b.txt" {
+		t.Errorf("Files = %v, want only b.txt", files)
+	}
+
+	// Matching a again reports it as added until it's read
+	added, removed, err = source.SetPatterns([]string{filepath.Join(tmpDir, "*.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || added[0] != "file:a.txt" || len(removed) != 0 {
+		t.Errorf("SetPatterns = %v, %v, want [file:a.txt] added", added, removed)
+	}
+}
@@ -0,0 +1,127 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Multi selects how files holding several concatenated dumps are read
+type Multi string
+
+const (
+	MultiMerge  Multi = "merge"  // the whole file as one dump
+	MultiLatest Multi = "latest" // only the last dump in the file
+	MultiAll    Multi = "all"    // every dump, as a separate snapshot
+)
+
+var (
+	goroutineIDRe     = regexp.MustCompile(`^goroutine (\d+) \[`)
+	profileHeaderRe   = regexp.MustCompile(`^goroutine profile: total \d+$`)
+	timestampMarkerRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}`)
+)
+
+// Layouts accepted for timestamp marker lines. Fractional seconds are
+// accepted by all of them.
+var markerLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+// dump is one goroutine dump cut out of a file
+type dump struct {
+	data    []byte
+	takenAt time.Time // from a timestamp marker, zero without one
+}
+
+// splitDumps cuts a file into its concatenated dumps. A new dump starts at a
+// block after a blank line that begins with a timestamp marker line, a
+// debug=1 profile header, or a goroutine whose ID the current dump already
+// holds (such as "goroutine 1 [" again). A file without any goroutines is
+// returned as a single dump so it parses the same as when merged.
+func splitDumps(r io.Reader) ([]dump, error) {
+	var dumps []dump
+	var current dump
+	var buf bytes.Buffer
+	seen := make(map[string]bool)
+	hasGoroutines := false
+	prevBlank := true
+
+	flush := func() {
+		if hasGoroutines {
+			current.data = bytes.Clone(buf.Bytes())
+			dumps = append(dumps, current)
+		}
+		current = dump{}
+		buf.Reset()
+		clear(seen)
+		hasGoroutines = false
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			text := strings.TrimSpace(string(line))
+			id := goroutineIDRe.FindStringSubmatch(text)
+
+			if prevBlank && text != "" {
+				if takenAt, ok := parseMarker(text); ok {
+					if hasGoroutines {
+						flush()
+					}
+					current.takenAt = takenAt
+				} else if profileHeaderRe.MatchString(text) || (id != nil && seen[id[1]]) {
+					if hasGoroutines {
+						flush()
+					}
+				}
+			}
+
+			if id != nil {
+				seen[id[1]] = true
+				hasGoroutines = true
+			} else if profileHeaderRe.MatchString(text) {
+				hasGoroutines = true
+			}
+
+			buf.Write(line)
+			prevBlank = text == ""
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(dumps) == 0 && !hasGoroutines {
+		// Nothing was cut off, so the buffer holds the whole file
+		return []dump{{data: buf.Bytes()}}, nil
+	}
+	flush()
+	return dumps, nil
+}
+
+// parseMarker reads a timestamp marker line such as "2024-01-31 15:45:00"
+// or "=== 2024-01-31T15:45:00Z ===". Times without a zone are taken as
+// local time.
+func parseMarker(line string) (time.Time, bool) {
+	line = strings.Trim(line, " \t=#")
+	if !timestampMarkerRe.MatchString(line) {
+		return time.Time{}, false
+	}
+	for _, layout := range markerLayouts {
+		if t, err := time.ParseInLocation(layout, line, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -1,11 +1,23 @@
 package http
 
 import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/anyproto/goru/internal/collector"
@@ -13,32 +25,114 @@ import (
 	"github.com/anyproto/goru/pkg/model"
 )
 
+// DefaultUserAgent is sent with requests unless SetUserAgent names the
+// version or something else
+const DefaultUserAgent = "goru"
+
+// Request describes how a goroutine dump is requested from a target
+type Request struct {
+	Method string
+	Body   []byte
+}
+
 // HTTPSource collects goroutine dumps from HTTP endpoints
 type HTTPSource struct {
-	targets  []string
-	client   *http.Client
-	parser   *parser.Parser
-	workers  int
-	
-	// Manual refresh support
-	refreshCh chan struct{}
-	
+	client    *http.Client
+	transport *http.Transport
+	parser    *parser.Parser
+	workers   int
+
+	// Targets and their overrides, which SetTargets can replace while
+	// collecting
+	targetsMu sync.RWMutex
+	targets   []string
+
+	// Plain GET unless overridden, for debug proxies that need a POST or body
+	request        Request
+	targetRequests map[string]Request
+
+	// How long a request may take, unless the target has its own limit
+	// (guarded by targetsMu). 0 means no limit.
+	timeout        time.Duration
+	targetTimeouts map[string]time.Duration
+
+	// Extra headers sent with every request, e.g. for auth proxies
+	headers http.Header
+
+	// Identifies goru in the targets' access logs
+	userAgent string
+
+	// Also scrape /debug/vars memstats with every dump
+	memStats bool
+
+	// Goroutine profile format: 2 for full dumps, 1 for aggregated counts
+	debugLevel int
+
+	// Bytes read from a response at most, 0 for no limit
+	maxBodySize int64
+
+	// Extra attempts for transient failures, with exponential backoff
+	retries      int
+	retryBackoff time.Duration
+
+	// Manual refresh support. Single-host refreshes collect in a set, so
+	// none is lost while the source is busy and repeats collapse, and
+	// hostRefreshCh signals that the set is non-empty.
+	refreshCh     chan struct{}
+	hostRefreshCh chan struct{}
+	pendingMu     sync.Mutex
+	pendingHosts  map[string]bool
+
+	// Per-target intervals (guarded by targetsMu), and when each target was
+	// last queued for a collection
+	intervals   map[string]time.Duration
+	scheduleMu  sync.Mutex
+	lastQueued  map[string]time.Time
+	scheduledCh chan round
+
+	// Scheduled requests are spread over up to this fraction of the
+	// target's interval, 0 to send them all at once
+	jitter float64
+
+	// Closed to stop collecting once the in-flight collection is done
+	drainCh   chan struct{}
+	drainOnce sync.Once
+
 	// Track errors per host
 	errorsMu sync.RWMutex
 	errors   map[string]error
+
+	// Notified before and after every collection attempt (nil error on success)
+	onAttempt func(host string)
+	onError   func(host string, err error)
 }
 
-// NewHTTPSource creates a new HTTP source
-func New(targets []string, timeout time.Duration, workers int) *HTTPSource {
+// New creates a new HTTP source collecting from up to workers targets at
+// once. Each request to a target may take up to timeout, unless
+// SetTargetTimeout gives the target a limit of its own.
+func New(targets []string, timeout time.Duration, workers int, parserOpts ...parser.Option) *HTTPSource {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
 	return &HTTPSource{
-		targets:   targets,
-		refreshCh: make(chan struct{}, 1), // Buffered to avoid blocking
-		client: &http.Client{
-			Timeout: timeout,
-		},
-		parser:  parser.New(),
-		workers: workers,
-		errors:  make(map[string]error),
+		targets:       targets,
+		refreshCh:     make(chan struct{}, 1), // Buffered to avoid blocking
+		hostRefreshCh: make(chan struct{}, 1),
+		pendingHosts:  make(map[string]bool),
+		scheduledCh:   make(chan round, 1),
+		drainCh:       make(chan struct{}),
+		// Timeouts are per target, set on each request's context
+		client:         &http.Client{Transport: transport},
+		transport:      transport,
+		parser:         parser.New(parserOpts...),
+		workers:        workers,
+		request:        Request{Method: http.MethodGet},
+		debugLevel:     2,
+		userAgent:      DefaultUserAgent,
+		targetRequests: make(map[string]Request),
+		timeout:        timeout,
+		targetTimeouts: make(map[string]time.Duration),
+		intervals:      make(map[string]time.Duration),
+		lastQueued:     make(map[string]time.Time),
+		errors:         make(map[string]error),
 	}
 }
 
@@ -53,37 +147,92 @@ func (h *HTTPSource) Collect(ctx context.Context, snapshots chan<- *model.Snapsh
 
 	// Wait for refresh triggers from orchestrator
 	for {
+		// Draining wins over refreshes that are already pending
+		select {
+		case <-h.drainCh:
+			return nil
+		default:
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-h.drainCh:
+			return nil
 		case <-h.refreshCh:
 			h.collectAll(ctx, snapshots)
+		case <-h.hostRefreshCh:
+			h.collectTargets(ctx, h.takePendingHosts(), nil, snapshots)
+		case r := <-h.scheduledCh:
+			h.collectTargets(ctx, r.targets, r.delays, snapshots)
 		}
 	}
 }
 
+// round is a scheduled collection of the targets that are due, each started
+// after its jitter delay
+type round struct {
+	targets []string
+	delays  map[string]time.Duration
+}
+
 func (h *HTTPSource) collectAll(ctx context.Context, snapshots chan<- *model.Snapshot) {
+	h.collectTargets(ctx, h.GetTargets(), nil, snapshots)
+}
+
+// collectTargets collects the targets, each no sooner than its delay after
+// the call. Targets without a delay are collected right away.
+func (h *HTTPSource) collectTargets(ctx context.Context, targets []string, delays map[string]time.Duration, snapshots chan<- *model.Snapshot) {
 	var wg sync.WaitGroup
-	workCh := make(chan string, len(h.targets))
+	workCh := make(chan string, len(targets))
 
-	// Start workers
-	for i := 0; i < h.workers; i++ {
+	// Start workers, no more than there are targets. A refresh while targets
+	// are held back can add any of them to the round.
+	workers := len(targets)
+	if len(delays) > 0 {
+		workers = max(workers, len(h.GetTargets()))
+	}
+	for i := 0; i < min(h.workers, workers); i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for target := range workCh {
+				// Targets removed since the round started are skipped, and
+				// ones removed while being fetched aren't reported. The
+				// handlers are called without holding the targets, so a
+				// removal racing them can still be reported, which the store
+				// ignores for hosts it dropped.
+				if !h.collects(target) {
+					continue
+				}
+				if h.onAttempt != nil {
+					h.onAttempt(target)
+				}
+
 				snapshot, err := h.collectOne(ctx, target)
-				
-				// Update error status
+
+				// Update error status. RemoveTarget forgets the error after
+				// dropping the target, so checking under errorsMu keeps a
+				// removed target's error from coming back.
 				h.errorsMu.Lock()
-				if err != nil {
+				collected := h.collects(target)
+				if collected && err != nil {
 					h.errors[target] = err
 				} else {
 					delete(h.errors, target)
 				}
 				h.errorsMu.Unlock()
-				
-				if err == nil {
+				if !collected {
+					continue
+				}
+
+				if h.onError != nil {
+					h.onError(target, err)
+				}
+
+				// A collection cut short by cancellation may hold a partial
+				// dump, so only complete ones are sent
+				if err == nil && ctx.Err() == nil {
 					select {
 					case snapshots <- snapshot:
 					case <-ctx.Done():
@@ -95,10 +244,45 @@ func (h *HTTPSource) collectAll(ctx context.Context, snapshots chan<- *model.Sna
 		}()
 	}
 
-	// Queue work
-	for _, target := range h.targets {
+	// Queue work, holding each target back until its delay is up. Draining
+	// queues the rest right away, so the round still finishes promptly.
+	// Manual refreshes arriving meanwhile aren't held up by the delays: the
+	// targets they ask for are queued at once.
+	pending := targets
+	if len(delays) > 0 {
+		pending = slices.Clone(targets)
+		slices.SortStableFunc(pending, func(a, b string) int {
+			return cmp.Compare(delays[a], delays[b])
+		})
+	}
+	start := time.Now()
+	for len(pending) > 0 {
+		target := pending[0]
+		if wait := delays[target] - time.Since(start); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-h.drainCh:
+			case <-h.refreshCh:
+				pending, delays = slices.Clone(h.GetTargets()), nil
+				continue
+			case <-h.hostRefreshCh:
+				hosts := h.takePendingHosts()
+				pending = append(hosts, slices.DeleteFunc(pending, func(t string) bool {
+					return slices.Contains(hosts, t)
+				})...)
+				for _, host := range hosts {
+					delete(delays, host)
+				}
+				continue
+			case <-ctx.Done():
+				close(workCh)
+				wg.Wait()
+				return
+			}
+		}
 		select {
 		case workCh <- target:
+			pending = pending[1:]
 		case <-ctx.Done():
 			close(workCh)
 			wg.Wait()
@@ -110,28 +294,164 @@ func (h *HTTPSource) collectAll(ctx context.Context, snapshots chan<- *model.Sna
 	wg.Wait()
 }
 
+// collects reports whether target is still collected
+func (h *HTTPSource) collects(target string) bool {
+	h.targetsMu.RLock()
+	defer h.targetsMu.RUnlock()
+	return slices.Contains(h.targets, target)
+}
+
+// collectOne fetches and parses a target's dump, retrying transient failures
+// such as timeouts and 5xx responses with exponential backoff. Each attempt
+// gets the target's timeout, and ctx being done ends them all.
 func (h *HTTPSource) collectOne(ctx context.Context, target string) (*model.Snapshot, error) {
-	url := fmt.Sprintf("http://%s/debug/pprof/goroutine?debug=2", target)
+	timeout := h.timeoutFor(target)
+	backoff := h.retryBackoff
+	for attempt := 1; ; attempt++ {
+		snapshot, err := h.fetchWithin(ctx, target, timeout)
+		if err == nil {
+			return snapshot, nil
+		}
+		if attempt > h.retries || !isTransient(err) || ctx.Err() != nil {
+			if attempt > 1 {
+				return nil, fmt.Errorf("after %d attempts: %w", attempt, err)
+			}
+			return nil, err
+		}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// fetchWithin makes a single attempt at fetching a target's dump, giving up
+// after timeout unless it is 0
+func (h *HTTPSource) fetchWithin(ctx context.Context, target string, timeout time.Duration) (*model.Snapshot, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return h.fetch(ctx, target)
+}
+
+// statusError is returned for non-200 responses
+type statusError struct {
+	code int
+	url  string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %s", e.code, e.url)
+}
+
+// fetchError wraps failures to get any response from a target
+type fetchError struct {
+	url string
+	err error
+}
+
+func (e *fetchError) Error() string {
+	return fmt.Sprintf("fetching %s: %v", e.url, e.err)
+}
+
+func (e *fetchError) Unwrap() error {
+	return e.err
+}
+
+// isTransient reports whether a failed fetch is worth retrying: a timeout, a
+// connection reset, or a server that is overloaded or restarting. Failures
+// that would only repeat, such as unknown hosts, refused connections or bad
+// certificates, aren't.
+func isTransient(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// fetch makes a single attempt at fetching and parsing a target's dump
+func (h *HTTPSource) fetch(ctx context.Context, target string) (*model.Snapshot, error) {
+	url := fmt.Sprintf("%s/debug/pprof/goroutine?debug=%d", baseURL(target), h.debugLevel)
+
+	r := h.requestFor(target)
+	var body io.Reader
+	if len(r.Body) > 0 {
+		// A fresh reader per attempt keeps the body re-readable across
+		// retries and redirects (the client sets GetBody for bytes.Reader)
+		body = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
+	h.setHeaders(req)
+	// Dumps are text and compress well. Asking for gzip ourselves, rather
+	// than leaving it to the transport, keeps it on when the configured
+	// headers set an Accept-Encoding of their own, and lets a cut-off
+	// stream still give its complete goroutines.
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 
 	resp, err := h.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetching %s: %w", url, err)
+		return nil, &fetchError{url: url, err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+		return nil, &statusError{code: resp.StatusCode, url: url}
+	}
+
+	var content io.Reader = resp.Body
+	contentLength := resp.ContentLength
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading gzip response: %w", err)
+		}
+		defer gz.Close()
+		content = gz
+		// The length is of the compressed body; a short stream shows up as
+		// a read error instead
+		contentLength = -1
 	}
 
-	// Read the response body
-	data, err := io.ReadAll(resp.Body)
+	// Read the response body. Large dumps sometimes get cut off by the
+	// server or the connection; keep what arrived and flag it as partial.
+	reader := content
+	if h.maxBodySize > 0 {
+		// One byte past the limit tells a dump of exactly the limit apart
+		// from a larger one
+		reader = io.LimitReader(content, h.maxBodySize+1)
+	}
+	data, err := io.ReadAll(reader)
+	partial, truncated := false, false
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+		if len(data) == 0 {
+			return nil, fmt.Errorf("reading response: %w", err)
+		}
+		partial = true
+	} else if h.maxBodySize > 0 && int64(len(data)) > h.maxBodySize {
+		data = data[:h.maxBodySize]
+		truncated = true
+	} else if contentLength > 0 && int64(len(data)) < contentLength {
+		partial = true
+	}
+	if partial || truncated {
+		data = trimIncompleteGoroutine(data)
 	}
 
 	// Parse the goroutine dump
@@ -139,15 +459,200 @@ func (h *HTTPSource) collectOne(ctx context.Context, target string) (*model.Snap
 	if err != nil {
 		return nil, fmt.Errorf("parsing dump from %s: %w", target, err)
 	}
+	snapshot.Partial = partial
+	snapshot.Truncated = snapshot.Truncated || truncated
+
+	if h.memStats {
+		// Best effort: many targets don't expose expvar
+		snapshot.MemStats, _ = h.collectMemStats(ctx, target)
+	}
 
 	return snapshot, nil
 }
 
+// collectMemStats fetches the memstats section of a target's expvar page
+func (h *HTTPSource) collectMemStats(ctx context.Context, target string) (*model.MemStats, error) {
+	url := baseURL(target) + "/debug/vars"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	h.setHeaders(req)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	var vars struct {
+		MemStats *struct {
+			HeapAlloc uint64
+			Sys       uint64
+			NumGC     uint32
+		} `json:"memstats"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", url, err)
+	}
+	if vars.MemStats == nil {
+		return nil, fmt.Errorf("no memstats in %s", url)
+	}
+
+	return &model.MemStats{
+		HeapAlloc: vars.MemStats.HeapAlloc,
+		Sys:       vars.MemStats.Sys,
+		NumGC:     vars.MemStats.NumGC,
+	}, nil
+}
+
+// baseURL returns the URL a target's endpoints live under. Targets are plain
+// host:port for HTTP, or carry their own scheme such as https://host:port.
+// The target string itself stays the host key in snapshots.
+func baseURL(target string) string {
+	if strings.Contains(target, "://") {
+		return strings.TrimSuffix(target, "/")
+	}
+	return "http://" + target
+}
+
+// trimIncompleteGoroutine drops the trailing goroutine of a truncated dump,
+// which is likely missing frames and would otherwise form a bogus group
+func trimIncompleteGoroutine(data []byte) []byte {
+	idx := bytes.LastIndex(data, []byte("\n\n"))
+	if idx < 0 {
+		return nil
+	}
+	return data[:idx+1]
+}
+
+// setHeaders adds the User-Agent and the configured extra headers, which
+// may replace it, to a request
+func (h *HTTPSource) setHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", h.userAgent)
+	for key, values := range h.headers {
+		req.Header[key] = values
+	}
+}
+
+func (h *HTTPSource) requestFor(target string) Request {
+	h.targetsMu.RLock()
+	defer h.targetsMu.RUnlock()
+	if r, ok := h.targetRequests[target]; ok {
+		return r
+	}
+	return h.request
+}
+
+// SetRequest sets the method and body used for targets without their own
+// request. It must be called before Collect.
+func (h *HTTPSource) SetRequest(req Request) {
+	h.request = req
+}
+
+// SetTargetRequest overrides the method and body used for a single target.
+// It must be called before Collect.
+func (h *HTTPSource) SetTargetRequest(target string, req Request) {
+	h.targetsMu.Lock()
+	defer h.targetsMu.Unlock()
+	h.targetRequests[target] = req
+}
+
+// SetTargetTimeout gives a target a request timeout of its own in place of
+// the one New was given, e.g. more time for a known slow service or less for
+// a flaky one
+func (h *HTTPSource) SetTargetTimeout(target string, timeout time.Duration) {
+	h.targetsMu.Lock()
+	defer h.targetsMu.Unlock()
+	h.targetTimeouts[target] = timeout
+}
+
+// timeoutFor returns how long a request to target may take
+func (h *HTTPSource) timeoutFor(target string) time.Duration {
+	h.targetsMu.RLock()
+	defer h.targetsMu.RUnlock()
+	if timeout, ok := h.targetTimeouts[target]; ok {
+		return timeout
+	}
+	return h.timeout
+}
+
+// SetHeaders sets extra headers sent with every request, such as an
+// Authorization header for targets behind an auth proxy. It must be called
+// before Collect.
+func (h *HTTPSource) SetHeaders(headers http.Header) {
+	h.headers = headers.Clone()
+}
+
+// SetUserAgent sets the User-Agent sent with every request, DefaultUserAgent
+// unless set. It must be called before Collect.
+func (h *HTTPSource) SetUserAgent(userAgent string) {
+	h.userAgent = userAgent
+}
+
+// SetInsecureSkipVerify disables TLS certificate verification for https
+// targets, for endpoints with self-signed certificates. It must be called
+// before Collect.
+func (h *HTTPSource) SetInsecureSkipVerify(skip bool) {
+	h.transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: skip}
+}
+
+// SetConnectionPool bounds the idle connections kept open between
+// collections to maxIdle across all targets, each closed after sitting idle
+// for idleTimeout. 0 means no limit for either. It must be called before
+// Collect.
+func (h *HTTPSource) SetConnectionPool(maxIdle int, idleTimeout time.Duration) {
+	h.transport.MaxIdleConns = maxIdle
+	h.transport.IdleConnTimeout = idleTimeout
+}
+
+// SetJitter spreads each scheduled round's requests over a random delay of
+// up to fraction of the target's interval, so a fleet isn't scraped in
+// lockstep. Manual refreshes still collect every target at once. It must be
+// called before Collect.
+func (h *HTTPSource) SetJitter(fraction float64) {
+	h.jitter = fraction
+}
+
+// SetRetries makes collections retry transient failures up to retries more
+// times, waiting backoff before the first retry and doubling it after each.
+// Errors are only reported once the retries are exhausted. It must be called
+// before Collect.
+func (h *HTTPSource) SetRetries(retries int, backoff time.Duration) {
+	h.retries = retries
+	h.retryBackoff = backoff
+}
+
+// SetMemStats enables scraping /debug/vars memstats alongside every dump.
+// Targets without expvar still produce snapshots, just without memstats.
+// It must be called before Collect.
+func (h *HTTPSource) SetMemStats(enabled bool) {
+	h.memStats = enabled
+}
+
+// SetMaxBodySize limits how many bytes of a dump are read; larger dumps are
+// cut at the last complete goroutine and marked truncated. 0 means no limit.
+func (h *HTTPSource) SetMaxBodySize(size int64) {
+	h.maxBodySize = size
+}
+
+// SetDebugLevel selects the goroutine profile format requested from targets:
+// 2 for full dumps or 1 for the aggregated format, for servers that disable
+// debug=2 because of its size. It must be called before Collect.
+func (h *HTTPSource) SetDebugLevel(level int) {
+	h.debugLevel = level
+}
+
 // GetErrors returns the current errors for each host
 func (h *HTTPSource) GetErrors() map[string]error {
 	h.errorsMu.RLock()
 	defer h.errorsMu.RUnlock()
-	
+
 	// Return a copy
 	result := make(map[string]error)
 	for k, v := range h.errors {
@@ -156,21 +661,250 @@ func (h *HTTPSource) GetErrors() map[string]error {
 	return result
 }
 
+// SetAttemptHandler registers a callback that is notified right before each
+// target is fetched
+func (h *HTTPSource) SetAttemptHandler(handler func(host string)) {
+	h.onAttempt = handler
+}
+
+// SetErrorHandler registers a callback that receives the outcome of every
+// collection attempt as soon as it completes
+func (h *HTTPSource) SetErrorHandler(handler func(host string, err error)) {
+	h.onError = handler
+}
+
 // GetTargets returns all configured targets for this source
 func (h *HTTPSource) GetTargets() []string {
+	h.targetsMu.RLock()
+	defer h.targetsMu.RUnlock()
 	return h.targets
 }
 
+// SetTargets replaces the targets along with their per-target requests,
+// intervals and timeouts, e.g. after a config reload. Removed targets stop being
+// collected, even by a round under way, and their errors are forgotten; added
+// ones are collected from the next refresh. Schedules only tick as often as the shortest interval
+// known when collection started.
+func (h *HTTPSource) SetTargets(targets []string, requests map[string]Request, intervals, timeouts map[string]time.Duration) {
+	h.targetsMu.Lock()
+	h.targets = slices.Clone(targets)
+	h.targetRequests = maps.Clone(requests)
+	h.intervals = maps.Clone(intervals)
+	h.targetTimeouts = maps.Clone(timeouts)
+	if h.targetRequests == nil {
+		h.targetRequests = make(map[string]Request)
+	}
+	if h.intervals == nil {
+		h.intervals = make(map[string]time.Duration)
+	}
+	if h.targetTimeouts == nil {
+		h.targetTimeouts = make(map[string]time.Duration)
+	}
+	h.targetsMu.Unlock()
+
+	h.errorsMu.Lock()
+	for target := range h.errors {
+		if !slices.Contains(targets, target) {
+			delete(h.errors, target)
+		}
+	}
+	h.errorsMu.Unlock()
+
+	h.scheduleMu.Lock()
+	for target := range h.lastQueued {
+		if !slices.Contains(targets, target) {
+			delete(h.lastQueued, target)
+		}
+	}
+	h.scheduleMu.Unlock()
+}
+
+// AddTarget starts collecting from target along with the others, on the
+// default interval. It reports false if the target is already collected.
+func (h *HTTPSource) AddTarget(target string) bool {
+	h.targetsMu.Lock()
+	defer h.targetsMu.Unlock()
+	if slices.Contains(h.targets, target) {
+		return false
+	}
+	// GetTargets hands out the slice, so it's replaced rather than grown
+	h.targets = append(slices.Clone(h.targets), target)
+	return true
+}
+
+// RemoveTarget stops collecting from target, forgetting its overrides,
+// schedule and last error. A collection of it already under way finishes without being
+// reported. It reports false if the target isn't collected.
+func (h *HTTPSource) RemoveTarget(target string) bool {
+	h.targetsMu.Lock()
+	i := slices.Index(h.targets, target)
+	if i < 0 {
+		h.targetsMu.Unlock()
+		return false
+	}
+	h.targets = slices.Delete(slices.Clone(h.targets), i, i+1)
+	delete(h.targetRequests, target)
+	delete(h.intervals, target)
+	delete(h.targetTimeouts, target)
+	h.targetsMu.Unlock()
+
+	h.errorsMu.Lock()
+	delete(h.errors, target)
+	h.errorsMu.Unlock()
+
+	h.scheduleMu.Lock()
+	delete(h.lastQueued, target)
+	h.scheduleMu.Unlock()
+	return true
+}
+
 // TriggerRefresh manually triggers a refresh of all targets
 func (h *HTTPSource) TriggerRefresh() {
 	select {
 	case h.refreshCh <- struct{}{}:
-		// Refresh triggered
+		// Refresh triggered, which restarts every target's schedule
+		now := time.Now()
+		h.scheduleMu.Lock()
+		for _, target := range h.GetTargets() {
+			h.lastQueued[target] = now
+		}
+		h.scheduleMu.Unlock()
 	default:
 		// Channel is full, refresh already pending
 	}
 }
 
+// Drain stops the source from starting new collections. Collect returns once
+// the collection in flight, if any, is done.
+func (h *HTTPSource) Drain() {
+	h.drainOnce.Do(func() { close(h.drainCh) })
+}
+
+// SetTargetInterval makes a target collected on an interval of its own,
+// e.g. to poll a slow service less often than the rest
+func (h *HTTPSource) SetTargetInterval(target string, interval time.Duration) {
+	h.targetsMu.Lock()
+	defer h.targetsMu.Unlock()
+	h.intervals[target] = interval
+}
+
+// MinInterval returns the shortest per-target interval, or 0 if no target
+// has one
+func (h *HTTPSource) MinInterval() time.Duration {
+	h.targetsMu.RLock()
+	defer h.targetsMu.RUnlock()
+	var shortest time.Duration
+	for _, target := range h.targets {
+		if interval, ok := h.intervals[target]; ok && (shortest == 0 || interval < shortest) {
+			shortest = interval
+		}
+	}
+	return shortest
+}
+
+// CollectionPeriod returns the longest a target goes between successful
+// collections while it's healthy: its interval, or defaultInterval without one
+// of its own, stretched by the jitter, plus the time a collection may take
+// with its retries. It's 0 for targets only collected manually or not
+// collected by this source.
+func (h *HTTPSource) CollectionPeriod(host string, defaultInterval time.Duration) time.Duration {
+	h.targetsMu.RLock()
+	interval, ok := h.intervals[host]
+	if !ok {
+		interval = defaultInterval
+	}
+	managed := slices.Contains(h.targets, host)
+	h.targetsMu.RUnlock()
+	if !managed || interval == 0 {
+		return 0
+	}
 
+	timeout := h.timeoutFor(host)
+	period := interval + time.Duration(h.jitter*float64(interval)) + timeout
+	backoff := h.retryBackoff
+	for range h.retries {
+		period += backoff + timeout
+		backoff *= 2
+	}
+	return period
+}
 
-var _ collector.Source = (*HTTPSource)(nil)
+// TriggerDue queues a collection of the targets whose interval has elapsed
+// since they were last queued. Targets without an interval of their own use
+// defaultInterval, and are only refreshed manually when it is 0.
+func (h *HTTPSource) TriggerDue(now time.Time, defaultInterval time.Duration) {
+	h.scheduleMu.Lock()
+	h.targetsMu.RLock()
+	var due round
+	for _, target := range h.targets {
+		interval, ok := h.intervals[target]
+		if !ok {
+			interval = defaultInterval
+		}
+		if interval == 0 || now.Sub(h.lastQueued[target]) < interval {
+			continue
+		}
+		due.targets = append(due.targets, target)
+		if h.jitter > 0 {
+			if due.delays == nil {
+				due.delays = make(map[string]time.Duration)
+			}
+			due.delays[target] = time.Duration(rand.Float64() * h.jitter * float64(interval))
+		}
+	}
+	h.targetsMu.RUnlock()
+	h.scheduleMu.Unlock()
+	if len(due.targets) == 0 {
+		return
+	}
+
+	select {
+	case h.scheduledCh <- due:
+		h.scheduleMu.Lock()
+		for _, target := range due.targets {
+			h.lastQueued[target] = now
+		}
+		h.scheduleMu.Unlock()
+	default:
+		// Channel is full, the previous round is still pending
+	}
+}
+
+// TriggerRefreshHost triggers a refresh of a single target. It reports false
+// if the target isn't managed by this source.
+func (h *HTTPSource) TriggerRefreshHost(host string) bool {
+	if !slices.Contains(h.GetTargets(), host) {
+		return false
+	}
+
+	h.pendingMu.Lock()
+	h.pendingHosts[host] = true
+	h.pendingMu.Unlock()
+
+	select {
+	case h.hostRefreshCh <- struct{}{}:
+		// Refresh triggered
+	default:
+		// Channel is full, the pending hosts are collected together
+	}
+	return true
+}
+
+// takePendingHosts returns the targets TriggerRefreshHost asked for since the
+// last call, and forgets them
+func (h *HTTPSource) takePendingHosts() []string {
+	h.pendingMu.Lock()
+	defer h.pendingMu.Unlock()
+	hosts := slices.Sorted(maps.Keys(h.pendingHosts))
+	clear(h.pendingHosts)
+	return hosts
+}
+
+var (
+	_ collector.Source          = (*HTTPSource)(nil)
+	_ collector.ErrorReporter   = (*HTTPSource)(nil)
+	_ collector.AttemptReporter = (*HTTPSource)(nil)
+	_ collector.Scheduler       = (*HTTPSource)(nil)
+	_ collector.Drainer         = (*HTTPSource)(nil)
+	_ collector.HostRefresher   = (*HTTPSource)(nil)
+)
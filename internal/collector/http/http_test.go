@@ -1,10 +1,21 @@
 package http
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -74,6 +85,21 @@ main.main()
 	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
 	defer cancel()
 
+	// Collection is driven by the orchestrator, so simulate its ticks
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		source.TriggerRefresh()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				source.TriggerRefresh()
+			}
+		}
+	}()
+
 	snapshots := make(chan *model.Snapshot, 10)
 	err := source.Collect(ctx, snapshots)
 
@@ -116,6 +142,7 @@ main.server%d()
 	defer cancel()
 
 	snapshots := make(chan *model.Snapshot, 10)
+	source.TriggerRefresh()
 	go source.Collect(ctx, snapshots)
 
 	// Collect snapshots
@@ -191,3 +218,998 @@ func TestHTTPSourceErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPSourceErrorHandler(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+	}))
+	defer okServer.Close()
+
+	badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer badServer.Close()
+
+	okTarget := okServer.URL[7:]
+	badTarget := badServer.URL[7:]
+
+	source := New([]string{okTarget, badTarget}, time.Second, 2)
+
+	var mu sync.Mutex
+	reported := make(map[string]error)
+	source.SetErrorHandler(func(host string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported[host] = err
+	})
+
+	snapshots := make(chan *model.Snapshot, 10)
+	source.collectAll(context.Background(), snapshots)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err, ok := reported[okTarget]; !ok || err != nil {
+		t.Errorf("Expected nil error reported for %s, got %v (reported=%v)", okTarget, err, ok)
+	}
+	if err := reported[badTarget]; err == nil {
+		t.Errorf("Expected error reported for %s", badTarget)
+	}
+}
+
+func TestHTTPSourceRemoveTargetDuringFetch(t *testing.T) {
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(fetching)
+		<-release
+		fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+	}))
+	defer blocked.Close()
+	target := blocked.URL[7:]
+
+	// Another target, held back by jitter until after it's removed
+	waiting := "waiting:1"
+	source := New([]string{target, waiting}, 5*time.Second, 2)
+
+	var mu sync.Mutex
+	var attempts, reported []string
+	source.SetAttemptHandler(func(host string) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, host)
+	})
+	source.SetErrorHandler(func(host string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, host)
+	})
+
+	snapshots := make(chan *model.Snapshot, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		source.collectTargets(context.Background(), []string{target, waiting},
+			map[string]time.Duration{waiting: 200 * time.Millisecond}, snapshots)
+	}()
+
+	<-fetching
+	source.RemoveTarget(target)
+	source.RemoveTarget(waiting)
+	close(release)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(attempts, []string{target}) {
+		t.Errorf("Expected only the fetch started before removal, got %v", attempts)
+	}
+	if len(reported) != 0 {
+		t.Errorf("Expected nothing reported for removed targets, got %v", reported)
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("Expected no snapshots of removed targets, got %d", len(snapshots))
+	}
+	if len(source.GetErrors()) != 0 {
+		t.Errorf("Expected no errors of removed targets, got %v", source.GetErrors())
+	}
+}
+
+func TestHTTPSourceWorkers(t *testing.T) {
+	var inFlight, most atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			prev := most.Load()
+			if n <= prev || most.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+	})
+
+	var targets []string
+	for range 6 {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		targets = append(targets, server.URL[7:])
+	}
+
+	source := New(targets, time.Second, 2)
+	source.SetConnectionPool(1, time.Minute)
+	snapshots := make(chan *model.Snapshot, len(targets))
+	source.collectAll(context.Background(), snapshots)
+
+	if got := len(snapshots); got != len(targets) {
+		t.Errorf("Expected %d snapshots, got %d", len(targets), got)
+	}
+	if got := most.Load(); got > 2 {
+		t.Errorf("Expected at most 2 requests at once, got %d", got)
+	}
+}
+
+func TestHTTPSourceJitter(t *testing.T) {
+	source := New([]string{"fast:1", "slow:1"}, time.Second, 2)
+	source.SetTargetInterval("slow:1", 10*time.Second)
+	source.SetJitter(0.5)
+
+	now := time.Now()
+	source.TriggerDue(now, time.Second)
+	r := <-source.scheduledCh
+	limits := map[string]time.Duration{"fast:1": 500 * time.Millisecond, "slow:1": 5 * time.Second}
+	for target, limit := range limits {
+		if delay, ok := r.delays[target]; !ok || delay < 0 || delay >= limit {
+			t.Errorf("Delay of %s = %v, want within [0, %v)", target, delay, limit)
+		}
+	}
+
+	// Without jitter everything is collected at once
+	source.SetJitter(0)
+	source.TriggerDue(now.Add(time.Minute), time.Second)
+	if r := <-source.scheduledCh; r.delays != nil {
+		t.Errorf("Expected no delays without jitter, got %v", r.delays)
+	}
+}
+
+func TestHTTPSourceAddRemoveTarget(t *testing.T) {
+	source := New([]string{"a:1"}, time.Second, 2)
+	source.SetTargetInterval("a:1", time.Minute)
+	targets := source.GetTargets()
+
+	if !source.AddTarget("b:2") {
+		t.Fatal("Expected b:2 added")
+	}
+	if source.AddTarget("b:2") {
+		t.Error("Expected a duplicate target refused")
+	}
+	if got := source.GetTargets(); !slices.Equal(got, []string{"a:1", "b:2"}) {
+		t.Errorf("GetTargets() = %v", got)
+	}
+	if !slices.Equal(targets, []string{"a:1"}) {
+		t.Errorf("Expected earlier GetTargets() results untouched, got %v", targets)
+	}
+
+	if !source.RemoveTarget("a:1") {
+		t.Fatal("Expected a:1 removed")
+	}
+	if source.RemoveTarget("a:1") {
+		t.Error("Expected removing an unknown target to fail")
+	}
+	if got := source.GetTargets(); !slices.Equal(got, []string{"b:2"}) {
+		t.Errorf("GetTargets() = %v", got)
+	}
+	if _, ok := source.intervals["a:1"]; ok {
+		t.Error("Expected the removed target's interval forgotten")
+	}
+}
+
+func TestHTTPSourceCollectDelays(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]time.Time)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Host] = time.Now()
+		mu.Unlock()
+		fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+	})
+	var targets []string
+	for range 2 {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		targets = append(targets, server.URL[7:])
+	}
+
+	source := New(targets, time.Second, 2)
+	snapshots := make(chan *model.Snapshot, len(targets))
+	start := time.Now()
+	source.collectTargets(context.Background(), targets, map[string]time.Duration{targets[0]: 100 * time.Millisecond}, snapshots)
+
+	if got := len(snapshots); got != len(targets) {
+		t.Fatalf("Expected %d snapshots, got %d", len(targets), got)
+	}
+	if waited := seen[targets[0]].Sub(start); waited < 100*time.Millisecond {
+		t.Errorf("Expected the delayed target collected after 100ms, got %v", waited)
+	}
+	if waited := seen[targets[1]].Sub(start); waited >= 100*time.Millisecond {
+		t.Errorf("Expected the target without a delay collected right away, got %v", waited)
+	}
+}
+
+func TestHTTPSourceRefreshDuringDelays(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+	})
+	var targets []string
+	for range 3 {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+		targets = append(targets, server.URL[7:])
+	}
+
+	// A scheduled round holding two targets back for a long while
+	source := New(targets, time.Second, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	snapshots := make(chan *model.Snapshot, 10)
+	go source.Collect(ctx, snapshots)
+	source.scheduledCh <- round{
+		targets: targets[:2],
+		delays:  map[string]time.Duration{targets[0]: time.Minute, targets[1]: time.Minute},
+	}
+
+	received := func(n int) []string {
+		var hosts []string
+		for range n {
+			select {
+			case snapshot := <-snapshots:
+				hosts = append(hosts, snapshot.Host)
+			case <-time.After(time.Second):
+				t.Fatalf("Expected %d snapshots right away, got %v", n, hosts)
+			}
+		}
+		slices.Sort(hosts)
+		return hosts
+	}
+
+	// A single host comes first, the rest of the round keeps waiting
+	source.TriggerRefreshHost(targets[1])
+	if got := received(1); !slices.Equal(got, targets[1:2]) {
+		t.Errorf("Expected a snapshot of %s, got %v", targets[1], got)
+	}
+
+	// A manual refresh collects everything at once, the held back target
+	// and the one outside the round included
+	source.TriggerRefresh()
+	want := slices.Sorted(slices.Values(targets))
+	if got := received(3); !slices.Equal(got, want) {
+		t.Errorf("Expected snapshots of %v, got %v", want, got)
+	}
+}
+
+func TestHTTPSourceTriggerRefreshHost(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+
+	servers := make([]*httptest.Server, 2)
+	targets := make([]string, 2)
+	for i := range servers {
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls[r.Host]++
+			mu.Unlock()
+			fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+		}))
+		defer servers[i].Close()
+		targets[i] = servers[i].URL[7:] // Remove "http://"
+	}
+
+	source := New(targets, time.Second, 2)
+
+	if source.TriggerRefreshHost("unknown:1234") {
+		t.Error("Expected unknown host to be rejected")
+	}
+	if !source.TriggerRefreshHost(targets[1]) {
+		t.Fatal("Expected managed host to be accepted")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	snapshots := make(chan *model.Snapshot, 10)
+	go source.Collect(ctx, snapshots)
+
+	select {
+	case snapshot := <-snapshots:
+		if snapshot.Host != targets[1] {
+			t.Errorf("Expected snapshot for %s, got %s", targets[1], snapshot.Host)
+		}
+	case <-ctx.Done():
+		t.Fatal("No snapshot received")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls[targets[0]] != 0 {
+		t.Errorf("Expected no requests to %s, got %d", targets[0], calls[targets[0]])
+	}
+}
+
+func TestHTTPSourceTriggerRefreshHostPending(t *testing.T) {
+	// No initial targets, and refreshes asked for while nothing collects
+	source := New(nil, time.Second, 2)
+	source.AddTarget("a:1")
+	source.AddTarget("b:1")
+
+	for _, host := range []string{"a:1", "b:1", "a:1"} {
+		if !source.TriggerRefreshHost(host) {
+			t.Fatalf("Expected %s accepted", host)
+		}
+	}
+
+	select {
+	case <-source.hostRefreshCh:
+	default:
+		t.Fatal("Expected a pending host refresh")
+	}
+	if got := source.takePendingHosts(); !slices.Equal(got, []string{"a:1", "b:1"}) {
+		t.Errorf("Pending hosts = %v, want [a:1 b:1]", got)
+	}
+	if got := source.takePendingHosts(); len(got) != 0 {
+		t.Errorf("Expected pending hosts forgotten once taken, got %v", got)
+	}
+}
+
+func TestHTTPSourceTriggerDue(t *testing.T) {
+	source := New([]string{"fast:1", "slow:1", "default:1"}, time.Second, 2)
+	source.SetTargetInterval("fast:1", 2*time.Second)
+	source.SetTargetInterval("slow:1", 30*time.Second)
+
+	if got := source.MinInterval(); got != 2*time.Second {
+		t.Errorf("MinInterval() = %v, want 2s", got)
+	}
+
+	due := func(now time.Time, defaultInterval time.Duration) []string {
+		source.TriggerDue(now, defaultInterval)
+		select {
+		case r := <-source.scheduledCh:
+			return r.targets
+		default:
+			return nil
+		}
+	}
+
+	// A manual refresh queues everything and restarts the schedules
+	source.TriggerRefresh()
+	<-source.refreshCh
+	start := time.Now()
+
+	if got := due(start.Add(time.Second), 10*time.Second); len(got) != 0 {
+		t.Errorf("Nothing should be due after 1s, got %v", got)
+	}
+	if got := due(start.Add(2*time.Second), 10*time.Second); !slices.Equal(got, []string{"fast:1"}) {
+		t.Errorf("Due after 2s = %v, want [fast:1]", got)
+	}
+	if got := due(start.Add(10*time.Second), 10*time.Second); !slices.Equal(got, []string{"fast:1", "default:1"}) {
+		t.Errorf("Due after 10s = %v, want [fast:1 default:1]", got)
+	}
+	if got := due(start.Add(30*time.Second), 0); !slices.Equal(got, []string{"fast:1", "slow:1"}) {
+		t.Errorf("Due after 30s without a default = %v, want [fast:1 slow:1]", got)
+	}
+}
+
+func TestHTTPSourceCollectionPeriod(t *testing.T) {
+	source := New([]string{"slow:1", "default:1"}, time.Second, 2)
+	source.SetTargetInterval("slow:1", 5*time.Minute)
+	source.SetTargetTimeout("slow:1", 10*time.Second)
+
+	tests := []struct {
+		host            string
+		defaultInterval time.Duration
+		want            time.Duration
+	}{
+		{"slow:1", time.Minute, 5*time.Minute + 10*time.Second},
+		{"default:1", time.Minute, time.Minute + time.Second},
+		{"default:1", 0, 0},
+		{"unknown:1", time.Minute, 0},
+	}
+	for _, tt := range tests {
+		if got := source.CollectionPeriod(tt.host, tt.defaultInterval); got != tt.want {
+			t.Errorf("CollectionPeriod(%s, %v) = %v, want %v", tt.host, tt.defaultInterval, got, tt.want)
+		}
+	}
+
+	// Jitter can hold a collection back by part of the interval, and every
+	// retry can take the timeout again after its backoff
+	source.SetJitter(0.5)
+	source.SetRetries(2, time.Second)
+	want := 5*time.Minute + 150*time.Second + 3*10*time.Second + 3*time.Second
+	if got := source.CollectionPeriod("slow:1", time.Minute); got != want {
+		t.Errorf("CollectionPeriod with jitter and retries = %v, want %v", got, want)
+	}
+}
+
+func TestHTTPSourceSetTargets(t *testing.T) {
+	source := New([]string{"a:1", "b:1"}, time.Second, 2)
+	source.SetTargetInterval("a:1", 5*time.Second)
+	source.errors["a:1"] = fmt.Errorf("connection refused")
+	source.lastQueued["a:1"] = time.Now()
+
+	source.SetTargets([]string{"b:1", "c:1"},
+		map[string]Request{"c:1": {Method: http.MethodPost}},
+		map[string]time.Duration{"c:1": 2 * time.Second},
+		map[string]time.Duration{"c:1": 3 * time.Second})
+
+	if got := source.GetTargets(); !slices.Equal(got, []string{"b:1", "c:1"}) {
+		t.Errorf("GetTargets() = %v, want [b:1 c:1]", got)
+	}
+	if source.TriggerRefreshHost("a:1") {
+		t.Error("Removed target should not be refreshable")
+	}
+	if len(source.GetErrors()) != 0 {
+		t.Errorf("Errors of removed targets should be forgotten, got %v", source.GetErrors())
+	}
+	if _, ok := source.lastQueued["a:1"]; ok {
+		t.Error("Schedule of removed targets should be forgotten")
+	}
+	if got := source.MinInterval(); got != 2*time.Second {
+		t.Errorf("MinInterval() = %v, want 2s", got)
+	}
+	if got := source.requestFor("c:1").Method; got != http.MethodPost {
+		t.Errorf("Request method for c:1 = %s, want POST", got)
+	}
+	if got := source.timeoutFor("c:1"); got != 3*time.Second {
+		t.Errorf("Timeout for c:1 = %v, want 3s", got)
+	}
+	if got := source.timeoutFor("b:1"); got != time.Second {
+		t.Errorf("Timeout for b:1 = %v, want the default 1s", got)
+	}
+}
+
+func TestHTTPSourceTruncatedResponse(t *testing.T) {
+	// The server promises more than it sends and drops the connection
+	// partway through the third goroutine
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 2 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+
+goroutine 3 [chan receive]:
+main.worker()
+`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(dump)+1024))
+		fmt.Fprint(w, dump)
+	}))
+	defer server.Close()
+
+	target := server.URL[7:]
+	source := New([]string{target}, time.Second, 1)
+
+	snapshot, err := source.collectOne(context.Background(), target)
+	if err != nil {
+		t.Fatalf("collectOne failed: %v", err)
+	}
+
+	if !snapshot.Partial {
+		t.Error("Expected snapshot to be marked partial")
+	}
+
+	// The incomplete trailing goroutine is dropped
+	if total := snapshot.TotalGoroutines(); total != 2 {
+		t.Errorf("TotalGoroutines = %d, want 2", total)
+	}
+}
+
+func TestHTTPSourceMaxBodySize(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 2 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+
+goroutine 3 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, dump)
+	}))
+	defer server.Close()
+	target := server.URL[7:]
+
+	tests := []struct {
+		name      string
+		limit     int64
+		total     int
+		truncated bool
+	}{
+		{"no limit", 0, 3, false},
+		{"exactly the dump", int64(len(dump)), 3, false},
+		{"cut in the third goroutine", int64(len(dump) - 10), 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := New([]string{target}, time.Second, 1)
+			source.SetMaxBodySize(tt.limit)
+
+			snapshot, err := source.collectOne(context.Background(), target)
+			if err != nil {
+				t.Fatalf("collectOne failed: %v", err)
+			}
+			if snapshot.Truncated != tt.truncated {
+				t.Errorf("Truncated = %v, want %v", snapshot.Truncated, tt.truncated)
+			}
+			if snapshot.Partial {
+				t.Error("A dump cut at the limit should not be marked partial")
+			}
+			if total := snapshot.TotalGoroutines(); total != tt.total {
+				t.Errorf("TotalGoroutines = %d, want %d", total, tt.total)
+			}
+		})
+	}
+}
+
+func TestHTTPSourceRequestMethodAndBody(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+`
+
+	type received struct {
+		method string
+		body   string
+	}
+	var mu sync.Mutex
+	got := make(map[string]received)
+
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			got[name] = received{r.Method, string(body)}
+			mu.Unlock()
+			fmt.Fprint(w, dump)
+		}))
+	}
+	proxy := newServer("proxy")
+	defer proxy.Close()
+	plain := newServer("plain")
+	defer plain.Close()
+
+	proxyTarget := proxy.URL[7:]
+	plainTarget := plain.URL[7:]
+	source := New([]string{proxyTarget, plainTarget}, time.Second, 1)
+	source.SetTargetRequest(proxyTarget, Request{Method: http.MethodPost, Body: []byte(`{"profile":"goroutine"}`)})
+
+	for _, target := range []string{proxyTarget, plainTarget} {
+		if _, err := source.collectOne(context.Background(), target); err != nil {
+			t.Fatalf("collectOne(%s) failed: %v", target, err)
+		}
+	}
+	// The body must be sent again on the next attempt
+	if _, err := source.collectOne(context.Background(), proxyTarget); err != nil {
+		t.Fatalf("collectOne(%s) failed: %v", proxyTarget, err)
+	}
+
+	if r := got["proxy"]; r.method != http.MethodPost || r.body != `{"profile":"goroutine"}` {
+		t.Errorf("Proxy received %s %q, want POST with body", r.method, r.body)
+	}
+	if r := got["plain"]; r.method != http.MethodGet || r.body != "" {
+		t.Errorf("Plain target received %s %q, want GET without body", r.method, r.body)
+	}
+}
+
+func TestHTTPSourceMemStats(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+`
+
+	withVars := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/debug/vars":
+			fmt.Fprint(w, `{"cmdline": ["app"], "memstats": {"HeapAlloc": 1048576, "Sys": 8388608, "NumGC": 42}}`)
+		default:
+			fmt.Fprint(w, dump)
+		}
+	}))
+	defer withVars.Close()
+
+	withoutVars := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/debug/vars" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, dump)
+	}))
+	defer withoutVars.Close()
+
+	source := New([]string{withVars.URL[7:], withoutVars.URL[7:]}, time.Second, 1)
+	source.SetMemStats(true)
+
+	snapshot, err := source.collectOne(context.Background(), withVars.URL[7:])
+	if err != nil {
+		t.Fatalf("collectOne failed: %v", err)
+	}
+	want := model.MemStats{HeapAlloc: 1048576, Sys: 8388608, NumGC: 42}
+	if snapshot.MemStats == nil || *snapshot.MemStats != want {
+		t.Errorf("MemStats = %+v, want %+v", snapshot.MemStats, want)
+	}
+
+	// A missing /debug/vars doesn't fail the collection
+	snapshot, err = source.collectOne(context.Background(), withoutVars.URL[7:])
+	if err != nil {
+		t.Fatalf("collectOne failed: %v", err)
+	}
+	if snapshot.MemStats != nil {
+		t.Errorf("Expected no memstats, got %+v", snapshot.MemStats)
+	}
+}
+
+func TestHTTPSourceDebugLevel(t *testing.T) {
+	dump := `goroutine profile: total 3
+3 @ 0x43e6cf 0x4714c1
+#	0x4714c0	main.worker+0x100	/app/worker.go:25
+`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("debug") != "1" {
+			http.Error(w, "debug=2 disabled", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, dump)
+	}))
+	defer server.Close()
+
+	target := server.URL[7:]
+	source := New([]string{target}, time.Second, 1)
+	source.SetDebugLevel(1)
+
+	snapshot, err := source.collectOne(context.Background(), target)
+	if err != nil {
+		t.Fatalf("collectOne failed: %v", err)
+	}
+	if total := snapshot.TotalGoroutines(); total != 3 {
+		t.Errorf("TotalGoroutines = %d, want 3", total)
+	}
+}
+
+func TestHTTPSourceHeaders(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Team") != "infra" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, dump)
+	}))
+	defer server.Close()
+
+	target := server.URL[7:]
+	source := New([]string{target}, time.Second, 1)
+
+	if _, err := source.collectOne(context.Background(), target); err == nil {
+		t.Fatal("Expected an error without auth headers")
+	}
+
+	source.SetHeaders(http.Header{
+		"Authorization": {"Bearer secret"},
+		"X-Team":        {"infra"},
+	})
+	if _, err := source.collectOne(context.Background(), target); err != nil {
+		t.Fatalf("collectOne failed: %v", err)
+	}
+}
+
+func TestHTTPSourceUserAgent(t *testing.T) {
+	var userAgent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.UserAgent())
+		fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+	}))
+	defer server.Close()
+
+	target := server.URL[7:]
+	source := New([]string{target}, time.Second, 1)
+	tests := []struct {
+		name   string
+		setup  func()
+		wantUA string
+	}{
+		{"default", func() {}, DefaultUserAgent},
+		{"set", func() { source.SetUserAgent("goru/1.2.3") }, "goru/1.2.3"},
+		{"header wins", func() { source.SetHeaders(http.Header{"User-Agent": {"custom"}}) }, "custom"},
+	}
+	for _, tt := range tests {
+		tt.setup()
+		if _, err := source.collectOne(context.Background(), target); err != nil {
+			t.Fatalf("%s: collectOne failed: %v", tt.name, err)
+		}
+		if got := userAgent.Load(); got != tt.wantUA {
+			t.Errorf("%s: User-Agent = %v, want %q", tt.name, got, tt.wantUA)
+		}
+	}
+}
+
+func TestHTTPSourceGzip(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 2 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+
+goroutine 3 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+`
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(dump))
+	zw.Close()
+
+	var gzipped atomic.Int32
+	cut := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			fmt.Fprint(w, dump)
+			return
+		}
+		gzipped.Add(1)
+		w.Header().Set("Content-Encoding", "gzip")
+		data := compressed.Bytes()
+		if cut > 0 {
+			// Drop the connection partway through the stream
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+			data = data[:cut]
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	target := server.URL[7:]
+	source := New([]string{target}, time.Second, 1)
+	// Extra headers don't turn compression off
+	source.SetHeaders(http.Header{"X-Team": {"infra"}})
+
+	snapshot, err := source.collectOne(context.Background(), target)
+	if err != nil {
+		t.Fatalf("collectOne failed: %v", err)
+	}
+	if gzipped.Load() != 1 {
+		t.Fatal("Expected the request to ask for gzip")
+	}
+	if snapshot.Partial {
+		t.Error("A complete gzip response should not be marked partial")
+	}
+	if total := snapshot.TotalGoroutines(); total != 3 {
+		t.Errorf("TotalGoroutines = %d, want 3", total)
+	}
+
+	// A stream cut short keeps the goroutines that arrived whole
+	cut = compressed.Len() - 8
+	snapshot, err = source.collectOne(context.Background(), target)
+	if err != nil {
+		t.Fatalf("collectOne failed: %v", err)
+	}
+	if !snapshot.Partial {
+		t.Error("Expected a cut-off gzip response to be marked partial")
+	}
+	if total := snapshot.TotalGoroutines(); total != 2 {
+		t.Errorf("TotalGoroutines = %d, want 2", total)
+	}
+}
+
+func TestHTTPSourceHTTPS(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+`
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, dump)
+	}))
+	defer server.Close()
+
+	// The target keeps its scheme and is used as-is for the host key
+	target := server.URL
+	source := New([]string{target}, time.Second, 1)
+
+	// The test server's certificate is self-signed
+	if _, err := source.collectOne(context.Background(), target); err == nil {
+		t.Fatal("Expected a certificate error")
+	}
+
+	source.SetInsecureSkipVerify(true)
+	snapshot, err := source.collectOne(context.Background(), target)
+	if err != nil {
+		t.Fatalf("collectOne failed: %v", err)
+	}
+	if snapshot.Host != target {
+		t.Errorf("Host = %q, want %q", snapshot.Host, target)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"localhost:6060", "http://localhost:6060"},
+		{"http://localhost:6060", "http://localhost:6060"},
+		{"https://app.internal:443/", "https://app.internal:443"},
+	}
+
+	for _, tt := range tests {
+		if got := baseURL(tt.target); got != tt.expected {
+			t.Errorf("baseURL(%q) = %q, want %q", tt.target, got, tt.expected)
+		}
+	}
+}
+
+func TestHTTPSourceRetries(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+`
+
+	var requests atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Unavailable for the first two requests, as during a deploy
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, dump)
+	}))
+	defer flaky.Close()
+
+	target := flaky.URL[7:]
+	source := New([]string{target}, time.Second, 1)
+	source.SetRetries(2, time.Millisecond)
+
+	if _, err := source.collectOne(context.Background(), target); err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected 3 requests, got %d", got)
+	}
+
+	// Retries give up and report the attempt count
+	requests.Store(0)
+	source.SetRetries(1, time.Millisecond)
+	_, err := source.collectOne(context.Background(), target)
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("Expected an error after 2 attempts, got %v", err)
+	}
+
+	// Client errors aren't retried
+	var notFound atomic.Int32
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notFound.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer missing.Close()
+
+	source.SetRetries(3, time.Millisecond)
+	if _, err := source.collectOne(context.Background(), missing.URL[7:]); err == nil {
+		t.Error("Expected an error for a 404")
+	}
+	if got := notFound.Load(); got != 1 {
+		t.Errorf("Expected 1 request for a 404, got %d", got)
+	}
+
+	// Nor are refused connections, which would only be refused again
+	closed := httptest.NewServer(http.NotFoundHandler())
+	refused := closed.URL[7:]
+	closed.Close()
+	source.SetRetries(3, time.Second)
+	start := time.Now()
+	_, err = source.collectOne(context.Background(), refused)
+	if err == nil || strings.Contains(err.Error(), "attempts") {
+		t.Errorf("Expected a single failed attempt, got %v", err)
+	}
+	if waited := time.Since(start); waited >= time.Second {
+		t.Errorf("Expected no backoff for a refused connection, waited %v", waited)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"503", &statusError{code: http.StatusServiceUnavailable}, true},
+		{"429", &statusError{code: http.StatusTooManyRequests}, true},
+		{"404", &statusError{code: http.StatusNotFound}, false},
+		{"timeout", &fetchError{err: context.DeadlineExceeded}, true},
+		{"reset", &fetchError{err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, true},
+		{"closed mid-response", &fetchError{err: io.ErrUnexpectedEOF}, true},
+		{"refused", &fetchError{err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, false},
+		{"unknown host", &fetchError{err: &net.DNSError{Err: "no such host", Name: "nowhere", IsNotFound: true}}, false},
+		{"bad certificate", &fetchError{err: &tls.CertificateVerificationError{}}, false},
+		{"parse error", fmt.Errorf("parsing dump: %w", errors.New("no goroutines")), false},
+	}
+
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPSourceRetryCancellation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	target := server.URL[7:]
+	source := New([]string{target}, time.Second, 1)
+	source.SetRetries(5, time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := source.collectOne(ctx, target); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Cancellation should abort the backoff, took %v", elapsed)
+	}
+}
+
+func TestHTTPSourceTargetTimeout(t *testing.T) {
+	// The server answers after a delay, or not at all once the client gives up
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+			fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	// Two names for the same server, one of them given less time
+	slow := server.URL[7:]
+	flaky := strings.Replace(slow, "127.0.0.1", "localhost", 1)
+	source := New([]string{slow, flaky}, 5*time.Second, 2)
+	source.SetTargetTimeout(flaky, 50*time.Millisecond)
+
+	if _, err := source.collectOne(context.Background(), slow); err != nil {
+		t.Errorf("Expected the default timeout to leave enough time, got %v", err)
+	}
+
+	start := time.Now()
+	if _, err := source.collectOne(context.Background(), flaky); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the target's timeout to expire, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Expected the target's own timeout, took %v", elapsed)
+	}
+
+	// Canceling the collection wins over a generous timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := source.collectOne(ctx, slow); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the canceled collection to fail, got %v", err)
+	}
+}
@@ -2,13 +2,21 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
+
+	"github.com/anyproto/goru/internal/alert"
+	"github.com/anyproto/goru/pkg/model"
 )
 
 type Mode string
@@ -19,77 +27,339 @@ const (
 	ModeBoth Mode = "both"
 )
 
+// DiffMode selects the baseline the TUI compares the current snapshot against
+type DiffMode string
+
+const (
+	DiffPrevious DiffMode = "previous" // previous refresh (cycle-to-cycle)
+	DiffStart    DiffMode = "start"    // first snapshot seen since goru started
+)
+
+// NoTTYMode selects what tui mode does when there is no terminal (both mode
+// serves only the web UI)
+type NoTTYMode string
+
+const (
+	NoTTYReport NoTTYMode = "report" // collect once and print a plain-text report
+	NoTTYFail   NoTTYMode = "fail"   // refuse to start
+)
+
+// PackageFrame selects which stack frame the TUI package view groups by
+type PackageFrame string
+
+const (
+	PackageFrameTop PackageFrame = "top" // innermost frame outside the runtime
+	PackageFrameApp PackageFrame = "app" // innermost frame outside the standard library
+)
+
+// SortTies selects how the TUI orders groups that tie on the sort column
+type SortTies string
+
+const (
+	SortTiesTrace SortTies = "trace" // by displayed function, then file and line
+	SortTiesID    SortTies = "id"    // by group ID, a hash of the stack
+)
+
+// FileTimestamp selects where file snapshots get their capture time
+type FileTimestamp string
+
+const (
+	FileTimestampParse   FileTimestamp = "parse" // when goru read the file
+	FileTimestampModTime FileTimestamp = "mtime" // the file's modification time
+	FileTimestampName    FileTimestamp = "name"  // a timestamp in the file name, else mtime
+)
+
+// FileMulti selects how files holding several concatenated dumps are read
+type FileMulti string
+
+const (
+	FileMultiMerge  FileMulti = "merge"  // the whole file as one dump
+	FileMultiLatest FileMulti = "latest" // only the last dump in the file
+	FileMultiAll    FileMulti = "all"    // every dump, as a separate snapshot
+)
+
+// TargetRequest overrides how the goroutine dump is requested from one target,
+// for debug proxies that need something other than a plain GET
+type TargetRequest struct {
+	Method string `yaml:"method"`
+	Body   string `yaml:"body"`
+}
+
+// KeyActions are the TUI actions whose keys can be set in keybindings
+var KeyActions = []string{
+	"up", "down", "top", "bottom", "next_host", "prev_host", "details",
+	"filter", "state_filter", "clear", "sort", "pause", "select", "pin",
+	"absent_pins", "note", "export", "snapshot", "snapshot_text",
+	"created_by", "packages", "package_frame", "labels", "label_key", "leaks",
+	"fleet", "baseline", "pin_baseline", "add_target", "remove_target",
+	"refresh", "refresh_host", "copy",
+	"collapse", "tree", "creator_tree", "replay_next", "replay_prev", "replay_jump", "help", "quit",
+}
+
+// States are the goroutine states that can be given a color in
+// state_colors or picked with filter_state
+var States = []string{"running", "runnable", "syscall", "waiting", "blocked", "unknown"}
+
+// colorPattern matches an ANSI color number or a hex color
+var colorPattern = regexp.MustCompile(`^([0-9]{1,3}|#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6})$`)
+
 type Config struct {
-	Targets  []string      `yaml:"targets" envconfig:"GORU_TARGETS"`
-	Files    []string      `yaml:"files" envconfig:"GORU_FILES"`
-	Follow   bool          `yaml:"follow" envconfig:"GORU_FOLLOW"`
-	Interval time.Duration `yaml:"interval" envconfig:"GORU_INTERVAL"`
-	Timeout  time.Duration `yaml:"timeout" envconfig:"GORU_TIMEOUT"`
-	Mode     Mode          `yaml:"mode" envconfig:"GORU_MODE"`
-	PProf    string        `yaml:"pprof" envconfig:"GORU_PPROF"`
+	Targets            []string                     `yaml:"targets" envconfig:"GORU_TARGETS"`
+	TargetsFile        string                       `yaml:"targets_file" envconfig:"GORU_TARGETS_FILE"`
+	Files              []string                     `yaml:"files" envconfig:"GORU_FILES"`
+	Follow             bool                         `yaml:"follow" envconfig:"GORU_FOLLOW"`
+	FileTime           FileTimestamp                `yaml:"file_time" envconfig:"GORU_FILE_TIME"`
+	FilesMulti         FileMulti                    `yaml:"files_multi" envconfig:"GORU_FILES_MULTI"`
+	Interval           time.Duration                `yaml:"interval" envconfig:"GORU_INTERVAL"`
+	Timeout            time.Duration                `yaml:"timeout" envconfig:"GORU_TIMEOUT"`
+	ShutdownTimeout    time.Duration                `yaml:"shutdown_timeout" envconfig:"GORU_SHUTDOWN_TIMEOUT"`
+	StaleAfter         time.Duration                `yaml:"stale_after" envconfig:"GORU_STALE_AFTER"`
+	Method             string                       `yaml:"method" envconfig:"GORU_METHOD"`
+	Body               string                       `yaml:"body" envconfig:"GORU_BODY"`
+	Requests           map[string]TargetRequest     `yaml:"requests" ignored:"true"`
+	Intervals          map[string]time.Duration     `yaml:"intervals" ignored:"true"`
+	Timeouts           map[string]time.Duration     `yaml:"timeouts" ignored:"true"`
+	TargetLabels       map[string]map[string]string `yaml:"target_labels" ignored:"true"`
+	HostLabel          string                       `yaml:"host_label" envconfig:"GORU_HOST_LABEL"`
+	MemStats           bool                         `yaml:"memstats" envconfig:"GORU_MEMSTATS"`
+	MinWait            time.Duration                `yaml:"min_wait" envconfig:"GORU_MIN_WAIT"`
+	GroupDepth         int                          `yaml:"group_depth" envconfig:"GORU_GROUP_DEPTH"`
+	NormalizeGenerics  bool                         `yaml:"normalize_generics" envconfig:"GORU_NORMALIZE_GENERICS"`
+	GroupByLabels      bool                         `yaml:"group_by_labels" envconfig:"GORU_GROUP_BY_LABELS"`
+	GroupByArgs        bool                         `yaml:"group_by_args" envconfig:"GORU_GROUP_BY_ARGS"`
+	MaxGoroutines      int                          `yaml:"max_goroutines" envconfig:"GORU_MAX_GOROUTINES"`
+	Mode               Mode                         `yaml:"mode" envconfig:"GORU_MODE"`
+	PProf              string                       `yaml:"pprof" envconfig:"GORU_PPROF"`
+	Metrics            string                       `yaml:"metrics" envconfig:"GORU_METRICS"`
+	DiffMode           DiffMode                     `yaml:"diff_mode" envconfig:"GORU_DIFF_MODE"`
+	NoTTY              NoTTYMode                    `yaml:"no_tty" envconfig:"GORU_NO_TTY"`
+	Once               bool                         `yaml:"once" envconfig:"GORU_ONCE"`
+	Golden             string                       `yaml:"golden" envconfig:"GORU_GOLDEN"`
+	UpdateGolden       bool                         `yaml:"update_golden" envconfig:"GORU_UPDATE_GOLDEN"`
+	MaxDrift           int                          `yaml:"max_drift" envconfig:"GORU_MAX_DRIFT"`
+	MaxGoroutinesAlert int                          `yaml:"max_goroutines_alert" envconfig:"GORU_MAX_GOROUTINES_ALERT"`
+	CreatedByTop       int                          `yaml:"created_by_top" envconfig:"GORU_CREATED_BY_TOP"`
+	PackageFrame       PackageFrame                 `yaml:"package_frame" envconfig:"GORU_PACKAGE_FRAME"`
+	SortTies           SortTies                     `yaml:"sort_ties" envconfig:"GORU_SORT_TIES"`
+	HideAbsentPins     bool                         `yaml:"hide_absent_pins" envconfig:"GORU_HIDE_ABSENT_PINS"`
+	FramesColumn       bool                         `yaml:"frames_column" envconfig:"GORU_FRAMES_COLUMN"`
+	HistoryDepth       int                          `yaml:"history_depth" envconfig:"GORU_HISTORY_DEPTH"`
+	InternTraces       bool                         `yaml:"intern_traces" envconfig:"GORU_INTERN_TRACES"`
+	MaxMemory          int64                        `yaml:"max_memory" envconfig:"GORU_MAX_MEMORY"`
+	KeyBindings        map[string][]string          `yaml:"keybindings" ignored:"true"`
+	StateColors        map[string]string            `yaml:"state_colors" ignored:"true"`
+	NoState            bool                         `yaml:"no_state" envconfig:"GORU_NO_STATE"`
+	Filter             string                       `yaml:"filter" envconfig:"GORU_FILTER"`
+	FilterState        string                       `yaml:"filter_state" envconfig:"GORU_FILTER_STATE"`
+	AppPrefixes        []string                     `yaml:"app_prefixes" envconfig:"GORU_APP_PREFIXES"`
+	SkipPackages       []string                     `yaml:"skip_packages" envconfig:"GORU_SKIP_PACKAGES"`
+	WarnCount          int                          `yaml:"warn_count" envconfig:"GORU_WARN_COUNT"`
+	CritCount          int                          `yaml:"crit_count" envconfig:"GORU_CRIT_COUNT"`
+	RecordDir          string                       `yaml:"record" envconfig:"GORU_RECORD"`
+	LoadDir            string                       `yaml:"load" envconfig:"GORU_LOAD"`
+
+	HTTP struct {
+		DebugLevel         int           `yaml:"debug_level" envconfig:"GORU_HTTP_DEBUG_LEVEL"`
+		Headers            []string      `yaml:"headers" envconfig:"GORU_HTTP_HEADERS"`
+		BearerTokenEnv     string        `yaml:"bearer_token_env" envconfig:"GORU_HTTP_BEARER_TOKEN_ENV"`
+		UserAgent          string        `yaml:"user_agent" envconfig:"GORU_HTTP_USER_AGENT"`
+		Instance           string        `yaml:"instance" envconfig:"GORU_HTTP_INSTANCE"`
+		InsecureSkipVerify bool          `yaml:"insecure_skip_verify" envconfig:"GORU_HTTP_INSECURE_SKIP_VERIFY"`
+		Retries            int           `yaml:"retries" envconfig:"GORU_HTTP_RETRIES"`
+		RetryBackoff       time.Duration `yaml:"retry_backoff" envconfig:"GORU_HTTP_RETRY_BACKOFF"`
+		MaxBodySize        int64         `yaml:"max_body_size" envconfig:"GORU_HTTP_MAX_BODY_SIZE"`
+		Workers            int           `yaml:"workers" envconfig:"GORU_HTTP_WORKERS"`
+		MaxIdleConns       int           `yaml:"max_idle_conns" envconfig:"GORU_HTTP_MAX_IDLE_CONNS"`
+		IdleConnTimeout    time.Duration `yaml:"idle_conn_timeout" envconfig:"GORU_HTTP_IDLE_CONN_TIMEOUT"`
+		Jitter             float64       `yaml:"jitter" envconfig:"GORU_HTTP_JITTER"`
+	} `yaml:"http"`
 
 	Web struct {
-		Host    string `yaml:"host" envconfig:"GORU_WEB_HOST"`
-		Port    int    `yaml:"port" envconfig:"GORU_WEB_PORT"`
-		NoOpen  bool   `yaml:"no_open" envconfig:"GORU_WEB_NO_OPEN"`
-		TLSCert string `yaml:"tls_cert" envconfig:"GORU_WEB_TLS_CERT"`
-		TLSKey  string `yaml:"tls_key" envconfig:"GORU_WEB_TLS_KEY"`
+		Host      string `yaml:"host" envconfig:"GORU_WEB_HOST"`
+		Port      int    `yaml:"port" envconfig:"GORU_WEB_PORT"`
+		NoOpen    bool   `yaml:"no_open" envconfig:"GORU_WEB_NO_OPEN"`
+		TLSCert   string `yaml:"tls_cert" envconfig:"GORU_WEB_TLS_CERT"`
+		TLSKey    string `yaml:"tls_key" envconfig:"GORU_WEB_TLS_KEY"`
+		AuthUser  string `yaml:"auth_user" envconfig:"GORU_WEB_AUTH_USER"`
+		AuthPass  string `yaml:"auth_pass" envconfig:"GORU_WEB_AUTH_PASS"`
+		AuthToken string `yaml:"auth_token" envconfig:"GORU_WEB_AUTH_TOKEN"`
 	} `yaml:"web"`
 
 	Log struct {
-		Level string `yaml:"level" envconfig:"GORU_LOG_LEVEL"`
-		JSON  bool   `yaml:"json" envconfig:"GORU_LOG_JSON"`
+		Level   string `yaml:"level" envconfig:"GORU_LOG_LEVEL"`
+		JSON    bool   `yaml:"json" envconfig:"GORU_LOG_JSON"`
+		File    string `yaml:"file" envconfig:"GORU_LOG_FILE"`
+		MaxSize int64  `yaml:"max_size" envconfig:"GORU_LOG_MAX_SIZE"`
 	} `yaml:"log"`
 
+	// Alerts are rules checked on every snapshot, posted to a webhook when
+	// they hold
+	Alerts struct {
+		Webhook  string        `yaml:"webhook" envconfig:"GORU_ALERTS_WEBHOOK"`
+		Debounce time.Duration `yaml:"debounce" envconfig:"GORU_ALERTS_DEBOUNCE"`
+		Rules    []alert.Rule  `yaml:"rules" ignored:"true"`
+	} `yaml:"alerts"`
+
 	ConfigFile string `yaml:"-"`
+
+	// Flags given on the command line, kept for ReloadSources
+	flags flagValues
 }
 
 func New() *Config {
 	return &Config{
-		Interval: 10 * time.Second,
-		Timeout:  30 * time.Second,
-		Mode:     ModeTUI,
+		Interval:        10 * time.Second,
+		Timeout:         30 * time.Second,
+		ShutdownTimeout: 5 * time.Second,
+		Method:          http.MethodGet,
+		Mode:            ModeTUI,
+		DiffMode:        DiffPrevious,
+		NoTTY:           NoTTYReport,
+		FileTime:        FileTimestampParse,
+		FilesMulti:      FileMultiMerge,
+		CreatedByTop:    20,
+		PackageFrame:    PackageFrameTop,
+		SortTies:        SortTiesTrace,
+		HistoryDepth:    60,
+		SkipPackages:    slices.Clone(model.DefaultSkipPackages),
+		HTTP: struct {
+			DebugLevel         int           `yaml:"debug_level" envconfig:"GORU_HTTP_DEBUG_LEVEL"`
+			Headers            []string      `yaml:"headers" envconfig:"GORU_HTTP_HEADERS"`
+			BearerTokenEnv     string        `yaml:"bearer_token_env" envconfig:"GORU_HTTP_BEARER_TOKEN_ENV"`
+			UserAgent          string        `yaml:"user_agent" envconfig:"GORU_HTTP_USER_AGENT"`
+			Instance           string        `yaml:"instance" envconfig:"GORU_HTTP_INSTANCE"`
+			InsecureSkipVerify bool          `yaml:"insecure_skip_verify" envconfig:"GORU_HTTP_INSECURE_SKIP_VERIFY"`
+			Retries            int           `yaml:"retries" envconfig:"GORU_HTTP_RETRIES"`
+			RetryBackoff       time.Duration `yaml:"retry_backoff" envconfig:"GORU_HTTP_RETRY_BACKOFF"`
+			MaxBodySize        int64         `yaml:"max_body_size" envconfig:"GORU_HTTP_MAX_BODY_SIZE"`
+			Workers            int           `yaml:"workers" envconfig:"GORU_HTTP_WORKERS"`
+			MaxIdleConns       int           `yaml:"max_idle_conns" envconfig:"GORU_HTTP_MAX_IDLE_CONNS"`
+			IdleConnTimeout    time.Duration `yaml:"idle_conn_timeout" envconfig:"GORU_HTTP_IDLE_CONN_TIMEOUT"`
+			Jitter             float64       `yaml:"jitter" envconfig:"GORU_HTTP_JITTER"`
+		}{
+			DebugLevel:      2,
+			Retries:         2,
+			RetryBackoff:    500 * time.Millisecond,
+			Workers:         5,
+			MaxIdleConns:    100,
+			IdleConnTimeout: 90 * time.Second,
+		},
 		Web: struct {
-			Host    string `yaml:"host" envconfig:"GORU_WEB_HOST"`
-			Port    int    `yaml:"port" envconfig:"GORU_WEB_PORT"`
-			NoOpen  bool   `yaml:"no_open" envconfig:"GORU_WEB_NO_OPEN"`
-			TLSCert string `yaml:"tls_cert" envconfig:"GORU_WEB_TLS_CERT"`
-			TLSKey  string `yaml:"tls_key" envconfig:"GORU_WEB_TLS_KEY"`
+			Host      string `yaml:"host" envconfig:"GORU_WEB_HOST"`
+			Port      int    `yaml:"port" envconfig:"GORU_WEB_PORT"`
+			NoOpen    bool   `yaml:"no_open" envconfig:"GORU_WEB_NO_OPEN"`
+			TLSCert   string `yaml:"tls_cert" envconfig:"GORU_WEB_TLS_CERT"`
+			TLSKey    string `yaml:"tls_key" envconfig:"GORU_WEB_TLS_KEY"`
+			AuthUser  string `yaml:"auth_user" envconfig:"GORU_WEB_AUTH_USER"`
+			AuthPass  string `yaml:"auth_pass" envconfig:"GORU_WEB_AUTH_PASS"`
+			AuthToken string `yaml:"auth_token" envconfig:"GORU_WEB_AUTH_TOKEN"`
 		}{
 			Host: "localhost",
 			Port: 8080,
 		},
 		Log: struct {
-			Level string `yaml:"level" envconfig:"GORU_LOG_LEVEL"`
-			JSON  bool   `yaml:"json" envconfig:"GORU_LOG_JSON"`
+			Level   string `yaml:"level" envconfig:"GORU_LOG_LEVEL"`
+			JSON    bool   `yaml:"json" envconfig:"GORU_LOG_JSON"`
+			File    string `yaml:"file" envconfig:"GORU_LOG_FILE"`
+			MaxSize int64  `yaml:"max_size" envconfig:"GORU_LOG_MAX_SIZE"`
 		}{
 			Level: "info",
 		},
+		Alerts: struct {
+			Webhook  string        `yaml:"webhook" envconfig:"GORU_ALERTS_WEBHOOK"`
+			Debounce time.Duration `yaml:"debounce" envconfig:"GORU_ALERTS_DEBOUNCE"`
+			Rules    []alert.Rule  `yaml:"rules" ignored:"true"`
+		}{
+			Debounce: alert.DefaultDebounce,
+		},
 	}
 }
 
 func (c *Config) Load() error {
 	// 1. Define flags
-	pflag.StringSliceVar(&c.Targets, "targets", c.Targets, "Comma-separated host:port list to poll via HTTP")
-	pflag.StringSliceVar(&c.Files, "files", c.Files, "Paths or globs of goroutine-dump files (.txt or .gz)")
+	pflag.StringSliceVar(&c.Targets, "targets", c.Targets, "Comma-separated host:port list to poll via HTTP (prefix with https:// for TLS, suffix with @30s for an interval of its own)")
+	pflag.StringVar(&c.TargetsFile, "targets-file", c.TargetsFile, "File listing targets one per line or as a YAML list, added to --targets and re-read on SIGHUP")
+	pflag.StringVar(&c.HostLabel, "host-label", c.HostLabel, "Target label, e.g. env, shown next to each host and grouping the hosts in the TUI")
+	pflag.StringSliceVar(&c.Files, "files", c.Files, "Paths or globs of goroutine-dump files (plain text, or compressed with gzip, zstd, bzip2 or xz)")
 	pflag.BoolVar(&c.Follow, "follow", c.Follow, "Re-read growing files (tail-like)")
+	pflag.StringVar((*string)(&c.FilesMulti), "files.multi", string(c.FilesMulti), "Files holding several concatenated dumps: merge (parse as one), latest (last dump only), or all (each dump as a snapshot)")
+	pflag.StringVar((*string)(&c.FileTime), "file-time", string(c.FileTime), "Capture time of file snapshots: parse (read time), mtime, or name (timestamp in the file name, else mtime)")
 	pflag.DurationVar(&c.Interval, "interval", c.Interval, "Poll interval for HTTP targets or rescan interval for files (0 to disable auto-refresh)")
-	pflag.DurationVar(&c.Timeout, "timeout", c.Timeout, "HTTP timeout for fetching goroutine dumps")
+	pflag.DurationVar(&c.Timeout, "timeout", c.Timeout, "HTTP timeout for fetching goroutine dumps, unless set per target under timeouts in the config file")
+	pflag.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "How long to let in-flight collections finish on exit")
+	pflag.DurationVar(&c.StaleAfter, "stale-after", c.StaleAfter, "Mark a host STALE once it has gone this long without a successful collection, or longer for targets with a longer interval (0 to disable)")
+	pflag.StringVar(&c.Method, "method", c.Method, "HTTP method used to fetch goroutine dumps")
+	pflag.StringVar(&c.Body, "body", c.Body, "Request body sent with goroutine dump requests (requires a non-GET method)")
+	pflag.BoolVar(&c.MemStats, "memstats", c.MemStats, "Also scrape /debug/vars from HTTP targets and show memory figures in the TUI header")
+	pflag.DurationVar(&c.MinWait, "min-wait", c.MinWait, "Only record wait durations at least this long (0 to keep all)")
+	pflag.IntVar(&c.GroupDepth, "group-depth", c.GroupDepth, "Group goroutines by only the top N stack frames (0 for the full trace)")
+	pflag.BoolVar(&c.NormalizeGenerics, "normalize-generics", c.NormalizeGenerics, "Group instantiations of generic functions together by ignoring their type arguments")
+	pflag.BoolVar(&c.GroupByLabels, "group-by-labels", c.GroupByLabels, "Group goroutines by their pprof labels as well as their stacks")
+	pflag.BoolVar(&c.GroupByArgs, "group-by-args", c.GroupByArgs, "Group goroutines by their function arguments as well as their stacks, with addresses and numbers ignored")
+	pflag.IntVar(&c.MaxGoroutines, "max-goroutines", c.MaxGoroutines, "Stop parsing a dump after this many goroutines and mark it truncated (0 for no limit)")
 	pflag.StringVar((*string)(&c.Mode), "mode", string(c.Mode), "Run mode: tui, web, or both")
 	pflag.StringVar(&c.PProf, "pprof", c.PProf, "Host:port to expose pprof endpoints for self-inspection")
+	pflag.StringVar(&c.Metrics, "metrics", c.Metrics, "Host:port to expose Prometheus metrics on /metrics (defaults to the pprof address)")
+	pflag.StringVar((*string)(&c.DiffMode), "diff-mode", string(c.DiffMode), "Initial TUI diff baseline: previous or start")
+	pflag.StringVar((*string)(&c.NoTTY), "no-tty", string(c.NoTTY), "Without a terminal in tui mode: report (print a plain-text summary) or fail")
+	pflag.BoolVar(&c.Once, "once", c.Once, "Collect once, print a plain-text report, and exit")
+	pflag.StringVar(&c.Golden, "golden", c.Golden, "With --once, compare the collection against this golden snapshot and fail on drift")
+	pflag.BoolVar(&c.UpdateGolden, "update-golden", c.UpdateGolden, "With --golden, write the collection to the golden file instead of comparing")
+	pflag.IntVar(&c.MaxDrift, "max-drift", c.MaxDrift, "Largest per-group count change tolerated by --golden")
+	pflag.IntVar(&c.MaxGoroutinesAlert, "max-goroutines-alert", c.MaxGoroutinesAlert, "With --once, fail if any host has more goroutines than this (0 to disable)")
+	pflag.IntVar(&c.CreatedByTop, "created-by-top", c.CreatedByTop, "Number of creation sites shown in the TUI Created By view (0 for all)")
+	pflag.StringVar((*string)(&c.SortTies), "sort-ties", string(c.SortTies), "How the TUI orders groups that tie on the sort column: trace (function, then file and line) or id")
+	pflag.StringVar((*string)(&c.PackageFrame), "package-frame", string(c.PackageFrame), "Frame the TUI package view groups by: top or app")
+	pflag.BoolVar(&c.HideAbsentPins, "hide-absent-pins", c.HideAbsentPins, "Omit pinned groups from the TUI table on hosts that don't have them instead of showing zero-count rows")
+	pflag.BoolVar(&c.FramesColumn, "frames-column", c.FramesColumn, "Show a sortable TUI column with the number of frames in each stack")
+	pflag.BoolVar(&c.NoState, "no-state", c.NoState, "Don't restore or save the TUI sort mode, filter and host across runs")
+	pflag.StringVar(&c.Filter, "filter", c.Filter, "Filter the TUI table from the start by function or file name, or re:<regexp> (overrides the restored filter)")
+	pflag.StringVar(&c.FilterState, "filter-state", c.FilterState, "Show only goroutines in this state in the TUI table from the start")
+	pflag.StringSliceVar(&c.AppPrefixes, "app-prefix", c.AppPrefixes, "Package prefixes of application code, whose frames the TUI details view keeps when collapsing the rest (default: everything outside the standard library)")
+	pflag.StringSliceVar(&c.SkipPackages, "skip-packages", c.SkipPackages, "Packages, and those below them, the TUI Function column looks past to a goroutine's first frame elsewhere (empty for the innermost frame)")
+	pflag.IntVar(&c.WarnCount, "warn-count", c.WarnCount, "Color TUI goroutine counts above this yellow (0 to disable)")
+	pflag.IntVar(&c.CritCount, "crit-count", c.CritCount, "Color TUI goroutine counts above this red (0 to disable)")
+	pflag.StringVar(&c.RecordDir, "record", c.RecordDir, "Write every snapshot collected to this directory as gzipped JSON, for --load or goru replay later")
+	pflag.StringVar(&c.LoadDir, "load", c.LoadDir, "Play back the snapshots recorded with --record in this directory in the TUI instead of collecting")
+	pflag.IntVar(&c.HistoryDepth, "history-depth", c.HistoryDepth, "Number of recent snapshots kept per host for trend analysis (0 to disable)")
+	pflag.BoolVar(&c.InternTraces, "intern-traces", c.InternTraces, "Keep one copy of stack traces shared by many hosts or snapshots, to cut memory with large fleets")
+	pflag.Int64Var(&c.MaxMemory, "max-memory", c.MaxMemory, "Soft limit in bytes on the memory kept snapshots take, dropping the oldest history to stay under it and interning traces (0 for no limit)")
+
+	pflag.IntVar(&c.HTTP.DebugLevel, "http.debug-level", c.HTTP.DebugLevel, "Goroutine profile format to request: 2 (full dumps) or 1 (aggregated counts)")
+	pflag.StringArrayVar(&c.HTTP.Headers, "http.header", c.HTTP.Headers, "Header sent with every target request, as key=value (repeatable)")
+	pflag.StringVar(&c.HTTP.BearerTokenEnv, "http.bearer-token-env", c.HTTP.BearerTokenEnv, "Environment variable holding a bearer token sent as the Authorization header")
+	pflag.StringVar(&c.HTTP.UserAgent, "http.user-agent", c.HTTP.UserAgent, "User-Agent sent with every target request (default goru/<version>)")
+	pflag.StringVar(&c.HTTP.Instance, "http.instance", c.HTTP.Instance, "Name of this goru sent as the X-Goru-Instance header, to tell instances apart in access logs")
+	pflag.BoolVar(&c.HTTP.InsecureSkipVerify, "http.insecure-skip-verify", c.HTTP.InsecureSkipVerify, "Skip TLS certificate verification for https:// targets")
+	pflag.IntVar(&c.HTTP.Retries, "http.retries", c.HTTP.Retries, "Extra attempts for timeouts, connection resets and 5xx or 429 responses before a target is shown as failed")
+	pflag.DurationVar(&c.HTTP.RetryBackoff, "http.retry-backoff", c.HTTP.RetryBackoff, "Wait before the first retry, doubled after each one")
+	pflag.Int64Var(&c.HTTP.MaxBodySize, "http.max-body-size", c.HTTP.MaxBodySize, "Stop reading a dump after this many bytes and mark it truncated (0 for no limit)")
+	pflag.IntVar(&c.HTTP.Workers, "http.workers", c.HTTP.Workers, "Targets collected from at once")
+	pflag.IntVar(&c.HTTP.MaxIdleConns, "http.max-idle-conns", c.HTTP.MaxIdleConns, "Idle connections kept open across all targets between collections (0 for no limit)")
+	pflag.Float64Var(&c.HTTP.Jitter, "http.jitter", c.HTTP.Jitter, "Spread each target's scheduled requests over a random delay of up to this fraction of its interval, e.g. 0.2 (0 to disable)")
+	pflag.DurationVar(&c.HTTP.IdleConnTimeout, "http.idle-conn-timeout", c.HTTP.IdleConnTimeout, "Close connections idle for longer than this (0 to keep them open)")
 
 	pflag.StringVar(&c.Web.Host, "web.host", c.Web.Host, "Web server host")
 	pflag.IntVar(&c.Web.Port, "web.port", c.Web.Port, "Web server port")
 	pflag.BoolVar(&c.Web.NoOpen, "web.no-open", c.Web.NoOpen, "Don't open browser automatically")
 	pflag.StringVar(&c.Web.TLSCert, "web.tls-cert", c.Web.TLSCert, "TLS certificate file")
 	pflag.StringVar(&c.Web.TLSKey, "web.tls-key", c.Web.TLSKey, "TLS key file")
+	pflag.StringVar(&c.Web.AuthUser, "web.auth-user", c.Web.AuthUser, "Require HTTP basic auth with this user name for the web UI and API")
+	pflag.StringVar(&c.Web.AuthPass, "web.auth-pass", c.Web.AuthPass, "Password for --web.auth-user (prefer GORU_WEB_AUTH_PASS, flags show up in ps)")
+	pflag.StringVar(&c.Web.AuthToken, "web.auth-token", c.Web.AuthToken, "Require this bearer token, or basic auth with it as the password, for the web UI and API")
 
 	pflag.StringVar(&c.Log.Level, "log.level", c.Log.Level, "Log level (debug, info, warn, error)")
 	pflag.BoolVar(&c.Log.JSON, "log.json", c.Log.JSON, "Use JSON format for logs")
+	pflag.StringVar(&c.Log.File, "log.file", c.Log.File, "Write logs to this file instead of stderr")
+	pflag.StringVar(&c.Alerts.Webhook, "alerts.webhook", c.Alerts.Webhook, "URL to POST alerts to as JSON when a rule in the config's alerts section holds")
+	pflag.DurationVar(&c.Alerts.Debounce, "alerts.debounce", c.Alerts.Debounce, "Wait at least this long before repeating an alert for the same rule and host")
+	pflag.Int64Var(&c.Log.MaxSize, "log.max-size", c.Log.MaxSize, "Rotate the log file to <file>.1 once it grows past this many bytes (0 to never rotate)")
 
 	pflag.StringVar(&c.ConfigFile, "config", c.ConfigFile, "Config file path")
 
 	pflag.Parse()
+	flags := changedFlags()
+	c.flags = flags
 
 	// 2. Load from config file if specified
 	if c.ConfigFile != "" {
@@ -103,13 +373,106 @@ func (c *Config) Load() error {
 		return fmt.Errorf("processing env vars: %w", err)
 	}
 
-	// 4. Re-parse flags to override config file and env vars
-	pflag.Parse()
+	// 4. Re-apply flags to override config file and env vars
+	if err := flags.apply(); err != nil {
+		return fmt.Errorf("applying flags: %w", err)
+	}
+
+	// 5. Add the targets listed in the targets file
+	if err := c.loadTargetsFile(); err != nil {
+		return err
+	}
 
-	// 5. Validate
+	// 6. Validate
+	c.normalizeRequests()
 	return c.Validate()
 }
 
+// normalizeRequests upper-cases the request methods and fills in the default
+// method for targets that don't set their own
+func (c *Config) normalizeRequests() {
+	c.Method = strings.ToUpper(c.Method)
+	for target, req := range c.Requests {
+		req.Method = strings.ToUpper(req.Method)
+		if req.Method == "" {
+			req.Method = c.Method
+		}
+		c.Requests[target] = req
+	}
+}
+
+// ReloadSources re-reads the config file, environment and targets file for
+// a new set of targets and files, e.g. on SIGHUP, and returns a validated
+// copy of the config with them. Targets and files given as flags take
+// precedence as in Load, so they don't change. Other settings need a restart.
+func (c *Config) ReloadSources() (*Config, error) {
+	next := *c
+	next.Targets, next.Files = nil, nil
+	next.Requests, next.Intervals, next.Timeouts, next.TargetLabels = nil, nil, nil, nil
+
+	if c.ConfigFile != "" {
+		if err := next.loadFromFile(c.ConfigFile); err != nil {
+			return nil, fmt.Errorf("loading config file: %w", err)
+		}
+	}
+	if err := envconfig.Process("goru", &next); err != nil {
+		return nil, fmt.Errorf("processing env vars: %w", err)
+	}
+
+	for f, value := range c.flags {
+		switch f.Name {
+		case "targets":
+			next.Targets = slices.Clone(value)
+		case "files":
+			next.Files = slices.Clone(value)
+		case "targets-file":
+			next.TargetsFile = value[0]
+		}
+	}
+	if err := next.loadTargetsFile(); err != nil {
+		return nil, err
+	}
+
+	next.normalizeRequests()
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// flagValues holds the values of the flags given on the command line
+type flagValues map[*pflag.Flag][]string
+
+// changedFlags records the parsed value of every flag set on the command line
+func changedFlags() flagValues {
+	values := make(flagValues)
+	pflag.Visit(func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			values[f] = append([]string(nil), sv.GetSlice()...)
+		} else {
+			values[f] = []string{f.Value.String()}
+		}
+	})
+	return values
+}
+
+// apply sets the recorded flag values again. Parsing the command line a
+// second time instead would append repeatable flags to their earlier values.
+func (v flagValues) apply() error {
+	for f, value := range v {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			if err := sv.Replace(value); err != nil {
+				return fmt.Errorf("--%s: %w", f.Name, err)
+			}
+			continue
+		}
+		if err := f.Value.Set(value[0]); err != nil {
+			return fmt.Errorf("--%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
 func (c *Config) loadFromFile(path string) error {
 	file, err := os.Open(path)
 	if err != nil {
@@ -117,14 +480,168 @@ func (c *Config) loadFromFile(path string) error {
 	}
 	defer file.Close()
 
-	decoder := yaml.NewDecoder(file)
-	return decoder.Decode(c)
+	// Targets may be given with labels, which are moved to TargetLabels
+	// before the rest is decoded as usual
+	var root yaml.Node
+	if err := yaml.NewDecoder(file).Decode(&root); err != nil {
+		return err
+	}
+	var labels map[string]map[string]string
+	if len(root.Content) > 0 && root.Content[0].Kind == yaml.MappingNode {
+		doc := root.Content[0]
+		for i := 0; i+1 < len(doc.Content); i += 2 {
+			if doc.Content[i].Value != "targets" {
+				continue
+			}
+			var err error
+			if labels, err = splitTargetLabels(doc.Content[i+1]); err != nil {
+				return fmt.Errorf("targets: %w", err)
+			}
+		}
+	}
+	if err := root.Decode(c); err != nil {
+		return err
+	}
+	c.addTargetLabels(labels)
+	return nil
+}
+
+// targetEntry is a target with labels, as the config and targets files
+// accept in place of a plain host:port
+type targetEntry struct {
+	Addr   string            `yaml:"addr"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// splitTargetLabels replaces the {addr, labels} entries of a YAML list of
+// targets with their addresses and returns their labels by address. Plain
+// string entries are left as they are.
+func splitTargetLabels(list *yaml.Node) (map[string]map[string]string, error) {
+	if list.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+	var labels map[string]map[string]string
+	for i, item := range list.Content {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		var entry targetEntry
+		if err := item.Decode(&entry); err != nil {
+			return nil, err
+		}
+		if entry.Addr == "" {
+			return nil, fmt.Errorf("line %d: target without an addr", item.Line)
+		}
+		if len(entry.Labels) > 0 {
+			if labels == nil {
+				labels = make(map[string]map[string]string)
+			}
+			labels[entry.Addr] = entry.Labels
+		}
+		list.Content[i] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.Addr}
+	}
+	return labels, nil
+}
+
+// addTargetLabels adds labels to TargetLabels, leaving targets that already
+// have labels alone
+func (c *Config) addTargetLabels(labels map[string]map[string]string) {
+	for target, l := range labels {
+		if c.TargetLabels == nil {
+			c.TargetLabels = make(map[string]map[string]string)
+		}
+		if _, ok := c.TargetLabels[target]; !ok {
+			c.TargetLabels[target] = l
+		}
+	}
+}
+
+// loadTargetsFile adds the targets listed in the targets file to the
+// others, skipping ones already given
+func (c *Config) loadTargetsFile() error {
+	if c.TargetsFile == "" {
+		return nil
+	}
+	targets, labels, err := readTargetsFile(c.TargetsFile)
+	if err != nil {
+		return fmt.Errorf("reading targets file: %w", err)
+	}
+	for _, target := range targets {
+		if !slices.Contains(c.Targets, target) {
+			c.Targets = append(c.Targets, target)
+		}
+	}
+	c.addTargetLabels(labels)
+	return nil
+}
+
+// readTargetsFile reads a list of targets, either as a YAML list, whose
+// entries may carry labels, or one per line. Blank lines and lines starting
+// with # are skipped.
+func readTargetsFile(path string) ([]string, map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var list yaml.Node
+	if err := yaml.Unmarshal(data, &list); err == nil && len(list.Content) > 0 && list.Content[0].Kind == yaml.SequenceNode {
+		labels, err := splitTargetLabels(list.Content[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		var targets []string
+		if err := list.Content[0].Decode(&targets); err == nil {
+			return targets, labels, nil
+		}
+	}
+
+	var targets []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	return targets, nil, nil
+}
+
+// ValidateTarget checks a single target, such as one typed into the TUI:
+// host:port, optionally after an http:// or https:// scheme
+func ValidateTarget(target string) error {
+	hostPort := target
+	if scheme, rest, ok := strings.Cut(target, "://"); ok {
+		if scheme != "http" && scheme != "https" {
+			return fmt.Errorf("invalid target %s (scheme must be http or https)", target)
+		}
+		hostPort = rest
+	}
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || host == "" {
+		return fmt.Errorf("invalid target %s (must be host:port)", target)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid target %s (port must be 1-65535)", target)
+	}
+	return nil
 }
 
 func (c *Config) Validate() error {
-	// At least one source must be specified
-	if len(c.Targets) == 0 && len(c.Files) == 0 {
-		return fmt.Errorf("at least one of --targets or --files must be specified")
+	// A recording is played back instead of collecting, so it takes the
+	// place of the sources
+	if c.LoadDir != "" {
+		if len(c.Targets) > 0 || len(c.Files) > 0 {
+			return fmt.Errorf("--load plays back a recording and can't be combined with --targets or --files")
+		}
+		if c.RecordDir != "" {
+			return fmt.Errorf("--load and --record can't be used together")
+		}
+		if c.Mode != ModeTUI {
+			return fmt.Errorf("--load plays back in the TUI and needs --mode tui")
+		}
+	} else if len(c.Targets) == 0 && len(c.Files) == 0 {
+		return fmt.Errorf("at least one of --targets, --targets-file, --files or --load must be specified")
 	}
 
 	// Validate mode
@@ -135,6 +652,243 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid mode: %s (must be tui, web, or both)", c.Mode)
 	}
 
+	// Validate target schemes, moving host:port@interval suffixes into the
+	// per-target intervals
+	for i, target := range c.Targets {
+		if base, suffix, ok := cutLast(target, "@"); ok {
+			if interval, err := time.ParseDuration(suffix); err == nil {
+				if c.Intervals == nil {
+					c.Intervals = make(map[string]time.Duration)
+				}
+				c.Intervals[base] = interval
+				// Labels belong to the target, not its interval
+				if labels, ok := c.TargetLabels[target]; ok {
+					delete(c.TargetLabels, target)
+					c.TargetLabels[base] = labels
+				}
+				target = base
+				c.Targets[i] = target
+			}
+		}
+		scheme, _, ok := strings.Cut(target, "://")
+		if ok && scheme != "http" && scheme != "https" {
+			return fmt.Errorf("invalid target %s (scheme must be http or https)", target)
+		}
+	}
+	for target, interval := range c.Intervals {
+		if interval < 100*time.Millisecond {
+			return fmt.Errorf("interval for %s must be at least 100ms", target)
+		}
+	}
+
+	// Validate HTTP requests
+	if err := validateRequest(strings.ToUpper(c.Method), c.Body); err != nil {
+		return err
+	}
+	for target, req := range c.Requests {
+		// Targets without their own method inherit the default one
+		method := req.Method
+		if method == "" {
+			method = c.Method
+		}
+		if err := validateRequest(strings.ToUpper(method), req.Body); err != nil {
+			return fmt.Errorf("request for %s: %w", target, err)
+		}
+	}
+
+	for target, timeout := range c.Timeouts {
+		if timeout <= 0 {
+			return fmt.Errorf("timeout for %s must be positive", target)
+		}
+	}
+	for target, labels := range c.TargetLabels {
+		if _, ok := labels[""]; ok {
+			return fmt.Errorf("labels for %s: empty label name", target)
+		}
+	}
+
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("shutdown timeout must not be negative")
+	}
+
+	if c.StaleAfter < 0 {
+		return fmt.Errorf("stale after must not be negative")
+	}
+	// Hosts would turn stale between every two collections
+	if c.StaleAfter > 0 && c.Interval > 0 && c.StaleAfter <= c.Interval {
+		return fmt.Errorf("stale after (%v) must be longer than the interval (%v)", c.StaleAfter, c.Interval)
+	}
+
+	if c.MinWait < 0 {
+		return fmt.Errorf("min wait must not be negative")
+	}
+
+	// Validate file timestamp source
+	switch c.FileTime {
+	case FileTimestampParse, FileTimestampModTime, FileTimestampName:
+		// valid
+	default:
+		return fmt.Errorf("invalid file time: %s (must be parse, mtime, or name)", c.FileTime)
+	}
+
+	// Validate multi-dump handling
+	switch c.FilesMulti {
+	case FileMultiMerge, FileMultiLatest, FileMultiAll:
+		// valid
+	default:
+		return fmt.Errorf("invalid files multi: %s (must be merge, latest, or all)", c.FilesMulti)
+	}
+
+	// Validate diff mode
+	switch c.DiffMode {
+	case DiffPrevious, DiffStart:
+		// valid
+	default:
+		return fmt.Errorf("invalid diff mode: %s (must be previous or start)", c.DiffMode)
+	}
+
+	// Validate non-TTY behavior
+	switch c.NoTTY {
+	case NoTTYReport, NoTTYFail:
+		// valid
+	default:
+		return fmt.Errorf("invalid no-tty mode: %s (must be report or fail)", c.NoTTY)
+	}
+
+	// Validate golden comparison
+	if c.Golden != "" && !c.Once {
+		return fmt.Errorf("--golden requires --once")
+	}
+	if c.UpdateGolden && c.Golden == "" {
+		return fmt.Errorf("--update-golden requires --golden")
+	}
+	if c.MaxDrift < 0 {
+		return fmt.Errorf("max drift must not be negative")
+	}
+
+	// Validate the goroutine alert
+	if c.MaxGoroutinesAlert < 0 {
+		return fmt.Errorf("max goroutines alert must not be negative")
+	}
+	if c.MaxGoroutinesAlert > 0 && !c.Once {
+		return fmt.Errorf("--max-goroutines-alert requires --once")
+	}
+
+	if c.CreatedByTop < 0 {
+		return fmt.Errorf("created-by top must not be negative")
+	}
+
+	if c.GroupDepth < 0 {
+		return fmt.Errorf("group depth must not be negative")
+	}
+
+	if c.MaxGoroutines < 0 {
+		return fmt.Errorf("max goroutines must not be negative")
+	}
+
+	if c.MaxMemory < 0 {
+		return fmt.Errorf("max memory must not be negative")
+	}
+
+	if c.HistoryDepth < 0 {
+		return fmt.Errorf("history depth must not be negative")
+	}
+
+	// Validate the count thresholds
+	if c.WarnCount < 0 || c.CritCount < 0 {
+		return fmt.Errorf("count thresholds must not be negative")
+	}
+	if c.WarnCount > 0 && c.CritCount > 0 && c.CritCount < c.WarnCount {
+		return fmt.Errorf("crit count must not be below warn count")
+	}
+
+	// Validate key bindings
+	for action, keys := range c.KeyBindings {
+		if !slices.Contains(KeyActions, action) {
+			return fmt.Errorf("invalid key binding action: %s (must be one of %s)", action, strings.Join(KeyActions, ", "))
+		}
+		if len(keys) == 0 || slices.Contains(keys, "") {
+			return fmt.Errorf("key binding for %s must list non-empty keys", action)
+		}
+	}
+
+	// Validate state colors, an empty color leaves the state plain
+	for state, color := range c.StateColors {
+		if !slices.Contains(States, state) {
+			return fmt.Errorf("invalid state in state colors: %s (must be one of %s)", state, strings.Join(States, ", "))
+		}
+		if color == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(color); !colorPattern.MatchString(color) || (err == nil && n > 255) {
+			return fmt.Errorf("invalid color for %s: %s (must be 0-255 or #rrggbb)", state, color)
+		}
+	}
+
+	// Validate the startup filters
+	if pattern, ok := strings.CutPrefix(c.Filter, "re:"); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid filter regexp: %w", err)
+		}
+	}
+	if c.FilterState != "" && !slices.Contains(States, c.FilterState) {
+		return fmt.Errorf("invalid filter state: %s (must be one of %s)", c.FilterState, strings.Join(States, ", "))
+	}
+
+	// Validate package frame
+	switch c.PackageFrame {
+	case PackageFrameTop, PackageFrameApp:
+		// valid
+	default:
+		return fmt.Errorf("invalid package frame: %s (must be top or app)", c.PackageFrame)
+	}
+
+	// Validate sort ties
+	switch c.SortTies {
+	case SortTiesTrace, SortTiesID:
+		// valid
+	default:
+		return fmt.Errorf("invalid sort ties: %s (must be trace or id)", c.SortTies)
+	}
+
+	// Validate HTTP debug level
+	switch c.HTTP.DebugLevel {
+	case 1, 2:
+		// valid
+	default:
+		return fmt.Errorf("invalid HTTP debug level: %d (must be 1 or 2)", c.HTTP.DebugLevel)
+	}
+
+	// Validate HTTP retries
+	if c.HTTP.Retries < 0 {
+		return fmt.Errorf("HTTP retries must not be negative")
+	}
+	if c.HTTP.MaxBodySize < 0 {
+		return fmt.Errorf("HTTP max body size must not be negative")
+	}
+	if c.HTTP.RetryBackoff < 0 {
+		return fmt.Errorf("HTTP retry backoff must not be negative")
+	}
+
+	// Validate HTTP concurrency and connection pool
+	if c.HTTP.Workers < 1 {
+		return fmt.Errorf("HTTP workers must be at least 1")
+	}
+	if c.HTTP.MaxIdleConns < 0 {
+		return fmt.Errorf("HTTP max idle connections must not be negative")
+	}
+	if c.HTTP.IdleConnTimeout < 0 {
+		return fmt.Errorf("HTTP idle connection timeout must not be negative")
+	}
+	if c.HTTP.Jitter < 0 || c.HTTP.Jitter >= 1 {
+		return fmt.Errorf("HTTP jitter must be at least 0 and below 1")
+	}
+
+	// Validate HTTP headers
+	if _, err := c.HTTPHeaders(); err != nil {
+		return err
+	}
+
 	// Validate log level
 	switch strings.ToLower(c.Log.Level) {
 	case "debug", "info", "warn", "error":
@@ -142,12 +896,36 @@ func (c *Config) Validate() error {
 	default:
 		return fmt.Errorf("invalid log level: %s", c.Log.Level)
 	}
+	if c.Log.MaxSize < 0 {
+		return fmt.Errorf("--log.max-size must not be negative")
+	}
+	if c.Log.MaxSize > 0 && c.Log.File == "" {
+		return fmt.Errorf("--log.max-size requires --log.file")
+	}
+
+	// Validate alerts
+	for _, rule := range c.Alerts.Rules {
+		if _, err := alert.ParseCondition(rule.Condition); err != nil {
+			return fmt.Errorf("invalid alert %q: %w", rule.Name, err)
+		}
+	}
+	if len(c.Alerts.Rules) > 0 && c.Alerts.Webhook == "" {
+		return fmt.Errorf("alert rules require --alerts.webhook")
+	}
+	if c.Alerts.Debounce < 0 {
+		return fmt.Errorf("--alerts.debounce must not be negative")
+	}
 
 	// Validate TLS config
 	if (c.Web.TLSCert != "" && c.Web.TLSKey == "") || (c.Web.TLSCert == "" && c.Web.TLSKey != "") {
 		return fmt.Errorf("both --web.tls-cert and --web.tls-key must be specified for TLS")
 	}
 
+	// Validate web auth
+	if (c.Web.AuthUser != "") != (c.Web.AuthPass != "") {
+		return fmt.Errorf("both --web.auth-user and --web.auth-pass must be specified for basic auth")
+	}
+
 	// Validate interval
 	if c.Interval < 100*time.Millisecond {
 		return fmt.Errorf("interval must be at least 100ms")
@@ -156,6 +934,59 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// HTTPHeaders returns the headers sent with every target request: the
+// --http.header values plus a bearer token read from the environment
+func (c *Config) HTTPHeaders() (http.Header, error) {
+	headers := make(http.Header)
+	for _, h := range c.HTTP.Headers {
+		key, value, ok := strings.Cut(h, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid HTTP header %q (must be key=value)", h)
+		}
+		headers.Add(key, strings.TrimSpace(value))
+	}
+
+	if c.HTTP.BearerTokenEnv != "" {
+		token := os.Getenv(c.HTTP.BearerTokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("bearer token variable %s is empty or unset", c.HTTP.BearerTokenEnv)
+		}
+		headers.Set("Authorization", "Bearer "+token)
+	}
+
+	if c.HTTP.Instance != "" {
+		headers.Set("X-Goru-Instance", c.HTTP.Instance)
+	}
+
+	return headers, nil
+}
+
+// CollectionTimeout returns how long collecting the slowest target may take
+// when every attempt times out: each retry gets the target's timeout again,
+// after a backoff doubled each time
+func (c *Config) CollectionTimeout() time.Duration {
+	timeout := c.Timeout
+	for _, t := range c.Timeouts {
+		timeout = max(timeout, t)
+	}
+	total := timeout
+	backoff := c.HTTP.RetryBackoff
+	for range c.HTTP.Retries {
+		total += backoff + timeout
+		backoff *= 2
+	}
+	return total
+}
+
 func (c *Config) HasWeb() bool {
 	return c.Mode == ModeWeb || c.Mode == ModeBoth
 }
@@ -163,3 +994,16 @@ func (c *Config) HasWeb() bool {
 func (c *Config) HasTUI() bool {
 	return c.Mode == ModeTUI || c.Mode == ModeBoth
 }
+
+func validateRequest(method, body string) error {
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+		// valid
+	default:
+		return fmt.Errorf("invalid method: %s (must be GET, POST, PUT, or PATCH)", method)
+	}
+	if body != "" && method == http.MethodGet {
+		return fmt.Errorf("a request body requires a method other than GET")
+	}
+	return nil
+}
@@ -2,10 +2,15 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/spf13/pflag"
+
+	"github.com/anyproto/goru/internal/alert"
 )
 
 func TestConfigValidation(t *testing.T) {
@@ -15,61 +20,592 @@ func TestConfigValidation(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "valid with targets",
+			name: "valid with targets",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid with files",
+			setup: func() *Config {
+				c := New()
+				c.Files = []string{"dump.txt"}
+				c.Mode = ModeWeb
+				c.Log.Level = "debug"
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "no sources",
+			setup: func() *Config {
+				c := New()
+				c.Targets = nil
+				c.Files = nil
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "load instead of sources",
+			setup: func() *Config {
+				c := New()
+				c.LoadDir = "incident"
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "load with targets",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.LoadDir = "incident"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "load while recording",
+			setup: func() *Config {
+				c := New()
+				c.LoadDir = "incident"
+				c.RecordDir = "incident"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "load in web mode",
+			setup: func() *Config {
+				c := New()
+				c.LoadDir = "incident"
+				c.Mode = ModeWeb
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid mode",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Mode = "invalid"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid log level",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Log.Level = "invalid"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative log max size",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Log.File = "goru.log"
+				c.Log.MaxSize = -1
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "log max size without file",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Log.MaxSize = 1024
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "alert rules",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Alerts.Webhook = "https://hooks.example.com/goru"
+				c.Alerts.Rules = []alert.Rule{
+					{Name: "busy", Condition: "total_goroutines > 5000"},
+					{Condition: "leak_candidates"},
+				}
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid alert condition",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Alerts.Webhook = "https://hooks.example.com/goru"
+				c.Alerts.Rules = []alert.Rule{{Name: "busy", Condition: "goroutines > many"}}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "alert rules without webhook",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Alerts.Rules = []alert.Rule{{Condition: "total_goroutines > 5000"}}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "interval too small",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Interval = 50 * time.Millisecond
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero target timeout",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Timeouts = map[string]time.Duration{"localhost:8080": 0}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative shutdown timeout",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.ShutdownTimeout = -time.Second
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max memory",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.MaxMemory = -1
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative stale after",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.StaleAfter = -time.Second
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "stale after within the interval",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.StaleAfter = 5 * time.Second
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "stale after",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.StaleAfter = time.Minute
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "key bindings",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.KeyBindings = map[string][]string{"filter": {"/"}, "up": {"k"}, "down": {"j"}}
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown key binding action",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.KeyBindings = map[string][]string{"fly": {"F"}}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty key binding",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.KeyBindings = map[string][]string{"quit": {}}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "state colors",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.StateColors = map[string]string{"running": "33", "blocked": "#ff8800", "syscall": ""}
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown state color state",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.StateColors = map[string]string{"sleeping": "33"}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid state color",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.StateColors = map[string]string{"running": "300"}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "goroutine alert without once",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.MaxGoroutinesAlert = 1000
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "since start diff mode",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.DiffMode = DiffStart
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid diff mode",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.DiffMode = "invalid"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid no-tty mode",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.NoTTY = "invalid"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid package frame",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.PackageFrame = "bottom"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "post with body",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Method = "post"
+				c.Body = "profile=goroutine"
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid method",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Method = "TRACE"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "target body with inherited GET",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Requests = map[string]TargetRequest{
+					"localhost:8080": {Body: "profile=goroutine"},
+				}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "golden with once",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Once = true
+				c.Golden = "expected.json"
+				c.MaxDrift = 5
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "golden without once",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Golden = "expected.json"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "https target",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"https://localhost:8443", "localhost:8080"}
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported target scheme",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"ftp://localhost:21"}
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative group depth",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.GroupDepth = -1
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative history depth",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.HistoryDepth = -1
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "count thresholds",
 			setup: func() *Config {
 				c := New()
 				c.Targets = []string{"localhost:8080"}
+				c.WarnCount = 1000
+				c.CritCount = 5000
 				return c
 			},
 			wantErr: false,
 		},
 		{
-			name: "valid with files",
+			name: "crit count below warn count",
 			setup: func() *Config {
 				c := New()
-				c.Files = []string{"dump.txt"}
-				c.Mode = ModeWeb
-				c.Log.Level = "debug"
+				c.Targets = []string{"localhost:8080"}
+				c.WarnCount = 5000
+				c.CritCount = 1000
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative warn count",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.WarnCount = -1
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "debug level 1",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.HTTP.DebugLevel = 1
 				return c
 			},
 			wantErr: false,
 		},
 		{
-			name: "no sources",
+			name: "negative retries",
 			setup: func() *Config {
 				c := New()
-				c.Targets = nil
-				c.Files = nil
+				c.Targets = []string{"localhost:8080"}
+				c.HTTP.Retries = -1
 				return c
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid mode",
+			name: "startup filters",
 			setup: func() *Config {
 				c := New()
 				c.Targets = []string{"localhost:8080"}
-				c.Mode = "invalid"
+				c.Filter = "re:^main\\."
+				c.FilterState = "blocked"
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid filter regexp",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Filter = "re:("
 				return c
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid log level",
+			name: "invalid filter state",
 			setup: func() *Config {
 				c := New()
 				c.Targets = []string{"localhost:8080"}
-				c.Log.Level = "invalid"
+				c.FilterState = "sleeping"
 				return c
 			},
 			wantErr: true,
 		},
 		{
-			name: "interval too small",
+			name: "HTTP jitter",
 			setup: func() *Config {
 				c := New()
 				c.Targets = []string{"localhost:8080"}
-				c.Interval = 50 * time.Millisecond
+				c.HTTP.Jitter = 0.2
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "HTTP jitter of a whole interval",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.HTTP.Jitter = 1
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid sort ties",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.SortTies = "random"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "no HTTP workers",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.HTTP.Workers = 0
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative idle connection timeout",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.HTTP.IdleConnTimeout = -time.Second
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid debug level",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.HTTP.DebugLevel = 3
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid file time",
+			setup: func() *Config {
+				c := New()
+				c.Files = []string{"dump.txt"}
+				c.FileTime = "ctime"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "latest dump of multi-dump files",
+			setup: func() *Config {
+				c := New()
+				c.Files = []string{"dump.txt"}
+				c.FilesMulti = FileMultiLatest
+				return c
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid files multi",
+			setup: func() *Config {
+				c := New()
+				c.Files = []string{"dump.txt"}
+				c.FilesMulti = "first"
 				return c
 			},
 			wantErr: true,
@@ -85,6 +621,30 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "web auth user without password",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Mode = ModeWeb
+				c.Web.AuthUser = "admin"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "web auth",
+			setup: func() *Config {
+				c := New()
+				c.Targets = []string{"localhost:8080"}
+				c.Mode = ModeWeb
+				c.Web.AuthUser = "admin"
+				c.Web.AuthPass = "secret"
+				c.Web.AuthToken = "token"
+				return c
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -98,6 +658,305 @@ func TestConfigValidation(t *testing.T) {
 	}
 }
 
+func TestConfigTargetIntervals(t *testing.T) {
+	c := New()
+	c.Targets = []string{"fast:8080@2s", "https://user@slow:8443@30s", "plain:8080"}
+	c.Intervals = map[string]time.Duration{"plain:8080": 5 * time.Second}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	wantTargets := []string{"fast:8080", "https://user@slow:8443", "plain:8080"}
+	for i, want := range wantTargets {
+		if c.Targets[i] != want {
+			t.Errorf("Targets[%d] = %q, want %q", i, c.Targets[i], want)
+		}
+	}
+	wantIntervals := map[string]time.Duration{
+		"fast:8080":              2 * time.Second,
+		"https://user@slow:8443": 30 * time.Second,
+		"plain:8080":             5 * time.Second,
+	}
+	for target, want := range wantIntervals {
+		if got := c.Intervals[target]; got != want {
+			t.Errorf("Intervals[%s] = %v, want %v", target, got, want)
+		}
+	}
+
+	c = New()
+	c.Targets = []string{"fast:8080@10ms"}
+	if err := c.Validate(); err == nil {
+		t.Error("Expected an error for a target interval below 100ms")
+	}
+}
+
+func TestConfigReloadSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goru.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("targets: [a:1, b:2]\nfiles: [dump.txt]\n")
+
+	c := New()
+	c.ConfigFile = path
+	if err := c.loadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	write("targets: [b:2, c:3@30s]\n")
+	next, err := c.ReloadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(next.Targets) != 2 || next.Targets[0] != "b:2" || next.Targets[1] != "c:3" {
+		t.Errorf("Targets = %v, want [b:2 c:3]", next.Targets)
+	}
+	if next.Intervals["c:3"] != 30*time.Second {
+		t.Errorf("Intervals = %v, want c:3 every 30s", next.Intervals)
+	}
+	if len(next.Files) != 0 {
+		t.Errorf("Files = %v, want none", next.Files)
+	}
+	// The running config is left alone
+	if len(c.Targets) != 2 || c.Targets[0] != "a:1" {
+		t.Errorf("Original targets changed to %v", c.Targets)
+	}
+
+	write("targets: []\n")
+	if _, err := c.ReloadSources(); err == nil {
+		t.Error("Expected an error when the reloaded config has no sources")
+	}
+}
+
+func TestConfigTargetsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "targets.txt")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"lines", "# fleet\nb:2\n\n  c:3@30s  \n", []string{"b:2", "c:3@30s"}},
+		{"yaml list", "- b:2\n- https://c:3\n", []string{"b:2", "https://c:3"}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write(tt.content)
+			got, _, err := readTargetsFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readTargetsFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// File targets are added to the others, once
+	write("a:1\nb:2\n")
+	c := New()
+	c.Targets = []string{"a:1"}
+	c.TargetsFile = path
+	if err := c.loadTargetsFile(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(c.Targets, []string{"a:1", "b:2"}) {
+		t.Errorf("Targets = %v, want [a:1 b:2]", c.Targets)
+	}
+
+	// A reload re-reads the file
+	write("c:3\n")
+	next, err := c.ReloadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(next.Targets, []string{"c:3"}) {
+		t.Errorf("Reloaded targets = %v, want [c:3]", next.Targets)
+	}
+
+	c.TargetsFile = filepath.Join(dir, "missing.txt")
+	if err := c.loadTargetsFile(); err == nil {
+		t.Error("Expected an error for a missing targets file")
+	}
+}
+
+func TestConfigTargetLabels(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "goru.yaml")
+	content := `targets:
+  - plain:8080
+  - addr: 1.2.3.4:6060
+    labels: {env: prod, region: us}
+  - addr: staging:6060@30s
+    labels: {env: staging}
+target_labels:
+  plain:8080: {env: dev}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New()
+	if err := c.loadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(c.Targets, []string{"plain:8080", "1.2.3.4:6060", "staging:6060"}) {
+		t.Errorf("Targets = %v", c.Targets)
+	}
+	want := map[string]map[string]string{
+		"plain:8080":   {"env": "dev"},
+		"1.2.3.4:6060": {"env": "prod", "region": "us"},
+		"staging:6060": {"env": "staging"},
+	}
+	if !reflect.DeepEqual(c.TargetLabels, want) {
+		t.Errorf("TargetLabels = %v, want %v", c.TargetLabels, want)
+	}
+	if c.Intervals["staging:6060"] != 30*time.Second {
+		t.Errorf("Intervals = %v, want staging:6060 every 30s", c.Intervals)
+	}
+
+	// The targets file takes the same form
+	targetsPath := filepath.Join(dir, "targets.yaml")
+	if err := os.WriteFile(targetsPath, []byte("- a:1\n- addr: b:2\n  labels: {env: prod}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	targets, labels, err := readTargetsFile(targetsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(targets, []string{"a:1", "b:2"}) || labels["b:2"]["env"] != "prod" || labels["a:1"] != nil {
+		t.Errorf("readTargetsFile() = %v, %v", targets, labels)
+	}
+
+	if err := os.WriteFile(path, []byte("targets:\n  - labels: {env: prod}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := New().loadFromFile(path); err == nil {
+		t.Error("Expected an error for a target without an addr")
+	}
+}
+
+func TestConfigCollectionTimeout(t *testing.T) {
+	c := New()
+	c.Timeout = time.Second
+	c.HTTP.Retries = 0
+	if got := c.CollectionTimeout(); got != time.Second {
+		t.Errorf("CollectionTimeout() without retries = %v, want 1s", got)
+	}
+
+	// Three attempts of the slowest target's timeout, with backoffs of 100ms
+	// and 200ms between them
+	c.Timeouts = map[string]time.Duration{"slow:1": 2 * time.Second}
+	c.HTTP.Retries = 2
+	c.HTTP.RetryBackoff = 100 * time.Millisecond
+	if got, want := c.CollectionTimeout(), 6300*time.Millisecond; got != want {
+		t.Errorf("CollectionTimeout() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigRequestMethods(t *testing.T) {
+	c := New()
+	c.Targets = []string{"localhost:8080", "localhost:8081"}
+	c.Method = "post"
+	c.Requests = map[string]TargetRequest{
+		"localhost:8080": {Method: "put"},
+		"localhost:8081": {Body: "profile=goroutine"},
+	}
+
+	// Validation accepts lower-case methods without rewriting them
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if c.Method != "post" || c.Requests["localhost:8081"].Method != "" {
+		t.Errorf("Validate() changed the config: method %q, requests %v", c.Method, c.Requests)
+	}
+
+	c.normalizeRequests()
+	if c.Method != "POST" {
+		t.Errorf("Method = %q, want POST", c.Method)
+	}
+	if got := c.Requests["localhost:8080"].Method; got != "PUT" {
+		t.Errorf("Method for localhost:8080 = %q, want PUT", got)
+	}
+	if got := c.Requests["localhost:8081"].Method; got != "POST" {
+		t.Errorf("Method for localhost:8081 = %q, want the inherited POST", got)
+	}
+}
+
+func TestConfigHTTPHeaders(t *testing.T) {
+	os.Setenv("GORU_TEST_TOKEN", "secret")
+	defer os.Unsetenv("GORU_TEST_TOKEN")
+
+	c := New()
+	c.HTTP.Headers = []string{"X-Team=infra", "X-Env = prod"}
+	c.HTTP.BearerTokenEnv = "GORU_TEST_TOKEN"
+
+	headers, err := c.HTTPHeaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := headers.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer secret")
+	}
+	if got := headers.Get("X-Team"); got != "infra" {
+		t.Errorf("X-Team = %q, want %q", got, "infra")
+	}
+	if got := headers.Get("X-Env"); got != "prod" {
+		t.Errorf("X-Env = %q, want %q", got, "prod")
+	}
+	if got := headers.Get("X-Goru-Instance"); got != "" {
+		t.Errorf("Expected no X-Goru-Instance by default, got %q", got)
+	}
+
+	c.HTTP.Instance = "laptop-1"
+	if headers, err = c.HTTPHeaders(); err != nil {
+		t.Fatal(err)
+	}
+	if got := headers.Get("X-Goru-Instance"); got != "laptop-1" {
+		t.Errorf("X-Goru-Instance = %q, want %q", got, "laptop-1")
+	}
+
+	c.HTTP.Headers = []string{"missing-separator"}
+	if _, err := c.HTTPHeaders(); err == nil {
+		t.Error("Expected an error for a header without a value")
+	}
+
+	c.HTTP.Headers = nil
+	c.HTTP.BearerTokenEnv = "GORU_TEST_UNSET_TOKEN"
+	if _, err := c.HTTPHeaders(); err == nil {
+		t.Error("Expected an error for an unset token variable")
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	valid := []string{"localhost:6060", "10.0.0.1:80", "[::1]:6060", "https://app:443"}
+	for _, target := range valid {
+		if err := ValidateTarget(target); err != nil {
+			t.Errorf("ValidateTarget(%q) = %v", target, err)
+		}
+	}
+	invalid := []string{"", "localhost", ":6060", "host:0", "host:70000", "host:http", "ftp://host:21"}
+	for _, target := range invalid {
+		if err := ValidateTarget(target); err == nil {
+			t.Errorf("Expected ValidateTarget(%q) to fail", target)
+		}
+	}
+}
+
 func TestConfigModes(t *testing.T) {
 	tests := []struct {
 		mode   Mode
@@ -146,3 +1005,22 @@ func TestConfigPrecedence(t *testing.T) {
 		t.Errorf("Log.Level = %v, want error (flag should override env)", c.Log.Level)
 	}
 }
+
+func TestConfigRepeatableFlags(t *testing.T) {
+	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+
+	c := New()
+	os.Args = []string{"test", "--targets=a:1,b:2", "--targets=c:3", "--http.header=X-A=1", "--http.header=X-B=2"}
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Flags are applied on top of the config file and env vars without
+	// repeating their values
+	if len(c.Targets) != 3 {
+		t.Errorf("Targets = %v, want 3 targets", c.Targets)
+	}
+	if len(c.HTTP.Headers) != 2 {
+		t.Errorf("HTTP.Headers = %v, want 2 headers", c.HTTP.Headers)
+	}
+}
@@ -1,6 +1,8 @@
 package diff
 
 import (
+	"sort"
+
 	"github.com/anyproto/goru/pkg/model"
 )
 
@@ -21,6 +23,7 @@ func (d *Diff) Compare(old, new *model.Snapshot) *model.ChangeSet {
 		for _, group := range new.Groups {
 			changes.Added = append(changes.Added, group)
 		}
+		sortByID(changes.Added)
 		return changes
 	}
 
@@ -43,24 +46,67 @@ func (d *Diff) Compare(old, new *model.Snapshot) *model.ChangeSet {
 		}
 	}
 
+	// List groups by ID so changes come out the same way every time
+	sortByID(changes.Added)
+	sortByID(changes.Removed)
+	changes.Transitioned = transitions(changes.Removed, changes.Added)
+
 	return changes
 }
 
+func sortByID(groups []*model.Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID < groups[j].ID
+	})
+}
+
+// transitions pairs each added group with the removed groups that share its
+// trace. Since a group's ID covers its state and trace, a match can only
+// differ in state.
+func transitions(removed, added []*model.Group) []model.Transition {
+	if len(removed) == 0 || len(added) == 0 {
+		return nil
+	}
+
+	byTrace := make(map[string][]*model.Group, len(removed))
+	for _, g := range removed {
+		trace := g.Trace.String()
+		byTrace[trace] = append(byTrace[trace], g)
+	}
+
+	var result []model.Transition
+	for _, to := range added {
+		for _, from := range byTrace[to.Trace.String()] {
+			result = append(result, model.Transition{From: from, To: to})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].To.ID != result[j].To.ID {
+			return result[i].To.ID < result[j].To.ID
+		}
+		return result[i].From.ID < result[j].From.ID
+	})
+	return result
+}
+
 // DiffStats provides statistics about the differences
 type DiffStats struct {
-	TotalAdded        int
-	TotalRemoved      int
-	GroupsAdded       int
-	GroupsRemoved     int
-	GroupsWithChanges int
+	TotalAdded         int `json:"total_added"`
+	TotalRemoved       int `json:"total_removed"`
+	GroupsAdded        int `json:"groups_added"`
+	GroupsRemoved      int `json:"groups_removed"`
+	GroupsWithChanges  int `json:"groups_with_changes"`
+	GroupsTransitioned int `json:"groups_transitioned"`
 }
 
 // Stats computes statistics for a changeset
 func (d *Diff) Stats(changes *model.ChangeSet) DiffStats {
 	stats := DiffStats{
-		GroupsAdded:       len(changes.Added),
-		GroupsRemoved:     len(changes.Removed),
-		GroupsWithChanges: len(changes.Updated),
+		GroupsAdded:        len(changes.Added),
+		GroupsRemoved:      len(changes.Removed),
+		GroupsWithChanges:  len(changes.Updated),
+		GroupsTransitioned: len(changes.Transitioned),
 	}
 
 	// Count total goroutines added
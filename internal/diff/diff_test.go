@@ -207,6 +207,47 @@ func TestDiffNoChanges(t *testing.T) {
 	}
 }
 
+func TestDiffCompareTransitions(t *testing.T) {
+	d := New()
+
+	trace := model.StackTrace{{Func: "main.worker"}}
+	running := &model.Group{State: model.StateRunning, Count: 4, Trace: trace}
+	running.ID = running.GenerateID()
+	other := &model.Group{State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.gone"}}}
+	other.ID = other.GenerateID()
+
+	waiting := &model.Group{State: model.StateWaiting, Count: 4, Trace: trace}
+	waiting.ID = waiting.GenerateID()
+	fresh := &model.Group{State: model.StateWaiting, Count: 2, Trace: model.StackTrace{{Func: "main.fresh"}}}
+	fresh.ID = fresh.GenerateID()
+
+	oldSnapshot := model.NewSnapshot("test-host")
+	oldSnapshot.Groups[running.ID] = running
+	oldSnapshot.Groups[other.ID] = other
+
+	newSnapshot := model.NewSnapshot("test-host")
+	newSnapshot.Groups[waiting.ID] = waiting
+	newSnapshot.Groups[fresh.ID] = fresh
+
+	changes := d.Compare(oldSnapshot, newSnapshot)
+
+	if len(changes.Transitioned) != 1 {
+		t.Fatalf("Expected 1 transition, got %d", len(changes.Transitioned))
+	}
+	tr := changes.Transitioned[0]
+	if tr.From != running || tr.To != waiting {
+		t.Errorf("Expected running -> waiting transition, got %s -> %s", tr.From.State, tr.To.State)
+	}
+
+	// Transitioned groups are still reported as added and removed
+	if len(changes.Added) != 2 || len(changes.Removed) != 2 {
+		t.Errorf("Expected 2 added and 2 removed groups, got %d and %d", len(changes.Added), len(changes.Removed))
+	}
+	if stats := d.Stats(changes); stats.GroupsTransitioned != 1 {
+		t.Errorf("GroupsTransitioned = %d, want 1", stats.GroupsTransitioned)
+	}
+}
+
 func BenchmarkDiffCompare(b *testing.B) {
 	d := New()
 
@@ -0,0 +1,92 @@
+package golden
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/anyproto/goru/internal/diff"
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// Violation is a difference between a golden snapshot and a live one that
+// exceeds the allowed drift
+type Violation struct {
+	Group    *model.Group
+	Expected int // count in the golden snapshot, 0 for unexpected groups
+	Actual   int // count in the live snapshot, 0 for missing groups
+}
+
+func (v Violation) String() string {
+	function := model.NoStack
+	if len(v.Group.Trace) > 0 {
+		function = v.Group.Trace[0].Func
+	}
+	switch {
+	case v.Expected == 0:
+		return fmt.Sprintf("unexpected group %s (%s %s): %d goroutines", v.Group.ID, v.Group.State, function, v.Actual)
+	case v.Actual == 0:
+		return fmt.Sprintf("missing group %s (%s %s): expected %d goroutines", v.Group.ID, v.Group.State, function, v.Expected)
+	default:
+		return fmt.Sprintf("group %s (%s %s) drifted: expected %d, got %d", v.Group.ID, v.Group.State, function, v.Expected, v.Actual)
+	}
+}
+
+// Load reads a golden snapshot written by Save
+func Load(path string) (*model.Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot model.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	if snapshot.Groups == nil {
+		snapshot.Groups = make(map[model.GroupID]*model.Group)
+	}
+	return &snapshot, nil
+}
+
+// Save writes a snapshot as a golden file
+func Save(path string, snapshot *model.Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Check compares a live snapshot against a golden one. Groups that appear
+// only in the live snapshot are always violations; count changes, including
+// groups that disappeared, are violations once they exceed maxDrift.
+// Violations are ordered by group ID.
+func Check(golden, live *model.Snapshot, maxDrift int) []Violation {
+	changes := diff.New().Compare(golden, live)
+
+	var violations []Violation
+	for _, g := range changes.Added {
+		violations = append(violations, Violation{Group: g, Actual: g.Count})
+	}
+	for _, g := range changes.Removed {
+		if g.Count > maxDrift {
+			violations = append(violations, Violation{Group: g, Expected: g.Count})
+		}
+	}
+	for id, delta := range changes.Updated {
+		if delta > maxDrift || -delta > maxDrift {
+			violations = append(violations, Violation{
+				Group:    live.Groups[id],
+				Expected: golden.Groups[id].Count,
+				Actual:   live.Groups[id].Count,
+			})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Group.ID < violations[j].Group.ID
+	})
+	return violations
+}
@@ -0,0 +1,100 @@
+package golden
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+func TestCheck(t *testing.T) {
+	golden := &model.Snapshot{
+		Host: "golden",
+		Groups: map[model.GroupID]*model.Group{
+			"a": {ID: "a", State: model.StateWaiting, Count: 10, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"b": {ID: "b", State: model.StateBlocked, Count: 2, Trace: model.StackTrace{{Func: "main.handler"}}},
+			"c": {ID: "c", State: model.StateRunning, Count: 8, Trace: model.StackTrace{{Func: "main.poller"}}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		groups   map[model.GroupID]*model.Group
+		maxDrift int
+		expected []model.GroupID
+	}{
+		{
+			name: "identical",
+			groups: map[model.GroupID]*model.Group{
+				"a": {ID: "a", Count: 10},
+				"b": {ID: "b", Count: 2},
+				"c": {ID: "c", Count: 8},
+			},
+			expected: nil,
+		},
+		{
+			name: "drift within tolerance",
+			groups: map[model.GroupID]*model.Group{
+				"a": {ID: "a", Count: 14},
+				"c": {ID: "c", Count: 5},
+			},
+			maxDrift: 5,
+			expected: nil,
+		},
+		{
+			name: "drift beyond tolerance",
+			groups: map[model.GroupID]*model.Group{
+				"a": {ID: "a", Count: 16},
+				"b": {ID: "b", Count: 2},
+			},
+			maxDrift: 5,
+			expected: []model.GroupID{"a", "c"},
+		},
+		{
+			name: "unexpected group",
+			groups: map[model.GroupID]*model.Group{
+				"a": {ID: "a", Count: 10},
+				"b": {ID: "b", Count: 2},
+				"c": {ID: "c", Count: 8},
+				"d": {ID: "d", Count: 1},
+			},
+			maxDrift: 5,
+			expected: []model.GroupID{"d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := &model.Snapshot{Host: "live", Groups: tt.groups}
+			violations := Check(golden, live, tt.maxDrift)
+			if len(violations) != len(tt.expected) {
+				t.Fatalf("Expected %d violations, got %v", len(tt.expected), violations)
+			}
+			for i, v := range violations {
+				if v.Group.ID != tt.expected[i] {
+					t.Errorf("Violation %d is for group %s, want %s", i, v.Group.ID, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden.json")
+
+	snapshot := model.NewSnapshot("host1")
+	snapshot.AddGoroutine(1, model.StateWaiting, model.StackTrace{{Func: "main.worker", File: "main.go", Line: 10}}, "", nil)
+	snapshot.AddGoroutine(2, model.StateWaiting, model.StackTrace{{Func: "main.worker", File: "main.go", Line: 10}}, "", nil)
+
+	if err := Save(path, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if violations := Check(loaded, snapshot, 0); len(violations) != 0 {
+		t.Errorf("Expected a round-tripped snapshot to match, got %v", violations)
+	}
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// states are the goroutine states exported for every host, so a state
+// reads as 0 rather than disappearing when no goroutine is in it
+var states = []model.GoroutineState{
+	model.StateRunning,
+	model.StateRunnable,
+	model.StateBlocked,
+	model.StateWaiting,
+	model.StateSyscall,
+	model.StateUnknown,
+}
+
+// Handler serves the store's per-host gauges in the Prometheus text format
+func Handler(s *store.Store, logger telemetry.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := Write(w, s); err != nil {
+			logger.Error("writing metrics", telemetry.Error(err))
+		}
+	})
+}
+
+// Write writes the latest snapshot of every host as Prometheus metrics.
+// Hosts without a snapshot only report their failed collections.
+func Write(w io.Writer, s *store.Store) error {
+	snapshots := s.GetAllSnapshots()
+	failures := s.GetFailureCounts()
+
+	hosts := s.GetAllHosts()
+	sort.Strings(hosts)
+
+	bw := bufio.NewWriter(w)
+	writeHeader(bw, "goru_goroutines", "gauge", "Goroutines in the latest snapshot of the host.")
+	for _, host := range hosts {
+		if snapshot := snapshots[host]; snapshot != nil {
+			fmt.Fprintf(bw, "goru_goroutines{host=\"%s\"} %d\n", escape(host), snapshot.TotalGoroutines())
+		}
+	}
+
+	writeHeader(bw, "goru_groups", "gauge", "Goroutine groups in the latest snapshot of the host.")
+	for _, host := range hosts {
+		if snapshot := snapshots[host]; snapshot != nil {
+			fmt.Fprintf(bw, "goru_groups{host=\"%s\"} %d\n", escape(host), len(snapshot.Groups))
+		}
+	}
+
+	writeHeader(bw, "goru_goroutines_by_state", "gauge", "Goroutines in the latest snapshot of the host by state.")
+	for _, host := range hosts {
+		snapshot := snapshots[host]
+		if snapshot == nil {
+			continue
+		}
+		counts := snapshot.StateCounts()
+		for _, state := range states {
+			fmt.Fprintf(bw, "goru_goroutines_by_state{host=\"%s\",state=\"%s\"} %d\n", escape(host), state, counts[state])
+		}
+	}
+
+	stats := s.GetStats()
+	writeHeader(bw, "goru_fleet_goroutines", "gauge", "Goroutines in the latest snapshots of all hosts.")
+	fmt.Fprintf(bw, "goru_fleet_goroutines %d\n", stats.TotalGoroutines)
+
+	writeHeader(bw, "goru_fleet_goroutines_by_state", "gauge", "Goroutines in the latest snapshots of all hosts by state.")
+	for _, state := range states {
+		fmt.Fprintf(bw, "goru_fleet_goroutines_by_state{state=\"%s\"} %d\n", state, stats.GoroutinesByState[state])
+	}
+
+	writeHeader(bw, "goru_collection_errors_total", "counter", "Failed collections from the host.")
+	for _, host := range hosts {
+		fmt.Fprintf(bw, "goru_collection_errors_total{host=\"%s\"} %d\n", escape(host), failures[host])
+	}
+
+	writeHeader(bw, "goru_dropped_updates_total", "counter", "Store updates missed by views that fell behind.")
+	fmt.Fprintf(bw, "goru_dropped_updates_total %d\n", stats.DroppedUpdates)
+
+	writeHeader(bw, "goru_skipped_updates_total", "counter", "Collections left out of the store because nothing changed.")
+	fmt.Fprintf(bw, "goru_skipped_updates_total %d\n", stats.SkippedUpdates)
+
+	writeHeader(bw, "goru_interned_traces", "gauge", "Distinct stack traces shared between stored snapshots.")
+	fmt.Fprintf(bw, "goru_interned_traces %d\n", stats.InternedTraces)
+
+	writeHeader(bw, "goru_trace_dedup_ratio", "gauge", "Share of stored group traces that reuse an equal trace already held.")
+	fmt.Fprintf(bw, "goru_trace_dedup_ratio %g\n", stats.DedupRatio())
+
+	writeHeader(bw, "goru_store_estimated_bytes", "gauge", "Estimated memory taken by the snapshots kept.")
+	fmt.Fprintf(bw, "goru_store_estimated_bytes %d\n", stats.EstimatedBytes)
+
+	writeHeader(bw, "goru_evicted_snapshots_total", "counter", "History snapshots dropped to stay within --max-memory.")
+	fmt.Fprintf(bw, "goru_evicted_snapshots_total %d\n", stats.EvictedSnapshots)
+	return bw.Flush()
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape quotes a label value as the text format requires
+func escape(value string) string {
+	return escaper.Replace(value)
+}
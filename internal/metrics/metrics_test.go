@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+func TestHandler(t *testing.T) {
+	s := store.New()
+	s.RegisterHosts([]string{"host1", "host2"})
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 10},
+			"g2": {ID: "g2", State: model.StateRunning, Count: 2},
+			"g3": {ID: "g3", State: model.StateBlocked, Count: 1},
+		},
+	}, nil)
+	s.UpdateError("host2", errors.New("connection refused"))
+	s.UpdateError("host2", errors.New("connection refused"))
+
+	rec := httptest.NewRecorder()
+	Handler(s, telemetry.NewLogger("error", false)).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+
+	for _, want := range []string{
+		"# TYPE goru_goroutines gauge\n",
+		`goru_goroutines{host="host1"} 13`,
+		`goru_groups{host="host1"} 3`,
+		`goru_goroutines_by_state{host="host1",state="blocked"} 11`,
+		`goru_goroutines_by_state{host="host1",state="running"} 2`,
+		`goru_goroutines_by_state{host="host1",state="waiting"} 0`,
+		"goru_fleet_goroutines 13\n",
+		`goru_fleet_goroutines_by_state{state="blocked"} 11`,
+		`goru_fleet_goroutines_by_state{state="waiting"} 0`,
+		"# TYPE goru_collection_errors_total counter\n",
+		`goru_collection_errors_total{host="host1"} 0`,
+		`goru_collection_errors_total{host="host2"} 2`,
+		"goru_dropped_updates_total 0\n",
+		"goru_interned_traces 0\n",
+		"goru_trace_dedup_ratio 0\n",
+		"goru_evicted_snapshots_total 0\n",
+		"# TYPE goru_store_estimated_bytes gauge\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Hosts without a snapshot have no gauges
+	if strings.Contains(out, `goru_goroutines{host="host2"}`) {
+		t.Errorf("Host without a snapshot should have no gauges:\n%s", out)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if got := escape("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escape() = %q", got)
+	}
+}
@@ -4,30 +4,41 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anyproto/goru/internal/collector"
 	"github.com/anyproto/goru/internal/collector/http"
-	"github.com/anyproto/goru/internal/diff"
-	"github.com/anyproto/goru/internal/store"
 	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
 )
 
 // Orchestrator coordinates collectors, diff computation, and store updates
 type Orchestrator struct {
 	sources []collector.Source
 	store   *store.Store
-	diff    *diff.Diff
 
-	// Track previous snapshots for diff computation
+	// Track the latest snapshot per host, and its hash
 	mu            sync.RWMutex
 	lastSnapshots map[string]*model.Snapshot
-	
+	lastHashes    map[string]string
+
 	// Centralized refresh control
 	refreshCh chan struct{}
 	interval  time.Duration
 	paused    bool
 	pauseMu   sync.RWMutex
+
+	// Hosts without a successful collection for this long are flagged
+	// stale in the store, 0 to never flag them
+	staleAfter time.Duration
+
+	// Graceful shutdown: stopCh ends the refresh controller, and drained is
+	// closed once every source has finished and its snapshots are stored
+	draining atomic.Bool
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	drained  chan struct{}
 }
 
 // New creates a new orchestrator
@@ -35,10 +46,12 @@ func New(store *store.Store, interval time.Duration, sources ...collector.Source
 	return &Orchestrator{
 		sources:       sources,
 		store:         store,
-		diff:          diff.New(),
 		lastSnapshots: make(map[string]*model.Snapshot),
+		lastHashes:    make(map[string]string),
 		refreshCh:     make(chan struct{}, 1), // Buffered to avoid blocking
 		interval:      interval,
+		stopCh:        make(chan struct{}),
+		drained:       make(chan struct{}),
 	}
 }
 
@@ -56,6 +69,14 @@ func (o *Orchestrator) Start(ctx context.Context) error {
 
 	// Start each source
 	for i, source := range o.sources {
+		// Push per-host collection status straight into the store as it happens
+		if reporter, ok := source.(collector.AttemptReporter); ok {
+			reporter.SetAttemptHandler(o.store.MarkInFlight)
+		}
+		if reporter, ok := source.(collector.ErrorReporter); ok {
+			reporter.SetErrorHandler(o.store.UpdateError)
+		}
+
 		ch := make(chan *model.Snapshot, 10)
 		channels[i] = ch
 
@@ -74,12 +95,13 @@ func (o *Orchestrator) Start(ctx context.Context) error {
 	// Start processing snapshots
 	go o.processSnapshots(ctx, channels)
 
-	// Start error monitoring for HTTP sources
-	go o.monitorErrors(ctx)
-	
 	// Start centralized refresh controller
 	go o.refreshController(ctx)
 
+	if o.staleAfter > 0 {
+		go o.watchStale(ctx)
+	}
+
 	// Wait for completion
 	go func() {
 		wg.Wait()
@@ -125,6 +147,7 @@ func (o *Orchestrator) processSnapshots(ctx context.Context, channels []<-chan *
 		select {
 		case snapshot, ok := <-merged:
 			if !ok {
+				close(o.drained)
 				return
 			}
 			o.handleSnapshot(snapshot)
@@ -135,20 +158,24 @@ func (o *Orchestrator) processSnapshots(ctx context.Context, channels []<-chan *
 }
 
 func (o *Orchestrator) handleSnapshot(snapshot *model.Snapshot) {
-	// Get previous snapshot
+	// An idle host gives the same snapshot every time, which would only
+	// churn the store and redraw the UI
+	hash := snapshot.Hash()
 	o.mu.RLock()
-	lastSnapshot := o.lastSnapshots[snapshot.Host]
+	last, seen := o.lastHashes[snapshot.Host]
 	o.mu.RUnlock()
+	if seen && hash == last && o.store.GetSnapshot(snapshot.Host) != nil {
+		o.store.MarkUnchanged(snapshot.Host)
+		return
+	}
 
-	// Compute diff
-	changeSet := o.diff.Compare(lastSnapshot, snapshot)
-
-	// Update store
-	o.store.UpdateSnapshot(snapshot, changeSet)
+	// Diff against the previous snapshot and update the store
+	o.store.Ingest(snapshot)
 
 	// Update last snapshot
 	o.mu.Lock()
 	o.lastSnapshots[snapshot.Host] = snapshot
+	o.lastHashes[snapshot.Host] = hash
 	o.mu.Unlock()
 }
 
@@ -156,7 +183,11 @@ func (o *Orchestrator) handleSnapshot(snapshot *model.Snapshot) {
 type Stats struct {
 	ActiveSources  int
 	HostsMonitored int
-	StoreStats     store.Stats
+	// The goroutine population across all hosts, in total and by state,
+	// from the store's latest snapshots
+	TotalGoroutines   int
+	GoroutinesByState map[model.GoroutineState]int
+	StoreStats        store.Stats
 }
 
 func (o *Orchestrator) GetStats() Stats {
@@ -164,15 +195,21 @@ func (o *Orchestrator) GetStats() Stats {
 	hostsMonitored := len(o.lastSnapshots)
 	o.mu.RUnlock()
 
+	storeStats := o.store.GetStats()
 	return Stats{
-		ActiveSources:  len(o.sources),
-		HostsMonitored: hostsMonitored,
-		StoreStats:     o.store.GetStats(),
+		ActiveSources:     len(o.sources),
+		HostsMonitored:    hostsMonitored,
+		TotalGoroutines:   storeStats.TotalGoroutines,
+		GoroutinesByState: storeStats.GoroutinesByState,
+		StoreStats:        storeStats,
 	}
 }
 
 // TriggerRefresh manually triggers a refresh for all sources
 func (o *Orchestrator) TriggerRefresh() {
+	if o.draining.Load() {
+		return
+	}
 	select {
 	case o.refreshCh <- struct{}{}:
 		// Refresh triggered
@@ -181,6 +218,44 @@ func (o *Orchestrator) TriggerRefresh() {
 	}
 }
 
+// TriggerRefreshHost manually triggers a refresh of a single host, regardless
+// of the pause state. It reports false if no source manages the host, or
+// during shutdown.
+func (o *Orchestrator) TriggerRefreshHost(host string) bool {
+	if o.draining.Load() {
+		return false
+	}
+	for _, source := range o.sources {
+		if refresher, ok := source.(collector.HostRefresher); ok && refresher.TriggerRefreshHost(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Shutdown stops starting new collections and waits until the ones in
+// flight have finished and their snapshots are in the store, so the store
+// keeps the last complete state. It gives up when ctx is done, e.g. after a
+// grace period; the caller then cancels the context passed to Start to abort
+// what is left. Sources that can't drain keep Shutdown waiting until then.
+func (o *Orchestrator) Shutdown(ctx context.Context) error {
+	o.draining.Store(true)
+	o.stopOnce.Do(func() { close(o.stopCh) })
+
+	for _, source := range o.sources {
+		if drainer, ok := source.(collector.Drainer); ok {
+			drainer.Drain()
+		}
+	}
+
+	select {
+	case <-o.drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for in-flight collections: %w", ctx.Err())
+	}
+}
+
 // SetPaused sets the pause state
 func (o *Orchestrator) SetPaused(paused bool) {
 	o.pauseMu.Lock()
@@ -195,37 +270,82 @@ func (o *Orchestrator) IsPaused() bool {
 	return o.paused
 }
 
+// SetStaleAfter sets how long a host may go without a successful collection
+// before it's flagged stale, 0 to never flag it. It must be called before
+// Start.
+func (o *Orchestrator) SetStaleAfter(after time.Duration) {
+	o.staleAfter = after
+}
+
+// watchStale flags hosts whose last success is older than staleAfter, or
+// their collection period when that's longer, checking a few times per
+// staleAfter so the flag comes soon after
+func (o *Orchestrator) watchStale(ctx context.Context) {
+	ticker := time.NewTicker(max(o.staleAfter/4, 100*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.stopCh:
+			return
+		case now := <-ticker.C:
+			o.store.MarkStale(now, o.staleAfterFor)
+		}
+	}
+}
+
+// staleAfterFor returns how long host may go without a successful collection
+// before it's flagged stale: staleAfter, unless its sources only collect it
+// less often than that
+func (o *Orchestrator) staleAfterFor(host string) time.Duration {
+	after := o.staleAfter
+	for _, source := range o.sources {
+		if scheduler, ok := source.(collector.Scheduler); ok {
+			after = max(after, scheduler.CollectionPeriod(host, o.interval))
+		}
+	}
+	return after
+}
+
 // refreshController manages the centralized refresh logic
 func (o *Orchestrator) refreshController(ctx context.Context) {
 	// Trigger initial collection only if not paused
 	if !o.IsPaused() {
 		o.triggerAllSources()
 	}
-	
-	// If interval is 0, only collect on manual refresh
-	if o.interval == 0 {
+
+	// If no host has an interval, only collect on manual refresh
+	tick := o.tickInterval()
+	if tick == 0 {
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case <-o.stopCh:
+				return
 			case <-o.refreshCh:
 				o.triggerAllSources()
 			}
 		}
 	}
-	
-	// Normal periodic collection mode
-	ticker := time.NewTicker(o.interval)
+
+	// Normal periodic collection mode. Ticks come as often as the shortest
+	// interval, and each one collects the hosts that are due.
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			// Only collect if not paused
+		case <-o.stopCh:
+			return
+		case now := <-ticker.C:
+			// Only collect if not paused. Looking half a tick ahead keeps
+			// ticker jitter from pushing a due host to the next tick.
 			if !o.IsPaused() {
-				o.triggerAllSources()
+				o.triggerDueSources(now.Add(tick / 2))
 			}
 			// Note: when paused, we simply ignore the ticker event
 		case <-o.refreshCh:
@@ -235,6 +355,30 @@ func (o *Orchestrator) refreshController(ctx context.Context) {
 	}
 }
 
+// tickInterval returns how often the refresh controller ticks: the global
+// interval, or a shorter per-host one
+func (o *Orchestrator) tickInterval() time.Duration {
+	tick := o.interval
+	for _, source := range o.sources {
+		if scheduler, ok := source.(collector.Scheduler); ok {
+			if interval := scheduler.MinInterval(); interval > 0 && (tick == 0 || interval < tick) {
+				tick = interval
+			}
+		}
+	}
+	return tick
+}
+
+// triggerDueSources triggers collection of the hosts whose interval has
+// elapsed by now
+func (o *Orchestrator) triggerDueSources(now time.Time) {
+	for _, source := range o.sources {
+		if scheduler, ok := source.(collector.Scheduler); ok {
+			scheduler.TriggerDue(now, o.interval)
+		}
+	}
+}
+
 // triggerAllSources triggers collection for all sources
 func (o *Orchestrator) triggerAllSources() {
 	for _, source := range o.sources {
@@ -244,34 +388,3 @@ func (o *Orchestrator) triggerAllSources() {
 		// Add support for other source types as needed
 	}
 }
-
-func (o *Orchestrator) monitorErrors(ctx context.Context) {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			// Check each source for errors
-			for _, source := range o.sources {
-				if httpSource, ok := source.(*http.HTTPSource); ok {
-					currentErrors := httpSource.GetErrors()
-					sourceTargets := httpSource.GetTargets()
-					
-					// Update error status only for hosts managed by this source
-					for _, host := range sourceTargets {
-						if err, hasError := currentErrors[host]; hasError {
-							// Host has an error
-							o.store.UpdateError(host, err)
-						} else {
-							// Host is working (no error in the errors map)
-							o.store.UpdateError(host, nil)
-						}
-					}
-				}
-			}
-		}
-	}
-}
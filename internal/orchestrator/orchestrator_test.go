@@ -2,12 +2,17 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/anyproto/goru/internal/collector"
-	"github.com/anyproto/goru/internal/store"
+	"github.com/anyproto/goru/internal/collector/http"
 	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
 )
 
 // Mock source for testing
@@ -60,7 +65,7 @@ func TestOrchestratorBasic(t *testing.T) {
 		},
 	}
 
-	o := New(s, source)
+	o := New(s, 0, source)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
@@ -104,7 +109,7 @@ func TestOrchestratorMultipleSources(t *testing.T) {
 					Host:    "host1",
 					TakenAt: time.Now(),
 					Groups: map[model.GroupID]*model.Group{
-						"g1": {ID: "g1", Count: 1},
+						"g1": {ID: "g1", State: model.StateRunning, Count: 1},
 					},
 				},
 			},
@@ -116,14 +121,14 @@ func TestOrchestratorMultipleSources(t *testing.T) {
 					Host:    "host2",
 					TakenAt: time.Now(),
 					Groups: map[model.GroupID]*model.Group{
-						"g2": {ID: "g2", Count: 2},
+						"g2": {ID: "g2", State: model.StateWaiting, Count: 2},
 					},
 				},
 			},
 		},
 	}
 
-	o := New(s, sources...)
+	o := New(s, 0, sources...)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
@@ -144,6 +149,13 @@ func TestOrchestratorMultipleSources(t *testing.T) {
 		t.Errorf("Expected 2 active sources, got %d", stats.ActiveSources)
 	}
 
+	if stats.TotalGoroutines != 3 {
+		t.Errorf("Expected 3 goroutines across hosts, got %d", stats.TotalGoroutines)
+	}
+	if got := stats.GoroutinesByState[model.StateWaiting]; got != 2 {
+		t.Errorf("Expected 2 waiting goroutines, got %d", got)
+	}
+
 	// Verify snapshots in store
 	all := s.GetAllSnapshots()
 	if len(all) != 2 {
@@ -177,7 +189,7 @@ func TestOrchestratorDiffComputation(t *testing.T) {
 		interval: 20 * time.Millisecond,
 	}
 
-	o := New(s, source)
+	o := New(s, 0, source)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
@@ -228,9 +240,58 @@ done:
 	}
 }
 
+func TestOrchestratorSkipsUnchanged(t *testing.T) {
+	s := store.New()
+	snapshot := func(taken time.Time, count int) *model.Snapshot {
+		return &model.Snapshot{
+			Host:    "test-host",
+			TakenAt: taken,
+			Groups: map[model.GroupID]*model.Group{
+				"g1": {ID: "g1", Count: count},
+			},
+		}
+	}
+	now := time.Now()
+	first := snapshot(now, 5)
+	last := snapshot(now.Add(3*time.Second), 6)
+
+	// An idle host repeats itself before changing
+	o := New(s, 0)
+	o.handleSnapshot(first)
+	s.MarkInFlight("test-host")
+	o.handleSnapshot(snapshot(now.Add(time.Second), 5))
+	if phase := s.GetPhase("test-host"); phase != store.PhaseSucceeded {
+		t.Errorf("Phase = %q, want %q", phase, store.PhaseSucceeded)
+	}
+	o.handleSnapshot(snapshot(now.Add(2*time.Second), 5))
+	o.handleSnapshot(last)
+
+	if got := s.GetStats().SkippedUpdates; got != 2 {
+		t.Errorf("Expected 2 skipped updates, got %d", got)
+	}
+	if got := len(s.GetHistory("test-host")); got != 2 {
+		t.Errorf("Expected 2 snapshots in the history, got %d", got)
+	}
+	if s.GetSnapshot("test-host") != last {
+		t.Error("Expected the changed snapshot to be stored")
+	}
+	if delta := s.GetChangeSet("test-host").Updated["g1"]; delta != 1 {
+		t.Errorf("Expected count delta +1 for g1, got %d", delta)
+	}
+
+	// A host dropped from the store and registered again takes its next
+	// snapshot even if the same
+	s.RemoveHosts([]string{"test-host"})
+	s.RegisterHosts([]string{"test-host"})
+	o.handleSnapshot(snapshot(now.Add(4*time.Second), 6))
+	if s.GetSnapshot("test-host") == nil {
+		t.Error("Expected the snapshot of a removed host to be stored again")
+	}
+}
+
 func TestOrchestratorNoSources(t *testing.T) {
 	s := store.New()
-	o := New(s) // No sources
+	o := New(s, 0) // No sources
 
 	ctx := context.Background()
 	err := o.Start(ctx)
@@ -253,7 +314,7 @@ func TestOrchestratorContextCancellation(t *testing.T) {
 		},
 	}
 
-	o := New(s, source)
+	o := New(s, 0, source)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -276,3 +337,216 @@ func TestOrchestratorContextCancellation(t *testing.T) {
 		t.Error("Orchestrator didn't stop on context cancellation")
 	}
 }
+
+// Mock source that reports a collection error for its host
+type failingSource struct {
+	mockSource
+	err     error
+	handler func(host string, err error)
+}
+
+func (f *failingSource) SetErrorHandler(handler func(host string, err error)) {
+	f.handler = handler
+}
+
+func (f *failingSource) Collect(ctx context.Context, snapshots chan<- *model.Snapshot) error {
+	f.handler("test-host", f.err)
+	return f.mockSource.Collect(ctx, snapshots)
+}
+
+func TestOrchestratorPushesErrors(t *testing.T) {
+	s := store.New()
+	s.RegisterHosts([]string{"test-host"})
+
+	source := &failingSource{
+		mockSource: mockSource{name: "test"},
+		err:        errors.New("connection refused"),
+	}
+
+	o := New(s, 0, source)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	updates := make(chan store.Update, 1)
+	s.Subscribe(updates)
+
+	go o.Start(ctx)
+
+	// The error should reach the store without any polling delay
+	select {
+	case update := <-updates:
+		if update.Error == nil || update.Error.Error() != "connection refused" {
+			t.Errorf("Expected pushed error, got %v", update.Error)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("Error was not pushed to the store")
+	}
+
+	if err := s.GetErrors()["test-host"]; err == nil {
+		t.Error("Expected error to be recorded in the store")
+	}
+}
+
+// Mock source with per-host intervals that records due triggers
+type scheduledSource struct {
+	mockSource
+	minInterval time.Duration
+	periods     map[string]time.Duration
+	due         chan time.Duration
+}
+
+func (s *scheduledSource) MinInterval() time.Duration {
+	return s.minInterval
+}
+
+func (s *scheduledSource) CollectionPeriod(host string, defaultInterval time.Duration) time.Duration {
+	return s.periods[host]
+}
+
+func (s *scheduledSource) TriggerDue(now time.Time, defaultInterval time.Duration) {
+	select {
+	case s.due <- defaultInterval:
+	default:
+	}
+}
+
+func TestOrchestratorSchedules(t *testing.T) {
+	source := &scheduledSource{
+		mockSource:  mockSource{name: "scheduled"},
+		minInterval: 100 * time.Millisecond,
+		due:         make(chan time.Duration, 1),
+	}
+	o := New(store.New(), 10*time.Second, source, &mockSource{name: "plain"})
+
+	// The shortest per-host interval drives the ticks
+	if got := o.tickInterval(); got != 100*time.Millisecond {
+		t.Errorf("tickInterval() = %v, want 100ms", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go o.refreshController(ctx)
+
+	select {
+	case defaultInterval := <-source.due:
+		if defaultInterval != 10*time.Second {
+			t.Errorf("TriggerDue() default interval = %v, want 10s", defaultInterval)
+		}
+	case <-ctx.Done():
+		t.Fatal("Scheduled source was never triggered")
+	}
+}
+
+func TestOrchestratorStaleAfterPerHost(t *testing.T) {
+	source := &scheduledSource{
+		mockSource: mockSource{name: "scheduled"},
+		periods:    map[string]time.Duration{"slow:1": 5 * time.Minute, "fast:1": time.Second},
+	}
+	o := New(store.New(), time.Second, source)
+	o.SetStaleAfter(time.Minute)
+
+	// A host polled less often than staleAfter gets its period instead
+	tests := map[string]time.Duration{
+		"slow:1":  5 * time.Minute,
+		"fast:1":  time.Minute,
+		"other:1": time.Minute,
+	}
+	for host, want := range tests {
+		if got := o.staleAfterFor(host); got != want {
+			t.Errorf("staleAfterFor(%s) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestOrchestratorShutdown(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		started <- struct{}{}
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n")
+	}))
+	defer server.Close()
+	target := server.URL[7:] // Remove "http://"
+
+	s := store.New()
+	o := New(s, 0, http.New([]string{target}, time.Second, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Start(ctx)
+
+	// Shut down while the first collection is in flight
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("Collection never started")
+	}
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelDrain()
+	if err := o.Shutdown(drainCtx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if s.GetSnapshot(target) == nil {
+		t.Error("In-flight collection should be stored before Shutdown returns")
+	}
+
+	// No new collections are started once draining
+	if o.TriggerRefreshHost(target) {
+		t.Error("Refreshes should be rejected during shutdown")
+	}
+}
+
+func TestOrchestratorShutdownTimeout(t *testing.T) {
+	// A source that can't drain keeps running until its context is done
+	source := &mockSource{
+		name:      "endless",
+		snapshots: []*model.Snapshot{{Host: "host1", Groups: map[model.GroupID]*model.Group{}}},
+		interval:  time.Hour,
+	}
+	o := New(store.New(), 0, source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Start(ctx)
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelDrain()
+	if err := o.Shutdown(drainCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestOrchestratorFlagsStale(t *testing.T) {
+	s := store.New()
+	source := &mockSource{
+		name: "test",
+		snapshots: []*model.Snapshot{
+			{Host: "test-host", TakenAt: time.Now(), Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", Count: 1}}},
+		},
+	}
+	o := New(s, 0, source)
+	o.SetStaleAfter(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	updates := make(chan store.Update, 10)
+	s.Subscribe(updates)
+	go o.Start(ctx)
+
+	// The one snapshot arrives, then nothing more does
+	for {
+		select {
+		case update := <-updates:
+			if update.Stale {
+				if update.Host != "test-host" || !s.IsStale("test-host") {
+					t.Errorf("Expected test-host flagged stale, got %+v", update)
+				}
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for the host to turn stale")
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+var (
+	// Delve's "goroutines -t" output: "  Goroutine 7 - User: ./main.go:12
+	// main.worker (0x4a2b3c) [chan receive]", "*" marking the current one,
+	// followed by "\t0  0x000000000043d1c6 in runtime.gopark" and
+	// "\t    at /usr/local/go/src/runtime/proc.go:398" per frame
+	delveHeaderRe = regexp.MustCompile(`^\*?\s*Goroutine (\d+) - (.*)$`)
+	delveFrameRe  = regexp.MustCompile(`^\s*\d+\s+0x[0-9a-fA-F]+ in (.+)$`)
+	delveAtRe     = regexp.MustCompile(`^\s+at (.+):(\d+)$`)
+	delveWaitRe   = regexp.MustCompile(`\[([^\]]+)\]$`)
+	delveThreadRe = regexp.MustCompile(`\(thread \d+\)`)
+	// With -g the header also names the go statement that started it:
+	// "Go: ./main.go:15 main.main (0x4a2c10)"
+	delveGoRe = regexp.MustCompile(`Go: (\S+):(\d+) (\S+)`)
+)
+
+// detectPeek is how much of the input is looked at to tell the formats apart
+const detectPeek = 64 * 1024
+
+// isDelve reports whether the first goroutine header in data is one of
+// Delve's rather than the runtime's
+func isDelve(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case delveHeaderRe.Match(line):
+			return true
+		case goroutineHeaderRe.Match(line), debug1HeaderRe.Match(line):
+			return false
+		}
+	}
+	return false
+}
+
+// ParseDelve parses the output of Delve's "goroutines -t" command, as
+// printed for a live process or a core dump, into a snapshot. Delve names a
+// state only for waiting goroutines, so the others are running when on a
+// thread and unknown otherwise.
+func (p *Parser) ParseDelve(r io.Reader, host string) (*model.Snapshot, error) {
+	snapshot := model.NewSnapshot(host)
+	snapshot.GroupDepth = p.groupDepth
+	snapshot.NormalizeGenerics = p.normalizeGenerics
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+
+	var currentID uint64
+	var currentState model.GoroutineState
+	var currentReason string
+	var currentStack []model.StackFrame
+	var currentCreatedBy *model.StackFrame
+	var inGoroutine bool
+
+	parsed := 0
+	addGoroutine := func() {
+		if len(currentStack) > 0 {
+			g := snapshot.AddLabeledGoroutine(currentID, currentState, currentStack, "", currentCreatedBy, 0, nil)
+			if g.BlockReason == "" {
+				g.BlockReason = currentReason
+			}
+			parsed++
+		}
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if matches := delveHeaderRe.FindStringSubmatch(line); matches != nil {
+			if inGoroutine {
+				addGoroutine()
+			}
+			if p.maxGoroutines > 0 && parsed >= p.maxGoroutines {
+				snapshot.Truncated = true
+				inGoroutine = false
+				break
+			}
+
+			inGoroutine = true
+			currentID, _ = strconv.ParseUint(matches[1], 10, 64)
+			currentState, currentReason = p.delveState(matches[2])
+			currentStack = nil
+			currentCreatedBy = nil
+			if goMatches := delveGoRe.FindStringSubmatch(matches[2]); goMatches != nil {
+				lineNum, _ := strconv.Atoi(goMatches[2])
+				currentCreatedBy = &model.StackFrame{
+					Func: goMatches[3],
+					File: goMatches[1],
+					Line: lineNum,
+				}
+			}
+			continue
+		}
+
+		if !inGoroutine {
+			continue
+		}
+
+		// A frame's location is on the line after its function; labels,
+		// the "[N goroutines]" summary and such are skipped
+		if matches := delveFrameRe.FindStringSubmatch(line); matches != nil {
+			currentStack = append(currentStack, model.StackFrame{Func: strings.TrimSpace(matches[1])})
+			continue
+		}
+		if matches := delveAtRe.FindStringSubmatch(line); matches != nil && len(currentStack) > 0 {
+			frame := &currentStack[len(currentStack)-1]
+			if frame.File == "" {
+				frame.File = matches[1]
+				frame.Line, _ = strconv.Atoi(matches[2])
+			}
+		}
+	}
+
+	if inGoroutine {
+		addGoroutine()
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scanning input: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// delveState maps the rest of a Delve goroutine header onto a state and a
+// block reason. The wait reason in brackets reads like the runtime's, past a
+// trailing wait time in some versions, which the runtime's state mapping
+// ignores.
+func (p *Parser) delveState(header string) (model.GoroutineState, string) {
+	if matches := delveWaitRe.FindStringSubmatch(header); matches != nil {
+		return p.parseState(matches[1]), p.blockReason(matches[1])
+	}
+	if delveThreadRe.MatchString(header) {
+		return model.StateRunning, ""
+	}
+	return model.StateUnknown, ""
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// WriteDump reconstructs a debug=2 style goroutine dump from a snapshot,
+// writing each group's goroutines out one by one so that Parse reads back
+// the same groups. Goroutines get their recorded numbers, or new ones past
+// the highest recorded number, and the group's wait durations in order.
+func WriteDump(w io.Writer, snapshot *model.Snapshot) error {
+	groups := make([]*model.Group, 0, len(snapshot.Groups))
+	nextID := uint64(1)
+	for _, g := range snapshot.Groups {
+		groups = append(groups, g)
+		for _, id := range g.IDs {
+			nextID = max(nextID, id+1)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].ID < groups[j].ID
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, g := range groups {
+		waits := g.WaitTimes()
+		for i := 0; i < g.Count; i++ {
+			id := nextID
+			if i < len(g.IDs) {
+				id = g.IDs[i]
+			} else {
+				nextID++
+			}
+
+			var wait time.Duration
+			if i < len(waits) {
+				wait = waits[i]
+			}
+			writeGoroutine(bw, id, g, wait)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeGoroutine writes one goroutine of a group, followed by a blank line
+func writeGoroutine(w *bufio.Writer, id uint64, g *model.Group, wait time.Duration) {
+	// The block reason reads back as the same state, and keeps the reason
+	status := string(g.State)
+	if g.BlockReason != "" {
+		status = g.BlockReason
+	}
+	fmt.Fprintf(w, "goroutine %d [%s", id, status)
+	switch {
+	case wait >= time.Minute:
+		fmt.Fprintf(w, ", %d minutes", int(wait/time.Minute))
+	case wait >= time.Second:
+		fmt.Fprintf(w, ", %d seconds", int(wait/time.Second))
+	}
+	w.WriteString("]:\n")
+	if len(g.Labels) > 0 {
+		labels, _ := json.Marshal(g.Labels)
+		fmt.Fprintf(w, "# labels: %s\n", labels)
+	}
+
+	for _, frame := range g.Trace {
+		if frame.Func == model.ElidedFrames {
+			w.WriteString(model.ElidedFrames + "\n")
+			continue
+		}
+		fmt.Fprintf(w, "%s(...)\n\t%s:%d\n", frame.Func, frame.File, frame.Line)
+	}
+	if g.CreatedBy != nil {
+		fmt.Fprintf(w, "created by %s", g.CreatedBy.Func)
+		if g.CreatedByGoroutine != 0 {
+			fmt.Fprintf(w, " in goroutine %d", g.CreatedByGoroutine)
+		}
+		fmt.Fprintf(w, "\n\t%s:%d\n", g.CreatedBy.File, g.CreatedBy.Line)
+	}
+	w.WriteString("\n")
+}
@@ -3,66 +3,288 @@ package parser
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/anyproto/goru/pkg/model"
 )
 
 var (
-	goroutineHeaderRe = regexp.MustCompile(`^goroutine (\d+) \[([\w\s,]+?)(?:, (\d+ minutes?))?\]:$`)
+	goroutineHeaderRe = regexp.MustCompile(`^goroutine (\d+) \[([^\]]+?)(?:, (\d+ (?:seconds?|minutes?|hours?)))?\]:$`)
 	stackFrameRe      = regexp.MustCompile(`^(.+?)\(.*?\)$`)
 	fileLineRe        = regexp.MustCompile(`^\s+(.+?):(\d+)(?:\s|$)`)
 	createdByRe       = regexp.MustCompile(`^created by (.+)$`)
 	createdAtRe       = regexp.MustCompile(`^\s+(.+?):(\d+)(?:\s|$)`)
-	
+
+	// debug=1 format: "5 @ 0x43e6cf 0x40ecb1" followed by "#\t0x...\tfunc+0x..\tfile:line".
+	// Function names may hold spaces, from generic shapes like
+	// "go.shape.struct { X int }", so fields don't cross tabs.
+	debug1HeaderRe = regexp.MustCompile(`^(\d+) @(?: 0x[0-9a-fA-F]+)*$`)
+	debug1FrameRe  = regexp.MustCompile(`^#\s+0x[0-9a-fA-F]+\s+([^\t]+?)(?:\+0x[0-9a-fA-F]+)?\s+([^\t]+):(\d+)$`)
+
+	// pprof labels, e.g. `# labels: {"tenant":"acme", "handler":"/api"}`
+	labelsRe = regexp.MustCompile(`^\s*# labels: (\{.*\})$`)
+
 	// Regexes for extractFunctionName
 	funcRe = regexp.MustCompile(`^([^(]+(?:\(\*[^)]+\))?[^(]*)(?:\(|$)`)
-	
+
 	// Regexes for stripMemoryAddresses
-	ptrRe = regexp.MustCompile(`\((0x[0-9a-fA-F]+(?:,\s*0x[0-9a-fA-F]+)*(?:,\s*[^)]+)*)\)`)
-	hexRe = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	ptrRe     = regexp.MustCompile(`\((0x[0-9a-fA-F]+(?:,\s*0x[0-9a-fA-F]+)*(?:,\s*[^)]+)*)\)`)
+	hexRe     = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	argListRe = regexp.MustCompile(`\([^()]*\)`)
+	decimalRe = regexp.MustCompile(`-?\b\d+\b`)
 )
 
 type Parser struct {
-	stripAddresses bool
+	minWait           time.Duration
+	groupDepth        int
+	normalizeGenerics bool
+	groupByLabels     bool
+	groupByArgs       bool
+	maxGoroutines     int
+}
+
+// Option configures optional Parser behavior
+type Option func(*Parser)
+
+// WithMinWait drops wait durations shorter than min, so only genuinely long
+// blocks are recorded on groups
+func WithMinWait(min time.Duration) Option {
+	return func(p *Parser) {
+		p.minWait = min
+	}
+}
+
+// WithGroupDepth groups goroutines by only the top depth frames of their
+// traces instead of the full trace (0)
+func WithGroupDepth(depth int) Option {
+	return func(p *Parser) {
+		p.groupDepth = depth
+	}
+}
+
+// WithNormalizeGenerics groups goroutines by their traces with the type
+// arguments of generic functions stripped, so code instantiated for
+// different types shares a group. Traces keep their full names.
+func WithNormalizeGenerics(normalize bool) Option {
+	return func(p *Parser) {
+		p.normalizeGenerics = normalize
+	}
 }
 
-func New() *Parser {
-	return &Parser{
-		stripAddresses: true,
+// WithGroupByLabels groups goroutines by their pprof labels as well as their
+// traces, so goroutines of the same code serving different requests or
+// tenants get groups of their own
+func WithGroupByLabels(group bool) Option {
+	return func(p *Parser) {
+		p.groupByLabels = group
 	}
 }
 
+// WithGroupByArgs keeps each frame's argument list, with addresses and
+// numbers such as sequence numbers or request IDs replaced by placeholders,
+// so goroutines are grouped by their arguments as well as their traces
+func WithGroupByArgs(group bool) Option {
+	return func(p *Parser) {
+		p.groupByArgs = group
+	}
+}
+
+// WithMaxGoroutines stops parsing a dump once max goroutines (0 for no
+// limit) are read and marks the snapshot truncated, so huge dumps can't
+// exhaust memory
+func WithMaxGoroutines(max int) Option {
+	return func(p *Parser) {
+		p.maxGoroutines = max
+	}
+}
+
+func New(opts ...Option) *Parser {
+	p := &Parser{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// utf8BOM is the byte order mark some Windows tools put before UTF-8 text
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Parse parses a goroutine dump: the runtime's debug=2 or debug=1 text, or
+// Delve's "goroutines -t" output, told apart by the first goroutine header.
+// A leading byte order mark is skipped, and lines may end in \r\n or a
+// lone \r as well as \n.
 func (p *Parser) Parse(r io.Reader, host string) (*model.Snapshot, error) {
+	br := bufio.NewReaderSize(r, detectPeek)
+	head, err := br.Peek(detectPeek)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+	if bytes.HasPrefix(head, utf8BOM) {
+		br.Discard(len(utf8BOM))
+		head = head[len(utf8BOM):]
+	}
+	if isDelve(head) {
+		return p.ParseDelve(br, host)
+	}
+	return p.parseRuntime(br, host)
+}
+
+// parseRuntime parses the runtime's own dump formats
+func (p *Parser) parseRuntime(r io.Reader, host string) (*model.Snapshot, error) {
 	snapshot := model.NewSnapshot(host)
+	snapshot.GroupDepth = p.groupDepth
+	snapshot.NormalizeGenerics = p.normalizeGenerics
+	snapshot.GroupByLabels = p.groupByLabels
 	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
 
+	var currentID uint64
 	var currentState model.GoroutineState
+	var currentReason string
 	var currentWait string
 	var currentStack []model.StackFrame
 	var currentCreatedBy *model.StackFrame
+	var currentParent uint64
+	var currentLabels map[string]string
 	var inGoroutine bool
 
+	// Aggregated debug=1 records carry a count instead of a goroutine header
+	var recordCount int
+	var recordStack []model.StackFrame
+	var recordLabels map[string]string
+	var inRecord bool
+
+	// What couldn't be read, reported as the snapshot's warnings
+	var badHeaders, badFrames int
+	var stackless int      // goroutines whose header no frame followed
+	var startBadFrames int // badFrames when the current goroutine started
+	var lastBad string     // what the last line failed to read as, if anything
+	var cutShort bool
+
+	// Goroutines read so far, against the limit. The limit is checked when
+	// the next goroutine starts, so a dump of exactly max isn't truncated.
+	parsed := 0
+	full := func() bool {
+		return p.maxGoroutines > 0 && parsed >= p.maxGoroutines
+	}
+	addGoroutine := func() {
+		g := snapshot.AddLabeledGoroutine(currentID, currentState, currentStack, currentWait, currentCreatedBy, currentParent, currentLabels)
+		if g.BlockReason == "" {
+			g.BlockReason = currentReason
+		}
+		parsed++
+	}
+	// endGoroutine adds the goroutine read so far, leaving it out when it
+	// has no frames, such as one "running on other thread" whose stack the
+	// runtime couldn't take. One whose frames didn't read is already counted
+	// in badFrames.
+	endGoroutine := func() {
+		if len(currentStack) == 0 {
+			if badFrames == startBadFrames {
+				stackless++
+			}
+			return
+		}
+		addGoroutine()
+	}
+	addRecord := func() {
+		count := recordCount
+		if p.maxGoroutines > 0 && parsed+count > p.maxGoroutines {
+			count = p.maxGoroutines - parsed
+			snapshot.Truncated = true
+		}
+		if count > 0 {
+			snapshot.AddLabeledGroup(count, model.StateUnknown, recordStack, recordLabels)
+			parsed += count
+		}
+	}
+
 	for scanner.Scan() {
 		line := scanner.Text()
+		lastBad = ""
+
+		// Check for a debug=1 record header
+		if matches := debug1HeaderRe.FindStringSubmatch(line); matches != nil {
+			if inRecord && len(recordStack) > 0 {
+				addRecord()
+			}
+			if full() {
+				snapshot.Truncated = true
+				inRecord = false
+				break
+			}
+			inRecord = true
+			inGoroutine = false
+			recordCount, _ = strconv.Atoi(matches[1])
+			recordStack = nil
+			recordLabels = nil
+			continue
+		}
+
+		if inRecord {
+			// Empty line ends the record
+			if line == "" {
+				if len(recordStack) > 0 {
+					addRecord()
+				}
+				inRecord = false
+			} else if matches := labelsRe.FindStringSubmatch(line); matches != nil {
+				recordLabels = parseLabels(matches[1])
+			} else if matches := debug1FrameRe.FindStringSubmatch(line); matches != nil {
+				lineNum, _ := strconv.Atoi(matches[3])
+				recordStack = append(recordStack, model.StackFrame{
+					Func: matches[1],
+					File: matches[2],
+					Line: lineNum,
+				})
+			} else {
+				badFrames++
+				lastBad = "frame"
+			}
+			continue
+		}
 
 		// Check for goroutine header
 		if matches := goroutineHeaderRe.FindStringSubmatch(line); matches != nil {
 			// Save previous goroutine if any
-			if inGoroutine && len(currentStack) > 0 {
-				snapshot.AddGoroutine(currentState, currentStack, currentWait, currentCreatedBy)
+			if inGoroutine {
+				endGoroutine()
+			}
+			if full() {
+				snapshot.Truncated = true
+				inGoroutine = false
+				break
 			}
 
 			// Start new goroutine
 			inGoroutine = true
+			currentID, _ = strconv.ParseUint(matches[1], 10, 64)
 			currentState = p.parseState(matches[2])
-			currentWait = matches[3]
+			currentReason = p.blockReason(matches[2])
+			currentWait = p.filterWait(matches[3])
 			currentStack = nil
 			currentCreatedBy = nil
+			currentParent = 0
+			currentLabels = nil
+			startBadFrames = badFrames
+			continue
+		}
+
+		// A header that doesn't read, e.g. one cut off mid-line, still ends
+		// the goroutine before it. Its own frames are skipped.
+		if strings.HasPrefix(line, "goroutine ") && !strings.HasPrefix(line, "goroutine profile:") {
+			if inGoroutine {
+				endGoroutine()
+			}
+			inGoroutine = false
+			badHeaders++
+			lastBad = "header"
 			continue
 		}
 
@@ -72,21 +294,27 @@ func (p *Parser) Parse(r io.Reader, host string) (*model.Snapshot, error) {
 
 		// Empty line ends the goroutine
 		if line == "" {
-			if len(currentStack) > 0 {
-				snapshot.AddGoroutine(currentState, currentStack, currentWait, currentCreatedBy)
-			}
+			endGoroutine()
 			inGoroutine = false
 			continue
 		}
 
+		// Labels aren't a frame, and have no file:line line to consume
+		if matches := labelsRe.FindStringSubmatch(line); matches != nil {
+			currentLabels = parseLabels(matches[1])
+			continue
+		}
+
 		// Check for "created by" line
 		if matches := createdByRe.FindStringSubmatch(line); matches != nil {
 			// Extract the function name that created this goroutine
 			createdByFunc := matches[1]
 
-			// Remove "in goroutine X" suffix if present
-			if idx := strings.Index(createdByFunc, " in goroutine "); idx > 0 {
-				createdByFunc = createdByFunc[:idx]
+			// Remove "in goroutine X" suffix if present, keeping the parent's
+			// number
+			if fn, parent, ok := strings.Cut(createdByFunc, " in goroutine "); ok && fn != "" {
+				createdByFunc = fn
+				currentParent, _ = strconv.ParseUint(strings.TrimSpace(parent), 10, 64)
 			}
 
 			// Next line should have file:line
@@ -99,7 +327,12 @@ func (p *Parser) Parse(r io.Reader, host string) (*model.Snapshot, error) {
 						File: fileMatches[1],
 						Line: lineNum,
 					}
+				} else {
+					badFrames++
+					lastBad = "frame"
 				}
+			} else {
+				cutShort = true
 			}
 			continue
 		}
@@ -109,6 +342,13 @@ func (p *Parser) Parse(r io.Reader, host string) (*model.Snapshot, error) {
 			continue
 		}
 
+		// Deep stacks have their middle frames elided; the marker has no
+		// file:line line, so don't consume the next function as one
+		if strings.TrimSpace(line) == model.ElidedFrames {
+			currentStack = append(currentStack, model.StackFrame{Func: model.ElidedFrames})
+			continue
+		}
+
 		// Check for stack frame function
 		if !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, " ") {
 			// This line contains a function name
@@ -122,21 +362,58 @@ func (p *Parser) Parse(r io.Reader, host string) (*model.Snapshot, error) {
 						Func: funcName,
 						File: matches[1],
 						Line: lineNum,
+						Args: p.extractArgs(line),
 					})
+				} else {
+					badFrames++
+					lastBad = "frame"
 				}
+			} else {
+				cutShort = true
 			}
 		}
 	}
 
-	// Handle last goroutine if file doesn't end with empty line
-	if inGoroutine && len(currentStack) > 0 {
-		snapshot.AddGoroutine(currentState, currentStack, currentWait, currentCreatedBy)
-	}
-
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("scanning input: %w", err)
 	}
 
+	// A dump ending on a line that doesn't read was cut off there
+	switch {
+	case lastBad == "header":
+		badHeaders--
+		snapshot.Warnings = append(snapshot.Warnings, "final goroutine cut off in its header and left out")
+	case lastBad == "frame" && (inGoroutine || inRecord):
+		badFrames--
+		cutShort = true
+	}
+
+	// Handle last goroutine if file doesn't end with empty line
+	switch {
+	case inGoroutine && len(currentStack) == 0:
+		snapshot.Warnings = append(snapshot.Warnings, fmt.Sprintf("final goroutine %d cut off before its stack and left out", currentID))
+	case inGoroutine:
+		addGoroutine()
+		if cutShort {
+			snapshot.Warnings = append(snapshot.Warnings, fmt.Sprintf("final goroutine %d cut off, its stack is incomplete", currentID))
+		}
+	}
+	if inRecord && len(recordStack) > 0 {
+		addRecord()
+		if cutShort {
+			snapshot.Warnings = append(snapshot.Warnings, "final record cut off, its stack is incomplete")
+		}
+	}
+	if badHeaders > 0 {
+		snapshot.Warnings = append(snapshot.Warnings, fmt.Sprintf("%d goroutine header line(s) couldn't be read", badHeaders))
+	}
+	if badFrames > 0 {
+		snapshot.Warnings = append(snapshot.Warnings, fmt.Sprintf("%d stack frame line(s) couldn't be read", badFrames))
+	}
+	if stackless > 0 {
+		snapshot.Warnings = append(snapshot.Warnings, fmt.Sprintf("%d goroutine(s) without stack frames left out", stackless))
+	}
+
 	return snapshot, nil
 }
 
@@ -144,9 +421,70 @@ func (p *Parser) parseState(stateStr string) model.GoroutineState {
 	// Clean up the state string
 	stateStr = strings.TrimSpace(stateStr)
 	stateStr = strings.Split(stateStr, ",")[0]
-	
-	// Return the state as-is
-	return model.GoroutineState(stateStr)
+
+	switch {
+	case stateStr == "running":
+		return model.StateRunning
+	case stateStr == "runnable":
+		return model.StateRunnable
+	case stateStr == "syscall":
+		return model.StateSyscall
+	case stateStr == "blocked":
+		// As written by WriteDump, which has only the summarized state
+		return model.StateBlocked
+	case stateStr == "unknown":
+		return model.StateUnknown
+	case strings.HasPrefix(stateStr, "chan "),
+		strings.HasPrefix(stateStr, "select"),
+		strings.HasPrefix(stateStr, "sync."):
+		// Blocked on a channel operation or a sync primitive
+		return model.StateBlocked
+	default:
+		// IO wait, sleep, semacquire, GC and finalizer waits, etc.
+		return model.StateWaiting
+	}
+}
+
+// parseLabels reads the pprof labels of a "# labels:" line, written by the
+// runtime as quoted keys and values much like a JSON object. Labels that
+// don't read as one are ignored.
+func parseLabels(s string) map[string]string {
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(s), &labels); err != nil || len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// blockReason returns the status of a goroutine header naming what the
+// goroutine waits on, such as "sync.Mutex.Lock" or "chan receive", or an
+// empty string for goroutines that aren't waiting
+func (p *Parser) blockReason(stateStr string) string {
+	status := strings.TrimSpace(strings.Split(stateStr, ",")[0])
+	switch p.parseState(status) {
+	case model.StateBlocked, model.StateWaiting:
+		if status != "blocked" {
+			return status
+		}
+	}
+	return ""
+}
+
+// filterWait returns the wait annotation if it meets the minimum wait
+// threshold, or an empty string otherwise
+func (p *Parser) filterWait(wait string) string {
+	if wait == "" || p.minWait <= 0 {
+		return wait
+	}
+
+	d, ok := model.ParseWait(wait)
+	if !ok {
+		return wait
+	}
+	if d < p.minWait {
+		return ""
+	}
+	return wait
 }
 
 func (p *Parser) extractFunctionName(line string) string {
@@ -167,6 +505,20 @@ func (p *Parser) extractFunctionName(line string) string {
 	return line
 }
 
+// extractArgs returns the normalized argument list of a frame line when
+// grouping by arguments, and "" otherwise
+func (p *Parser) extractArgs(line string) string {
+	if !p.groupByArgs {
+		return ""
+	}
+	line = strings.TrimSpace(line)
+	idx := strings.LastIndex(line, "(")
+	if idx < 0 || !strings.HasSuffix(line, ")") {
+		return ""
+	}
+	return p.stripMemoryAddresses(line[idx:])
+}
+
 func (p *Parser) stripMemoryAddresses(s string) string {
 	// Strip pointer values in function arguments first using pre-compiled regex
 	if ptrRe.MatchString(s) {
@@ -176,9 +528,40 @@ func (p *Parser) stripMemoryAddresses(s string) string {
 	// Strip standalone hex addresses like 0x123abc using pre-compiled regex
 	s = hexRe.ReplaceAllString(s, "0x?")
 
+	// Replace decimal values inside argument lists (sequence numbers, request
+	// IDs) so stacks differing only by a counter argument normalize the same
+	s = argListRe.ReplaceAllStringFunc(s, func(args string) string {
+		return decimalRe.ReplaceAllString(args, "?")
+	})
+
 	return s
 }
 
+// scanLines is bufio.ScanLines that also ends a line at a lone \r, so dumps
+// with any of the usual line endings split the same
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A \r at the end of the buffer may be the first half of a \r\n
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 func (p *Parser) ParseBytes(data []byte, host string) (*model.Snapshot, error) {
 	return p.Parse(bytes.NewReader(data), host)
 }
@@ -1,9 +1,15 @@
 package parser
 
 import (
+	"bytes"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/anyproto/goru/pkg/model"
 )
@@ -75,6 +81,10 @@ func TestParseSimple(t *testing.T) {
 			t.Errorf("Worker group should have count 2, got %d", workerGroup.Count)
 		}
 
+		if len(workerGroup.IDs) != 2 || workerGroup.IDs[0] != 2 || workerGroup.IDs[1] != 3 {
+			t.Errorf("Worker group should have goroutine IDs [2 3], got %v", workerGroup.IDs)
+		}
+
 		if len(workerGroup.WaitDurations) != 2 {
 			t.Errorf("Worker group should have 2 wait durations, got %d", len(workerGroup.WaitDurations))
 		}
@@ -144,6 +154,8 @@ func TestParseState(t *testing.T) {
 		{"sleep", model.StateWaiting},
 		{"finalizer wait", model.StateWaiting},
 		{"chan receive, 5 minutes", model.StateBlocked},
+		{"blocked", model.StateBlocked},
+		{"unknown", model.StateUnknown},
 	}
 
 	for _, tt := range tests {
@@ -156,6 +168,69 @@ func TestParseState(t *testing.T) {
 	}
 }
 
+func TestParseBlockReason(t *testing.T) {
+	dump := `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 5 [sync.Mutex.Lock, 3 minutes]:
+sync.(*Mutex).Lock(0xc000010000)
+	/usr/local/go/src/sync/mutex.go:90 +0x30
+main.update()
+	/app/state.go:20 +0x40
+
+goroutine 6 [sync.WaitGroup.Wait]:
+sync.(*WaitGroup).Wait(0xc000010010)
+	/usr/local/go/src/sync/waitgroup.go:118 +0x60
+main.shutdown()
+	/app/main.go:40 +0x20
+
+goroutine 7 [select (no cases)]:
+main.forever()
+	/app/main.go:50 +0x10
+
+goroutine 8 [IO wait, locked to thread]:
+internal/poll.runtime_pollWait(0x7f0000000000, 0x72)
+	/usr/local/go/src/runtime/netpoll.go:351 +0x85
+`
+	snapshot, err := New().ParseBytes([]byte(dump), "test-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		fn     string
+		state  model.GoroutineState
+		reason string
+	}{
+		{"main.main", model.StateRunning, ""},
+		{"sync.(*Mutex).Lock", model.StateBlocked, "sync.Mutex.Lock"},
+		{"sync.(*WaitGroup).Wait", model.StateBlocked, "sync.WaitGroup.Wait"},
+		{"main.forever", model.StateBlocked, "select (no cases)"},
+		{"internal/poll.runtime_pollWait", model.StateWaiting, "IO wait"},
+	}
+	if len(snapshot.Groups) != len(tests) {
+		t.Fatalf("Expected %d groups, got %d", len(tests), len(snapshot.Groups))
+	}
+	byFunc := map[string]*model.Group{}
+	for _, g := range snapshot.Groups {
+		byFunc[g.Trace[0].Func] = g
+	}
+	for _, tt := range tests {
+		g := byFunc[tt.fn]
+		if g == nil {
+			t.Errorf("Missing group for %s", tt.fn)
+			continue
+		}
+		if g.State != tt.state || g.BlockReason != tt.reason {
+			t.Errorf("%s: got %s (%q), want %s (%q)", tt.fn, g.State, g.BlockReason, tt.state, tt.reason)
+		}
+	}
+	if g := byFunc["sync.(*Mutex).Lock"]; g != nil && len(g.WaitDurations) != 1 {
+		t.Errorf("Expected the mutex wait to be kept, got %v", g.WaitDurations)
+	}
+}
+
 func TestStripMemoryAddresses(t *testing.T) {
 	p := New()
 
@@ -175,6 +250,22 @@ func TestStripMemoryAddresses(t *testing.T) {
 			input:    "no addresses here",
 			expected: "no addresses here",
 		},
+		{
+			input:    "main.handle(12345, {0xc000012345, 0x10}, -7)",
+			expected: "main.handle(?, {0x?, 0x?}, ?)",
+		},
+		{
+			input:    "main.handle(12346, {0xc000054321, 0x10}, -7)",
+			expected: "main.handle(?, {0x?, 0x?}, ?)",
+		},
+		{
+			input:    "main.worker.func2(0x1, 42)",
+			expected: "main.worker.func2(...)",
+		},
+		{
+			input:    "pkg.(*T2).Run(7)",
+			expected: "pkg.(*T2).Run(?)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -203,3 +294,713 @@ func BenchmarkParse(b *testing.B) {
 		}
 	}
 }
+
+func TestParseMinWait(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "simple.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		minWait  time.Duration
+		expected int
+	}{
+		{0, 2},
+		{5 * time.Minute, 2},
+		{6 * time.Minute, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.minWait.String(), func(t *testing.T) {
+			p := New(WithMinWait(tt.minWait))
+			snapshot, err := p.ParseBytes(data, "test-host")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			waits := 0
+			for _, g := range snapshot.Groups {
+				waits += len(g.WaitDurations)
+			}
+			if waits != tt.expected {
+				t.Errorf("Expected %d wait durations, got %d", tt.expected, waits)
+			}
+
+			// Goroutines are still counted even when their wait is dropped
+			if total := snapshot.TotalGoroutines(); total != 4 {
+				t.Errorf("Expected 4 goroutines, got %d", total)
+			}
+		})
+	}
+}
+
+func TestParseGroupDepth(t *testing.T) {
+	dump := `goroutine 1 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+main.startA()
+	/app/a.go:10 +0x20
+
+goroutine 2 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+main.startB()
+	/app/b.go:12 +0x20
+`
+
+	tests := []struct {
+		depth  int
+		groups int
+	}{
+		{0, 2},
+		{1, 1},
+		{2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("depth %d", tt.depth), func(t *testing.T) {
+			p := New(WithGroupDepth(tt.depth))
+			snapshot, err := p.ParseBytes([]byte(dump), "test-host")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(snapshot.Groups) != tt.groups {
+				t.Fatalf("Expected %d groups, got %d", tt.groups, len(snapshot.Groups))
+			}
+			// Collapsed groups still carry a full trace
+			for _, g := range snapshot.Groups {
+				if len(g.Trace) != 2 {
+					t.Errorf("Expected the full 2-frame trace, got %d frames", len(g.Trace))
+				}
+			}
+		})
+	}
+}
+
+// Call sites in the same functions but on different lines are told apart:
+// group IDs hash each frame's file and line along with its function
+func TestParseDistinctLines(t *testing.T) {
+	dump := `goroutine 1 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 2 [chan receive]:
+main.worker()
+	/app/worker.go:31 +0x140
+main.main()
+	/app/main.go:10 +0x20
+
+goroutine 3 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+main.main()
+	/app/main.go:10 +0x20
+`
+
+	snapshot, err := New().ParseBytes([]byte(dump), "test-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Groups) != 2 {
+		t.Fatalf("Expected 2 groups for 2 lines, got %d", len(snapshot.Groups))
+	}
+	for _, g := range snapshot.Groups {
+		if want := map[int]int{25: 2, 31: 1}[g.Trace[0].Line]; g.Count != want {
+			t.Errorf("Expected %d goroutines at line %d, got %d", want, g.Trace[0].Line, g.Count)
+		}
+	}
+}
+
+func TestParseNormalizeGenerics(t *testing.T) {
+	// debug=1 dumps name instantiations by their shape types
+	dump := `2 @ 0x43e6cf 0x40ecb1
+#	0x43e6ce	example.com/lib.Map[go.shape.int].func1+0x4e	/app/lib/map.go:42
+#	0x40ecb0	main.main+0x30	/app/main.go:10
+
+3 @ 0x43e6cf 0x40ecb2
+#	0x43e6ce	example.com/lib.Map[go.shape.struct { Name string; Tags []string }].func1+0x4e	/app/lib/map.go:42
+#	0x40ecb0	main.main+0x30	/app/main.go:10
+`
+
+	tests := []struct {
+		normalize bool
+		groups    int
+	}{
+		{false, 2},
+		{true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("normalize %v", tt.normalize), func(t *testing.T) {
+			p := New(WithNormalizeGenerics(tt.normalize))
+			snapshot, err := p.ParseBytes([]byte(dump), "test-host")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(snapshot.Groups) != tt.groups {
+				t.Fatalf("Expected %d groups, got %d", tt.groups, len(snapshot.Groups))
+			}
+			for _, g := range snapshot.Groups {
+				if !strings.HasPrefix(g.Trace[0].Func, "example.com/lib.Map[go.shape.") {
+					t.Errorf("Expected the full generic name in the trace, got %q", g.Trace[0].Func)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGroupByArgs(t *testing.T) {
+	// The first two differ only by a request ID and a pointer
+	dump := `goroutine 1 [select]:
+main.handle(12345, 0xc000010000)
+	/app/main.go:20 +0x40
+
+goroutine 2 [select]:
+main.handle(12346, 0xc000020000)
+	/app/main.go:20 +0x40
+
+goroutine 3 [select]:
+main.handle(7)
+	/app/main.go:20 +0x40
+`
+
+	tests := []struct {
+		group  bool
+		groups int
+		args   []string
+	}{
+		{false, 1, []string{""}},
+		{true, 2, []string{"(?)", "(?, 0x?)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("group %v", tt.group), func(t *testing.T) {
+			p := New(WithGroupByArgs(tt.group))
+			snapshot, err := p.ParseBytes([]byte(dump), "test-host")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(snapshot.Groups) != tt.groups {
+				t.Fatalf("Expected %d groups, got %d", tt.groups, len(snapshot.Groups))
+			}
+			var args []string
+			for _, g := range snapshot.Groups {
+				if g.Trace[0].Func != "main.handle" {
+					t.Errorf("Func = %q, want main.handle", g.Trace[0].Func)
+				}
+				args = append(args, g.Trace[0].Args)
+			}
+			slices.Sort(args)
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("Args = %q, want %q", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestParseMaxGoroutines(t *testing.T) {
+	simple, err := os.ReadFile(filepath.Join("testdata", "simple.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	debug1, err := os.ReadFile(filepath.Join("testdata", "debug1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		max       int
+		total     int
+		truncated bool
+	}{
+		{"no limit", simple, 0, 4, false},
+		{"under the limit", simple, 10, 4, false},
+		{"exactly the limit", simple, 4, 4, false},
+		{"over the limit", simple, 2, 2, true},
+		{"debug=1 under the limit", debug1, 6, 6, false},
+		{"debug=1 record cut", debug1, 4, 4, true},
+		{"debug=1 at a record boundary", debug1, 5, 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := New(WithMaxGoroutines(tt.max)).ParseBytes(tt.data, "test-host")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total := snapshot.TotalGoroutines(); total != tt.total {
+				t.Errorf("Expected %d goroutines, got %d", tt.total, total)
+			}
+			if snapshot.Truncated != tt.truncated {
+				t.Errorf("Truncated = %v, want %v", snapshot.Truncated, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestParseCreatedByGoroutine(t *testing.T) {
+	dump := `goroutine 10 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+created by main.startWorkers in goroutine 1
+	/app/main.go:15 +0x30
+
+goroutine 11 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+created by main.startWorkers in goroutine 1
+	/app/main.go:15 +0x30
+
+goroutine 20 [IO wait]:
+main.handle()
+	/app/server.go:40 +0x50
+created by main.serve in goroutine 7
+	/app/server.go:30 +0x20
+
+goroutine 21 [IO wait]:
+main.handle()
+	/app/server.go:40 +0x50
+created by main.serve in goroutine 8
+	/app/server.go:30 +0x20
+`
+
+	snapshot, err := New().ParseBytes([]byte(dump), "test-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		fn        string
+		createdBy string
+		parent    uint64
+	}{
+		{"main.worker", "main.startWorkers", 1},
+		// Spawned by different goroutines, so there's no single parent
+		{"main.handle", "main.serve", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			var group *model.Group
+			for _, g := range snapshot.Groups {
+				if g.Trace[0].Func == tt.fn {
+					group = g
+				}
+			}
+			if group == nil {
+				t.Fatalf("Missing %s group", tt.fn)
+			}
+			if group.CreatedBy == nil || group.CreatedBy.Func != tt.createdBy {
+				t.Errorf("Expected created by %s, got %+v", tt.createdBy, group.CreatedBy)
+			}
+			if group.CreatedByGoroutine != tt.parent {
+				t.Errorf("CreatedByGoroutine = %d, want %d", group.CreatedByGoroutine, tt.parent)
+			}
+		})
+	}
+}
+
+func TestParseDebug1(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "debug1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New()
+	snapshot, err := p.ParseBytes(data, "test-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total := snapshot.TotalGoroutines(); total != 6 {
+		t.Errorf("Expected 6 goroutines, got %d", total)
+	}
+
+	if len(snapshot.Groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %d", len(snapshot.Groups))
+	}
+
+	var workers *model.Group
+	for _, g := range snapshot.Groups {
+		if g.State != model.StateUnknown {
+			t.Errorf("Expected unknown state, got %s", g.State)
+		}
+		if g.Trace[0].Func == "main.worker" {
+			workers = g
+		}
+	}
+
+	if workers == nil {
+		t.Fatal("Missing worker group")
+	}
+	if workers.Count != 3 {
+		t.Errorf("Worker group should have count 3, got %d", workers.Count)
+	}
+	want := model.StackFrame{Func: "main.startWorkers.func1", File: "/app/main.go", Line: 15}
+	if len(workers.Trace) != 2 || workers.Trace[1] != want {
+		t.Errorf("Unexpected worker trace: %v", workers.Trace)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	debug2 := `goroutine 1 [chan receive]:
+# labels: {"handler":"/api", "tenant":"acme"}
+main.handle()
+	/app/main.go:20 +0x1d
+
+goroutine 2 [chan receive]:
+# labels: {"handler":"/api", "tenant":"globex"}
+main.handle()
+	/app/main.go:20 +0x1d
+
+goroutine 3 [chan receive]:
+main.handle()
+	/app/main.go:20 +0x1d
+`
+	debug1 := `goroutine profile: total 5
+3 @ 0x1 0x2
+# labels: {"tenant":"acme"}
+#	0x1	main.handle+0x1d	/app/main.go:20
+
+2 @ 0x1 0x2
+#	0x1	main.handle+0x1d	/app/main.go:20
+`
+
+	tests := []struct {
+		name    string
+		dump    string
+		byLabel bool
+		want    map[string]int // goroutines by tenant, "" for none
+	}{
+		{"debug=2", debug2, false, map[string]int{"": 3}},
+		{"debug=2 by labels", debug2, true, map[string]int{"acme": 1, "globex": 1, "": 1}},
+		{"debug=1", debug1, false, map[string]int{"": 5}},
+		{"debug=1 by labels", debug1, true, map[string]int{"acme": 3, "": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := New(WithGroupByLabels(tt.byLabel)).ParseBytes([]byte(tt.dump), "test-host")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(snapshot.Groups) != len(tt.want) {
+				t.Fatalf("Expected %d groups, got %d", len(tt.want), len(snapshot.Groups))
+			}
+			for _, g := range snapshot.Groups {
+				if g.Count != tt.want[g.Labels["tenant"]] {
+					t.Errorf("Tenant %q: got %d goroutines, want %d", g.Labels["tenant"], g.Count, tt.want[g.Labels["tenant"]])
+				}
+				// The labels line isn't taken for a frame
+				if len(g.Trace) != 1 || g.Trace[0].Func != "main.handle" {
+					t.Errorf("Unexpected trace: %v", g.Trace)
+				}
+			}
+		})
+	}
+}
+
+func TestParseWarnings(t *testing.T) {
+	// Complete dumps parse without warnings
+	for _, name := range []string{"simple.txt", "debug1.txt", "elided.txt"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshot, err := New().ParseBytes(data, "test-host")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snapshot.Warnings) > 0 {
+			t.Errorf("%s: unexpected warnings %v", name, snapshot.Warnings)
+		}
+	}
+
+	complete := "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x20\n\n"
+	tests := []struct {
+		name       string
+		dump       string
+		goroutines int
+		want       string
+	}{
+		{"cut after a header", complete + "goroutine 2 [chan receive]:\n", 1,
+			"final goroutine 2 cut off before its stack and left out"},
+		{"cut in a header", complete + "goroutine 2 [chan rec", 1,
+			"final goroutine cut off in its header and left out"},
+		{"cut in a function", complete + "goroutine 2 [chan receive]:\nmain.worker()\n\t/app/worker.go:25 +0x100\nmain.st", 2,
+			"final goroutine 2 cut off, its stack is incomplete"},
+		{"cut in a file line", complete + "goroutine 2 [chan receive]:\nmain.worker()\n\t/app/worker.go:25 +0x100\nmain.start()\n\t/app/wor", 2,
+			"final goroutine 2 cut off, its stack is incomplete"},
+		{"cut in a record", "goroutine profile: total 3\n3 @ 0x1 0x2\n#\t0x1\tmain.worker+0x1d\t/app/main.go:10\n#\t0x2\tmain.st", 3,
+			"final record cut off, its stack is incomplete"},
+		{"bad header", "goroutine 7 gp=0x1 [?:\nmain.lost()\n\t/app/lost.go:1 +0x1\n\n" + complete, 1,
+			"1 goroutine header line(s) couldn't be read"},
+		{"bad frame", "goroutine 1 [running]:\nmain.main()\nnot a file line\n\n", 0,
+			"1 stack frame line(s) couldn't be read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := New().ParseBytes([]byte(tt.dump), "test-host")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := snapshot.TotalGoroutines(); got != tt.goroutines {
+				t.Errorf("Expected %d goroutines, got %d", tt.goroutines, got)
+			}
+			if len(snapshot.Warnings) != 1 || snapshot.Warnings[0] != tt.want {
+				t.Errorf("Warnings = %q, want [%q]", snapshot.Warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNoFrames(t *testing.T) {
+	dump := `goroutine 1 [running]:
+
+goroutine 2 [running]:
+	goroutine running on other thread; stack unavailable
+
+goroutine 3 [chan receive]:
+main.worker()
+	/app/worker.go:25 +0x100
+
+`
+	snapshot, err := New().ParseBytes([]byte(dump), "test-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Goroutines without a frame are left out rather than kept with an
+	// empty trace
+	if got := snapshot.TotalGoroutines(); got != 1 {
+		t.Errorf("Expected 1 goroutine, got %d", got)
+	}
+	for _, g := range snapshot.Groups {
+		if len(g.Trace) == 0 {
+			t.Errorf("Group %s has an empty trace", g.ID)
+		}
+	}
+	want := "2 goroutine(s) without stack frames left out"
+	if len(snapshot.Warnings) != 1 || snapshot.Warnings[0] != want {
+		t.Errorf("Warnings = %q, want [%q]", snapshot.Warnings, want)
+	}
+}
+
+func TestParseLineEndings(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "simple.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delve, err := os.ReadFile(filepath.Join("testdata", "delve.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bom := []byte("\xef\xbb\xbf")
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"crlf", bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n")), 4},
+		{"bom", append(bom, data...), 4},
+		{"bom and crlf", append(bom, bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))...), 4},
+		{"lone cr", bytes.ReplaceAll(data, []byte("\n"), []byte("\r")), 4},
+		{"delve crlf", append(bom, bytes.ReplaceAll(delve, []byte("\n"), []byte("\r\n"))...), 5},
+	}
+
+	want, err := New().ParseBytes(data, "test-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := New().ParseBytes(tt.data, "test-host")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total := snapshot.TotalGoroutines(); total != tt.want {
+				t.Errorf("Expected %d goroutines, got %d", tt.want, total)
+			}
+			if tt.want != 4 {
+				return
+			}
+			for id, g := range want.Groups {
+				got, ok := snapshot.Groups[id]
+				if !ok {
+					t.Errorf("Missing group %s", id)
+					continue
+				}
+				if got.Count != g.Count || got.State != g.State || len(got.Trace) != len(g.Trace) {
+					t.Errorf("Group %s differs: got %+v, want %+v", id, got, g)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDelve(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "delve.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parse recognizes the format on its own
+	snapshot, err := New().ParseBytes(data, "core")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total := snapshot.TotalGoroutines(); total != 5 {
+		t.Errorf("Expected 5 goroutines, got %d", total)
+	}
+	if len(snapshot.Groups) != 4 {
+		t.Fatalf("Expected 4 groups, got %d", len(snapshot.Groups))
+	}
+
+	byFunc := map[string]*model.Group{}
+	for _, g := range snapshot.Groups {
+		byFunc[g.Trace[0].Func] = g
+	}
+
+	if g := byFunc["main.main"]; g == nil || g.State != model.StateRunning {
+		t.Errorf("Expected running main.main group, got %+v", g)
+	}
+	if g := byFunc["runtime.gopark"]; g == nil {
+		t.Fatal("Missing gopark groups")
+	}
+
+	var workers, server *model.Group
+	for _, g := range snapshot.Groups {
+		for _, f := range g.Trace {
+			switch f.Func {
+			case "main.worker":
+				workers = g
+			case "main.(*server).serve":
+				server = g
+			}
+		}
+	}
+	if workers == nil || workers.Count != 2 || workers.State != model.StateBlocked || workers.BlockReason != "chan receive" {
+		t.Fatalf("Expected 2 workers blocked on chan receive, got %+v", workers)
+	}
+	want := model.StackFrame{Func: "main.worker", File: "./worker.go", Line: 25}
+	if len(workers.Trace) != 4 || workers.Trace[2] != want {
+		t.Errorf("Unexpected worker trace: %v", workers.Trace)
+	}
+	if server == nil || server.State != model.StateBlocked {
+		t.Fatalf("Expected blocked server group, got %+v", server)
+	}
+	wantCreated := model.StackFrame{Func: "main.main", File: "./main.go", Line: 15}
+	if server.CreatedBy == nil || *server.CreatedBy != wantCreated {
+		t.Errorf("Unexpected created by: %v", server.CreatedBy)
+	}
+
+	// The limit applies as it does to runtime dumps
+	snapshot, err = New(WithMaxGoroutines(2)).ParseBytes(data, "core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := snapshot.TotalGoroutines(); total != 2 || !snapshot.Truncated {
+		t.Errorf("Expected 2 goroutines and truncation, got %d (truncated %v)", total, snapshot.Truncated)
+	}
+}
+
+func TestParseElidedFrames(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "elided.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New()
+	snapshot, err := p.ParseBytes(data, "test-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshot.Groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %d", len(snapshot.Groups))
+	}
+
+	var recursive *model.Group
+	for _, g := range snapshot.Groups {
+		if g.Trace[0].Func == "main.recurse" {
+			recursive = g
+		}
+	}
+	if recursive == nil {
+		t.Fatal("Missing recursive group")
+	}
+
+	expected := []model.StackFrame{
+		{Func: "main.recurse", File: "/app/recurse.go", Line: 12},
+		{Func: "main.recurse", File: "/app/recurse.go", Line: 14},
+		{Func: model.ElidedFrames},
+		{Func: "main.recurse", File: "/app/recurse.go", Line: 14},
+		{Func: "main.start", File: "/app/recurse.go", Line: 20},
+	}
+	if len(recursive.Trace) != len(expected) {
+		t.Fatalf("Expected %d frames, got %v", len(expected), recursive.Trace)
+	}
+	for i, frame := range expected {
+		if recursive.Trace[i] != frame {
+			t.Errorf("Frame %d = %+v, want %+v", i, recursive.Trace[i], frame)
+		}
+	}
+
+	if recursive.CreatedBy == nil || recursive.CreatedBy.Func != "main.main" {
+		t.Errorf("Expected created by main.main, got %+v", recursive.CreatedBy)
+	}
+}
+
+func TestWriteDumpRoundTrip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "simple.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New()
+	original, err := p.ParseBytes(data, "test-host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Also cover states that only exist in summarized form
+	original.AddLabeledGroup(3, model.StateUnknown, model.StackTrace{{Func: "main.poll", File: "/app/poll.go", Line: 7}},
+		map[string]string{"tenant": "acme"})
+
+	var buf bytes.Buffer
+	if err := WriteDump(&buf, original); err != nil {
+		t.Fatalf("WriteDump failed: %v", err)
+	}
+
+	parsed, err := p.Parse(&buf, "test-host")
+	if err != nil {
+		t.Fatalf("Parsing the written dump failed: %v", err)
+	}
+
+	if len(parsed.Groups) != len(original.Groups) {
+		t.Fatalf("Got %d groups back, want %d", len(parsed.Groups), len(original.Groups))
+	}
+	for id, want := range original.Groups {
+		got, ok := parsed.Groups[id]
+		if !ok {
+			t.Errorf("Group %s (%s) missing after the round trip", id, want.Trace[0].Func)
+			continue
+		}
+		if got.Count != want.Count || got.State != want.State || got.BlockReason != want.BlockReason {
+			t.Errorf("Group %s: got %d %s (%s), want %d %s (%s)", id, got.Count, got.State, got.BlockReason, want.Count, want.State, want.BlockReason)
+		}
+		if len(got.WaitDurations) != len(want.WaitDurations) {
+			t.Errorf("Group %s: got waits %v, want %v", id, got.WaitDurations, want.WaitDurations)
+		}
+		if !maps.Equal(got.Labels, want.Labels) {
+			t.Errorf("Group %s: got labels %v, want %v", id, got.Labels, want.Labels)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Package record keeps every snapshot collected as a gzipped JSON file, and
+// reads a directory of them back as a timeline for replay
+package record
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anyproto/goru/internal/collector/file"
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// Ext ends the name of every recorded snapshot
+const Ext = ".json.gz"
+
+// ErrNoSnapshots is returned by Load for a directory without recordings
+var ErrNoSnapshots = errors.New("no recorded snapshots")
+
+// nameTime puts the snapshot time in file names the way file.TimestampName
+// reads it, with milliseconds so a host's snapshots don't collide
+const nameTime = "20060102-150405.000"
+
+// unsafeName matches the characters of a host that don't belong in a file
+// name, such as the colon before a port
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Recorder writes the snapshots put in a store to a directory
+type Recorder struct {
+	dir    string
+	logger telemetry.Logger
+}
+
+// New returns a recorder writing to dir, creating it if needed
+func New(dir string, logger telemetry.Logger) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, logger: logger}, nil
+}
+
+// Start writes every snapshot the store receives until ctx is done. Each is
+// written before the store takes the next, so none are missed however slow
+// the disk.
+func (r *Recorder) Start(ctx context.Context, s *store.Store) {
+	// Hook in before returning so no snapshot collected after Start is
+	// missed
+	remove := s.OnSnapshot(func(snapshot *model.Snapshot) {
+		if _, err := r.Write(snapshot); err != nil {
+			r.logger.Error("Recording snapshot failed", telemetry.String("host", snapshot.Host), telemetry.Error(err))
+		}
+	})
+
+	go func() {
+		<-ctx.Done()
+		remove()
+	}()
+}
+
+// Write saves one snapshot as <host>-<time>.json.gz and returns its path
+func (r *Recorder) Write(snapshot *model.Snapshot) (string, error) {
+	name := unsafeName.ReplaceAllString(snapshot.Host, "_") + "-" + snapshot.TakenAt.Local().Format(nameTime) + Ext
+	path := filepath.Join(r.dir, name)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	zw := gzip.NewWriter(f)
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		f.Close()
+		return "", fmt.Errorf("encoding snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// Load reads every snapshot recorded in dir, oldest first, keeping the
+// hosts they were collected from. Other files are skipped.
+func Load(dir string) ([]*model.Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*model.Snapshot
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), Ext) {
+			continue
+		}
+		snapshot, err := read(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", entry.Name(), err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoSnapshots, dir)
+	}
+
+	// ReadDir sorts by name, so a stable sort keeps names as the tie-break
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].TakenAt.Before(snapshots[j].TakenAt)
+	})
+	return snapshots, nil
+}
+
+func read(path string) (*model.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader, err := file.Decompress(f)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var snapshot model.Snapshot
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("decoding snapshot: %w", err)
+	}
+	if snapshot.Groups == nil {
+		snapshot.Groups = make(map[model.GroupID]*model.Group)
+	}
+	return &snapshot, nil
+}
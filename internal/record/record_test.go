@@ -0,0 +1,120 @@
+package record
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+func snapshot(host string, minute, n int) *model.Snapshot {
+	return &model.Snapshot{
+		Host:    host,
+		TakenAt: time.Date(2024, 1, 31, 10, minute, 0, 0, time.Local),
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: n, Trace: model.StackTrace{{Func: "main.worker", File: "/app/main.go", Line: 10}}},
+		},
+	}
+}
+
+func TestWriteLoad(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(filepath.Join(dir, "incident"), telemetry.NewLogger("error", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*model.Snapshot{snapshot("b:6060", 2, 3), snapshot("a:6060", 1, 1)} {
+		if _, err := r.Write(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path, err := r.Write(snapshot("a:6060", 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := filepath.Base(path); name != "a_6060-20240131-100000.000.json.gz" {
+		t.Errorf("Unexpected file name %s", name)
+	}
+	// Other files in the directory are left alone
+	if err := os.WriteFile(filepath.Join(dir, "incident", "notes.txt"), []byte("notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := Load(filepath.Join(dir, "incident"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range snapshots {
+		got = append(got, s.Host+"/"+s.TakenAt.Format("04"))
+	}
+	if want := "a:6060/00 a:6060/01 b:6060/02"; strings.Join(got, " ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, " "))
+	}
+	if g := snapshots[2].Groups["g1"]; g == nil || g.Count != 3 || g.Trace[0].Func != "main.worker" {
+		t.Errorf("Expected the group to survive the round trip, got %+v", g)
+	}
+
+	if _, err := Load(dir); !errors.Is(err, ErrNoSnapshots) {
+		t.Errorf("Expected ErrNoSnapshots without recordings, got %v", err)
+	}
+}
+
+func TestRecorderStart(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(dir, telemetry.NewLogger("error", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := store.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx, s)
+
+	s.UpdateSnapshot(snapshot("host", 0, 1), nil)
+	s.UpdateSnapshot(snapshot("host", 1, 2), nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		snapshots, _ := Load(dir)
+		if len(snapshots) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for 2 recorded snapshots, got %d", len(snapshots))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecorderStartBurst(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(dir, telemetry.NewLogger("error", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := store.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Start(ctx, s)
+
+	// Snapshots are written as they're stored, so a burst is recorded whole
+	for i := range 150 {
+		s.UpdateSnapshot(snapshot(fmt.Sprintf("host%d", i), 0, 1), nil)
+	}
+	snapshots, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 150 {
+		t.Errorf("Expected 150 recorded snapshots, got %d", len(snapshots))
+	}
+}
@@ -0,0 +1,235 @@
+// Package replay plays back a directory of archived goroutine dumps, one
+// frame at a time, for post-mortem browsing in the TUI
+package replay
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyproto/goru/internal/collector/file"
+	"github.com/anyproto/goru/internal/diff"
+	"github.com/anyproto/goru/internal/parser"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// Order selects how the dumps of a directory are put in sequence
+type Order string
+
+const (
+	OrderTime Order = "time" // by the timestamp in the file name, else the modification time
+	OrderName Order = "name" // by file name
+)
+
+// Load parses every dump in dir into a frame, in order, all under host.
+// Hidden files and subdirectories are skipped.
+func Load(dir, host string, order Order, parserOpts ...parser.Option) ([]*model.Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var frames []*model.Snapshot
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		snapshot, err := file.LoadAt(filepath.Join(dir, entry.Name()), file.TimestampName, parserOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", entry.Name(), err)
+		}
+		snapshot.Host = host
+		frames = append(frames, snapshot)
+		names = append(names, entry.Name())
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no dumps in %s", dir)
+	}
+
+	// ReadDir sorts by name, so a stable sort keeps names as the tie-break
+	if order == OrderTime {
+		sort.Stable(byTime(frames))
+	}
+	return frames, nil
+}
+
+type byTime []*model.Snapshot
+
+func (f byTime) Len() int           { return len(f) }
+func (f byTime) Less(i, j int) bool { return f[i].TakenAt.Before(f[j].TakenAt) }
+func (f byTime) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// Player shows one frame of a timeline at a time in the store, with the
+// changes since the frame before it, so the views diff consecutive frames
+// whichever way the timeline is stepped. A timeline of several hosts, such
+// as a recording, shows each host's last frame up to the current one.
+type Player struct {
+	store  *store.Store
+	frames []*model.Snapshot
+	rate   time.Duration
+	diff   *diff.Diff
+
+	mu      sync.Mutex
+	pos     int
+	shown   map[string]int // frame shown for each host
+	playing bool
+	wake    chan struct{} // restarts the playback timer after a change
+}
+
+// New returns a player for frames (oldest first), advancing one frame per
+// rate while playing
+func New(s *store.Store, frames []*model.Snapshot, rate time.Duration) *Player {
+	return &Player{
+		store:  s,
+		frames: frames,
+		rate:   rate,
+		diff:   diff.New(),
+		pos:    -1,
+		shown:  make(map[string]int),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Run shows the first frame and plays the timeline until ctx is done.
+// Playback stops at the last frame.
+func (p *Player) Run(ctx context.Context) {
+	p.mu.Lock()
+	p.show(0)
+	p.playing = true
+	p.mu.Unlock()
+
+	timer := time.NewTimer(p.rate)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+			// Count the full rate from the change
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-timer.C:
+			p.mu.Lock()
+			if p.playing {
+				if p.pos+1 < len(p.frames) {
+					p.show(p.pos + 1)
+				}
+				p.playing = p.pos+1 < len(p.frames)
+			}
+			p.mu.Unlock()
+		}
+		timer.Reset(p.rate)
+	}
+}
+
+// show moves the timeline to frame i, putting in the store the last frame
+// of every host up to it that isn't shown yet; p.mu must be held
+func (p *Player) show(i int) {
+	if i == p.pos {
+		return
+	}
+	p.pos = i
+
+	var hosts []string
+	latest := make(map[string]int)
+	for j, frame := range p.frames[:i+1] {
+		if _, ok := latest[frame.Host]; !ok {
+			hosts = append(hosts, frame.Host)
+		}
+		latest[frame.Host] = j
+	}
+	for _, host := range hosts {
+		j := latest[host]
+		shown, ok := p.shown[host]
+		if ok && shown == j {
+			continue
+		}
+		if !ok {
+			// Hosts taken away by stepping back need registering again
+			p.store.RegisterHosts([]string{host})
+		}
+		p.shown[host] = j
+		p.store.UpdateSnapshot(p.frames[j], p.diff.Compare(p.previous(j), p.frames[j]))
+	}
+
+	// Stepping back before a host's first frame takes it away again
+	for host := range p.shown {
+		if _, ok := latest[host]; !ok {
+			delete(p.shown, host)
+			p.store.RemoveHost(host)
+		}
+	}
+}
+
+// previous returns the frame of the same host before frame i, or nil
+func (p *Player) previous(i int) *model.Snapshot {
+	for j := i - 1; j >= 0; j-- {
+		if p.frames[j].Host == p.frames[i].Host {
+			return p.frames[j]
+		}
+	}
+	return nil
+}
+
+func (p *Player) poke() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Step moves n frames forward, or back for a negative n, stopping at either
+// end, and pauses playback
+func (p *Player) Step(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.playing = false
+	p.show(min(max(p.pos+n, 0), len(p.frames)-1))
+	p.poke()
+}
+
+// SetPlaying starts or pauses playback. Playing from the last frame starts
+// over from the first.
+func (p *Player) SetPlaying(playing bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if playing && p.pos == len(p.frames)-1 {
+		p.show(0)
+	}
+	p.playing = playing
+	p.poke()
+}
+
+// Seek shows the last frame taken at or before t, or the first frame if
+// they were all taken later. Playback carries on from there if it was on.
+func (p *Player) Seek(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := sort.Search(len(p.frames), func(i int) bool {
+		return p.frames[i].TakenAt.After(t)
+	})
+	p.show(max(i-1, 0))
+	p.poke()
+}
+
+// Position returns the frame shown, counted from 1, the number of frames,
+// when the frame was taken and whether the timeline is playing
+func (p *Player) Position() (frame, total int, takenAt time.Time, playing bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pos >= 0 {
+		takenAt = p.frames[p.pos].TakenAt
+	}
+	return p.pos + 1, len(p.frames), takenAt, p.playing
+}
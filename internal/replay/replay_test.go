@@ -0,0 +1,189 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// writeDump writes a dump of n goroutines blocked in main.worker
+func writeDump(t *testing.T, dir, name string, n int) {
+	t.Helper()
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "goroutine %d [chan receive]:\nmain.worker()\n\t/app/main.go:10 +0x1d\n\n", i+1)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	// Names sort differently from the timestamps in them
+	writeDump(t, dir, "b-20240131-100000.txt", 1)
+	writeDump(t, dir, "a-20240131-100200.txt", 3)
+	writeDump(t, dir, "c-20240131-100100.txt", 2)
+	writeDump(t, dir, ".hidden.txt", 9)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		order Order
+		want  []int // goroutines per frame
+	}{
+		{OrderTime, []int{1, 2, 3}},
+		{OrderName, []int{3, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			frames, err := Load(dir, "replay", tt.order)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			var got []int
+			for _, f := range frames {
+				if f.Host != "replay" {
+					t.Errorf("Expected host replay, got %s", f.Host)
+				}
+				got = append(got, f.TotalGoroutines())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected frames %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := Load(t.TempDir(), "replay", OrderTime); err == nil {
+		t.Error("Expected an error for a directory without dumps")
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	dir := t.TempDir()
+	writeDump(t, dir, "dump-20240131-100000.txt", 1)
+	writeDump(t, dir, "dump-20240131-100100.txt", 4)
+	writeDump(t, dir, "dump-20240131-100200.txt", 2)
+	frames, err := Load(dir, "replay", OrderTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := store.New()
+	p := New(s, frames, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	// The first frame shows up straight away
+	deadline := time.Now().Add(5 * time.Second)
+	for s.GetSnapshot("replay") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the first frame")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// shown checks the frame in the store and its delta from the one before
+	shown := func(frame, goroutines, delta int) {
+		t.Helper()
+		if got, _, _, _ := p.Position(); got != frame {
+			t.Errorf("Expected frame %d, got %d", frame, got)
+		}
+		if got := s.GetSnapshot("replay").TotalGoroutines(); got != goroutines {
+			t.Errorf("Expected %d goroutines, got %d", goroutines, got)
+		}
+		changes := s.GetChangeSet("replay")
+		got := 0
+		for _, d := range changes.Updated {
+			got += d
+		}
+		if got != delta {
+			t.Errorf("Expected a delta of %d, got %d", delta, got)
+		}
+	}
+
+	p.Step(1)
+	shown(2, 4, 3)
+	if _, _, _, playing := p.Position(); playing {
+		t.Error("Expected stepping to pause playback")
+	}
+	p.Step(5)
+	shown(3, 2, -2)
+
+	// Stepping back diffs against the frame before, not the one left
+	p.Step(-1)
+	shown(2, 4, 3)
+	p.Step(-5)
+	if got, _, _, _ := p.Position(); got != 1 {
+		t.Errorf("Expected to stop at the first frame, got %d", got)
+	}
+
+	p.Seek(time.Date(2024, 1, 31, 10, 1, 30, 0, time.Local))
+	shown(2, 4, 3)
+	p.Seek(time.Date(2024, 1, 31, 9, 0, 0, 0, time.Local))
+	if got, _, _, _ := p.Position(); got != 1 {
+		t.Errorf("Expected a seek before the start to show the first frame, got %d", got)
+	}
+
+	// Playing from the end starts over
+	p.Step(2)
+	p.SetPlaying(true)
+	frame, total, takenAt, playing := p.Position()
+	if frame != 1 || total != 3 || !playing {
+		t.Errorf("Expected to play from frame 1 of 3, got %d of %d, playing %v", frame, total, playing)
+	}
+	if want := time.Date(2024, 1, 31, 10, 0, 0, 0, time.Local); !takenAt.Equal(want) {
+		t.Errorf("Expected the frame taken at %v, got %v", want, takenAt)
+	}
+}
+
+func TestPlayerHosts(t *testing.T) {
+	frame := func(host string, minute, n int) *model.Snapshot {
+		return &model.Snapshot{
+			Host:    host,
+			TakenAt: time.Date(2024, 1, 31, 10, minute, 0, 0, time.Local),
+			Groups: map[model.GroupID]*model.Group{
+				"g1": {ID: "g1", State: model.StateWaiting, Count: n, Trace: model.StackTrace{{Func: "main.worker"}}},
+			},
+		}
+	}
+	frames := []*model.Snapshot{frame("a", 0, 1), frame("b", 1, 5), frame("a", 2, 3), frame("b", 3, 6)}
+
+	s := store.New()
+	p := New(s, frames, time.Hour)
+	p.Seek(time.Date(2024, 1, 31, 11, 0, 0, 0, time.Local))
+
+	// Each host shows its last frame, diffed against its own frame before
+	for host, want := range map[string]int{"a": 3, "b": 6} {
+		if got := s.GetSnapshot(host).TotalGoroutines(); got != want {
+			t.Errorf("Expected %d goroutines on %s, got %d", want, host, got)
+		}
+	}
+	if got := s.GetChangeSet("a").Updated["g1"]; got != 2 {
+		t.Errorf("Expected a delta of 2 on a, got %d", got)
+	}
+
+	// Stepping back puts earlier frames back and drops hosts not seen yet
+	p.Step(-3)
+	if got := s.GetSnapshot("a").TotalGoroutines(); got != 1 {
+		t.Errorf("Expected a back at 1 goroutine, got %d", got)
+	}
+	if s.GetSnapshot("b") != nil {
+		t.Error("Expected b gone before its first frame")
+	}
+
+	// ...and stepping forward again brings them back
+	p.Step(1)
+	if s.GetSnapshot("b") == nil {
+		t.Error("Expected b back at its first frame")
+	}
+}
@@ -0,0 +1,236 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/anyproto/goru/internal/analysis"
+	"github.com/anyproto/goru/internal/diff"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// stateOrder is the order goroutine states are summed up in
+var stateOrder = []model.GoroutineState{
+	model.StateRunning,
+	model.StateRunnable,
+	model.StateBlocked,
+	model.StateWaiting,
+	model.StateSyscall,
+	model.StateUnknown,
+}
+
+// Write prints a plain-text summary of every host in the store: goroutine
+// totals, errors, the top groups by count, and the groups whose count kept
+// growing across the host's history. With several hosts it ends with the
+// goroutines of all of them by state.
+func Write(w io.Writer, s *store.Store, top int) error {
+	hosts := s.GetAllHosts()
+	sort.Strings(hosts)
+	errors := s.GetErrors()
+
+	for i, host := range hosts {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		snapshot := s.GetSnapshot(host)
+		if err, ok := errors[host]; ok {
+			if _, werr := fmt.Fprintf(w, "%s: error: %v\n", host, err); werr != nil {
+				return werr
+			}
+			if snapshot == nil {
+				continue
+			}
+		}
+		if snapshot == nil {
+			if _, err := fmt.Fprintf(w, "%s: no data\n", host); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := writeSnapshot(w, snapshot, top); err != nil {
+			return err
+		}
+		if err := writeLeaks(w, analysis.DetectLeaks(s.GetHistory(host))); err != nil {
+			return err
+		}
+	}
+
+	if len(hosts) > 1 {
+		return writeTotals(w, s.GetStats())
+	}
+	return nil
+}
+
+// writeTotals prints the goroutines across all hosts, e.g.
+// "all hosts: 14 goroutines (running:1 blocked:10 waiting:3)"
+func writeTotals(w io.Writer, stats store.Stats) error {
+	var parts []string
+	for _, state := range stateOrder {
+		if n := stats.GoroutinesByState[state]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s:%d", state, n))
+		}
+	}
+	summary := ""
+	if len(parts) > 0 {
+		summary = " (" + strings.Join(parts, " ") + ")"
+	}
+	_, err := fmt.Fprintf(w, "\nall hosts: %d goroutines%s\n", stats.TotalGoroutines, summary)
+	return err
+}
+
+// OverLimit returns the hosts with more goroutines than limit, in order
+func OverLimit(s *store.Store, limit int) []string {
+	var hosts []string
+	for _, snapshot := range s.GetAllSnapshots() {
+		if snapshot.TotalGoroutines() > limit {
+			hosts = append(hosts, snapshot.Host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func writeLeaks(w io.Writer, candidates []analysis.LeakCandidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "  leak candidates:\n"); err != nil {
+		return err
+	}
+	for _, c := range candidates {
+		if _, err := fmt.Fprintf(w, "  %7s  %d -> %d (%.1f/min) %s\n",
+			fmt.Sprintf("+%d", c.Growth()), c.StartCount, c.CurrentCount, c.Rate, c.TopFrame.Func); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSnapshot(w io.Writer, snapshot *model.Snapshot, top int) error {
+	partial := ""
+	if snapshot.Truncated {
+		partial = " (truncated dump, counts are incomplete)"
+	} else if snapshot.Partial {
+		partial = " (partial dump, counts may be incomplete)"
+	}
+	if _, err := fmt.Fprintf(w, "%s: %d goroutines in %d groups%s\n",
+		snapshot.Host, snapshot.TotalGoroutines(), len(snapshot.Groups), partial); err != nil {
+		return err
+	}
+
+	groups := make([]*model.Group, 0, len(snapshot.Groups))
+	for _, g := range snapshot.Groups {
+		groups = append(groups, g)
+	}
+	sortByCount(groups)
+	if top > 0 && len(groups) > top {
+		groups = groups[:top]
+	}
+
+	for _, warning := range snapshot.Warnings {
+		if _, err := fmt.Fprintf(w, "  warning: %s\n", warning); err != nil {
+			return err
+		}
+	}
+	for _, g := range groups {
+		if _, err := fmt.Fprintf(w, "  %7d  %-10s %s\n", g.Count, g.State, topFunction(g)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteDiff prints the changes between two snapshots: a summary, then the
+// added and removed groups by size and the groups whose count changed by
+// how much
+func WriteDiff(w io.Writer, before, after *model.Snapshot, changes *model.ChangeSet, stats diff.DiffStats) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d goroutines in %d groups\n", before.Host, before.TotalGoroutines(), len(before.Groups))
+	fmt.Fprintf(&b, "%s: %d goroutines in %d groups\n", after.Host, after.TotalGoroutines(), len(after.Groups))
+	fmt.Fprintf(&b, "+%d/-%d goroutines | +%d/-%d groups | %d groups changed",
+		stats.TotalAdded, stats.TotalRemoved, stats.GroupsAdded, stats.GroupsRemoved, stats.GroupsWithChanges)
+	if stats.GroupsTransitioned > 0 {
+		fmt.Fprintf(&b, " | %d changed state", stats.GroupsTransitioned)
+	}
+	b.WriteString("\n")
+
+	writeGroups := func(title, sign string, groups []*model.Group) {
+		if len(groups) == 0 {
+			return
+		}
+		groups = append([]*model.Group(nil), groups...)
+		sortByCount(groups)
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, g := range groups {
+			fmt.Fprintf(&b, "  %7s  %-10s %s\n", sign+fmt.Sprint(g.Count), g.State, topFunction(g))
+		}
+	}
+	writeGroups("Added groups", "+", changes.Added)
+	writeGroups("Removed groups", "-", changes.Removed)
+
+	if len(changes.Updated) > 0 {
+		ids := make([]model.GroupID, 0, len(changes.Updated))
+		for id := range changes.Updated {
+			ids = append(ids, id)
+		}
+		// Largest changes first, growth before shrinkage of the same size
+		sort.Slice(ids, func(i, j int) bool {
+			di, dj := changes.Updated[ids[i]], changes.Updated[ids[j]]
+			if abs(di) != abs(dj) {
+				return abs(di) > abs(dj)
+			}
+			if di != dj {
+				return di > dj
+			}
+			return ids[i] < ids[j]
+		})
+
+		b.WriteString("\nChanged counts:\n")
+		for _, id := range ids {
+			g := after.Groups[id]
+			fmt.Fprintf(&b, "  %+7d  %-10s %s (%d -> %d)\n",
+				changes.Updated[id], g.State, topFunction(g), g.Count-changes.Updated[id], g.Count)
+		}
+	}
+
+	if len(changes.Transitioned) > 0 {
+		b.WriteString("\nChanged state:\n")
+		for _, t := range changes.Transitioned {
+			fmt.Fprintf(&b, "  %s -> %s  %s\n", t.From.State, t.To.State, topFunction(t.To))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func sortByCount(groups []*model.Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].ID < groups[j].ID
+	})
+}
+
+func topFunction(g *model.Group) string {
+	if len(g.Trace) == 0 {
+		return model.NoStack
+	}
+	return g.Trace[0].Func
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
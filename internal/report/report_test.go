@@ -0,0 +1,184 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/internal/analysis"
+	"github.com/anyproto/goru/internal/diff"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+func TestWrite(t *testing.T) {
+	s := store.New()
+	s.RegisterHosts([]string{"host1", "host2", "host3"})
+
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 10, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g2": {ID: "g2", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}},
+			"g3": {ID: "g3", State: model.StateWaiting, Count: 3, Trace: model.StackTrace{{Func: "net.(*netFD).Read"}}},
+		},
+	}, nil)
+	s.UpdateError("host2", errors.New("connection refused"))
+
+	var buf bytes.Buffer
+	if err := Write(&buf, s, 2); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"host1: 14 goroutines in 3 groups",
+		"10  blocked    main.worker",
+		"3  waiting    net.(*netFD).Read",
+		"host2: error: connection refused",
+		"host3: no data",
+		"all hosts: 14 goroutines (running:1 blocked:10 waiting:3)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Only the top 2 groups are listed
+	if strings.Contains(out, "main.main") {
+		t.Errorf("Output should be limited to top groups:\n%s", out)
+	}
+}
+
+func TestWritePartial(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 2, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+		Partial: true,
+	}, nil)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, s, 10); err != nil {
+		t.Fatal(err)
+	}
+	if want := "host1: 2 goroutines in 1 groups (partial dump, counts may be incomplete)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("Output missing %q:\n%s", want, buf.String())
+	}
+
+	// A dump cut at a configured limit is reported as truncated instead
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 2, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+		Truncated: true,
+	}, nil)
+
+	buf.Reset()
+	if err := Write(&buf, s, 10); err != nil {
+		t.Fatal(err)
+	}
+	if want := "host1: 2 goroutines in 1 groups (truncated dump, counts are incomplete)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("Output missing %q:\n%s", want, buf.String())
+	}
+
+	// Parse warnings are listed under the host
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 2, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+		Warnings: []string{"final goroutine 3 cut off before its stack and left out"},
+	}, nil)
+
+	buf.Reset()
+	if err := Write(&buf, s, 10); err != nil {
+		t.Fatal(err)
+	}
+	if want := "  warning: final goroutine 3 cut off before its stack and left out\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("Output missing %q:\n%s", want, buf.String())
+	}
+}
+
+func TestWriteLeaks(t *testing.T) {
+	s := store.New()
+	start := time.Now()
+	for i := range analysis.LeakWindow {
+		s.UpdateSnapshot(&model.Snapshot{
+			Host:    "host1",
+			TakenAt: start.Add(time.Duration(i) * time.Minute),
+			Groups: map[model.GroupID]*model.Group{
+				"g1": {ID: "g1", State: model.StateWaiting, Count: 10 + 5*i, Trace: model.StackTrace{{Func: "main.leaky"}}},
+				"g2": {ID: "g2", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}},
+			},
+		}, nil)
+	}
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host2",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 3, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+	}, nil)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, s, 10); err != nil {
+		t.Fatal(err)
+	}
+	if want := "leak candidates:\n      +20  10 -> 30 (5.0/min) main.leaky"; !strings.Contains(buf.String(), want) {
+		t.Errorf("Output missing %q:\n%s", want, buf.String())
+	}
+	if strings.Count(buf.String(), "leak candidates") != 1 {
+		t.Errorf("Expected leak candidates for host1 only:\n%s", buf.String())
+	}
+
+	if over := OverLimit(s, 10); len(over) != 1 || over[0] != "host1" {
+		t.Errorf("OverLimit(10) = %v, want [host1]", over)
+	}
+	if over := OverLimit(s, 31); len(over) != 0 {
+		t.Errorf("OverLimit(31) = %v, want none", over)
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	before := &model.Snapshot{
+		Host: "old.txt",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 10, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g2": {ID: "g2", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}},
+		},
+	}
+	after := &model.Snapshot{
+		Host: "new.txt",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 25, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g3": {ID: "g3", State: model.StateWaiting, Count: 3, Trace: model.StackTrace{{Func: "net.(*netFD).Read"}}},
+		},
+	}
+
+	d := diff.New()
+	changes := d.Compare(before, after)
+
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, before, after, changes, d.Stats(changes)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"old.txt: 11 goroutines in 2 groups",
+		"new.txt: 28 goroutines in 2 groups",
+		"+18/-1 goroutines | +1/-1 groups | 1 groups changed",
+		"Added groups:\n       +3  waiting    net.(*netFD).Read",
+		"Removed groups:\n       -1  running    main.main",
+		"Changed counts:\n      +15  blocked    main.worker (10 -> 25)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q:\n%s", want, out)
+		}
+	}
+}
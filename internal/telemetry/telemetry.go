@@ -2,11 +2,15 @@ package telemetry
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"strings"
+	"time"
 )
 
 // Logger provides structured logging
@@ -61,8 +65,27 @@ const (
 	ErrorLevel
 )
 
+// LoggerOption configures a logger made by NewLogger
+type LoggerOption func(*loggerOptions)
+
+type loggerOptions struct {
+	output io.Writer
+}
+
+// WithOutput writes the log lines to w instead of stderr
+func WithOutput(w io.Writer) LoggerOption {
+	return func(o *loggerOptions) {
+		o.output = w
+	}
+}
+
 // NewLogger creates a new logger
-func NewLogger(level string, json bool) Logger {
+func NewLogger(level string, json bool, opts ...LoggerOption) Logger {
+	o := loggerOptions{output: os.Stderr}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	var logLevel LogLevel
 	switch level {
 	case "debug":
@@ -81,7 +104,7 @@ func NewLogger(level string, json bool) Logger {
 	}
 
 	return &simpleLogger{
-		logger: log.New(os.Stderr, "", flags),
+		logger: log.New(o.output, "", flags),
 		level:  logLevel,
 		json:   json,
 	}
@@ -99,19 +122,7 @@ func (l *simpleLogger) log(level LogLevel, levelStr, msg string, fields []Field)
 	allFields := append(l.fields, fields...)
 
 	if l.json {
-		// Simple JSON format
-		fmt.Fprintf(os.Stderr, `{"level":"%s","msg":"%s"`, levelStr, msg)
-		for _, f := range allFields {
-			switch v := f.Value.(type) {
-			case string:
-				fmt.Fprintf(os.Stderr, `,"%s":"%s"`, f.Key, v)
-			case error:
-				fmt.Fprintf(os.Stderr, `,"%s":"%s"`, f.Key, v.Error())
-			default:
-				fmt.Fprintf(os.Stderr, `,"%s":%v`, f.Key, v)
-			}
-		}
-		fmt.Fprintln(os.Stderr, "}")
+		l.logger.Print(formatJSON(time.Now(), levelStr, msg, allFields))
 	} else {
 		// Human-readable format
 		parts := []interface{}{levelStr, msg}
@@ -122,6 +133,42 @@ func (l *simpleLogger) log(level LogLevel, levelStr, msg string, fields []Field)
 	}
 }
 
+// formatJSON renders a log line as a JSON object with the time, level and
+// message first, then the fields in order. Errors and other Stringers are
+// written as their text, and values JSON can't encode as fmt prints them.
+func formatJSON(t time.Time, levelStr, msg string, fields []Field) string {
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	writeJSONValue(&b, t.Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(&b, levelStr)
+	b.WriteString(`,"msg":`)
+	writeJSONValue(&b, msg)
+	for _, f := range fields {
+		b.WriteByte(',')
+		writeJSONValue(&b, f.Key)
+		b.WriteByte(':')
+		switch v := f.Value.(type) {
+		case error:
+			writeJSONValue(&b, v.Error())
+		case fmt.Stringer:
+			writeJSONValue(&b, v.String())
+		default:
+			writeJSONValue(&b, v)
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func writeJSONValue(b *strings.Builder, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
 func (l *simpleLogger) Debug(msg string, fields ...Field) {
 	l.log(DebugLevel, "DEBUG", msg, fields)
 }
@@ -147,8 +194,15 @@ func (l *simpleLogger) With(fields ...Field) Logger {
 	}
 }
 
-// StartPProf starts the pprof HTTP server if configured
-func StartPProf(ctx context.Context, addr string, logger Logger) error {
+// Route is an extra handler served next to the pprof endpoints
+type Route struct {
+	Pattern string
+	Handler http.Handler
+}
+
+// StartPProf starts the pprof HTTP server if configured, also serving any
+// extra routes on it
+func StartPProf(ctx context.Context, addr string, logger Logger, routes ...Route) error {
 	if addr == "" {
 		return nil
 	}
@@ -169,12 +223,36 @@ func StartPProf(ctx context.Context, addr string, logger Logger) error {
 	mux.Handle("/debug/pprof/block", pprof.Handler("block"))
 	mux.Handle("/debug/pprof/mutex", pprof.Handler("mutex"))
 
+	for _, route := range routes {
+		mux.Handle(route.Pattern, route.Handler)
+	}
+
+	serve(ctx, "pprof", addr, mux, logger)
+	return nil
+}
+
+// StartMetrics serves the metrics handler on /metrics of its own HTTP server
+// if configured
+func StartMetrics(ctx context.Context, addr string, handler http.Handler, logger Logger) error {
+	if addr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+
+	serve(ctx, "metrics", addr, mux, logger)
+	return nil
+}
+
+// serve runs an HTTP server in the background until ctx is done
+func serve(ctx context.Context, name, addr string, handler http.Handler, logger Logger) {
 	server := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: handler,
 	}
 
-	logger.Info("Starting pprof server", String("addr", addr))
+	logger.Info("Starting "+name+" server", String("addr", addr))
 
 	go func() {
 		<-ctx.Done()
@@ -183,9 +261,7 @@ func StartPProf(ctx context.Context, addr string, logger Logger) error {
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("pprof server error", Error(err))
+			logger.Error(name+" server error", Error(err))
 		}
 	}()
-
-	return nil
 }
@@ -2,9 +2,13 @@ package telemetry
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -135,6 +139,105 @@ func TestLoggerJSON(t *testing.T) {
 	}
 }
 
+func TestLoggerJSONEscaping(t *testing.T) {
+	// Redirect stderr to capture output
+	r, w, _ := os.Pipe()
+	oldStderr := os.Stderr
+	os.Stderr = w
+	defer func() {
+		os.Stderr = oldStderr
+	}()
+
+	logger := NewLogger("info", true).With(String("host", `a"b`))
+	logger.Warn("say \"hi\"\nthen leave",
+		String("path", `C:\dumps\"x".txt`),
+		Error(errors.New(`bad "quote"`)),
+		Duration("wait", 1500*time.Millisecond),
+		Int("count", 3),
+	)
+
+	w.Close()
+	output, _ := io.ReadAll(r)
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected one line, got %q", output)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, output)
+	}
+
+	want := map[string]any{
+		"level": "WARN",
+		"msg":   "say \"hi\"\nthen leave",
+		"host":  `a"b`,
+		"path":  `C:\dumps\"x".txt`,
+		"error": `bad "quote"`,
+		"wait":  "1.5s",
+		"count": float64(3),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %#v, want %#v", key, entry[key], value)
+		}
+	}
+	if ts, ok := entry["time"].(string); !ok {
+		t.Error("Missing time in JSON output")
+	} else if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Errorf("time = %q is not RFC 3339: %v", ts, err)
+	}
+	if !strings.HasPrefix(lines[0], `{"time":`) {
+		t.Errorf("Expected time, level and msg first, got %s", lines[0])
+	}
+}
+
+func TestLoggerFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goru.log")
+	f, err := OpenLogFile(path, 100)
+	if err != nil {
+		t.Fatalf("OpenLogFile: %v", err)
+	}
+
+	logger := NewLogger("info", true, WithOutput(f))
+	for i := range 5 {
+		logger.Info("line", Int("n", i))
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading log: %v", err)
+	}
+	rotated, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatalf("reading rotated log: %v", err)
+	}
+	if len(current) > 100 || len(rotated) > 100 {
+		t.Errorf("log files grew past the limit: %d and %d bytes", len(current), len(rotated))
+	}
+	if !strings.Contains(string(current), `"n":4`) {
+		t.Errorf("last line missing from the current log: %s", current)
+	}
+	if strings.Contains(string(rotated), `"n":4`) || !strings.Contains(string(rotated), `"n":3`) {
+		t.Errorf("rotated log should hold the line before the last: %s", rotated)
+	}
+
+	// Reopening appends to what's there
+	f, err = OpenLogFile(path, 0)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	NewLogger("info", false, WithOutput(f)).Info("again")
+	f.Close()
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), string(current)) || !strings.Contains(string(data), "INFO again") {
+		t.Errorf("reopened log lost its contents: %s", data)
+	}
+}
+
 func TestLoggerWith(t *testing.T) {
 	// Redirect stderr to capture output
 	r, w, _ := os.Pipe()
@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// copyStatusDuration is how long the copy result stays in the footer
+const copyStatusDuration = 2 * time.Second
+
+// writeClipboard puts text on the system clipboard. It fails where there is
+// no clipboard, e.g. over SSH without X forwarding.
+var writeClipboard = clipboard.WriteAll
+
+// copiedMsg reports the result of copying a stack trace
+type copiedMsg struct {
+	err error
+}
+
+// clearCopyStatusMsg clears the copy result numbered seq from the footer,
+// unless a later copy replaced it
+type clearCopyStatusMsg struct {
+	seq int
+}
+
+// copyTrace copies the group's stack trace to the clipboard in the
+// background, as clipboard tools can be slow to start
+func copyTrace(g *model.Group) tea.Cmd {
+	text := formatTrace(g)
+	return func() tea.Msg {
+		return copiedMsg{err: writeClipboard(text)}
+	}
+}
+
+// formatTrace renders a group's stack trace as plain text laid out like a
+// goroutine dump, for pasting into tickets
+func formatTrace(g *model.Group) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d goroutine(s) [%s]:\n", g.Count, g.State)
+	for _, frame := range g.Trace {
+		if frame.Func == model.ElidedFrames {
+			b.WriteString("...additional frames elided...\n")
+			continue
+		}
+		b.WriteString(frame.Func + "\n")
+		if frame.File != "" {
+			fmt.Fprintf(&b, "\t%s:%d\n", frame.File, frame.Line)
+		}
+	}
+	if g.CreatedBy != nil {
+		b.WriteString("created by " + g.CreatedBy.Func)
+		if g.CreatedByGoroutine != 0 {
+			fmt.Fprintf(&b, " in goroutine %d", g.CreatedByGoroutine)
+		}
+		b.WriteString("\n")
+		if g.CreatedBy.File != "" {
+			fmt.Fprintf(&b, "\t%s:%d\n", g.CreatedBy.File, g.CreatedBy.Line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// defaultStateColors are the State column's colors, by state. States
+// without a color are left plain.
+var defaultStateColors = map[model.GoroutineState]lipgloss.Color{
+	model.StateRunning:  "42",  // green
+	model.StateRunnable: "39",  // blue
+	model.StateSyscall:  "241", // dim
+	model.StateWaiting:  "220", // yellow
+	model.StateBlocked:  "196", // red
+}
+
+// Colors of the goroutine counts over the warn and crit thresholds. Basic
+// colors keep the escape codes, which the table counts towards the Count
+// column's width, within countColorWidth.
+const (
+	countWarnColor lipgloss.Color = "3" // yellow
+	countCritColor lipgloss.Color = "1" // red
+)
+
+// ansiPattern matches the color sequences in rendered cells
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// WithStateColors overrides the State column's color of the named states.
+// Colors are ANSI numbers or hex codes, and an empty one leaves the state
+// plain.
+func WithStateColors(colors map[string]string) Option {
+	return func(m *Model) {
+		for state, color := range colors {
+			m.stateColors[model.GoroutineState(state)] = lipgloss.Color(color)
+		}
+	}
+}
+
+// WithCountThresholds colors goroutine counts in the Count column and the
+// header total yellow above warn and red above crit. A zero threshold is
+// off.
+func WithCountThresholds(warn, crit int) Option {
+	return func(m *Model) {
+		m.warnCount = warn
+		m.critCount = crit
+	}
+}
+
+// tableStyles are the table's styles
+func (m Model) tableStyles() table.Styles {
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderBottom(true).
+		Bold(false)
+	// The highlight drops the cell colors so the selected row reads the
+	// same whatever its state
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false).
+		Transform(func(row string) string {
+			return ansiPattern.ReplaceAllString(row, "")
+		})
+	return s
+}
+
+// formatState renders the State cell of a group in state, colored by the
+// state. The cell may carry markers around the state.
+func (m Model) formatState(cell string, state model.GoroutineState) string {
+	color := m.stateColors[state]
+	if color == "" {
+		return cell
+	}
+	return lipgloss.NewStyle().Foreground(color).Render(cell)
+}
+
+// stateColumnWidth is the State column's width, with room for the escape
+// codes of the longest state color
+func (m Model) stateColumnWidth() int {
+	extra := 0
+	for _, color := range m.stateColors {
+		if color == "" {
+			continue
+		}
+		cell := lipgloss.NewStyle().Foreground(color).Render(" ")
+		extra = max(extra, len(cell)-lipgloss.Width(cell))
+	}
+	return stateWidth + extra
+}
+
+// formatCount renders a goroutine count for the Count column, colored when
+// it's over a count threshold
+func (m Model) formatCount(count int) string {
+	cell := fmt.Sprintf("%d", count)
+	color := m.countColor(count)
+	if color == "" {
+		return cell
+	}
+	return lipgloss.NewStyle().Foreground(color).Render(cell)
+}
+
+// countColumnWidth is the Count column's width, with room for the escape
+// codes of the count colors when a threshold is set
+func (m Model) countColumnWidth() int {
+	if m.warnCount > 0 || m.critCount > 0 {
+		return countWidth + countColorWidth
+	}
+	return countWidth
+}
+
+// countColor is the color of a goroutine count: red above the crit
+// threshold, yellow above the warn one and none otherwise
+func (m Model) countColor(count int) lipgloss.Color {
+	switch {
+	case m.critCount > 0 && count > m.critCount:
+		return countCritColor
+	case m.warnCount > 0 && count > m.warnCount:
+		return countWarnColor
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anyproto/goru/internal/parser"
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// exportedGroup is a group as written to an export file, with its note
+type exportedGroup struct {
+	*model.Group
+	Note string `json:"note,omitempty"`
+}
+
+// groupExport is the JSON document written by the export key
+type groupExport struct {
+	Host       string          `json:"host"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Groups     []exportedGroup `json:"groups"`
+}
+
+// targetGroups returns the groups batch actions operate on: the selected
+// groups on the current host, or the group under the cursor if none are
+func (m Model) targetGroups() []*model.Group {
+	if len(m.selected) == 0 {
+		if g := m.cursorGroup(); g != nil {
+			return []*model.Group{g}
+		}
+		return nil
+	}
+
+	snapshot := m.selectedSnapshot()
+	if snapshot == nil {
+		return nil
+	}
+
+	var groups []*model.Group
+	for id := range m.selected {
+		// Groups that disappeared since they were selected are skipped
+		if g, ok := snapshot.Groups[id]; ok {
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups
+}
+
+// exportGroups writes the target groups and their notes to a JSON file in
+// exportDir and returns its path. It writes nothing if there are no groups.
+func (m Model) exportGroups() (string, error) {
+	groups := m.targetGroups()
+	if len(groups) == 0 {
+		return "", nil
+	}
+
+	export := groupExport{
+		Host:       m.selectedHost,
+		ExportedAt: time.Now(),
+	}
+	for _, g := range groups {
+		export.Groups = append(export.Groups, exportedGroup{Group: g, Note: m.notes[g.ID]})
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding export: %w", err)
+	}
+
+	path := m.exportPath("", export.ExportedAt, ".json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("writing export: %w", err)
+	}
+
+	return path, nil
+}
+
+// snapshotFormat selects how the snapshot export key writes snapshots
+type snapshotFormat string
+
+const (
+	snapshotJSON snapshotFormat = "json" // the snapshot's JSON encoding
+	snapshotText snapshotFormat = "text" // a reconstructed goroutine dump
+)
+
+// exportSnapshot writes the selected host's snapshot to a timestamped file
+// in exportDir and returns its path. Text dumps can be loaded again with
+// the file collector. It writes nothing if the host has no snapshot yet.
+func (m Model) exportSnapshot(format snapshotFormat) (string, error) {
+	snapshot := m.selectedSnapshot()
+	if snapshot == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	ext := ".json"
+	if format == snapshotText {
+		ext = ".txt"
+		if err := parser.WriteDump(&buf, snapshot); err != nil {
+			return "", fmt.Errorf("writing dump: %w", err)
+		}
+	} else {
+		data, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("encoding snapshot: %w", err)
+		}
+		buf.Write(data)
+	}
+
+	path := m.exportPath("snapshot-", time.Now(), ext)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return "", fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	return path, nil
+}
+
+// exportPath names an export file after the selected host and time
+func (m Model) exportPath(kind string, at time.Time, ext string) string {
+	// Host names are usually host:port, which isn't a friendly file name
+	host := strings.NewReplacer(":", "_", "/", "_", "*", "").Replace(m.selectedHost)
+	name := fmt.Sprintf("goru-%s%s-%s%s", kind, host, at.Format("20060102-150405"), ext)
+	return filepath.Join(m.exportDir, name)
+}
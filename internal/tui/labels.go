@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// currentLabelKey is the label the labels view sums by: the chosen one while
+// some goroutine still carries it, else the first one
+func (m Model) currentLabelKey() string {
+	keys := model.LabelKeys(m.aggregateSnapshots()...)
+	if slices.Contains(keys, m.labelKey) {
+		return m.labelKey
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+// nextLabelKey returns the label key after current, wrapping around
+func nextLabelKey(keys []string, current string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	i := slices.Index(keys, current)
+	return keys[(i+1)%len(keys)]
+}
+
+// labelValues returns the label's values for the labels view, largest first
+func (m Model) labelValues() []*model.LabelCount {
+	key := m.currentLabelKey()
+	if key == "" {
+		return nil
+	}
+	return model.AggregateLabel(key, m.aggregateSnapshots()...)
+}
+
+// labelFilter is the table filter matching exactly the goroutines carrying
+// the value of the label
+func labelFilter(key, value string) string {
+	return regexFilterPrefix + "^" + regexp.QuoteMeta(key+"="+value) + "$"
+}
+
+// formatLabels writes labels as sorted key=value pairs
+func formatLabels(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return pairs
+}
+
+func (m Model) renderLabelsView() string {
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("229"))
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57"))
+
+	key := m.currentLabelKey()
+	b.WriteString(titleStyle.Render("Goroutines by Label"))
+	b.WriteString("\n")
+	if key == "" {
+		b.WriteString(dimStyle.Render(m.aggregateScope()))
+		b.WriteString("\n\n")
+		b.WriteString(dimStyle.Render("No pprof labels in the dumps"))
+		b.WriteString("\n")
+	} else {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("%s | by %s", m.aggregateScope(), key)))
+		b.WriteString("\n\n")
+	}
+
+	values := m.labelValues()
+	// Leave room for the title and footer
+	limit := m.height - 7
+	for i, lc := range values {
+		if limit > 0 && i >= limit {
+			b.WriteString(dimStyle.Render(fmt.Sprintf("… %d more values", len(values)-i)))
+			b.WriteString("\n")
+			break
+		}
+		value := lc.Value
+		if value == "" {
+			value = "(none)"
+		}
+		line := fmt.Sprintf("%7d  %s", lc.Count, value)
+		if i == m.labelCursor {
+			line = selectedStyle.Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString(dimStyle.Render(fmt.Sprintf(" (%d groups)", lc.Groups)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	help := []string{
+		shortKey(m.keys.Up) + "/" + shortKey(m.keys.Down) + ": Navigate",
+		shortKey(m.keys.Enter) + ": Filter table",
+		shortKey(m.keys.LabelKey) + ": Next label",
+		shortKey(m.keys.Fleet) + ": Host/All hosts",
+		"Esc: Back",
+	}
+	b.WriteString(dimStyle.Render(strings.Join(help, " • ")))
+
+	return b.String()
+}
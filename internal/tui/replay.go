@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Replayer steps through a recording of snapshots in place of collecting
+// them. It is implemented by the replay player.
+type Replayer interface {
+	// Step pauses playback and moves n frames forward, or back if negative
+	Step(n int)
+	// SetPlaying starts or pauses playback
+	SetPlaying(playing bool)
+	// Seek shows the last frame taken at or before t
+	Seek(t time.Time)
+	// Position returns the shown frame counted from 1, the number of
+	// frames, when the shown one was taken and whether playback is running
+	Position() (frame, total int, takenAt time.Time, playing bool)
+}
+
+// jumpLayouts are the time formats the replay jump prompt accepts
+var jumpLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"15:04:05",
+	"15:04",
+}
+
+// WithReplay puts the TUI in replay mode: the pause key plays and pauses
+// the recording, and the replay keys step through it and jump in time.
+func WithReplay(r Replayer) Option {
+	return func(m *Model) {
+		m.replay = r
+	}
+}
+
+// handleJumpInput edits the jump prompt and seeks once it's applied
+func (m Model) handleJumpInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		_, _, takenAt, _ := m.replay.Position()
+		t, err := parseJumpTime(strings.TrimSpace(m.jumpInput.Value()), takenAt)
+		if err != nil {
+			m.statusMsg = err.Error()
+		} else {
+			m.replay.Seek(t)
+			m.statusMsg = ""
+		}
+		m.jumpMode = false
+		m.jumpInput.Blur()
+	case tea.KeyEsc:
+		m.jumpMode = false
+		m.jumpInput.Blur()
+	default:
+		var cmd tea.Cmd
+		m.jumpInput, cmd = m.jumpInput.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// parseJumpTime reads a time typed at the jump prompt. A bare clock time is
+// taken on the day, and in the location, of ref.
+func parseJumpTime(s string, ref time.Time) (time.Time, error) {
+	for _, layout := range jumpLayouts {
+		t, err := time.ParseInLocation(layout, s, ref.Location())
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") {
+			y, mo, d := ref.Date()
+			t = time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), 0, ref.Location())
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, want 15:04:05 or 2006-01-02 15:04:05", s)
+}
+
+// renderReplayStatus shows the replay's position for the header
+func (m Model) renderReplayStatus() string {
+	frame, total, takenAt, playing := m.replay.Position()
+	mark := "⏸"
+	if playing {
+		mark = "▶"
+	}
+	replayStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("39")).
+		Bold(true)
+	return replayStyle.Render(fmt.Sprintf("REPLAY %d/%d %s %s",
+		frame, total, takenAt.Format("15:04:05"), mark))
+}
@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// State is the view preferences kept across runs, and the notes on groups,
+// which keep their IDs from one run to the next
+type State struct {
+	SortBy string                   `json:"sort_by,omitempty"`
+	Filter string                   `json:"filter,omitempty"`
+	Host   string                   `json:"host,omitempty"`
+	Notes  map[model.GroupID]string `json:"notes,omitempty"`
+}
+
+// sortModes are the table's sort modes in the order the sort key cycles them
+var sortModes = []string{"count", "state", "function", "wait", "frames"}
+
+// DefaultStatePath is where the state is kept: goru/state.json in the
+// user's config directory
+func DefaultStatePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goru", "state.json"), nil
+}
+
+// LoadState reads the state saved at path. A missing file gives the zero
+// state; a corrupt one gives the zero state and an error saying why.
+func LoadState(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return State{}, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return st, nil
+}
+
+// SaveState writes the state to path, creating its directory. The file is
+// replaced in one step so a crash can't leave it half written.
+func SaveState(path string, st State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// WithState restores the sort mode, filter, host and notes of a previous
+// run. The host is selected once it shows up, unless another one is picked
+// first.
+func WithState(st State) Option {
+	return func(m *Model) {
+		if slices.Contains(sortModes, st.SortBy) {
+			m.sortBy = st.SortBy
+		}
+		m.setFilter(st.Filter)
+		m.preferredHost = st.Host
+		maps.Copy(m.notes, st.Notes)
+	}
+}
+
+// State returns the view preferences to save for the next run
+func (m Model) State() State {
+	host := m.selectedHost
+	if m.preferredHost != "" {
+		// The restored host never showed up, keep it for next time
+		host = m.preferredHost
+	}
+	st := State{
+		SortBy: m.sortBy,
+		Filter: m.filter,
+		Host:   host,
+	}
+	if len(m.notes) > 0 {
+		st.Notes = maps.Clone(m.notes)
+	}
+	return st
+}
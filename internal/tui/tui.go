@@ -2,6 +2,9 @@ package tui
 
 import (
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -10,60 +13,291 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
-	"github.com/anyproto/goru/internal/store"
+	"github.com/anyproto/goru/internal/analysis"
+	"github.com/anyproto/goru/internal/diff"
 	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
 )
 
 // Refresher interface for manual refresh capability
 type Refresher interface {
 	TriggerRefresh()
+	TriggerRefreshHost(host string) bool
 	SetPaused(bool)
 	IsPaused() bool
 }
 
+// TargetEditor adds and removes the HTTP targets collected from while
+// running. Errors explain why a target was refused.
+type TargetEditor interface {
+	AddTarget(target string) error
+	RemoveTarget(target string) error
+}
+
 // Model represents the TUI model
 type Model struct {
-	store        *store.Store
-	refresher    Refresher
-	interval     time.Duration
-	table        table.Model
-	filterInput  textinput.Model
-	updates      <-chan store.Update
-	selectedHost string
-	filter       string
-	filterMode   bool
-	showDetails  bool
-	width        int
-	height       int
-	lastUpdate   time.Time
-	stats        store.Stats
+	store         *store.Store
+	refresher     Refresher
+	interval      time.Duration
+	table         table.Model
+	tableOffset   int // first table row shown, kept by scrollTable
+	filterInput   textinput.Model
+	updates       chan store.Update
+	selectedHost  string
+	preferredHost string // restored from the last run, selected once it shows up
+	filter        string
+	filterRe      *regexp.Regexp // filter compiled, when it's a regular expression
+	filterErr     error          // why a regular expression filter didn't compile
+	filterMode    bool
+	stateFilter   model.GoroutineState // only groups in this state, "" for all
+	showDetails   bool
+	width         int
+	height        int
+	stateColors   map[model.GoroutineState]lipgloss.Color // State column colors
+	warnCount     int                                     // counts above this are yellow, 0 for off
+	critCount     int                                     // counts above this are red, 0 for off
+	keys          keyMap
+	lastUpdate    time.Time
+	stats         store.Stats
 
 	// For details view
 	selectedRow   int
 	selectedGroup *model.Group // Store the selected group when entering details
+	details       viewport.Model
+	copyStatus    string // result of the last copy to the clipboard
+	copySeq       int    // numbers copies so only the latest status is cleared
 
-	// Keep track of displayed groups for details lookup
+	// Keep track of displayed groups for details lookup, nil on package rows
+	// of the tree view
 	displayedGroups []*model.Group
+	shownGroups     int // groups matching the filters, shown or collapsed
+
+	// Tree view: groups nested under the package of their top frame, or
+	// under the function that created them with treeByCreator
+	treeView          bool
+	treeByCreator     bool
+	expandedPackages  map[string]bool
+	displayedPackages []string // the package or creator of each row in the tree view
+
+	// Multi-select for batch export and annotation
+	selected  map[model.GroupID]bool   // groups marked on the selected host
+	notes     map[model.GroupID]string // annotations, kept across hosts
+	noteInput textinput.Model
+	noteMode  bool
+	exportDir string
+	statusMsg string // result of the last export, saved snapshot or target edit
+
+	// Adding and removing targets at runtime, when there's an editor
+	targets     TargetEditor
+	targetInput textinput.Model
+	targetMode  bool
+	targetErr   error // why the typed target was refused
+
+	// Pinned groups stay at the top of the table on every host. Hosts that
+	// lack a pinned group show it as an absent zero-count row unless hidden.
+	pinned         map[model.GroupID]*model.Group
+	hideAbsentPins bool
 
 	// Sorting
-	sortBy string // "count", "state", "function", "wait"
+	sortBy string // "count", "state", "function", "wait", "frames"
+
+	// Optional column with the number of frames in each trace
+	showFrames bool
+
+	// Diff baseline: previous refresh, or the first snapshot seen per host
+	diff       *diff.Diff
+	sinceStart bool
+	// When the current snapshots were pinned as the diff baseline, which
+	// takes the place of either; zero when none are
+	pinnedAt time.Time
+
+	// Aggregate views span all hosts instead of the selected one
+	aggregateFleet bool
+
+	// Created By view: creation sites ranked by spawned goroutines
+	showCreatedBy   bool
+	createdByTop    int // number of sites shown, 0 for all
+	createdByCursor int
+	expandedSite    string
+
+	// Package view: goroutines summed by the package of a chosen frame
+	showPackages bool
+	appFrames    bool // group by the innermost non-stdlib frame instead of the top one
+
+	// Details view: fold runs of frames outside the application, as told
+	// apart by appPrefixes, into one line each
+	collapseFrames bool
+	appPrefixes    []string
+
+	// Packages the Function column looks past to a group's primary frame
+	skipPackages []string
+
+	// Break sort ties by group ID rather than by trace
+	tiesByID bool
+
+	// Host label shown instead of all of a host's labels and grouping the
+	// hosts, e.g. env
+	hostLabel string
+
+	// Leaks view: groups whose count keeps growing across the host's history
+	showLeaks bool
+
+	// Labels view: goroutines summed by the value of a pprof label
+	showLabels  bool
+	labelKey    string // the label shown, the first one when unset or gone
+	labelCursor int
+
+	// Help overlay listing every key binding
+	showHelp bool
+	help     viewport.Model
+
+	// Replay of archived dumps, stepped through instead of collected
+	replay    Replayer
+	jumpInput textinput.Model
+	jumpMode  bool
+}
+
+// Option configures optional Model behavior
+type Option func(*Model)
+
+// WithSinceStart makes the header diff compare against the first snapshot
+// seen for each host instead of the previous refresh
+func WithSinceStart(enabled bool) Option {
+	return func(m *Model) {
+		m.sinceStart = enabled
+	}
+}
+
+// WithCreatedByTop caps the number of creation sites shown in the Created By
+// view (0 shows all of them)
+func WithCreatedByTop(n int) Option {
+	return func(m *Model) {
+		m.createdByTop = n
+	}
+}
+
+// WithAppFramePackages makes the package view group by each stack's innermost
+// frame outside the standard library instead of its innermost non-runtime frame
+func WithAppFramePackages(enabled bool) Option {
+	return func(m *Model) {
+		m.appFrames = enabled
+	}
+}
+
+// WithHideAbsentPins omits pinned groups from the table on hosts that don't
+// have them instead of showing them as zero-count rows
+func WithHideAbsentPins(hide bool) Option {
+	return func(m *Model) {
+		m.hideAbsentPins = hide
+	}
+}
+
+// WithAppPrefixes sets the package prefixes of application code, whose
+// frames the details view keeps when collapsing the others. Without any,
+// everything outside the standard library is application code.
+func WithAppPrefixes(prefixes []string) Option {
+	return func(m *Model) {
+		m.appPrefixes = prefixes
+	}
+}
+
+// WithSkipPackages sets the packages, along with those below them, that the
+// Function column looks past to show a group's first frame elsewhere. By
+// default these are model.DefaultSkipPackages; none shows the innermost
+// frame.
+func WithSkipPackages(packages []string) Option {
+	return func(m *Model) {
+		m.skipPackages = packages
+	}
+}
+
+// WithIDTieBreak orders groups that tie on the sort column by group ID, a
+// hash of the stack, instead of by function, file and line. The order is
+// just as stable but means nothing to a reader.
+func WithIDTieBreak(enabled bool) Option {
+	return func(m *Model) {
+		m.tiesByID = enabled
+	}
+}
+
+// WithHostLabel shows only the value of one of the hosts' labels next to
+// them, and orders the hosts by it so ←/→ go through, say, every prod host
+// before the staging ones. Hosts without the label come last.
+func WithHostLabel(label string) Option {
+	return func(m *Model) {
+		m.hostLabel = label
+	}
+}
+
+// WithFramesColumn adds a column with each group's stack depth, which can
+// also be sorted by
+func WithFramesColumn(enabled bool) Option {
+	return func(m *Model) {
+		m.showFrames = enabled
+	}
+}
+
+// WithFilter filters the table by text, or by a regular expression after
+// "re:", from the first render. An empty filter leaves the table unfiltered,
+// or with the filter restored by WithState.
+func WithFilter(filter string) Option {
+	return func(m *Model) {
+		if filter != "" {
+			m.setFilter(filter)
+		}
+	}
+}
+
+// WithStateFilter shows only the goroutines in state from the first render,
+// or all of them when state is empty
+func WithStateFilter(state string) Option {
+	return func(m *Model) {
+		m.stateFilter = model.GoroutineState(state)
+	}
+}
+
+// WithTargetEditor lets the target keys add a typed host:port and remove
+// the selected host through editor
+func WithTargetEditor(editor TargetEditor) Option {
+	return func(m *Model) {
+		m.targets = editor
+	}
+}
+
+// WithKeyBindings replaces the keys of the named actions, leaving the
+// default keys of the others. Names that aren't actions are ignored, the
+// config rejects them when it's loaded.
+func WithKeyBindings(bindings map[string][]string) Option {
+	return func(m *Model) {
+		actions := m.keys.actions()
+		for name, ks := range bindings {
+			if b, ok := actions[name]; ok {
+				*b = key.NewBinding(
+					key.WithKeys(ks...),
+					key.WithHelp(helpKeys(ks), b.Help().Desc),
+				)
+			}
+		}
+	}
 }
 
 // New creates a new TUI model
-func New(s *store.Store, refresher Refresher, interval time.Duration) Model {
+func New(s *store.Store, refresher Refresher, interval time.Duration, opts ...Option) Model {
 	// Subscribe to store updates
 	updates := make(chan store.Update, 10)
 	s.Subscribe(updates)
 
 	// Create table
 	columns := []table.Column{
-		{Title: "State", Width: 13},
+		{Title: "State", Width: stateWidth},
 		{Title: "Function", Width: 52},
 		{Title: "Created By", Width: 75},
-		{Title: "Count ↓", Width: 7}, // Default sort by count
+		{Title: "Count ↓", Width: countWidth}, // Default sort by count
+		{Title: "Δ", Width: deltaWidth},
 		{Title: "Wait", Width: 10},
 	}
 
@@ -73,38 +307,69 @@ func New(s *store.Store, refresher Refresher, interval time.Duration) Model {
 		table.WithHeight(20),
 	)
 
-	// Style the table
-	s1 := table.DefaultStyles()
-	s1.Header = s1.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderBottom(true).
-		Bold(false)
-	s1.Selected = s1.Selected.
-		Foreground(lipgloss.Color("229")).
-		Background(lipgloss.Color("57")).
-		Bold(false)
-	t.SetStyles(s1)
-
 	// Create filter input
 	ti := textinput.New()
-	ti.Placeholder = "Filter by function name..."
-	ti.CharLimit = 50
+	ti.Placeholder = "Filter by function name, or re:<regexp>..."
+	ti.CharLimit = 100
 	ti.Width = 50
 
+	// Create note input
+	ni := textinput.New()
+	ni.Placeholder = "Note for selected groups..."
+	ni.CharLimit = 200
+	ni.Width = 80
+
+	// Create target input
+	tgi := textinput.New()
+	tgi.Placeholder = "host:port or https://host:port"
+	tgi.CharLimit = 200
+	tgi.Width = 50
+
+	// Create replay jump input
+	ji := textinput.New()
+	ji.Placeholder = "15:04:05, or 2006-01-02 15:04:05"
+	ji.CharLimit = 40
+	ji.Width = 40
+
 	m := Model{
-		store:       s,
-		refresher:   refresher,
-		interval:    interval,
-		table:       t,
-		filterInput: ti,
-		updates:     updates,
-		stats:       s.GetStats(),
-		sortBy:      "count", // default sort by count
+		store:            s,
+		refresher:        refresher,
+		interval:         interval,
+		table:            t,
+		filterInput:      ti,
+		selected:         make(map[model.GroupID]bool),
+		notes:            make(map[model.GroupID]string),
+		pinned:           make(map[model.GroupID]*model.Group),
+		expandedPackages: make(map[string]bool),
+		noteInput:        ni,
+		targetInput:      tgi,
+		jumpInput:        ji,
+		exportDir:        ".",
+		updates:          updates,
+		stats:            s.GetStats(),
+		sortBy:           "count", // default sort by count
+		diff:             diff.New(),
+		keys:             defaultKeys,
+		stateColors:      maps.Clone(defaultStateColors),
+		skipPackages:     model.DefaultSkipPackages,
 	}
 
-	// Select first host if available
+	for _, opt := range opts {
+		opt(&m)
+	}
+	m.table.KeyMap = m.keys.tableKeyMap()
+	m.table.SetStyles(m.tableStyles())
+	if m.sortBy == "frames" && !m.showFrames {
+		m.sortBy = "count"
+	}
+	m.table.SetColumns(m.tableColumns())
+
+	// Select the restored host, or the first one, if available
 	hosts := m.getSortedHosts()
-	if len(hosts) > 0 {
+	if slices.Contains(hosts, m.preferredHost) {
+		m.selectedHost = m.preferredHost
+		m.preferredHost = ""
+	} else if len(hosts) > 0 {
 		m.selectedHost = hosts[0]
 	}
 
@@ -121,6 +386,17 @@ func (m Model) Init() tea.Cmd {
 
 // Update handles messages
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	if m, ok := next.(Model); ok {
+		m.tableOffset = m.scrollOffset()
+		next = m
+	}
+	return next, cmd
+}
+
+// update handles messages for Update, which then scrolls the table to the
+// cursor
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	var cmds []tea.Cmd
 
@@ -130,25 +406,235 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		m.table.SetHeight(m.height - 10) // Leave room for header and footer
 		m.table.SetWidth(m.width)
+		m.details.Width = m.width
+		m.details.Height = m.detailsHeight()
+		if m.showDetails {
+			// Keep the scroll offset within the resized content
+			m.details.SetContent(m.renderDetailsContent())
+			m.details.SetYOffset(m.details.YOffset)
+		}
+		m.help.Width = m.width
+		m.help.Height = m.detailsHeight()
+		if m.showHelp {
+			m.help.SetContent(m.renderHelpContent())
+			m.help.SetYOffset(m.help.YOffset)
+		}
+
+	case tea.MouseMsg:
+		// The mouse wheel scrolls long traces in the details view
+		if m.showDetails {
+			m.details, cmd = m.details.Update(msg)
+			return m, cmd
+		}
+		if m.showHelp {
+			m.help, cmd = m.help.Update(msg)
+			return m, cmd
+		}
+		if !m.showCreatedBy && !m.showPackages && !m.showLabels && !m.showLeaks && !m.filterMode && !m.noteMode && !m.targetMode {
+			cmds = append(cmds, m.handleTableMouse(msg))
+		}
 
 	case tea.KeyMsg:
 		// Handle details view first
 		if m.showDetails {
-			switch msg.Type {
-			case tea.KeyEnter, tea.KeyEsc:
+			switch {
+			case msg.Type == tea.KeyEnter, msg.Type == tea.KeyEsc:
 				m.showDetails = false
 				m.selectedGroup = nil // Clear the stored group
-			case tea.KeyCtrlC:
+			case msg.Type == tea.KeyCtrlC:
+				return m, tea.Quit
+			case key.Matches(msg, m.keys.Copy):
+				return m, copyTrace(m.selectedGroup)
+			case key.Matches(msg, m.keys.Collapse):
+				m.collapseFrames = !m.collapseFrames
+				m.details.SetContent(m.renderDetailsContent())
+				m.details.SetYOffset(m.details.YOffset)
+			default:
+				// Scroll with the arrows, PgUp/PgDn and the like
+				m.details, cmd = m.details.Update(msg)
+				return m, cmd
+			}
+			return m, nil
+		}
+
+		// Handle help overlay
+		if m.showHelp {
+			switch {
+			case msg.Type == tea.KeyEsc, key.Matches(msg, m.keys.Help):
+				m.showHelp = false
+			case msg.Type == tea.KeyCtrlC:
+				return m, tea.Quit
+			default:
+				m.help, cmd = m.help.Update(msg)
+				return m, cmd
+			}
+			return m, nil
+		}
+
+		// Handle Created By view
+		if m.showCreatedBy {
+			sites, _ := m.createdBySites()
+			switch {
+			case msg.Type == tea.KeyCtrlC:
+				return m, tea.Quit
+			case msg.Type == tea.KeyEsc, key.Matches(msg, m.keys.CreatedBy):
+				m.showCreatedBy = false
+			case key.Matches(msg, m.keys.Up):
+				if m.createdByCursor > 0 {
+					m.createdByCursor--
+				}
+			case key.Matches(msg, m.keys.Down):
+				if m.createdByCursor < len(sites)-1 {
+					m.createdByCursor++
+				}
+			case key.Matches(msg, m.keys.Enter):
+				// Expand or collapse the site under the cursor
+				if m.createdByCursor < len(sites) {
+					site := sites[m.createdByCursor].Func
+					if m.expandedSite == site {
+						m.expandedSite = ""
+					} else {
+						m.expandedSite = site
+					}
+				}
+			case key.Matches(msg, m.keys.Fleet):
+				m.aggregateFleet = !m.aggregateFleet
+				m.createdByCursor = 0
+				m.expandedSite = ""
+			}
+			return m, nil
+		}
+
+		// Handle package view
+		if m.showPackages {
+			switch {
+			case msg.Type == tea.KeyCtrlC:
+				return m, tea.Quit
+			case msg.Type == tea.KeyEsc, key.Matches(msg, m.keys.Packages):
+				m.showPackages = false
+			case key.Matches(msg, m.keys.Fleet):
+				m.aggregateFleet = !m.aggregateFleet
+			case key.Matches(msg, m.keys.PackageFrame):
+				m.appFrames = !m.appFrames
+			}
+			return m, nil
+		}
+
+		// Handle labels view
+		if m.showLabels {
+			switch {
+			case msg.Type == tea.KeyCtrlC:
+				return m, tea.Quit
+			case msg.Type == tea.KeyEsc, key.Matches(msg, m.keys.Labels):
+				m.showLabels = false
+			case key.Matches(msg, m.keys.Up):
+				if m.labelCursor > 0 {
+					m.labelCursor--
+				}
+			case key.Matches(msg, m.keys.Down):
+				if m.labelCursor < len(m.labelValues())-1 {
+					m.labelCursor++
+				}
+			case key.Matches(msg, m.keys.Enter):
+				// Narrow the table down to the goroutines with the value
+				values := m.labelValues()
+				if m.labelCursor < len(values) && values[m.labelCursor].Value != "" {
+					filter := labelFilter(m.currentLabelKey(), values[m.labelCursor].Value)
+					m.setFilter(filter)
+					m.filterInput.SetValue(filter)
+					m.showLabels = false
+					return m, m.refreshData()
+				}
+			case key.Matches(msg, m.keys.Fleet):
+				m.aggregateFleet = !m.aggregateFleet
+				m.labelCursor = 0
+			case key.Matches(msg, m.keys.LabelKey):
+				m.labelKey = nextLabelKey(model.LabelKeys(m.aggregateSnapshots()...), m.currentLabelKey())
+				m.labelCursor = 0
+			}
+			return m, nil
+		}
+
+		// Handle leaks view
+		if m.showLeaks {
+			switch {
+			case msg.Type == tea.KeyCtrlC:
 				return m, tea.Quit
+			case msg.Type == tea.KeyEsc, key.Matches(msg, m.keys.Leaks):
+				m.showLeaks = false
 			}
 			return m, nil
 		}
 
+		// Handle note input
+		if m.noteMode {
+			switch msg.Type {
+			case tea.KeyEnter:
+				note := strings.TrimSpace(m.noteInput.Value())
+				for _, g := range m.targetGroups() {
+					if note == "" {
+						delete(m.notes, g.ID)
+					} else {
+						m.notes[g.ID] = note
+					}
+				}
+				m.noteMode = false
+				m.noteInput.Blur()
+				cmds = append(cmds, m.refreshData())
+			case tea.KeyEsc:
+				m.noteMode = false
+				m.noteInput.Blur()
+			default:
+				var cmd tea.Cmd
+				m.noteInput, cmd = m.noteInput.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+			return m, tea.Batch(cmds...)
+		}
+
+		// Handle target input
+		if m.targetMode {
+			switch msg.Type {
+			case tea.KeyEnter:
+				target := strings.TrimSpace(m.targetInput.Value())
+				if target == "" {
+					m.targetMode = false
+					m.targetInput.Blur()
+					break
+				}
+				// A refused target stays in the input to be fixed
+				if err := m.targets.AddTarget(target); err != nil {
+					m.targetErr = err
+					break
+				}
+				m.targetMode = false
+				m.targetInput.Blur()
+				// Switched to once its first dump is in
+				m.preferredHost = target
+				m.statusMsg = "Added target " + target
+				cmds = append(cmds, m.refreshData())
+			case tea.KeyEsc:
+				m.targetMode = false
+				m.targetInput.Blur()
+			default:
+				var cmd tea.Cmd
+				m.targetInput, cmd = m.targetInput.Update(msg)
+				m.targetErr = nil
+				cmds = append(cmds, cmd)
+			}
+			return m, tea.Batch(cmds...)
+		}
+
+		// Handle replay jump input
+		if m.jumpMode {
+			return m.handleJumpInput(msg)
+		}
+
 		// Handle filter mode input
 		if m.filterMode {
 			switch msg.Type {
 			case tea.KeyEnter:
-				m.filter = m.filterInput.Value()
+				m.setFilter(m.filterInput.Value())
 				m.filterMode = false
 				m.filterInput.Blur()
 				cmds = append(cmds, m.refreshData())
@@ -166,7 +652,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Normal mode key handling
 		switch {
-		case key.Matches(msg, keys.Quit):
+		case key.Matches(msg, m.keys.Quit):
 			return m, tea.Quit
 
 		// Handle Alt+Up/Down for jumping by 10
@@ -191,29 +677,56 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.table.SetCursor(newCursor)
 			}
 
-		case key.Matches(msg, keys.Enter):
-			// Enter details view
+		case key.Matches(msg, m.keys.Enter):
+			// Expand or collapse a package, or enter details view
+			if pkg, ok := m.cursorPackage(); ok {
+				m.expandedPackages[pkg] = !m.expandedPackages[pkg]
+				cmds = append(cmds, m.refreshData())
+				break
+			}
 			m.selectedRow = m.table.Cursor()
-			if m.selectedRow >= 0 && m.selectedRow < len(m.displayedGroups) {
-				// Store a copy of the selected group
-				selectedGroup := m.displayedGroups[m.selectedRow]
-				groupCopy := *selectedGroup
-				m.selectedGroup = &groupCopy
-				m.showDetails = true
+			if g := m.cursorGroup(); g != nil {
+				m.openDetails(g)
 			}
 
-		case key.Matches(msg, keys.Filter):
+		case key.Matches(msg, m.keys.Tree), key.Matches(msg, m.keys.CreatorTree):
+			m.toggleTree(key.Matches(msg, m.keys.CreatorTree))
+			m.table.SetCursor(0)
+			cmds = append(cmds, m.refreshData())
+
+		case key.Matches(msg, m.keys.Filter):
 			m.filterMode = true
 			m.filterInput.Focus()
 			m.filterInput.SetValue(m.filter)
 			cmds = append(cmds, textinput.Blink)
 
-		case key.Matches(msg, keys.Clear):
-			m.filter = ""
+		case key.Matches(msg, m.keys.StateFilter):
+			m.stateFilter = nextStateFilter(m.stateFilter)
+			cmds = append(cmds, m.refreshData())
+
+		case key.Matches(msg, m.keys.Clear):
+			m.setFilter("")
 			m.filterInput.SetValue("")
+			m.stateFilter = ""
 			cmds = append(cmds, m.refreshData())
 
-		case key.Matches(msg, keys.Pause):
+		case key.Matches(msg, m.keys.Pause) && m.replay != nil:
+			_, _, _, playing := m.replay.Position()
+			m.replay.SetPlaying(!playing)
+
+		case key.Matches(msg, m.keys.ReplayNext) && m.replay != nil:
+			m.replay.Step(1)
+
+		case key.Matches(msg, m.keys.ReplayPrev) && m.replay != nil:
+			m.replay.Step(-1)
+
+		case key.Matches(msg, m.keys.ReplayJump) && m.replay != nil:
+			m.jumpMode = true
+			m.jumpInput.SetValue("")
+			m.jumpInput.Focus()
+			cmds = append(cmds, textinput.Blink)
+
+		case key.Matches(msg, m.keys.Pause):
 			if m.refresher != nil {
 				paused := !m.refresher.IsPaused()
 				m.refresher.SetPaused(paused)
@@ -223,16 +736,110 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 
-		case key.Matches(msg, keys.NextHost):
+		case key.Matches(msg, m.keys.Select):
+			// Toggle the group under the cursor; don't let the table page down
+			if g := m.cursorGroup(); g != nil {
+				id := g.ID
+				if m.selected[id] {
+					delete(m.selected, id)
+				} else {
+					m.selected[id] = true
+				}
+			}
+			return m, m.refreshData()
+
+		case key.Matches(msg, m.keys.Pin):
+			if g := m.cursorGroup(); g != nil {
+				if _, ok := m.pinned[g.ID]; ok {
+					delete(m.pinned, g.ID)
+				} else {
+					// Keep a copy so the group can be shown on hosts that lack it
+					groupCopy := *g
+					m.pinned[g.ID] = &groupCopy
+				}
+			}
+			cmds = append(cmds, m.refreshData())
+
+		case key.Matches(msg, m.keys.AbsentPins):
+			m.hideAbsentPins = !m.hideAbsentPins
+			cmds = append(cmds, m.refreshData())
+
+		case key.Matches(msg, m.keys.Note):
+			if groups := m.targetGroups(); len(groups) > 0 {
+				m.noteMode = true
+				// A single group's note is edited rather than retyped
+				m.noteInput.SetValue("")
+				if len(groups) == 1 {
+					m.noteInput.SetValue(m.notes[groups[0].ID])
+				}
+				m.noteInput.Focus()
+				cmds = append(cmds, textinput.Blink)
+			}
+
+		case key.Matches(msg, m.keys.AddTarget) && m.targets != nil:
+			m.targetMode = true
+			m.targetErr = nil
+			m.targetInput.SetValue("")
+			m.targetInput.Focus()
+			cmds = append(cmds, textinput.Blink)
+
+		case key.Matches(msg, m.keys.RemoveTarget) && m.targets != nil:
+			switch {
+			case m.selectedHost == "" || m.selectedHost == allHosts:
+				m.statusMsg = "Select a host to remove"
+			default:
+				if err := m.targets.RemoveTarget(m.selectedHost); err != nil {
+					m.statusMsg = fmt.Sprintf("Removing target failed: %v", err)
+				} else {
+					m.statusMsg = "Removed target " + m.selectedHost
+					cmds = append(cmds, m.refreshData())
+				}
+			}
+
+		case key.Matches(msg, m.keys.Export):
+			if path, err := m.exportGroups(); err != nil {
+				m.statusMsg = fmt.Sprintf("Export failed: %v", err)
+			} else if path != "" {
+				m.statusMsg = "Exported to " + path
+			}
+
+		case key.Matches(msg, m.keys.Snapshot), key.Matches(msg, m.keys.SnapshotText):
+			format := snapshotJSON
+			if key.Matches(msg, m.keys.SnapshotText) {
+				format = snapshotText
+			}
+			if path, err := m.exportSnapshot(format); err != nil {
+				m.statusMsg = fmt.Sprintf("Snapshot export failed: %v", err)
+			} else if path != "" {
+				m.statusMsg = "Saved snapshot to " + path
+			}
+
+		case key.Matches(msg, m.keys.NextHost) && m.onPackageRow() && !m.cursorExpanded():
+			// → expands a collapsed package in the tree view
+			pkg, _ := m.cursorPackage()
+			m.expandedPackages[pkg] = true
+			cmds = append(cmds, m.refreshData())
+
+		case key.Matches(msg, m.keys.PrevHost) && m.cursorExpanded():
+			// ← collapses the package of the row under the cursor, moving up
+			// to the package row
+			pkg := m.displayedPackages[m.table.Cursor()]
+			m.expandedPackages[pkg] = false
+			m.table.SetCursor(slices.Index(m.displayedPackages, pkg))
+			cmds = append(cmds, m.refreshData())
+
+		case key.Matches(msg, m.keys.NextHost):
 			m.selectNextHost()
+			m.selected = make(map[model.GroupID]bool)
 			cmds = append(cmds, m.refreshData())
 
-		case key.Matches(msg, keys.PrevHost):
+		case key.Matches(msg, m.keys.PrevHost):
 			m.selectPrevHost()
+			m.selected = make(map[model.GroupID]bool)
 			cmds = append(cmds, m.refreshData())
 
-		case key.Matches(msg, keys.Sort):
-			// Cycle through sort modes: count -> state -> function -> wait -> count
+		case key.Matches(msg, m.keys.Sort):
+			// Cycle through sort modes: count -> state -> function -> wait -> (frames) -> count
 			switch m.sortBy {
 			case "count":
 				m.sortBy = "state"
@@ -241,7 +848,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "function":
 				m.sortBy = "wait"
 			case "wait":
-				m.sortBy = "count"
+				if m.showFrames {
+					m.sortBy = "frames"
+				} else {
+					m.sortBy = "count"
+				}
 			default:
 				m.sortBy = "count"
 			}
@@ -249,14 +860,63 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.updateTableColumns()
 			// No need to call refreshData - updateTableColumns already rebuilds the table
 
-		case key.Matches(msg, keys.Refresh):
+		case key.Matches(msg, m.keys.CreatedBy):
+			m.showCreatedBy = true
+			m.createdByCursor = 0
+			m.expandedSite = ""
+
+		case key.Matches(msg, m.keys.Packages):
+			m.showPackages = true
+
+		case key.Matches(msg, m.keys.Labels):
+			m.showLabels = true
+
+		case key.Matches(msg, m.keys.Leaks):
+			m.showLeaks = true
+
+		case key.Matches(msg, m.keys.Help):
+			m.help = viewport.New(m.width, m.detailsHeight())
+			m.help.SetContent(m.renderHelpContent())
+			m.showHelp = true
+
+		case key.Matches(msg, m.keys.Baseline):
+			// Toggle between cycle-to-cycle and since-start diffs
+			m.sinceStart = !m.sinceStart
+
+		case key.Matches(msg, m.keys.PinBaseline):
+			// Pin the current snapshots as the diff baseline, or unpin them
+			if m.pinnedAt.IsZero() {
+				m.store.PinBaselines()
+				m.pinnedAt = time.Now()
+			} else {
+				m.store.UnpinBaselines()
+				m.pinnedAt = time.Time{}
+			}
+			cmds = append(cmds, m.refreshData())
+
+		case key.Matches(msg, m.keys.Refresh):
 			// Trigger manual refresh
 			if m.refresher != nil {
 				m.refresher.TriggerRefresh()
 			}
+
+		case key.Matches(msg, m.keys.RefreshHost):
+			// Re-collect only the selected host, or every host in the
+			// all-hosts view
+			if m.refresher != nil && m.selectedHost == allHosts {
+				m.refresher.TriggerRefresh()
+			} else if m.refresher != nil && m.selectedHost != "" {
+				m.refresher.TriggerRefreshHost(m.selectedHost)
+			}
 		}
 
 	case store.Update:
+		// The details of a removed host's group can't be refreshed; the
+		// table falls back to another host
+		if msg.Removed && (msg.Host == "" || msg.Host == m.selectedHost) {
+			m.showDetails = false
+			m.selectedGroup = nil
+		}
 		if !m.showDetails {
 			m.lastUpdate = time.Now()
 			m.stats = m.store.GetStats()
@@ -268,10 +928,28 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case refreshMsg:
 		rows := m.buildTableRows()
 		m.table.SetRows(rows)
+
+	case copiedMsg:
+		if msg.err != nil {
+			m.copyStatus = fmt.Sprintf("Copy failed: %v", msg.err)
+		} else {
+			m.copyStatus = "Copied!"
+		}
+		m.copySeq++
+		seq := m.copySeq
+		return m, tea.Tick(copyStatusDuration, func(time.Time) tea.Msg {
+			return clearCopyStatusMsg{seq: seq}
+		})
+
+	case clearCopyStatusMsg:
+		if msg.seq == m.copySeq {
+			m.copyStatus = ""
+		}
+		return m, nil
 	}
 
-	// Update table only if not in filter mode or details view
-	if !m.filterMode && !m.showDetails {
+	// Update table only if not in filter mode, details view or help
+	if !m.filterMode && !m.noteMode && !m.targetMode && !m.showDetails && !m.showHelp {
 		m.table, cmd = m.table.Update(msg)
 		cmds = append(cmds, cmd)
 	}
@@ -290,12 +968,49 @@ func (m Model) View() string {
 		return m.renderDetailsView()
 	}
 
+	if m.showHelp {
+		return m.renderHelpView()
+	}
+
+	if m.showCreatedBy {
+		return m.renderCreatedByView()
+	}
+
+	if m.showPackages {
+		return m.renderPackagesView()
+	}
+
+	if m.showLabels {
+		return m.renderLabelsView()
+	}
+
+	if m.showLeaks {
+		return m.renderLeaksView()
+	}
+
 	// Otherwise show main table view
 	return m.renderTableView()
 }
 
 func (m Model) renderTableView() string {
 	var b strings.Builder
+	b.WriteString(m.renderTableTop())
+
+	// Always show table
+	b.WriteString(m.tableView())
+	b.WriteString("\n")
+
+	// Footer
+	footer := m.renderFooter()
+	b.WriteString(footer)
+
+	return b.String()
+}
+
+// renderTableTop renders the lines of the table view above the table: the
+// header and the filter and note inputs
+func (m Model) renderTableTop() string {
+	var b strings.Builder
 
 	// Header
 	header := m.renderHeader()
@@ -313,21 +1028,347 @@ func (m Model) renderTableView() string {
 		filterStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241"))
 		b.WriteString(filterStyle.Render(fmt.Sprintf("Filter: %s", m.filter)))
+		if m.filterErr != nil {
+			errStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("196"))
+			b.WriteString(errStyle.Render(fmt.Sprintf(" (matching as text: %v)", m.filterErr)))
+		}
 		b.WriteString("\n\n")
 	}
 
-	// Always show table
-	b.WriteString(m.table.View())
-	b.WriteString("\n")
+	// Note input if annotating
+	if m.noteMode {
+		noteStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("205"))
+		b.WriteString(noteStyle.Render(fmt.Sprintf("Note (%d groups): ", len(m.targetGroups()))))
+		b.WriteString(m.noteInput.View())
+		b.WriteString("\n\n")
+	}
 
-	// Footer
-	footer := m.renderFooter()
-	b.WriteString(footer)
+	// Target input if adding one
+	if m.targetMode {
+		targetStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("205"))
+		b.WriteString(targetStyle.Render("Add target: "))
+		b.WriteString(m.targetInput.View())
+		if m.targetErr != nil {
+			errStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("196"))
+			b.WriteString(errStyle.Render(fmt.Sprintf(" (%v)", m.targetErr)))
+		}
+		b.WriteString("\n\n")
+	}
+
+	// Time input if jumping in a replay
+	if m.jumpMode {
+		jumpStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("205"))
+		b.WriteString(jumpStyle.Render("Jump to: "))
+		b.WriteString(m.jumpInput.View())
+		b.WriteString("\n\n")
+	}
 
 	return b.String()
 }
 
+const (
+	// headerHostLine is the line of the header showing the selected host
+	headerHostLine = 1
+
+	// tableHeaderHeight is the number of lines above the first table row:
+	// the column titles and their bottom border
+	tableHeaderHeight = 2
+)
+
+// handleTableMouse scrolls the table with the wheel, selects a clicked row
+// and opens its details when it's clicked again. Clicking the host in the
+// header moves to the next host.
+func (m *Model) handleTableMouse(msg tea.MouseMsg) tea.Cmd {
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		m.table.MoveUp(1)
+		return nil
+	case tea.MouseButtonWheelDown:
+		m.table.MoveDown(1)
+		return nil
+	case tea.MouseButtonLeft:
+		if msg.Action != tea.MouseActionPress {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if msg.Y == headerHostLine {
+		m.selectNextHost()
+		m.selected = make(map[model.GroupID]bool)
+		return m.refreshData()
+	}
+
+	row := m.rowAt(msg.Y)
+	if row < 0 {
+		return nil
+	}
+	if row != m.table.Cursor() {
+		m.table.SetCursor(row)
+		return nil
+	}
+	if pkg, ok := m.cursorPackage(); ok {
+		m.expandedPackages[pkg] = !m.expandedPackages[pkg]
+		return m.refreshData()
+	}
+	m.selectedRow = row
+	if g := m.cursorGroup(); g != nil {
+		m.openDetails(g)
+	}
+	return nil
+}
+
+// toggleTree turns the tree view on, nesting groups by package or by creator,
+// or off when it already nests them that way. Rows expanded under the other
+// nesting are collapsed.
+func (m *Model) toggleTree(byCreator bool) {
+	if m.treeView && m.treeByCreator == byCreator {
+		m.treeView = false
+		return
+	}
+	if m.treeByCreator != byCreator {
+		m.expandedPackages = make(map[string]bool)
+	}
+	m.treeView = true
+	m.treeByCreator = byCreator
+}
+
+// cursorGroup returns the group under the cursor, nil when there's none or
+// the cursor is on a package row
+func (m Model) cursorGroup() *model.Group {
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(m.displayedGroups) {
+		return nil
+	}
+	return m.displayedGroups[cursor]
+}
+
+// cursorPackage returns the package of the tree view's package row under
+// the cursor
+func (m Model) cursorPackage() (string, bool) {
+	if !m.onPackageRow() {
+		return "", false
+	}
+	return m.displayedPackages[m.table.Cursor()], true
+}
+
+// onTreeRow reports whether the cursor is on a row of the tree view
+func (m Model) onTreeRow() bool {
+	cursor := m.table.Cursor()
+	return m.treeView && cursor >= 0 && cursor < len(m.displayedPackages)
+}
+
+// cursorExpanded reports whether the cursor is in an expanded package of
+// the tree view
+func (m Model) cursorExpanded() bool {
+	return m.onTreeRow() && m.expandedPackages[m.displayedPackages[m.table.Cursor()]]
+}
+
+// onPackageRow reports whether the cursor is on a package row of the tree
+// view
+func (m Model) onPackageRow() bool {
+	return m.onTreeRow() && m.displayedGroups[m.table.Cursor()] == nil
+}
+
+// rowAt returns the index of the table row drawn on line y of the table
+// view, or -1 when there's no row there
+func (m Model) rowAt(y int) int {
+	line := y - strings.Count(m.renderTableTop(), "\n") - tableHeaderHeight
+	if line < 0 || line >= m.table.Height() {
+		return -1
+	}
+	row := m.scrollOffset() + line
+	if row >= len(m.table.Rows()) {
+		return -1
+	}
+	return row
+}
+
+// scrollOffset returns the first table row to show: the one shown last
+// time, moved just enough to keep the cursor in view and the table full
+func (m Model) scrollOffset() int {
+	offset, cursor, height := m.tableOffset, m.table.Cursor(), m.table.Height()
+	if cursor < offset {
+		offset = cursor
+	}
+	if cursor >= offset+height {
+		offset = cursor - height + 1
+	}
+	return max(min(offset, len(m.table.Rows())-height), 0)
+}
+
+// tableView renders the rows of the table from scrollOffset on. The table
+// scrolls itself in ways it doesn't report, so it's only given the rows that
+// fit, which makes the row on each line known for rowAt.
+func (m Model) tableView() string {
+	t := m.table
+	rows, cursor := t.Rows(), t.Cursor()
+	offset := m.scrollOffset()
+	end := min(offset+t.Height(), len(rows))
+
+	// Emptying the table first resets its own scroll position
+	t.SetRows(nil)
+	t.SetRows(rows[offset:end])
+	t.SetCursor(cursor - offset)
+	return t.View()
+}
+
+// openDetails shows the details view for a copy of the group, scrolled to
+// the top
+func (m *Model) openDetails(g *model.Group) {
+	groupCopy := *g
+	m.selectedGroup = &groupCopy
+	m.showDetails = true
+	m.details = viewport.New(m.width, m.detailsHeight())
+	m.details.SetContent(m.renderDetailsContent())
+}
+
+// primaryFunc is the function shown for a group: its innermost one outside
+// the skipped packages, so parked goroutines show where they park rather
+// than runtime.gopark
+func (m Model) primaryFunc(g *model.Group) string {
+	if frame := g.Trace.PrimaryFrame(m.skipPackages); frame != nil {
+		return frame.Func
+	}
+	return model.NoStack
+}
+
+// libraryRun returns how many frames at the start of trace are outside the
+// application. The runtime's elided frames marker ends a run, so it always
+// shows.
+func (m Model) libraryRun(trace model.StackTrace) int {
+	for i, frame := range trace {
+		if frame.Func == model.ElidedFrames || model.IsAppPackage(model.FramePackage(frame.Func), m.appPrefixes) {
+			return i
+		}
+	}
+	return len(trace)
+}
+
+// runLabel names a run of collapsed frames by their package when they share
+// one, as most runs of runtime or net/http frames do
+func runLabel(run model.StackTrace) string {
+	pkg := model.FramePackage(run[0].Func)
+	for _, frame := range run[1:] {
+		if model.FramePackage(frame.Func) != pkg {
+			return "library"
+		}
+	}
+	return pkg
+}
+
+// detailsHeight is the height of the details viewport, leaving a line for
+// its footer
+func (m Model) detailsHeight() int {
+	return max(m.height-2, 1)
+}
+
 func (m Model) renderDetailsView() string {
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+	frames := "Collapse library frames"
+	if m.collapseFrames {
+		frames = "Show all frames"
+	}
+	help := shortKey(m.keys.Collapse) + ": " + frames + " • " +
+		shortKey(m.keys.Copy) + ": Copy trace • Enter or Esc: Return"
+	if !m.details.AtTop() || !m.details.AtBottom() {
+		help = fmt.Sprintf("↑/↓ PgUp/PgDn: Scroll (%.0f%%) • %s", m.details.ScrollPercent()*100, help)
+	}
+	if m.copyStatus != "" {
+		help = m.copyStatus + " | " + help
+	}
+
+	return m.details.View() + "\n" + helpStyle.Render(help)
+}
+
+func (m Model) renderHelpView() string {
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+	help := "Esc or " + shortKey(m.keys.Help) + ": Return"
+	if !m.help.AtTop() || !m.help.AtBottom() {
+		help = fmt.Sprintf("↑/↓ PgUp/PgDn: Scroll (%.0f%%) • %s", m.help.ScrollPercent()*100, help)
+	}
+
+	return m.help.View() + "\n" + helpStyle.Render(help)
+}
+
+// stateHelp explains the goroutine states shown in the table
+var stateHelp = []struct {
+	state model.GoroutineState
+	desc  string
+}{
+	{model.StateRunning, "executing on a thread"},
+	{model.StateRunnable, "ready to run, waiting for a thread"},
+	{model.StateSyscall, "in a system call"},
+	{model.StateWaiting, "on I/O, a sleep, a semaphore or the runtime; Wait is how long"},
+	{model.StateBlocked, "on a channel operation, a select or a sync primitive"},
+	{model.StateUnknown, "the dump format doesn't record states (debug=1)"},
+}
+
+// renderHelpContent renders the key bindings and a short guide for the
+// scrollable help overlay
+func (m Model) renderHelpContent() string {
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("229"))
+	sectionStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("86"))
+	keyStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("205")).
+		Width(14)
+
+	line := func(k, desc string) {
+		b.WriteString("  " + keyStyle.Render(k) + desc + "\n")
+	}
+
+	b.WriteString(titleStyle.Render("Goroutine Explorer Help"))
+	b.WriteString("\n\n")
+
+	b.WriteString(sectionStyle.Render("Keys"))
+	b.WriteString("\n")
+	for _, binding := range m.keys.helpBindings() {
+		h := binding.Help()
+		line(h.Key, h.Desc)
+	}
+	line("alt+↑/↓", "jump 10 rows")
+	line("click", "select a row, click it again for details")
+	line("wheel", "scroll the table")
+
+	b.WriteString("\n")
+	b.WriteString(sectionStyle.Render("Details view"))
+	b.WriteString("\n")
+	line("enter/esc", "return to the table")
+	line(m.keys.Copy.Help().Key, m.keys.Copy.Help().Desc)
+	line("↑/↓ pgup/pgdn", "scroll long traces")
+	b.WriteString("  The details show the group's state, count and goroutine IDs, its full\n")
+	b.WriteString("  stack trace, where it was created and how long its goroutines waited.\n")
+
+	b.WriteString("\n")
+	b.WriteString(sectionStyle.Render("States"))
+	b.WriteString("\n")
+	for _, sh := range stateHelp {
+		line(string(sh.state), sh.desc)
+	}
+	b.WriteString("\n")
+	b.WriteString("  Goroutines with the same state and stack are grouped into one row.\n")
+	b.WriteString("  Δ is the change in count since the previous refresh, or since start.\n")
+
+	return b.String()
+}
+
+// renderDetailsContent renders the selected group's details for the
+// scrollable details view
+func (m Model) renderDetailsContent() string {
 	if m.selectedGroup == nil {
 		return "No details available"
 	}
@@ -354,8 +1395,20 @@ func (m Model) renderDetailsView() string {
 
 	b.WriteString(labelStyle.Render("Host:") + infoStyle.Render(m.selectedHost) + "\n")
 	b.WriteString(labelStyle.Render("State:") + infoStyle.Render(string(g.State)) + "\n")
+	if g.BlockReason != "" {
+		b.WriteString(labelStyle.Render("Blocked on:") + infoStyle.Render(g.BlockReason) + "\n")
+	}
+	if len(g.Labels) > 0 {
+		b.WriteString(labelStyle.Render("Labels:") + infoStyle.Render(strings.Join(formatLabels(g.Labels), " ")) + "\n")
+	}
 	b.WriteString(labelStyle.Render("Count:") + infoStyle.Render(fmt.Sprintf("%d", g.Count)) + "\n")
 	b.WriteString(labelStyle.Render("Group ID:") + infoStyle.Render(string(g.ID)) + "\n")
+	if len(g.IDs) > 0 {
+		b.WriteString(labelStyle.Render("Goroutines:") + infoStyle.Render(formatGoroutineIDs(g.IDs, 10)) + "\n")
+	}
+	if note, ok := m.notes[g.ID]; ok {
+		b.WriteString(labelStyle.Render("Note:") + infoStyle.Render(note) + "\n")
+	}
 
 	b.WriteString("\n")
 
@@ -363,14 +1416,42 @@ func (m Model) renderDetailsView() string {
 	stackTitle := lipgloss.NewStyle().
 		Bold(true).
 		Foreground(lipgloss.Color("229"))
+	// Where a merged group's goroutines are
+	if m.selectedHost == allHosts {
+		b.WriteString(stackTitle.Render("Hosts:"))
+		b.WriteString("\n")
+		for _, hc := range m.hostCounts(g.ID) {
+			b.WriteString(fmt.Sprintf("  • %s: %d\n", m.hostTitle(hc.host), hc.count))
+		}
+		b.WriteString("\n")
+	}
+
 	b.WriteString(stackTitle.Render("Stack Trace:"))
 	b.WriteString("\n")
 
 	frameStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("252"))
 
-	for i, frame := range g.Trace {
+	if len(g.Trace) == 0 {
+		b.WriteString("\n    ")
+		b.WriteString(fileStyle.Render(model.NoStack))
+	}
+	for i := 0; i < len(g.Trace); i++ {
+		frame := g.Trace[i]
+		if m.collapseFrames {
+			if n := m.libraryRun(g.Trace[i:]); n > 1 {
+				b.WriteString("\n    ")
+				b.WriteString(fileStyle.Render(fmt.Sprintf("… %d %s frames …", n, runLabel(g.Trace[i:i+n]))))
+				i += n - 1
+				continue
+			}
+		}
 		b.WriteString(fmt.Sprintf("\n%2d. ", i+1))
+		if frame.Func == model.ElidedFrames {
+			// Make it obvious the trace is incomplete
+			b.WriteString(fileStyle.Render("… additional frames elided by the runtime …"))
+			continue
+		}
 		b.WriteString(frameStyle.Render(frame.Func))
 		if frame.File != "" {
 			b.WriteString("\n    ")
@@ -388,6 +1469,29 @@ func (m Model) renderDetailsView() string {
 			b.WriteString("\n")
 			b.WriteString(fileStyle.Render(fmt.Sprintf("%s:%d", g.CreatedBy.File, g.CreatedBy.Line)))
 		}
+		if g.CreatedByGoroutine != 0 {
+			b.WriteString("\n")
+			b.WriteString(infoStyle.Render(fmt.Sprintf("Spawned by goroutine %d", g.CreatedByGoroutine)))
+		}
+	}
+
+	// How the waits moved over the host's history
+	history := m.store.GetHistory(m.selectedHost)
+	if trend, ok := analysis.WaitTrends(history)[g.ID]; ok && len(trend.Max) > 1 {
+		b.WriteString("\n\n")
+		b.WriteString(stackTitle.Render(fmt.Sprintf("Wait Trend (%d snapshots):", len(trend.Max))))
+		b.WriteString("\n")
+		last := len(trend.Max) - 1
+		b.WriteString(fmt.Sprintf("  • longest: %s → %s\n", formatWait(trend.Max[0]), formatWait(trend.Max[last])))
+		b.WriteString(fmt.Sprintf("  • median:  %s → %s\n", formatWait(trend.Median[0]), formatWait(trend.Median[last])))
+		if trend.Stuck {
+			b.WriteString(infoStyle.Render("  Stuck: the longest wait keeps growing"))
+			b.WriteString("\n")
+		}
+		if f, ok := analysis.FrozenGroups(history)[g.ID]; ok {
+			b.WriteString(infoStyle.Render(fmt.Sprintf("  Frozen: %d goroutine(s) unmoved for %d snapshots", f.Count, f.Snapshots)))
+			b.WriteString("\n")
+		}
 	}
 
 	// Wait durations
@@ -428,11 +1532,187 @@ func (m Model) renderDetailsView() string {
 		}
 	}
 
-	// Footer
+	return b.String()
+}
+
+// aggregateSnapshots returns the snapshots the aggregate views summarize:
+// the selected host's, or every host's in fleet mode
+func (m Model) aggregateSnapshots() []*model.Snapshot {
+	if !m.aggregateFleet && m.selectedHost != allHosts {
+		return []*model.Snapshot{m.store.GetSnapshot(m.selectedHost)}
+	}
+
+	var snapshots []*model.Snapshot
+	for _, snapshot := range m.store.GetAllSnapshots() {
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+func (m Model) aggregateScope() string {
+	if m.aggregateFleet || m.selectedHost == allHosts {
+		return fmt.Sprintf("All hosts (%d)", len(m.store.GetAllSnapshots()))
+	}
+	return "Host: " + m.selectedHost
+}
+
+// createdBySites returns the creation sites for the selected host (or all
+// hosts), capped at createdByTop, along with the uncapped number of sites
+func (m Model) createdBySites() ([]*model.CreatedBySite, int) {
+	sites := model.AggregateCreatedBy(m.aggregateSnapshots()...)
+	total := len(sites)
+	if m.createdByTop > 0 && len(sites) > m.createdByTop {
+		sites = sites[:m.createdByTop]
+	}
+	return sites, total
+}
+
+func (m Model) renderCreatedByView() string {
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("229"))
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+	selectedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57"))
+
+	b.WriteString(titleStyle.Render("Goroutines by Creation Site"))
 	b.WriteString("\n")
-	helpStyle := lipgloss.NewStyle().
+	b.WriteString(dimStyle.Render(m.aggregateScope()))
+	b.WriteString("\n\n")
+
+	sites, total := m.createdBySites()
+	if len(sites) == 0 {
+		b.WriteString(dimStyle.Render("No goroutines with a known creator"))
+		b.WriteString("\n")
+	}
+
+	for i, site := range sites {
+		marker := "▸"
+		if site.Func == m.expandedSite {
+			marker = "▾"
+		}
+		line := fmt.Sprintf("%s %7d  %s (%d groups)", marker, site.Count, site.Func, len(site.Groups))
+		if i == m.createdByCursor {
+			line = selectedStyle.Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+
+		if site.Func != m.expandedSite {
+			continue
+		}
+		for _, g := range site.Groups {
+			b.WriteString(dimStyle.Render(fmt.Sprintf("    %7d  %-10s %s", g.Count, g.State, m.primaryFunc(g))))
+			b.WriteString("\n")
+		}
+	}
+
+	if len(sites) < total {
+		b.WriteString("\n")
+		b.WriteString(dimStyle.Render(fmt.Sprintf("Showing top %d of %d sites", len(sites), total)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	help := []string{
+		shortKey(m.keys.Up) + "/" + shortKey(m.keys.Down) + ": Navigate",
+		shortKey(m.keys.Enter) + ": Expand",
+		shortKey(m.keys.Fleet) + ": Host/All hosts",
+		"Esc: Back",
+	}
+	b.WriteString(dimStyle.Render(strings.Join(help, " • ")))
+
+	return b.String()
+}
+
+func (m Model) renderPackagesView() string {
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("229"))
+	dimStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("241"))
-	b.WriteString(helpStyle.Render("Press Enter or Esc to return"))
+
+	frame := "top frame"
+	if m.appFrames {
+		frame = "application frame"
+	}
+	b.WriteString(titleStyle.Render("Goroutines by Package"))
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render(fmt.Sprintf("%s | by %s", m.aggregateScope(), frame)))
+	b.WriteString("\n\n")
+
+	packages := model.AggregatePackages(m.appFrames, m.aggregateSnapshots()...)
+	if len(packages) == 0 {
+		b.WriteString(dimStyle.Render("No goroutines"))
+		b.WriteString("\n")
+	}
+
+	// Leave room for the title and footer
+	limit := m.height - 7
+	for i, pc := range packages {
+		if limit > 0 && i >= limit {
+			b.WriteString(dimStyle.Render(fmt.Sprintf("… %d more packages", len(packages)-i)))
+			b.WriteString("\n")
+			break
+		}
+		b.WriteString(fmt.Sprintf("%7d  %s", pc.Count, pc.Package))
+		b.WriteString(dimStyle.Render(fmt.Sprintf(" (%d groups)", pc.Groups)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	help := []string{
+		shortKey(m.keys.Fleet) + ": Host/All hosts",
+		shortKey(m.keys.PackageFrame) + ": Top/App frame",
+		"Esc: Back",
+	}
+	b.WriteString(dimStyle.Render(strings.Join(help, " • ")))
+
+	return b.String()
+}
+
+func (m Model) renderLeaksView() string {
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("229"))
+	dimStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+	leakStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("208"))
+
+	history := m.store.GetHistory(m.selectedHost)
+	b.WriteString(titleStyle.Render("Leak Candidates"))
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render(fmt.Sprintf("Host: %s | groups growing across the last %d snapshots", m.selectedHost, analysis.LeakWindow)))
+	b.WriteString("\n\n")
+
+	candidates := analysis.DetectLeaks(history)
+	switch {
+	case len(history) < analysis.LeakWindow:
+		b.WriteString(dimStyle.Render(fmt.Sprintf("Collecting history: %d of %d snapshots", len(history), analysis.LeakWindow)))
+		b.WriteString("\n")
+	case len(candidates) == 0:
+		b.WriteString(dimStyle.Render("No steadily growing groups"))
+		b.WriteString("\n")
+	}
+
+	for _, c := range candidates {
+		b.WriteString(leakStyle.Render(fmt.Sprintf("%7d → %-7d %+6.1f/min", c.StartCount, c.CurrentCount, c.Rate)))
+		b.WriteString("  ")
+		b.WriteString(c.TopFrame.Func)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dimStyle.Render("Esc: Back"))
 
 	return b.String()
 }
@@ -445,7 +1725,9 @@ func (m Model) renderHeader() string {
 
 	statusIndicator := ""
 	paused := m.refresher != nil && m.refresher.IsPaused()
-	if paused {
+	if m.replay != nil {
+		statusIndicator = " " + m.renderReplayStatus()
+	} else if paused {
 		pauseStyle := lipgloss.NewStyle().
 			Bold(true).
 			Foreground(lipgloss.Color("196")).
@@ -460,38 +1742,132 @@ func (m Model) renderHeader() string {
 			Padding(0, 1)
 		statusIndicator = " " + manualStyle.Render("MANUAL")
 	}
+	// The watchdog flagged the host's data as old, so it isn't taken for live
+	if m.store.IsStale(m.selectedHost) {
+		staleStyle := lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("196")).
+			Background(lipgloss.Color("235")).
+			Padding(0, 1)
+		last := m.store.LastSuccess(m.selectedHost).Format("15:04:05")
+		statusIndicator += " " + staleStyle.Render("STALE since "+last)
+	}
 
-	displayedGroups := len(m.displayedGroups)
-	totalHosts := len(m.getSortedHosts())
+	displayedGroups := m.shownGroups
+	hosts := m.navigableHosts()
+	totalHosts := len(hosts)
 	hostIndex := 0
-	for i, h := range m.getSortedHosts() {
+	for i, h := range hosts {
 		if h == m.selectedHost {
 			hostIndex = i + 1
 			break
 		}
 	}
-	stats := fmt.Sprintf("Host %d/%d: %s | Groups: %d/%d | Goroutines: %d | Updated: %s%s",
+	// The selected host's goroutines by state, and memory figures when its
+	// memstats were scraped
+	states := ""
+	memStats := ""
+	if snapshot := m.selectedSnapshot(); snapshot != nil {
+		if counts := formatStateCounts(snapshot.StateCounts()); counts != "" {
+			states = " | " + counts
+		}
+		if snapshot.MemStats != nil {
+			memStats = fmt.Sprintf(" | Heap: %s | Sys: %s | GCs: %d",
+				formatBytes(snapshot.MemStats.HeapAlloc),
+				formatBytes(snapshot.MemStats.Sys),
+				snapshot.MemStats.NumGC,
+			)
+		}
+	}
+	if m.stateFilter != "" {
+		states += fmt.Sprintf(" | State: %s", m.stateFilter)
+	}
+	// Updates missed while the view was busy; the table may lag behind
+	missed := ""
+	if dropped := m.store.Dropped(m.updates); dropped > 0 {
+		missed = fmt.Sprintf(" | Missed updates: %d", dropped)
+	}
+	statsHead := fmt.Sprintf("Host %d/%d: %s | Groups: %d/%d | Goroutines: ",
 		hostIndex,
 		totalHosts,
-		m.selectedHost,
+		m.hostTitle(m.selectedHost),
 		displayedGroups,
 		m.stats.TotalGroups,
-		m.stats.TotalGoroutines,
+	)
+	total := fmt.Sprintf("%d", m.stats.TotalGoroutines)
+	statsTail := fmt.Sprintf("%s%s | Updated: %s%s%s",
+		states,
+		memStats,
 		m.lastUpdate.Format("15:04:05"),
+		missed,
 		statusIndicator,
 	)
+	stats := statsHead + total + statsTail
 
 	statsStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("241"))
+	statsLine := statsStyle.Render(stats)
+	// The total takes the count colors when it's over a threshold
+	if color := m.countColor(m.stats.TotalGoroutines); color != "" {
+		statsLine = statsStyle.Render(statsHead) +
+			lipgloss.NewStyle().Foreground(color).Render(total) +
+			statsStyle.Render(statsTail)
+	}
+
+	// The host's totals over its history, in whatever room the line leaves
+	if history := m.store.GetHistory(m.selectedHost); len(history) > 1 {
+		width := min(m.width-lipgloss.Width(stats)-1, sparklineMaxWidth)
+		if width >= sparklineMinWidth {
+			totals := make([]int, len(history))
+			for i, snapshot := range history {
+				totals[i] = snapshot.TotalGoroutines()
+			}
+			sparkStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("39"))
+			statsLine += " " + sparkStyle.Render(sparkline(totals, width))
+		}
+	}
+
+	lines := []string{title, statsLine}
+	if m.sinceStart || !m.pinnedAt.IsZero() {
+		lines = append(lines, statsStyle.Render(m.renderChangeSummary()))
+	}
 
-	// Check for errors and fetching status
+	// Check for errors and collection status
 	errors := m.store.GetErrors()
 	fetching := m.store.GetFetchingHosts()
-	
+	pending := 0
+	for _, phase := range m.store.GetPhases() {
+		if phase == store.PhaseRegistered {
+			pending++
+		}
+	}
+	partial, truncated, warned, stale := 0, 0, 0, 0
+	for host, snapshot := range m.store.GetAllSnapshots() {
+		if m.store.IsStale(host) {
+			stale++
+		}
+		if snapshot.Partial {
+			partial++
+		}
+		if snapshot.Truncated {
+			truncated++
+		}
+		if len(snapshot.Warnings) > 0 {
+			warned++
+		}
+	}
+	selected := m.selectedSnapshot()
+
 	var statusDisplay string
-	
-	// Check if current host is fetching
-	if _, isFetching := fetching[m.selectedHost]; isFetching {
+
+	if m.store.GetPhase(m.selectedHost) == store.PhaseRegistered {
+		// Current host hasn't been tried yet
+		pendingStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241")).
+			Bold(true)
+		statusDisplay = pendingStyle.Render("… Waiting for first collection")
+	} else if _, isFetching := fetching[m.selectedHost]; isFetching {
 		fetchingStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("226")).
 			Bold(true)
@@ -502,7 +1878,25 @@ func (m Model) renderHeader() string {
 			Foreground(lipgloss.Color("196")).
 			Bold(true)
 		statusDisplay = errorStyle.Render(fmt.Sprintf("⚠ Error: %v", err))
-	} else if len(errors) > 0 || len(fetching) > 0 {
+	} else if selected != nil && selected.Truncated {
+		// goru stopped reading the dump at a configured limit
+		truncatedStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("208")).
+			Bold(true)
+		statusDisplay = truncatedStyle.Render("⚠ Truncated dump: stopped at the configured limit, counts are incomplete")
+	} else if selected != nil && selected.Partial {
+		// The last dump was cut short, so counts can't be trusted
+		partialStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("208")).
+			Bold(true)
+		statusDisplay = partialStyle.Render("⚠ Partial dump: response was truncated, counts may be incomplete")
+	} else if selected != nil && len(selected.Warnings) > 0 {
+		// The dump parsed, but not all of it
+		warningStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("208")).
+			Bold(true)
+		statusDisplay = warningStyle.Render("⚠ Incomplete parse: " + strings.Join(selected.Warnings, "; "))
+	} else if len(errors) > 0 || len(fetching) > 0 || pending > 0 || partial > 0 || truncated > 0 || warned > 0 || stale > 0 {
 		// Show summary of other hosts with issues
 		var parts []string
 		if len(errors) > 0 {
@@ -515,33 +1909,123 @@ func (m Model) renderHeader() string {
 				Foreground(lipgloss.Color("226"))
 			parts = append(parts, fetchingStyle.Render(fmt.Sprintf("%d fetching", len(fetching))))
 		}
+		if pending > 0 {
+			pendingStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("241"))
+			parts = append(parts, pendingStyle.Render(fmt.Sprintf("%d pending", pending)))
+		}
+		if partial > 0 {
+			partialStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("208"))
+			parts = append(parts, partialStyle.Render(fmt.Sprintf("%d partial", partial)))
+		}
+		if truncated > 0 {
+			truncatedStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("208"))
+			parts = append(parts, truncatedStyle.Render(fmt.Sprintf("%d truncated", truncated)))
+		}
+		if warned > 0 {
+			warningStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("208"))
+			parts = append(parts, warningStyle.Render(fmt.Sprintf("%d with parse warnings", warned)))
+		}
+		if stale > 0 {
+			staleStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("196"))
+			parts = append(parts, staleStyle.Render(fmt.Sprintf("%d stale", stale)))
+		}
 		if len(parts) > 0 {
 			statusDisplay = strings.Join(parts, " | ")
 		}
 	}
-	
-	if statusDisplay != "" {
-		return lipgloss.JoinVertical(lipgloss.Left, title, statsStyle.Render(stats), statusDisplay)
+
+	if statusDisplay != "" {
+		lines = append(lines, statusDisplay)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// renderChangeSummary summarizes how the selected host has changed since the
+// first snapshot goru saw for it
+func (m Model) renderChangeSummary() string {
+	since := "Since start"
+	if !m.pinnedAt.IsZero() {
+		since = "Since pinned " + m.pinnedAt.Format("15:04:05")
+	}
+	snapshot := m.selectedSnapshot()
+	if snapshot == nil {
+		return since + ": waiting for first snapshot"
+	}
+
+	changes := m.currentChanges(snapshot)
+	if changes.IsEmpty() {
+		return since + ": no changes"
 	}
 
-	return lipgloss.JoinVertical(lipgloss.Left, title, statsStyle.Render(stats))
+	stats := m.diff.Stats(changes)
+	summary := fmt.Sprintf("%s: +%d/-%d goroutines | +%d/-%d groups | %d groups changed",
+		since,
+		stats.TotalAdded,
+		stats.TotalRemoved,
+		stats.GroupsAdded,
+		stats.GroupsRemoved,
+		stats.GroupsWithChanges,
+	)
+	if stats.GroupsTransitioned > 0 {
+		summary += fmt.Sprintf(" | %d changed state", stats.GroupsTransitioned)
+	}
+	return summary
 }
 
 func (m Model) renderFooter() string {
+	k := m.keys
 	help := []string{
-		"↑/↓: Navigate",
+		shortKey(k.Up) + "/" + shortKey(k.Down) + ": Navigate",
 		"Alt+↑/↓: ±10",
-		"←/→: Host",
-		"Enter: Details",
-		"f: Filter",
-		"c: Clear",
-		"s: Sort",
-		"r: Refresh",
-		"p: Pause",
-		"q: Quit",
+		shortKey(k.PrevHost) + "/" + shortKey(k.NextHost) + ": Host",
+		shortKey(k.Enter) + ": Details",
+		"Click: Select/Details",
+		shortKey(k.Filter) + ": Filter",
+		shortKey(k.StateFilter) + ": State",
+		shortKey(k.Clear) + ": Clear",
+		shortKey(k.Sort) + ": Sort",
+		shortKey(k.Tree) + "/" + shortKey(k.CreatorTree) + ": Tree by package/creator",
+		shortKey(k.CreatedBy) + ": Created By",
+		shortKey(k.Packages) + ": Packages",
+		shortKey(k.Labels) + ": Labels",
+		shortKey(k.Leaks) + ": Leaks",
+		shortKey(k.Select) + ": Select",
+		shortKey(k.Pin) + ": Pin",
+		shortKey(k.AbsentPins) + ": Absent pins",
+		shortKey(k.Note) + ": Note",
+		shortKey(k.Export) + ": Export",
+		shortKey(k.Snapshot) + "/" + shortKey(k.SnapshotText) + ": Save snapshot",
+		shortKey(k.Baseline) + ": Baseline",
+		shortKey(k.PinBaseline) + ": Pin baseline",
+		shortKey(k.Refresh) + "/" + shortKey(k.RefreshHost) + ": Refresh all/host",
+		shortKey(k.Pause) + ": Pause",
+		shortKey(k.Help) + ": Help",
+		shortKey(k.Quit) + ": Quit",
+	}
+	if m.targets != nil {
+		help = slices.Insert(help, len(help)-4, shortKey(k.AddTarget)+"/"+shortKey(k.RemoveTarget)+": Add/Remove target")
 	}
 
-	if m.filterMode {
+	if m.replay != nil {
+		// Playback takes the place of collection
+		replay := []string{
+			shortKey(k.ReplayPrev) + "/" + shortKey(k.ReplayNext) + ": Step",
+			shortKey(k.Pause) + ": Play/Pause",
+			shortKey(k.ReplayJump) + ": Jump to time",
+		}
+		help = slices.DeleteFunc(help, func(h string) bool {
+			return strings.HasSuffix(h, ": Pause") || strings.HasSuffix(h, ": Refresh all/host")
+		})
+		help = slices.Insert(help, len(help)-2, replay...)
+	}
+
+	if m.filterMode || m.noteMode || m.targetMode || m.jumpMode {
 		help = []string{
 			"Enter: Apply",
 			"Esc: Cancel",
@@ -551,7 +2035,18 @@ func (m Model) renderFooter() string {
 	helpStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("241"))
 
-	return helpStyle.Render(strings.Join(help, " • "))
+	footer := helpStyle.Render(strings.Join(help, " • "))
+	var status []string
+	if len(m.selected) > 0 {
+		status = append(status, fmt.Sprintf("%d selected", len(m.selected)))
+	}
+	if m.statusMsg != "" {
+		status = append(status, m.statusMsg)
+	}
+	if len(status) > 0 {
+		footer = helpStyle.Render(strings.Join(status, " | ")) + "\n" + footer
+	}
+	return footer
 }
 
 func (m *Model) buildTableRows() []table.Row {
@@ -559,11 +2054,24 @@ func (m *Model) buildTableRows() []table.Row {
 
 	// Clear displayed groups - MUST do this every time we rebuild
 	m.displayedGroups = nil
+	m.displayedPackages = nil
+	m.shownGroups = 0
+
+	// The selected host may be gone after a config reload
+	if m.selectedHost != "" && !slices.Contains(m.navigableHosts(), m.selectedHost) {
+		m.selectedHost = ""
+	}
+
+	// Switch to the restored host when it shows up
+	if m.preferredHost != "" && slices.Contains(m.navigableHosts(), m.preferredHost) {
+		m.selectedHost = m.preferredHost
+		m.preferredHost = ""
+	}
 
 	// Get current snapshot
 	var snapshot *model.Snapshot
 	if m.selectedHost != "" {
-		snapshot = m.store.GetSnapshot(m.selectedHost)
+		snapshot = m.selectedSnapshot()
 	} else {
 		// Select first available host
 		hosts := m.getSortedHosts()
@@ -584,83 +2092,77 @@ func (m *Model) buildTableRows() []table.Row {
 		groups = append(groups, g)
 	}
 
+	// Count changes since the diff baseline, for the delta column
+	changes := m.currentChanges(snapshot)
+	added := make(map[model.GroupID]bool)
+	if changes != nil {
+		for _, g := range changes.Added {
+			added[g.ID] = true
+		}
+	}
+
+	// Groups whose longest wait keeps growing are marked in the Wait column,
+	// and frozen ones with the number of snapshots they haven't moved in
+	history := m.store.GetHistory(m.selectedHost)
+	trends := analysis.WaitTrends(history)
+	frozen := analysis.FrozenGroups(history)
+
 	// Sort based on current sort mode
-	switch m.sortBy {
-	case "state":
-		sort.Slice(groups, func(i, j int) bool {
-			if groups[i].State != groups[j].State {
-				return groups[i].State < groups[j].State
-			}
-			// Secondary sort by count
-			if groups[i].Count != groups[j].Count {
-				return groups[i].Count > groups[j].Count
-			}
-			// Tertiary sort by group ID for deterministic ordering
-			return groups[i].ID < groups[j].ID
-		})
-	case "function":
-		sort.Slice(groups, func(i, j int) bool {
-			if groups[i].Trace[0].Func != groups[j].Trace[0].Func {
-				return groups[i].Trace[0].Func < groups[j].Trace[0].Func
-			}
-			// Secondary sort by count
-			if groups[i].Count != groups[j].Count {
-				return groups[i].Count > groups[j].Count
+	analysis.SortGroups(groups, m.sortBy, m.skipPackages, m.tiesByID)
+
+	// Pinned groups go first, followed by absent pins as zero-count rows
+	if len(m.pinned) > 0 {
+		var pinned, rest []*model.Group
+		for _, g := range groups {
+			if _, ok := m.pinned[g.ID]; ok {
+				pinned = append(pinned, g)
+			} else {
+				rest = append(rest, g)
 			}
-			// Tertiary sort by group ID for deterministic ordering
-			return groups[i].ID < groups[j].ID
-		})
-	case "wait":
-		sort.Slice(groups, func(i, j int) bool {
-			// Get max wait time for each group
-			maxI := getMaxWaitMinutes(groups[i].WaitDurations)
-			maxJ := getMaxWaitMinutes(groups[j].WaitDurations)
-			if maxI != maxJ {
-				return maxI > maxJ // Longer waits first
-			}
-			// Secondary sort by count
-			if groups[i].Count != groups[j].Count {
-				return groups[i].Count > groups[j].Count
-			}
-			// Tertiary sort by group ID for deterministic ordering
-			return groups[i].ID < groups[j].ID
-		})
-	default: // "count"
-		sort.Slice(groups, func(i, j int) bool {
-			if groups[i].Count != groups[j].Count {
-				return groups[i].Count > groups[j].Count
+		}
+
+		if !m.hideAbsentPins {
+			var absent []*model.Group
+			for id, g := range m.pinned {
+				if _, ok := snapshot.Groups[id]; !ok {
+					placeholder := *g
+					placeholder.Count = 0
+					placeholder.WaitDurations = nil
+					placeholder.Waits = nil
+					absent = append(absent, &placeholder)
+				}
 			}
-			// Secondary sort by group ID for deterministic ordering
-			return groups[i].ID < groups[j].ID
-		})
+			sort.Slice(absent, func(i, j int) bool {
+				return absent[i].ID < absent[j].ID
+			})
+			pinned = append(pinned, absent...)
+		}
+
+		groups = append(pinned, rest...)
 	}
 
 	// Build rows
+	var shown []*model.Group
 	for _, g := range groups {
+		_, isPinned := m.pinned[g.ID]
 
-		// Apply filter - search entire stack trace
-		if m.filter != "" {
-			found := false
-			searchTerm := strings.ToLower(m.filter)
-			for _, frame := range g.Trace {
-				if strings.Contains(strings.ToLower(frame.Func), searchTerm) ||
-					strings.Contains(strings.ToLower(frame.File), searchTerm) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
+		// Apply filters. Pins are always shown.
+		if !isPinned && !m.matchesFilters(g) {
+			continue
 		}
 
 		// Store the group for details view
-		m.displayedGroups = append(m.displayedGroups, g)
+		shown = append(shown, g)
 
 		// Format wait duration with abbreviated units
 		wait := ""
 		if len(g.WaitDurations) > 0 {
-			wait = formatWaitRange(g.WaitDurations)
+			wait = formatWaitRange(g)
+		}
+		if f, ok := frozen[g.ID]; ok {
+			wait = fmt.Sprintf("≡%d %s", f.Snapshots, wait)
+		} else if trends[g.ID].Stuck {
+			wait = "↑ " + wait
 		}
 
 		// Format created by
@@ -673,22 +2175,275 @@ func (m *Model) buildTableRows() []table.Row {
 			}
 		}
 
+		// Mark selected and pinned groups, and annotated ones with an asterisk
+		shownState := g.State
+		if isPinned && g.Count == 0 {
+			shownState = "absent"
+		}
+		state := string(shownState)
+		if isPinned {
+			state = "★ " + state
+		}
+		if m.selected[g.ID] {
+			state = "● " + state
+		}
+		if _, ok := m.notes[g.ID]; ok {
+			state += " *"
+		}
+
+		delta := ""
+		if added[g.ID] {
+			delta = formatDelta(0, true)
+		} else if changes != nil {
+			delta = formatDelta(changes.Updated[g.ID], false)
+		}
+
 		// Main row
 		mainRow := table.Row{
-			string(g.State),
-			g.Trace[0].Func,
+			m.formatState(state, shownState),
+			m.primaryFunc(g),
 			createdBy,
-			fmt.Sprintf("%d", g.Count),
+			m.formatCount(g.Count),
+			delta,
 			wait,
 		}
+		if m.showFrames {
+			mainRow = append(mainRow, fmt.Sprintf("%d", len(g.Trace)))
+		}
 		rows = append(rows, mainRow)
 	}
 
+	m.shownGroups = len(shown)
+	if m.treeView {
+		return m.buildTreeRows(shown, rows, added, changes)
+	}
+	m.displayedGroups = shown
 	return rows
 }
 
-func (m *Model) selectNextHost() {
+// buildTreeRows nests the group rows under a row for the package of their
+// top frame, with the packages in the order their first group is sorted.
+// With treeByCreator they're nested under the function that created them
+// instead, the creators with the most goroutines first when sorting by count,
+// as that's where a leak is started. Collapsed rows hide their groups.
+func (m *Model) buildTreeRows(groups []*model.Group, groupRows []table.Row, added map[model.GroupID]bool, changes *model.ChangeSet) []table.Row {
+	var packages []string
+	members := make(map[string][]int)
+	counts := make(map[string]int)
+	for i, g := range groups {
+		pkg := model.FramePackage(m.primaryFunc(g))
+		if m.treeByCreator {
+			pkg = noCreator
+			if g.CreatedBy != nil {
+				pkg = g.CreatedBy.Func
+			}
+		}
+		if _, ok := members[pkg]; !ok {
+			packages = append(packages, pkg)
+		}
+		members[pkg] = append(members[pkg], i)
+		counts[pkg] += g.Count
+	}
+	if m.treeByCreator && m.sortBy == analysis.SortCount {
+		sort.SliceStable(packages, func(i, j int) bool {
+			return counts[packages[i]] > counts[packages[j]]
+		})
+	}
+
+	var rows []table.Row
+	for _, pkg := range packages {
+		count, delta := counts[pkg], 0
+		for _, i := range members[pkg] {
+			g := groups[i]
+			if added[g.ID] {
+				delta += g.Count
+			} else if changes != nil {
+				delta += changes.Updated[g.ID]
+			}
+		}
+
+		marker := "▸"
+		if m.expandedPackages[pkg] {
+			marker = "▾"
+		}
+		noun := "groups"
+		if len(members[pkg]) == 1 {
+			noun = "group"
+		}
+		pkgRow := table.Row{
+			fmt.Sprintf("%s %d %s", marker, len(members[pkg]), noun),
+			pkg,
+			"",
+			m.formatCount(count),
+			formatDelta(delta, false),
+			"",
+		}
+		if m.showFrames {
+			pkgRow = append(pkgRow, "")
+		}
+		rows = append(rows, pkgRow)
+		m.displayedGroups = append(m.displayedGroups, nil)
+		m.displayedPackages = append(m.displayedPackages, pkg)
+
+		if !m.expandedPackages[pkg] {
+			continue
+		}
+		for _, i := range members[pkg] {
+			row := slices.Clone(groupRows[i])
+			row[1] = "  " + row[1]
+			rows = append(rows, row)
+			m.displayedGroups = append(m.displayedGroups, groups[i])
+			m.displayedPackages = append(m.displayedPackages, pkg)
+		}
+	}
+	return rows
+}
+
+// noCreator stands in the creator tree for the goroutines started without a
+// go statement, such as main
+const noCreator = "(no creator)"
+
+// currentChanges returns how the snapshot differs from the diff baseline:
+// the pinned snapshot when there is one, else the previous refresh, or the
+// first snapshot when diffing since start
+func (m Model) currentChanges(snapshot *model.Snapshot) *model.ChangeSet {
+	if !m.pinnedAt.IsZero() {
+		baseline := m.store.GetPinnedBaseline(snapshot.Host)
+		if snapshot.Host == allHosts {
+			baseline = m.mergeHosts(m.store.GetPinnedBaseline)
+		}
+		return m.diff.Compare(baseline, snapshot)
+	}
+	if m.sinceStart {
+		baseline := m.store.GetBaseline(snapshot.Host)
+		if snapshot.Host == allHosts {
+			baseline = m.mergeHosts(m.store.GetBaseline)
+		}
+		return m.diff.Compare(baseline, snapshot)
+	}
+	if snapshot.Host == allHosts {
+		return m.mergedChangeSet()
+	}
+	return m.store.GetChangeSet(snapshot.Host)
+}
+
+// mergedChangeSet sums every host's latest changes into count deltas. A
+// group that appeared or vanished on one host may still exist on others, so
+// it counts as growing or shrinking by its full count rather than as added
+// or removed.
+func (m Model) mergedChangeSet() *model.ChangeSet {
+	merged := model.NewChangeSet(allHosts)
+	for _, host := range m.getSortedHosts() {
+		changes := m.store.GetChangeSet(host)
+		if changes == nil {
+			continue
+		}
+		for id, delta := range changes.Updated {
+			merged.Updated[id] += delta
+		}
+		for _, g := range changes.Added {
+			merged.Updated[g.ID] += g.Count
+		}
+		for _, g := range changes.Removed {
+			merged.Updated[g.ID] -= g.Count
+		}
+	}
+	return merged
+}
+
+// stateWidth is the width of the state column
+const stateWidth = 13
+
+// countWidth is the width of the count column
+const countWidth = 7
+
+// countColorWidth is what the count colors' escape codes add to the count
+// column's width, which the table counts towards it like deltaWidth
+const countColorWidth = 7
+
+// deltaWidth leaves room in the delta column for the color escape codes,
+// which the table counts towards a cell's width
+const deltaWidth = 12
+
+var (
+	deltaGrowingStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	deltaShrinkingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	deltaNewStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+)
+
+// formatDelta renders a group's count change, red when growing and green
+// when shrinking. Unchanged groups are blank and new ones marked "new".
+func formatDelta(delta int, added bool) string {
+	switch {
+	case added:
+		return deltaNewStyle.Render("new")
+	case delta > 0:
+		return deltaGrowingStyle.Render(fmt.Sprintf("+%d", delta))
+	case delta < 0:
+		return deltaShrinkingStyle.Render(fmt.Sprintf("%d", delta))
+	default:
+		return ""
+	}
+}
+
+// allHosts is the pseudo-host whose table merges every host's groups
+const allHosts = "*all*"
+
+// navigableHosts returns the hosts ←/→ cycle through: every host, then the
+// all-hosts view when there's more than one
+func (m Model) navigableHosts() []string {
 	hosts := m.getSortedHosts()
+	if len(hosts) > 1 {
+		hosts = append(hosts, allHosts)
+	}
+	return hosts
+}
+
+// selectedSnapshot returns the selected host's snapshot, or every host's
+// merged in the all-hosts view
+func (m Model) selectedSnapshot() *model.Snapshot {
+	if m.selectedHost == allHosts {
+		return m.mergeHosts(m.store.GetSnapshot)
+	}
+	return m.store.GetSnapshot(m.selectedHost)
+}
+
+// mergeHosts merges a snapshot of every host, in host order so merged
+// groups are stable between refreshes
+func (m Model) mergeHosts(get func(host string) *model.Snapshot) *model.Snapshot {
+	var snapshots []*model.Snapshot
+	for _, host := range m.getSortedHosts() {
+		snapshots = append(snapshots, get(host))
+	}
+	return model.MergeSnapshots(allHosts, snapshots...)
+}
+
+// hostCount is how many goroutines of a group one host has
+type hostCount struct {
+	host  string
+	count int
+}
+
+// hostCounts breaks a group's count down by host, largest first
+func (m Model) hostCounts(id model.GroupID) []hostCount {
+	var counts []hostCount
+	for host, snapshot := range m.store.GetAllSnapshots() {
+		if g, ok := snapshot.Groups[id]; ok {
+			counts = append(counts, hostCount{host, g.Count})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].host < counts[j].host
+	})
+	return counts
+}
+
+func (m *Model) selectNextHost() {
+	m.preferredHost = ""
+	hosts := m.navigableHosts()
 	if len(hosts) == 0 {
 		return
 	}
@@ -702,7 +2457,8 @@ func (m *Model) selectNextHost() {
 }
 
 func (m *Model) selectPrevHost() {
-	hosts := m.getSortedHosts()
+	m.preferredHost = ""
+	hosts := m.navigableHosts()
 	if len(hosts) == 0 {
 		return
 	}
@@ -723,18 +2479,48 @@ func (m Model) getSortedHosts() []string {
 	// Get all registered hosts from the store
 	hosts := m.store.GetAllHosts()
 	sort.Strings(hosts)
+	if m.hostLabel != "" {
+		sort.SliceStable(hosts, func(i, j int) bool {
+			vi, iok := m.store.GetLabels(hosts[i])[m.hostLabel]
+			vj, jok := m.store.GetLabels(hosts[j])[m.hostLabel]
+			if iok != jok {
+				return iok
+			}
+			return vi < vj
+		})
+	}
 	return hosts
 }
 
-func (m *Model) updateTableColumns() {
-	// Create columns with sort indicator
+// hostTitle returns a host followed by its labels, or only the value of the
+// host label when there is one, e.g. "10.0.0.1:6060 [prod]"
+func (m Model) hostTitle(host string) string {
+	labels := m.store.GetLabels(host)
+	if m.hostLabel != "" {
+		if value, ok := labels[m.hostLabel]; ok {
+			return host + " [" + value + "]"
+		}
+		return host
+	}
+	if len(labels) == 0 {
+		return host
+	}
+	return host + " [" + strings.Join(formatLabels(labels), " ") + "]"
+}
+
+// tableColumns returns the table columns with an arrow on the sorted one
+func (m Model) tableColumns() []table.Column {
 	columns := []table.Column{
-		{Title: "State", Width: 13},
+		{Title: "State", Width: m.stateColumnWidth()},
 		{Title: "Function", Width: 52},
 		{Title: "Created By", Width: 75},
-		{Title: "Count", Width: 7},
+		{Title: "Count", Width: m.countColumnWidth()},
+		{Title: "Δ", Width: deltaWidth},
 		{Title: "Wait", Width: 10},
 	}
+	if m.showFrames {
+		columns = append(columns, table.Column{Title: "Frames", Width: 8})
+	}
 
 	// Add arrow to the sorted column
 	switch m.sortBy {
@@ -745,9 +2531,18 @@ func (m *Model) updateTableColumns() {
 	case "count":
 		columns[3].Title = "Count ↓"
 	case "wait":
-		columns[4].Title = "Wait ↓"
+		columns[5].Title = "Wait ↓"
+	case "frames":
+		columns[6].Title = "Frames ↓"
 	}
 
+	return columns
+}
+
+func (m *Model) updateTableColumns() {
+	// Create columns with sort indicator
+	columns := m.tableColumns()
+
 	// Get current cursor position
 	cursor := m.table.Cursor()
 
@@ -764,16 +2559,9 @@ func (m *Model) updateTableColumns() {
 	)
 
 	// Apply the same styles
-	s1 := table.DefaultStyles()
-	s1.Header = s1.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderBottom(true).
-		Bold(false)
-	s1.Selected = s1.Selected.
-		Foreground(lipgloss.Color("229")).
-		Background(lipgloss.Color("57")).
-		Bold(false)
-	t.SetStyles(s1)
+	t.SetStyles(m.tableStyles())
+
+	t.KeyMap = m.keys.tableKeyMap()
 
 	// Set the current rows and cursor
 	rows := m.buildTableRows()
@@ -785,61 +2573,146 @@ func (m *Model) updateTableColumns() {
 	m.table = t
 }
 
+// formatGoroutineIDs lists up to limit goroutine numbers, summarizing the rest
+// as "+N more"
+func formatGoroutineIDs(ids []uint64, limit int) string {
+	parts := make([]string, 0, limit+1)
+	for i, id := range ids {
+		if i == limit {
+			parts = append(parts, fmt.Sprintf("+%d more", len(ids)-limit))
+			break
+		}
+		parts = append(parts, strconv.FormatUint(id, 10))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stateOrder lists goroutine states in the order they're shown
+var stateOrder = []model.GoroutineState{
+	model.StateRunning,
+	model.StateRunnable,
+	model.StateBlocked,
+	model.StateWaiting,
+	model.StateSyscall,
+	model.StateUnknown,
+}
+
+// formatStateCounts renders goroutine counts by state, e.g.
+// "running:12 waiting:340 blocked:5", skipping empty states
+func formatStateCounts(counts map[model.GoroutineState]int) string {
+	var parts []string
+	for _, state := range stateOrder {
+		if counts[state] > 0 {
+			parts = append(parts, fmt.Sprintf("%s:%d", state, counts[state]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "12.3 MiB"
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Bounds of the header sparkline's width; it isn't shown with less room
+const (
+	sparklineMinWidth = 5
+	sparklineMaxWidth = 30
+)
+
+// sparkBars are the bars of a sparkline, lowest first
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the last width values as bars scaled between the lowest
+// and highest of them, so a flat series is a row of the lowest bar
+func sparkline(values []int, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	if len(values) == 0 {
+		return ""
+	}
+
+	lo, hi := slices.Min(values), slices.Max(values)
+	bars := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if hi > lo {
+			level = (v - lo) * (len(sparkBars) - 1) / (hi - lo)
+		}
+		bars[i] = sparkBars[level]
+	}
+	return string(bars)
+}
+
 func abbreviateWaitTime(waitTime string) string {
 	// Replace "minutes" with "min" or "mins"
 	waitTime = strings.ReplaceAll(waitTime, " minutes", " mins")
 	waitTime = strings.ReplaceAll(waitTime, " minute", " min")
+	waitTime = strings.ReplaceAll(waitTime, " seconds", "s")
+	waitTime = strings.ReplaceAll(waitTime, " second", "s")
+	waitTime = strings.ReplaceAll(waitTime, " hours", "h")
+	waitTime = strings.ReplaceAll(waitTime, " hour", "h")
 	return waitTime
 }
 
-func formatWaitRange(durations []string) string {
-	if len(durations) == 0 {
+func formatWaitRange(g *model.Group) string {
+	if len(g.WaitDurations) == 0 {
 		return ""
 	}
 
 	// Get unique values
 	uniqueMap := make(map[string]int)
-	for _, d := range durations {
+	for _, d := range g.WaitDurations {
 		uniqueMap[d]++
 	}
 
 	// If only one unique value, just return it without count
 	if len(uniqueMap) == 1 {
-		dur := durations[0]
-		return abbreviateWaitTime(dur)
+		return abbreviateWaitTime(g.WaitDurations[0])
 	}
 
 	// Multiple unique values - find min and max
-	// Parse durations to find range
-	minMinutes := int64(999999)
-	maxMinutes := int64(0)
-
-	for dur := range uniqueMap {
-		minutes := parseMinutes(dur)
-		if minutes < minMinutes {
-			minMinutes = minutes
-		}
-		if minutes > maxMinutes {
-			maxMinutes = minutes
-		}
+	waits := g.WaitTimes()
+	shortest, longest := waits[0], waits[0]
+	for _, d := range waits[1:] {
+		shortest = min(shortest, d)
+		longest = max(longest, d)
 	}
 
-	// Format range as "X-Ymin"
-	if minMinutes == maxMinutes {
-		return formatMinutes(minMinutes)
+	if shortest == longest {
+		return formatWait(shortest)
 	}
-	return fmt.Sprintf("%d-%dmin", minMinutes, maxMinutes)
+	// Format whole-minute ranges compactly as "X-Ymin"
+	if shortest >= time.Minute && longest < time.Hour && shortest%time.Minute == 0 && longest%time.Minute == 0 {
+		return fmt.Sprintf("%d-%dmin", int64(shortest/time.Minute), int64(longest/time.Minute))
+	}
+	return formatWait(shortest) + "-" + formatWait(longest)
 }
 
-func parseMinutes(duration string) int64 {
-	// Simple parser for "X minutes" format
-	parts := strings.Fields(duration)
-	if len(parts) >= 2 {
-		if val, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
-			return val
+// formatWait renders a wait duration in the largest unit that fits, e.g.
+// "90s", "5 mins" or "2h"
+func formatWait(d time.Duration) string {
+	switch {
+	case d >= time.Hour:
+		if rest := (d % time.Hour) / time.Minute; rest > 0 {
+			return fmt.Sprintf("%dh%dm", int64(d/time.Hour), int64(rest))
 		}
+		return fmt.Sprintf("%dh", int64(d/time.Hour))
+	case d >= time.Minute && d%time.Minute == 0:
+		return formatMinutes(int64(d / time.Minute))
+	default:
+		return fmt.Sprintf("%ds", int64(d/time.Second))
 	}
-	return 0
 }
 
 func formatMinutes(minutes int64) string {
@@ -849,22 +2722,6 @@ func formatMinutes(minutes int64) string {
 	return fmt.Sprintf("%d mins", minutes)
 }
 
-// getMaxWaitMinutes returns the maximum wait time in minutes from a list of wait durations
-func getMaxWaitMinutes(durations []string) int64 {
-	if len(durations) == 0 {
-		return 0
-	}
-	
-	maxMinutes := int64(0)
-	for _, dur := range durations {
-		minutes := parseMinutes(dur)
-		if minutes > maxMinutes {
-			maxMinutes = minutes
-		}
-	}
-	return maxMinutes
-}
-
 // Messages
 type refreshMsg struct{}
 
@@ -875,6 +2732,87 @@ func (m Model) waitForUpdate() tea.Cmd {
 	}
 }
 
+// regexFilterPrefix marks a text filter as a regular expression
+const regexFilterPrefix = "re:"
+
+// setFilter applies a text filter, compiling it once when it's a regular
+// expression. A pattern that doesn't compile is matched as plain text and
+// its error kept for display.
+func (m *Model) setFilter(filter string) {
+	m.filter = filter
+	m.filterRe = nil
+	m.filterErr = nil
+	if pattern, ok := strings.CutPrefix(filter, regexFilterPrefix); ok {
+		m.filterRe, m.filterErr = regexp.Compile(pattern)
+	}
+}
+
+// matchesFilters reports whether a group is in the filtered state and, when
+// a text filter is set, has it in its block reason, one of its key=value
+// labels or somewhere in its stack trace
+func (m Model) matchesFilters(g *model.Group) bool {
+	if m.stateFilter != "" && g.State != m.stateFilter {
+		return false
+	}
+	if m.filter == "" {
+		return true
+	}
+
+	if m.filterRe != nil {
+		if g.BlockReason != "" && m.filterRe.MatchString(g.BlockReason) {
+			return true
+		}
+		for _, label := range formatLabels(g.Labels) {
+			if m.filterRe.MatchString(label) {
+				return true
+			}
+		}
+		for _, frame := range g.Trace {
+			if m.filterRe.MatchString(frame.Func) || m.filterRe.MatchString(frame.File) {
+				return true
+			}
+		}
+		return false
+	}
+
+	searchTerm := strings.ToLower(strings.TrimPrefix(m.filter, regexFilterPrefix))
+	if strings.Contains(strings.ToLower(g.BlockReason), searchTerm) {
+		return true
+	}
+	for _, label := range formatLabels(g.Labels) {
+		if strings.Contains(strings.ToLower(label), searchTerm) {
+			return true
+		}
+	}
+	for _, frame := range g.Trace {
+		if strings.Contains(strings.ToLower(frame.Func), searchTerm) ||
+			strings.Contains(strings.ToLower(frame.File), searchTerm) {
+			return true
+		}
+	}
+	return false
+}
+
+// stateFilters is the cycle of the state filter, starting from all states
+var stateFilters = []model.GoroutineState{
+	"",
+	model.StateRunning,
+	model.StateRunnable,
+	model.StateBlocked,
+	model.StateWaiting,
+	model.StateSyscall,
+}
+
+// nextStateFilter returns the state filter that follows current in the cycle
+func nextStateFilter(current model.GoroutineState) model.GoroutineState {
+	for i, state := range stateFilters {
+		if state == current {
+			return stateFilters[(i+1)%len(stateFilters)]
+		}
+	}
+	return ""
+}
+
 func (m Model) refreshData() tea.Cmd {
 	return func() tea.Msg {
 		return refreshMsg{}
@@ -883,20 +2821,156 @@ func (m Model) refreshData() tea.Cmd {
 
 // Key bindings
 type keyMap struct {
-	Up       key.Binding
-	Down     key.Binding
-	NextHost key.Binding
-	PrevHost key.Binding
-	Enter    key.Binding
-	Filter   key.Binding
-	Clear    key.Binding
-	Pause    key.Binding
-	Sort     key.Binding
-	Refresh  key.Binding
-	Quit     key.Binding
-}
-
-var keys = keyMap{
+	Up           key.Binding
+	Down         key.Binding
+	NextHost     key.Binding
+	PrevHost     key.Binding
+	Enter        key.Binding
+	Filter       key.Binding
+	StateFilter  key.Binding
+	Clear        key.Binding
+	Pause        key.Binding
+	Select       key.Binding
+	Pin          key.Binding
+	AbsentPins   key.Binding
+	Note         key.Binding
+	Export       key.Binding
+	Snapshot     key.Binding
+	SnapshotText key.Binding
+	Sort         key.Binding
+	CreatedBy    key.Binding
+	Packages     key.Binding
+	PackageFrame key.Binding
+	Labels       key.Binding
+	LabelKey     key.Binding
+	Leaks        key.Binding
+	Fleet        key.Binding
+	Baseline     key.Binding
+	AddTarget    key.Binding
+	RemoveTarget key.Binding
+	Collapse     key.Binding
+	PinBaseline  key.Binding
+	Refresh      key.Binding
+	RefreshHost  key.Binding
+	Copy         key.Binding
+	Top          key.Binding
+	Bottom       key.Binding
+	Tree         key.Binding
+	CreatorTree  key.Binding
+	ReplayNext   key.Binding
+	ReplayPrev   key.Binding
+	ReplayJump   key.Binding
+	Help         key.Binding
+	Quit         key.Binding
+}
+
+// actions maps the action names used in the config to the bindings
+func (k *keyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"up":            &k.Up,
+		"down":          &k.Down,
+		"next_host":     &k.NextHost,
+		"prev_host":     &k.PrevHost,
+		"details":       &k.Enter,
+		"filter":        &k.Filter,
+		"state_filter":  &k.StateFilter,
+		"clear":         &k.Clear,
+		"pause":         &k.Pause,
+		"select":        &k.Select,
+		"pin":           &k.Pin,
+		"absent_pins":   &k.AbsentPins,
+		"note":          &k.Note,
+		"export":        &k.Export,
+		"snapshot":      &k.Snapshot,
+		"snapshot_text": &k.SnapshotText,
+		"sort":          &k.Sort,
+		"created_by":    &k.CreatedBy,
+		"packages":      &k.Packages,
+		"package_frame": &k.PackageFrame,
+		"labels":        &k.Labels,
+		"label_key":     &k.LabelKey,
+		"leaks":         &k.Leaks,
+		"fleet":         &k.Fleet,
+		"baseline":      &k.Baseline,
+		"pin_baseline":  &k.PinBaseline,
+		"add_target":    &k.AddTarget,
+		"remove_target": &k.RemoveTarget,
+		"refresh":       &k.Refresh,
+		"refresh_host":  &k.RefreshHost,
+		"copy":          &k.Copy,
+		"collapse":      &k.Collapse,
+		"top":           &k.Top,
+		"bottom":        &k.Bottom,
+		"tree":          &k.Tree,
+		"creator_tree":  &k.CreatorTree,
+		"replay_next":   &k.ReplayNext,
+		"replay_prev":   &k.ReplayPrev,
+		"replay_jump":   &k.ReplayJump,
+		"help":          &k.Help,
+		"quit":          &k.Quit,
+	}
+}
+
+// helpBindings are the bindings listed in the help overlay, in order
+func (k keyMap) helpBindings() []key.Binding {
+	return []key.Binding{
+		k.Up, k.Down, k.Top, k.Bottom, k.PrevHost, k.NextHost, k.Enter,
+		k.Filter, k.StateFilter, k.Clear, k.Sort, k.CreatedBy, k.Packages,
+		k.PackageFrame, k.Labels, k.LabelKey, k.Leaks, k.Fleet, k.Select, k.Pin, k.AbsentPins,
+		k.Note, k.Export, k.Snapshot, k.SnapshotText, k.Baseline, k.PinBaseline, k.AddTarget, k.RemoveTarget, k.Refresh,
+		k.RefreshHost, k.Pause, k.Collapse, k.Tree, k.CreatorTree, k.ReplayPrev, k.ReplayNext,
+		k.ReplayJump, k.Help, k.Quit,
+	}
+}
+
+// tableKeyMap is the table's default key map moving with the bindings for
+// up, down, top and bottom
+func (k keyMap) tableKeyMap() table.KeyMap {
+	km := table.DefaultKeyMap()
+	km.LineUp = k.Up
+	km.LineDown = k.Down
+	km.GotoTop = k.Top
+	km.GotoBottom = k.Bottom
+	return km
+}
+
+// keyNames are the symbols shown in help for keys with long names
+var keyNames = map[string]string{
+	"up":    "↑",
+	"down":  "↓",
+	"left":  "←",
+	"right": "→",
+	"enter": "Enter",
+	"esc":   "Esc",
+	" ":     "space",
+}
+
+// keyName is how a key is shown in help
+func keyName(k string) string {
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	return k
+}
+
+// helpKeys is the help text for a binding's keys
+func helpKeys(ks []string) string {
+	names := make([]string, len(ks))
+	for i, k := range ks {
+		names[i] = keyName(k)
+	}
+	return strings.Join(names, "/")
+}
+
+// shortKey is the first key of a binding as shown in the footer
+func shortKey(b key.Binding) string {
+	if len(b.Keys()) == 0 {
+		return ""
+	}
+	return keyName(b.Keys()[0])
+}
+
+var defaultKeys = keyMap{
 	Up: key.NewBinding(
 		key.WithKeys("up", "k"),
 		key.WithHelp("↑/k", "up"),
@@ -921,22 +2995,142 @@ var keys = keyMap{
 		key.WithKeys("f", "/"),
 		key.WithHelp("f", "filter"),
 	),
+	StateFilter: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "cycle state filter"),
+	),
 	Clear: key.NewBinding(
 		key.WithKeys("c"),
-		key.WithHelp("c", "clear filter"),
+		key.WithHelp("c", "clear filters"),
 	),
 	Pause: key.NewBinding(
-		key.WithKeys("p", " "),
-		key.WithHelp("p/space", "pause updates"),
+		key.WithKeys("p"),
+		key.WithHelp("p", "pause updates"),
+	),
+	Select: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("space", "toggle selection"),
+	),
+	Pin: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("m", "pin group"),
+	),
+	AbsentPins: key.NewBinding(
+		key.WithKeys("z"),
+		key.WithHelp("z", "toggle absent pinned rows"),
+	),
+	Note: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "annotate selection"),
+	),
+	Export: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "export selection"),
+	),
+	Snapshot: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "save snapshot as JSON"),
+	),
+	SnapshotText: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("E", "save snapshot as a text dump"),
 	),
 	Sort: key.NewBinding(
 		key.WithKeys("s"),
 		key.WithHelp("s", "sort"),
 	),
+	CreatedBy: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "created by view"),
+	),
+	Packages: key.NewBinding(
+		key.WithKeys("P"),
+		key.WithHelp("P", "package view"),
+	),
+	PackageFrame: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "toggle top/app frame"),
+	),
+	Labels: key.NewBinding(
+		key.WithKeys("#"),
+		key.WithHelp("#", "goroutines by pprof label"),
+	),
+	LabelKey: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "next label in the labels view"),
+	),
+	Leaks: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("L", "leaks view"),
+	),
+	Fleet: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "toggle all hosts"),
+	),
+	Baseline: key.NewBinding(
+		key.WithKeys("b"),
+		key.WithHelp("b", "toggle since-start diff"),
+	),
+	Collapse: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "collapse library frames in details"),
+	),
+	AddTarget: key.NewBinding(
+		key.WithKeys("+"),
+		key.WithHelp("+", "add target"),
+	),
+	RemoveTarget: key.NewBinding(
+		key.WithKeys("-"),
+		key.WithHelp("-", "remove selected host"),
+	),
+	PinBaseline: key.NewBinding(
+		key.WithKeys("B"),
+		key.WithHelp("B", "pin/unpin diff baseline"),
+	),
 	Refresh: key.NewBinding(
 		key.WithKeys("r"),
 		key.WithHelp("r", "refresh"),
 	),
+	RefreshHost: key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("R", "refresh selected host"),
+	),
+	Copy: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "copy stack trace"),
+	),
+	Top: key.NewBinding(
+		key.WithKeys("home", "g"),
+		key.WithHelp("g/home", "go to top"),
+	),
+	Bottom: key.NewBinding(
+		key.WithKeys("end", "G"),
+		key.WithHelp("G/end", "go to bottom"),
+	),
+	Tree: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "toggle package tree"),
+	),
+	CreatorTree: key.NewBinding(
+		key.WithKeys("V"),
+		key.WithHelp("V", "toggle creator tree"),
+	),
+	ReplayNext: key.NewBinding(
+		key.WithKeys("]"),
+		key.WithHelp("]", "next replay frame"),
+	),
+	ReplayPrev: key.NewBinding(
+		key.WithKeys("["),
+		key.WithHelp("[", "previous replay frame"),
+	),
+	ReplayJump: key.NewBinding(
+		key.WithKeys("J"),
+		key.WithHelp("J", "jump to a time in the replay"),
+	),
+	Help: key.NewBinding(
+		key.WithKeys("?"),
+		key.WithHelp("?", "toggle help"),
+	),
 	Quit: key.NewBinding(
 		key.WithKeys("q", "ctrl+c"),
 		key.WithHelp("q", "quit"),
@@ -1,19 +1,30 @@
 package tui
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/atotto/clipboard"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
-	"github.com/anyproto/goru/internal/store"
+	"github.com/anyproto/goru/internal/analysis"
+	"github.com/anyproto/goru/internal/config"
 	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
 )
 
 func TestModelInit(t *testing.T) {
 	s := store.New()
-	m := New(s)
+	m := New(s, nil, 0)
 
 	// Init should return commands
 	cmd := m.Init()
@@ -24,7 +35,7 @@ func TestModelInit(t *testing.T) {
 
 func TestModelView(t *testing.T) {
 	s := store.New()
-	m := New(s)
+	m := New(s, nil, 0)
 
 	// View without size should show loading
 	view := m.View()
@@ -61,7 +72,7 @@ func TestModelUpdate(t *testing.T) {
 	}
 	s.UpdateSnapshot(snapshot, nil)
 
-	m := New(s)
+	m := New(s, nil, 0)
 
 	// Test window size message
 	msg := tea.WindowSizeMsg{Width: 100, Height: 30}
@@ -116,7 +127,7 @@ func TestBuildTableRows(t *testing.T) {
 
 	s.UpdateSnapshot(snapshot, changeSet)
 
-	m := New(s)
+	m := New(s, nil, 0)
 	m.selectedHost = "test-host"
 
 	rows := m.buildTableRows()
@@ -126,16 +137,24 @@ func TestBuildTableRows(t *testing.T) {
 	}
 
 	// Check first row (higher count)
-	if rows[0][2] != "main.worker" {
-		t.Errorf("Expected main.worker first, got %s", rows[0][2])
+	if rows[0][1] != "main.worker" {
+		t.Errorf("Expected main.worker first, got %s", rows[0][1])
 	}
 
 	if rows[0][3] != "10" {
 		t.Errorf("Expected count 10, got %s", rows[0][3])
 	}
 
-	if rows[0][4] != "5m" {
-		t.Errorf("Expected wait 5m, got %s", rows[0][4])
+	if rows[0][4] != "new" {
+		t.Errorf("Expected new group delta, got %s", rows[0][4])
+	}
+
+	if rows[1][4] != "+2" {
+		t.Errorf("Expected delta +2, got %s", rows[1][4])
+	}
+
+	if rows[0][5] != "5m" {
+		t.Errorf("Expected wait 5m, got %s", rows[0][5])
 	}
 }
 
@@ -152,7 +171,7 @@ func TestHostNavigation(t *testing.T) {
 		s.UpdateSnapshot(snapshot, nil)
 	}
 
-	m := New(s)
+	m := New(s, nil, 0)
 	m.selectedHost = "host1"
 
 	// Test next host
@@ -161,9 +180,13 @@ func TestHostNavigation(t *testing.T) {
 		t.Errorf("Expected host2, got %s", m.selectedHost)
 	}
 
-	// Test wrap around
+	// Test wrap around through the all-hosts view
 	m.selectedHost = "host3"
 	m.selectNextHost()
+	if m.selectedHost != allHosts {
+		t.Errorf("Expected %s, got %s", allHosts, m.selectedHost)
+	}
+	m.selectNextHost()
 	if m.selectedHost != "host1" {
 		t.Errorf("Expected host1 (wrap), got %s", m.selectedHost)
 	}
@@ -178,7 +201,1856 @@ func TestHostNavigation(t *testing.T) {
 	// Test wrap around backwards
 	m.selectedHost = "host1"
 	m.selectPrevHost()
+	m.selectPrevHost()
 	if m.selectedHost != "host3" {
 		t.Errorf("Expected host3 (wrap), got %s", m.selectedHost)
 	}
 }
+
+func TestHostLabels(t *testing.T) {
+	s := store.New()
+	s.RegisterHosts([]string{"a:1", "b:2", "c:3", "d:4"})
+	s.SetLabels("a:1", map[string]string{"env": "staging"})
+	s.SetLabels("b:2", map[string]string{"env": "prod", "region": "us"})
+	s.SetLabels("d:4", map[string]string{"env": "prod"})
+
+	// Without a host label every label is shown and hosts go by name
+	m := New(s, nil, 0)
+	if got := m.hostTitle("b:2"); got != "b:2 [env=prod region=us]" {
+		t.Errorf("hostTitle() = %q", got)
+	}
+	if got := m.hostTitle("c:3"); got != "c:3" {
+		t.Errorf("hostTitle() = %q for a host without labels", got)
+	}
+	if hosts := m.getSortedHosts(); !slices.Equal(hosts, []string{"a:1", "b:2", "c:3", "d:4"}) {
+		t.Errorf("Hosts = %v", hosts)
+	}
+
+	// With one the hosts are grouped by its value, unlabeled ones last
+	m = New(s, nil, 0, WithHostLabel("env"))
+	if got := m.hostTitle("b:2"); got != "b:2 [prod]" {
+		t.Errorf("hostTitle() = %q", got)
+	}
+	if hosts := m.getSortedHosts(); !slices.Equal(hosts, []string{"b:2", "d:4", "a:1", "c:3"}) {
+		t.Errorf("Hosts = %v, want prod, then staging, then unlabeled", hosts)
+	}
+}
+
+func TestAllHostsView(t *testing.T) {
+	s := store.New()
+	trace := model.StackTrace{{Func: "main.worker"}}
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 7, Trace: trace, WaitDurations: []string{"5 minutes"}},
+		},
+	}, nil)
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host2",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 3, Trace: trace, WaitDurations: []string{"5 minutes"}},
+			"g2": {ID: "g2", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.width = 120
+	m.height = 40
+	m.selectedHost = "host2"
+	m.selectNextHost()
+	if m.selectedHost != allHosts {
+		t.Fatalf("Expected %s after the last host, got %s", allHosts, m.selectedHost)
+	}
+
+	rows := m.buildTableRows()
+	if len(rows) != 2 || rows[0][1] != "main.worker" || rows[0][3] != "10" {
+		t.Fatalf("Expected main.worker summed to 10 first, got %v", rows)
+	}
+	if got := len(m.displayedGroups[0].WaitDurations); got != 2 {
+		t.Errorf("Expected 2 concatenated wait durations, got %d", got)
+	}
+
+	// The details view breaks the merged count down by host
+	m.table.SetRows(rows)
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	view := m.View()
+	if !strings.Contains(view, "host1: 7") || !strings.Contains(view, "host2: 3") {
+		t.Errorf("Expected a per-host breakdown in the details view, got:\n%s", view)
+	}
+}
+
+func TestSinceStartSummary(t *testing.T) {
+	s := store.New()
+
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "test-host",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 2, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+	}, nil)
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "test-host",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 5, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g2": {ID: "g2", State: model.StateBlocked, Count: 1, Trace: model.StackTrace{{Func: "main.handler"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0, WithSinceStart(true))
+	m.selectedHost = "test-host"
+
+	want := "Since start: +4/-0 goroutines | +1/-0 groups | 1 groups changed"
+	if got := m.renderChangeSummary(); got != want {
+		t.Errorf("renderChangeSummary() = %q, want %q", got, want)
+	}
+
+	// Toggling the baseline key switches back to cycle-to-cycle mode
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+	if newModel.(Model).sinceStart {
+		t.Error("Expected since-start mode to be toggled off")
+	}
+}
+
+func TestPinnedBaseline(t *testing.T) {
+	s := store.New()
+	update := func(count int) {
+		s.UpdateSnapshot(&model.Snapshot{
+			Host: "test-host",
+			Groups: map[model.GroupID]*model.Group{
+				"g1": {ID: "g1", State: model.StateWaiting, Count: count, Trace: model.StackTrace{{Func: "main.worker"}}},
+			},
+		}, nil)
+	}
+	update(2)
+	update(5)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "test-host"
+	press := func() {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'B'}})
+		m = newModel.(Model)
+	}
+
+	press()
+	if m.pinnedAt.IsZero() {
+		t.Fatal("Expected the baseline pinned")
+	}
+	if got := m.renderChangeSummary(); !strings.HasSuffix(got, ": no changes") || !strings.HasPrefix(got, "Since pinned ") {
+		t.Errorf("Expected no changes right after pinning, got %q", got)
+	}
+
+	// Later snapshots compare against the pinned one, not the previous one
+	update(6)
+	update(9)
+	changes := m.currentChanges(m.selectedSnapshot())
+	if got := changes.Updated["g1"]; got != 4 {
+		t.Errorf("Delta against the pinned baseline = %d, want 4", got)
+	}
+	if header := m.renderHeader(); !strings.Contains(header, "+4/-0 goroutines") {
+		t.Errorf("Expected the change summary in the header, got:\n%s", header)
+	}
+
+	// A second press unpins, back to cycle-to-cycle deltas
+	press()
+	if !m.pinnedAt.IsZero() || s.GetPinnedBaseline("test-host") != nil {
+		t.Error("Expected the baseline unpinned")
+	}
+	if strings.Contains(m.renderHeader(), "Since pinned") {
+		t.Error("Expected no change summary after unpinning")
+	}
+}
+
+func TestPrimaryFunction(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 3, Trace: model.StackTrace{
+				{Func: "runtime.gopark"},
+				{Func: "runtime.selectgo"},
+				{Func: "main.loop"},
+			}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	if rows := m.buildTableRows(); len(rows) != 1 || rows[0][1] != "main.loop" {
+		t.Errorf("Expected the runtime frames skipped in the Function column, got %v", rows)
+	}
+
+	// Without packages to skip the innermost frame shows
+	m = New(s, nil, 0, WithSkipPackages(nil))
+	m.selectedHost = "host1"
+	if rows := m.buildTableRows(); len(rows) != 1 || rows[0][1] != "runtime.gopark" {
+		t.Errorf("Expected the innermost frame, got %v", rows)
+	}
+}
+
+func TestSortTies(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"a": {ID: "a", State: model.StateWaiting, Count: 2, Trace: model.StackTrace{{Func: "main.zeta", File: "/app/z.go", Line: 1}}},
+			"b": {ID: "b", State: model.StateWaiting, Count: 2, Trace: model.StackTrace{{Func: "main.alpha", File: "/app/a.go", Line: 9}}},
+			"c": {ID: "c", State: model.StateWaiting, Count: 2, Trace: model.StackTrace{{Func: "main.alpha", File: "/app/a.go", Line: 3}}},
+			"d": {ID: "d", State: model.StateWaiting, Count: 5, Trace: model.StackTrace{{Func: "main.omega"}}},
+		},
+	}, nil)
+
+	order := func(m Model) []model.GroupID {
+		m.selectedHost = "host1"
+		m.buildTableRows()
+		var ids []model.GroupID
+		for _, g := range m.displayedGroups {
+			ids = append(ids, g.ID)
+		}
+		return ids
+	}
+
+	// Ties on count go by function, then file and line
+	if got, want := order(New(s, nil, 0)), []model.GroupID{"d", "c", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("Order by trace = %v, want %v", got, want)
+	}
+	if got, want := order(New(s, nil, 0, WithIDTieBreak(true))), []model.GroupID{"d", "a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("Order by ID = %v, want %v", got, want)
+	}
+}
+
+func TestCollapseFrames(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 1, Trace: model.StackTrace{
+				{Func: "runtime.gopark"},
+				{Func: "runtime.netpollblock"},
+				{Func: "internal/poll.(*pollDesc).wait"},
+				{Func: "main.handle"},
+				{Func: "net/http.HandlerFunc.ServeHTTP"},
+				{Func: "net/http.(*conn).serve"},
+				{Func: "github.com/org/app/api.(*Server).route"},
+			}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0, WithAppPrefixes([]string{"main", "github.com/org/app"}))
+	m.selectedHost = "host1"
+	press := func(msg tea.Msg) {
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	press(tea.WindowSizeMsg{Width: 120, Height: 60})
+	m.table.SetRows(m.buildTableRows())
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+
+	if content := m.renderDetailsContent(); !strings.Contains(content, "runtime.netpollblock") {
+		t.Fatalf("Expected the full trace by default, got:\n%s", content)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	content := m.renderDetailsContent()
+	for _, want := range []string{"… 3 library frames …", " 4. main.handle", "… 2 net/http frames …", " 7. github.com/org/app/api.(*Server).route"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected %q in the collapsed trace, got:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "runtime.gopark") {
+		t.Errorf("Expected the runtime frames collapsed, got:\n%s", content)
+	}
+
+	// Toggling back shows every frame again
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if content := m.renderDetailsContent(); !strings.Contains(content, "runtime.gopark") {
+		t.Errorf("Expected the full trace after toggling back, got:\n%s", content)
+	}
+}
+
+func TestDetailsViewScroll(t *testing.T) {
+	trace := make(model.StackTrace, 100)
+	for i := range trace {
+		trace[i] = model.StackFrame{Func: fmt.Sprintf("main.frame%d", i), File: "/app/main.go", Line: i + 1}
+	}
+
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 1, Trace: trace},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	press := func(msg tea.Msg) {
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	press(tea.WindowSizeMsg{Width: 120, Height: 20})
+	m.table.SetRows(m.buildTableRows())
+
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if !m.showDetails || m.details.YOffset != 0 {
+		t.Fatalf("Expected the details view at the top, got showDetails=%v offset=%d", m.showDetails, m.details.YOffset)
+	}
+	if strings.Contains(m.View(), "main.frame99") {
+		t.Error("Expected the bottom frames to be out of view")
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyDown})
+	if m.details.YOffset != 1 {
+		t.Errorf("Expected down to scroll one line, got offset %d", m.details.YOffset)
+	}
+	press(tea.KeyMsg{Type: tea.KeyPgDown})
+	if m.details.YOffset <= 1 {
+		t.Errorf("Expected PgDown to scroll a page, got offset %d", m.details.YOffset)
+	}
+
+	// The viewport follows resizes
+	press(tea.WindowSizeMsg{Width: 120, Height: 300})
+	if m.details.Height != 298 || m.details.YOffset != 0 || !strings.Contains(m.View(), "main.frame99") {
+		t.Errorf("Expected the whole trace in a taller viewport, got height %d offset %d", m.details.Height, m.details.YOffset)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.showDetails {
+		t.Error("Expected Esc to close the details view")
+	}
+}
+
+func TestCopyTrace(t *testing.T) {
+	var copied string
+	var copyErr error
+	writeClipboard = func(text string) error {
+		copied = text
+		return copyErr
+	}
+	defer func() { writeClipboard = clipboard.WriteAll }()
+
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {
+				ID:        "g1",
+				State:     model.StateWaiting,
+				Count:     2,
+				Trace:     model.StackTrace{{Func: "main.worker", File: "/app/worker.go", Line: 25}},
+				CreatedBy: &model.StackFrame{Func: "main.main", File: "/app/main.go", Line: 10},
+			},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	m.width = 120
+	m.height = 40
+	m.table.SetRows(m.buildTableRows())
+
+	// pressCopy runs the command returned for the copy key and feeds its result back
+	pressCopy := func() {
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+		m = newModel.(Model)
+		if cmd == nil {
+			t.Fatal("Expected a copy command")
+		}
+		newModel, _ = m.Update(cmd())
+		m = newModel.(Model)
+	}
+
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	pressCopy()
+
+	want := "2 goroutine(s) [waiting]:\nmain.worker\n\t/app/worker.go:25\ncreated by main.main\n\t/app/main.go:10\n"
+	if copied != want {
+		t.Errorf("Copied %q, want %q", copied, want)
+	}
+	if !strings.Contains(m.View(), "Copied!") {
+		t.Error("Expected a copy confirmation in the footer")
+	}
+
+	// The confirmation is cleared by its own tick only
+	newModel, _ = m.Update(clearCopyStatusMsg{seq: m.copySeq})
+	m = newModel.(Model)
+	if m.copyStatus != "" {
+		t.Errorf("Expected the confirmation to clear, got %q", m.copyStatus)
+	}
+
+	copyErr = errors.New("no clipboard utilities available")
+	pressCopy()
+	if !strings.Contains(m.View(), "Copy failed: no clipboard utilities available") {
+		t.Error("Expected the clipboard error in the footer")
+	}
+	newModel, _ = m.Update(clearCopyStatusMsg{seq: m.copySeq - 1})
+	m = newModel.(Model)
+	if m.copyStatus == "" {
+		t.Error("Expected a stale tick to leave the latest status")
+	}
+}
+
+func TestCreatedByView(t *testing.T) {
+	s := store.New()
+
+	spawner := &model.StackFrame{Func: "main.startWorkers"}
+	server := &model.StackFrame{Func: "net/http.(*Server).Serve"}
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 7, Trace: model.StackTrace{{Func: "main.worker"}}, CreatedBy: spawner},
+			"g2": {ID: "g2", State: model.StateBlocked, Count: 2, Trace: model.StackTrace{{Func: "main.handler"}}, CreatedBy: server},
+		},
+	}, nil)
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host2",
+		Groups: map[model.GroupID]*model.Group{
+			"g3": {ID: "g3", State: model.StateBlocked, Count: 9, Trace: model.StackTrace{{Func: "main.handler"}}, CreatedBy: server},
+		},
+	}, nil)
+
+	m := New(s, nil, 0, WithCreatedByTop(1))
+	m.width = 100
+	m.height = 30
+	m.selectedHost = "host1"
+
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	m = newModel.(Model)
+	if !m.showCreatedBy {
+		t.Fatal("Expected Created By view to be shown")
+	}
+
+	sites, total := m.createdBySites()
+	if len(sites) != 1 || total != 2 {
+		t.Fatalf("Expected 1 of 2 sites, got %d of %d", len(sites), total)
+	}
+	if sites[0].Func != "main.startWorkers" {
+		t.Errorf("Expected main.startWorkers on host1, got %s", sites[0].Func)
+	}
+
+	// Across the fleet the server spawns the most goroutines
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	m = newModel.(Model)
+	sites, _ = m.createdBySites()
+	if sites[0].Func != "net/http.(*Server).Serve" || sites[0].Count != 11 {
+		t.Errorf("Expected net/http.(*Server).Serve with 11, got %s with %d", sites[0].Func, sites[0].Count)
+	}
+
+	// Enter expands the site to list the groups it spawned
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if m.expandedSite != "net/http.(*Server).Serve" {
+		t.Errorf("Expected site to be expanded, got %q", m.expandedSite)
+	}
+	view := m.View()
+	if !strings.Contains(view, "main.handler") || !strings.Contains(view, "Showing top 1 of 2 sites") {
+		t.Errorf("Expected expanded groups and cap note in view, got:\n%s", view)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if newModel.(Model).showCreatedBy {
+		t.Error("Expected Esc to return to the table view")
+	}
+}
+
+func TestPackagesView(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 6, Trace: model.StackTrace{
+				{Func: "runtime.gopark"},
+				{Func: "net/http.(*conn).serve"},
+				{Func: "github.com/org/app/api.handle"},
+			}},
+			"g2": {ID: "g2", State: model.StateBlocked, Count: 2, Trace: model.StackTrace{{Func: "database/sql.(*DB).connectionOpener"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.width = 100
+	m.height = 30
+	m.selectedHost = "host1"
+
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'P'}})
+	m = newModel.(Model)
+	view := m.View()
+	if !strings.Contains(view, "6  net/http") || !strings.Contains(view, "2  database/sql") {
+		t.Errorf("Expected packages by top frame, got:\n%s", view)
+	}
+
+	// Switching to application frames attributes the HTTP goroutines to the app
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	m = newModel.(Model)
+	if view := m.View(); !strings.Contains(view, "6  github.com/org/app/api") {
+		t.Errorf("Expected packages by application frame, got:\n%s", view)
+	}
+}
+
+func TestLabelsView(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 5, Labels: map[string]string{"handler": "/api", "tenant": "acme"}, Trace: model.StackTrace{{Func: "main.handle"}}},
+			"g2": {ID: "g2", State: model.StateWaiting, Count: 3, Labels: map[string]string{"handler": "/api", "tenant": "globex"}, Trace: model.StackTrace{{Func: "main.handle"}}},
+			"g3": {ID: "g3", State: model.StateWaiting, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.width = 100
+	m.height = 30
+	m.selectedHost = "host1"
+
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'#'}})
+	m = newModel.(Model)
+	view := m.View()
+	if !strings.Contains(view, "by handler") || !strings.Contains(view, "8  /api") || !strings.Contains(view, "1  (none)") {
+		t.Errorf("Expected goroutines by handler, got:\n%s", view)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = newModel.(Model)
+	if view := m.View(); !strings.Contains(view, "5  acme") || !strings.Contains(view, "3  globex") {
+		t.Errorf("Expected goroutines by tenant, got:\n%s", view)
+	}
+
+	// Enter filters the table down to the selected value
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if m.showLabels {
+		t.Error("Expected Enter to close the labels view")
+	}
+	if rows := m.buildTableRows(); len(rows) != 1 || rows[0][3] != "3" {
+		t.Errorf("Expected only the globex row, got %v", rows)
+	}
+
+	m.selectedGroup = s.GetSnapshot("host1").Groups["g1"]
+	if details := m.renderDetailsContent(); !strings.Contains(details, "handler=/api tenant=acme") {
+		t.Errorf("Expected the labels in the details, got:\n%s", details)
+	}
+}
+
+func TestLeaksView(t *testing.T) {
+	s := store.New()
+	start := time.Now().Add(-10 * time.Minute)
+	for i := 0; i < 5; i++ {
+		s.UpdateSnapshot(&model.Snapshot{
+			Host:    "host1",
+			TakenAt: start.Add(time.Duration(i) * time.Minute),
+			Groups: map[model.GroupID]*model.Group{
+				"g1": {ID: "g1", State: model.StateWaiting, Count: 10 + 2*i, Trace: model.StackTrace{{Func: "main.leaky"}}},
+				"g2": {ID: "g2", State: model.StateRunning, Count: 3, Trace: model.StackTrace{{Func: "main.steady"}}},
+			},
+		}, nil)
+	}
+
+	m := New(s, nil, 0)
+	m.width = 100
+	m.height = 30
+	m.selectedHost = "host1"
+
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'L'}})
+	m = newModel.(Model)
+	view := m.View()
+	if !strings.Contains(view, "10 → 18") || !strings.Contains(view, "+2.0/min") || !strings.Contains(view, "main.leaky") {
+		t.Errorf("Expected main.leaky as a leak candidate, got:\n%s", view)
+	}
+	if strings.Contains(view, "main.steady") {
+		t.Errorf("Steady group should not be a leak candidate:\n%s", view)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if newModel.(Model).showLeaks {
+		t.Error("Esc should close the leaks view")
+	}
+}
+
+func TestStuckWaits(t *testing.T) {
+	s := store.New()
+	start := time.Now().Add(-10 * time.Minute)
+	for i := 0; i < 3; i++ {
+		s.UpdateSnapshot(&model.Snapshot{
+			Host:    "host1",
+			TakenAt: start.Add(time.Duration(i) * time.Minute),
+			Groups: map[model.GroupID]*model.Group{
+				// New goroutines join behind the one stuck at the front
+				"g1": {ID: "g1", State: model.StateBlocked, Count: i + 1, Trace: model.StackTrace{{Func: "main.wedged"}},
+					WaitDurations: []string{fmt.Sprintf("%d minutes", i+1)}},
+				"g2": {ID: "g2", State: model.StateWaiting, Count: 1, Trace: model.StackTrace{{Func: "main.idle"}},
+					WaitDurations: []string{"5 minutes"}},
+				// One goroutine that never moves
+				"g3": {ID: "g3", State: model.StateBlocked, Count: 1, Trace: model.StackTrace{{Func: "main.locked"}},
+					WaitDurations: []string{fmt.Sprintf("%d minutes", i+10)}},
+			},
+		}, nil)
+	}
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	m.sortBy = "function"
+
+	rows := m.buildTableRows()
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+	if rows[0][1] != "main.idle" || rows[0][5] != "5 mins" {
+		t.Errorf("Steady waits should not be marked, got %v", rows[0])
+	}
+	if rows[1][1] != "main.locked" || rows[1][5] != "≡3 12 mins" {
+		t.Errorf("Frozen groups should be marked with their snapshots, got %v", rows[1])
+	}
+	if rows[2][1] != "main.wedged" || rows[2][5] != "↑ 3 mins" {
+		t.Errorf("Growing waits should be marked, got %v", rows[2])
+	}
+
+	m.selectedGroup = m.displayedGroups[2]
+	details := m.renderDetailsContent()
+	if !strings.Contains(details, "Wait Trend (3 snapshots)") || !strings.Contains(details, "longest: 1 min → 3 mins") || !strings.Contains(details, "Stuck") {
+		t.Errorf("Expected the wait trend in the details, got:\n%s", details)
+	}
+	if strings.Contains(details, "Frozen") {
+		t.Errorf("Expected a group that grew not to be frozen, got:\n%s", details)
+	}
+
+	m.selectedGroup = m.displayedGroups[1]
+	if details := m.renderDetailsContent(); !strings.Contains(details, "Frozen: 1 goroutine(s) unmoved for 3 snapshots") {
+		t.Errorf("Expected the frozen group in the details, got:\n%s", details)
+	}
+}
+
+func TestMultiSelectExport(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "localhost:6060",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 9, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g2": {ID: "g2", State: model.StateBlocked, Count: 5, Trace: model.StackTrace{{Func: "main.handler"}}},
+			"g3": {ID: "g3", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "localhost:6060"
+	m.exportDir = t.TempDir()
+	m.table.SetRows(m.buildTableRows())
+
+	press := func(msg tea.KeyMsg) {
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+
+	// Select the first and third rows; space must not page the table
+	press(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if m.table.Cursor() != 0 {
+		t.Fatalf("Expected cursor to stay on the first row, got %d", m.table.Cursor())
+	}
+	m.table.SetCursor(2)
+	press(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if len(m.selected) != 2 {
+		t.Fatalf("Expected 2 selected groups, got %d", len(m.selected))
+	}
+
+	// Annotate both at once
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if !m.noteMode {
+		t.Fatal("Expected note mode")
+	}
+	for _, r := range "leak" {
+		press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.notes["g1"] != "leak" || m.notes["g3"] != "leak" || m.notes["g2"] != "" {
+		t.Errorf("Unexpected notes: %v", m.notes)
+	}
+
+	path, err := m.exportGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var export groupExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Host != "localhost:6060" || len(export.Groups) != 2 {
+		t.Fatalf("Expected 2 groups from localhost:6060, got %d from %s", len(export.Groups), export.Host)
+	}
+	if export.Groups[0].ID != "g1" || export.Groups[0].Note != "leak" || export.Groups[1].ID != "g3" {
+		t.Errorf("Unexpected exported groups: %+v, %+v", export.Groups[0], export.Groups[1])
+	}
+
+	// A single group's note comes back for editing
+	m.selected = make(map[model.GroupID]bool)
+	m.table.SetCursor(0)
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if got := m.noteInput.Value(); got != "leak" {
+		t.Errorf("Expected the note to edit, got %q", got)
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+
+	// Switching hosts clears the selection
+	press(tea.KeyMsg{Type: tea.KeyRight})
+	if len(m.selected) != 0 {
+		t.Errorf("Expected selection to be cleared, got %d", len(m.selected))
+	}
+}
+
+func TestSnapshotExport(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "localhost:6060",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 2, Trace: model.StackTrace{{Func: "main.worker", File: "/app/worker.go", Line: 25}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "localhost:6060"
+	m.exportDir = t.TempDir()
+
+	press := func(r rune) {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+	}
+
+	press('e')
+	path, ok := strings.CutPrefix(m.statusMsg, "Saved snapshot to ")
+	if !ok || !strings.HasSuffix(path, ".json") {
+		t.Fatalf("Expected the saved JSON path in the status, got %q", m.statusMsg)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot model.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Host != "localhost:6060" || snapshot.TotalGoroutines() != 2 {
+		t.Errorf("Expected 2 goroutines from localhost:6060, got %d from %s", snapshot.TotalGoroutines(), snapshot.Host)
+	}
+
+	press('E')
+	path, ok = strings.CutPrefix(m.statusMsg, "Saved snapshot to ")
+	if !ok || !strings.HasSuffix(path, ".txt") {
+		t.Fatalf("Expected the saved dump path in the status, got %q", m.statusMsg)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "goroutine "); got != 2 {
+		t.Errorf("Expected 2 goroutines in the text dump, got %d:\n%s", got, data)
+	}
+}
+
+func TestPinnedGroups(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 9, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g2": {ID: "g2", State: model.StateBlocked, Count: 1, Trace: model.StackTrace{{Func: "main.leaky"}}},
+		},
+	}, nil)
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host2",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 4, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	m.table.SetRows(m.buildTableRows())
+
+	press := func(msg tea.KeyMsg) {
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+
+	// Pin the smaller group; it moves to the top
+	m.table.SetCursor(1)
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}})
+	rows := m.buildTableRows()
+	if rows[0][1] != "main.leaky" || rows[0][0] != "★ blocked" {
+		t.Errorf("Expected pinned main.leaky first, got %v", rows[0])
+	}
+
+	// On a host without it, the pin shows as an absent zero-count row
+	m.selectedHost = "host2"
+	rows = m.buildTableRows()
+	if len(rows) != 2 || rows[0][0] != "★ absent" || rows[0][3] != "0" {
+		t.Errorf("Expected absent pinned row first, got %v", rows)
+	}
+
+	// ...unless absent pins are hidden
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'z'}})
+	rows = m.buildTableRows()
+	if len(rows) != 1 || rows[0][1] != "main.worker" {
+		t.Errorf("Expected only main.worker, got %v", rows)
+	}
+}
+
+func TestStateFilter(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateRunning, Count: 3, Trace: model.StackTrace{{Func: "main.main"}}},
+			"g2": {ID: "g2", State: model.StateBlocked, Count: 2, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g3": {ID: "g3", State: model.StateBlocked, Count: 1, Trace: model.StackTrace{{Func: "main.handler"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+
+	press := func(r rune) {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+	}
+
+	// all -> running
+	press('S')
+	if rows := m.buildTableRows(); len(rows) != 1 || rows[0][1] != "main.main" {
+		t.Errorf("Expected only running main.main, got %v", rows)
+	}
+
+	// running -> runnable -> blocked, combined with the text filter
+	press('S')
+	press('S')
+	if m.stateFilter != model.StateBlocked {
+		t.Fatalf("stateFilter = %q, want blocked", m.stateFilter)
+	}
+	m.filter = "worker"
+	if rows := m.buildTableRows(); len(rows) != 1 || rows[0][1] != "main.worker" {
+		t.Errorf("Expected only blocked main.worker, got %v", rows)
+	}
+
+	// Clearing resets both filters
+	press('c')
+	if rows := m.buildTableRows(); len(rows) != 3 {
+		t.Errorf("Expected 3 rows after clearing, got %d", len(rows))
+	}
+
+	// The cycle wraps around to all states
+	for range stateFilters {
+		press('S')
+	}
+	if m.stateFilter != "" {
+		t.Errorf("stateFilter = %q after a full cycle, want all", m.stateFilter)
+	}
+
+	// Filters given at startup apply from the first render, and win over
+	// the restored one
+	m = New(s, nil, 0, WithState(State{Filter: "main"}), WithFilter("re:work|hand"), WithStateFilter("blocked"))
+	m.selectedHost = "host1"
+	if rows := m.buildTableRows(); len(rows) != 2 {
+		t.Errorf("Expected the 2 blocked rows, got %v", rows)
+	}
+	m = New(s, nil, 0, WithState(State{Filter: "worker"}), WithFilter(""))
+	m.selectedHost = "host1"
+	if rows := m.buildTableRows(); len(rows) != 1 || rows[0][1] != "main.worker" {
+		t.Errorf("Expected the restored filter to stay, got %v", rows)
+	}
+}
+
+func TestRegexFilter(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 3, Trace: model.StackTrace{{Func: "github.com/foo/bar.Serve"}}},
+			"g2": {ID: "g2", State: model.StateWaiting, Count: 2, Trace: model.StackTrace{{Func: "github.com/foo/baz.Run"}}},
+			"g3": {ID: "g3", State: model.StateWaiting, Count: 1, Trace: model.StackTrace{{Func: "github.com/foo/qux.(*Server).Run"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	m.width = 120
+	m.height = 40
+
+	m.setFilter(`re:^github\.com/foo/(bar|baz)\.`)
+	if m.filterErr != nil {
+		t.Fatalf("Unexpected compile error: %v", m.filterErr)
+	}
+	if rows := m.buildTableRows(); len(rows) != 2 {
+		t.Errorf("Expected bar and baz rows, got %v", rows)
+	}
+
+	// An invalid pattern falls back to substring matching
+	m.setFilter("re:(*Server")
+	if m.filterErr == nil {
+		t.Fatal("Expected a compile error")
+	}
+	if rows := m.buildTableRows(); len(rows) != 1 || rows[0][1] != "github.com/foo/qux.(*Server).Run" {
+		t.Errorf("Expected only the qux row, got %v", rows)
+	}
+	if !strings.Contains(m.View(), "matching as text") {
+		t.Error("Expected the compile error in the filter line")
+	}
+}
+
+func TestBlockReason(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 3, BlockReason: "sync.Mutex.Lock", Trace: model.StackTrace{{Func: "main.update"}}},
+			"g2": {ID: "g2", State: model.StateBlocked, Count: 2, BlockReason: "chan receive", Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g3": {ID: "g3", State: model.StateWaiting, Count: 1, BlockReason: "IO wait", Trace: model.StackTrace{{Func: "main.serve"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+
+	// The filter matches block reasons as well as stacks
+	m.setFilter("mutex")
+	if rows := m.buildTableRows(); len(rows) != 1 || rows[0][1] != "main.update" {
+		t.Errorf("Expected only the mutex row, got %v", rows)
+	}
+	m.setFilter(`re:^(chan|IO) `)
+	if rows := m.buildTableRows(); len(rows) != 2 {
+		t.Errorf("Expected the channel and IO rows, got %v", rows)
+	}
+
+	m.selectedGroup = s.GetSnapshot("host1").Groups["g1"]
+	if details := m.renderDetailsContent(); !strings.Contains(details, "sync.Mutex.Lock") {
+		t.Errorf("Expected the block reason in the details, got:\n%s", details)
+	}
+}
+
+func TestParseWarnings(t *testing.T) {
+	s := store.New()
+	for _, host := range []string{"host1", "host2"} {
+		s.UpdateSnapshot(&model.Snapshot{
+			Host:     host,
+			Groups:   map[model.GroupID]*model.Group{"g1": {ID: "g1", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}}},
+			Warnings: []string{"final goroutine 2 cut off before its stack and left out"},
+		}, nil)
+	}
+	s.UpdateSnapshot(&model.Snapshot{
+		Host:   "host2",
+		Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}}},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	if header := m.renderHeader(); !strings.Contains(header, "Incomplete parse: final goroutine 2 cut off") {
+		t.Errorf("Expected the host's parse warning, got:\n%s", header)
+	}
+
+	// Other hosts' warnings are counted
+	m.selectedHost = "host2"
+	if header := m.renderHeader(); !strings.Contains(header, "1 with parse warnings") {
+		t.Errorf("Expected a count of hosts with warnings, got:\n%s", header)
+	}
+}
+
+func TestRemovedHost(t *testing.T) {
+	s := store.New()
+	for _, host := range []string{"host1", "host2"} {
+		s.UpdateSnapshot(&model.Snapshot{
+			Host:   host,
+			Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}}},
+		}, nil)
+	}
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host2"
+	m.selectedGroup = s.GetSnapshot("host2").Groups["g1"]
+	m.showDetails = true
+
+	s.RemoveHost("host2")
+	newModel, _ := m.Update(store.Update{Host: "host2", Removed: true})
+	m = newModel.(Model)
+	if m.showDetails || m.selectedGroup != nil {
+		t.Error("Expected the removed host's details to close")
+	}
+	if rows := m.buildTableRows(); m.selectedHost != "host1" || len(rows) != 1 {
+		t.Errorf("Expected to fall back to host1, got %q with %v", m.selectedHost, rows)
+	}
+
+	// Clearing the store leaves nothing to show
+	s.Clear()
+	newModel, _ = m.Update(store.Update{Removed: true})
+	m = newModel.(Model)
+	if rows := m.buildTableRows(); m.selectedHost != "" || len(rows) != 0 {
+		t.Errorf("Expected no host after clearing, got %q with %v", m.selectedHost, rows)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		input    uint64
+		expected string
+	}{
+		{512, "512 B"},
+		{1536, "1.5 KiB"},
+		{12 * 1024 * 1024, "12.0 MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := formatBytes(tt.input); got != tt.expected {
+				t.Errorf("formatBytes(%d) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []int
+		width  int
+		want   string
+	}{
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8}, 8, "▁▂▃▄▅▆▇█"},
+		{[]int{100, 200, 100}, 8, "▁█▁"},
+		{[]int{5, 5, 5}, 8, "▁▁▁"},
+		// Only the latest values that fit are drawn
+		{[]int{1000, 0, 10, 20}, 3, "▁▄█"},
+		{nil, 8, ""},
+	}
+	for _, tt := range tests {
+		if got := sparkline(tt.values, tt.width); got != tt.want {
+			t.Errorf("sparkline(%v, %d) = %q, want %q", tt.values, tt.width, got, tt.want)
+		}
+	}
+
+	s := store.New()
+	trace := model.StackTrace{{Func: "main.worker"}}
+	for _, count := range []int{10, 20, 40} {
+		s.UpdateSnapshot(&model.Snapshot{
+			Host:   "host1",
+			Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", State: model.StateWaiting, Count: count, Trace: trace}},
+		}, nil)
+	}
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	m.width = 200
+	if header := m.renderHeader(); !strings.Contains(header, "▁▃█") {
+		t.Errorf("Expected the host's sparkline in the header, got:\n%s", header)
+	}
+	// No room, no sparkline
+	m.width = 40
+	if header := m.renderHeader(); strings.ContainsAny(header, string(sparkBars)) {
+		t.Errorf("Expected no sparkline in a narrow terminal, got:\n%s", header)
+	}
+}
+
+func TestFormatStateCounts(t *testing.T) {
+	counts := map[model.GoroutineState]int{
+		model.StateWaiting: 340,
+		model.StateRunning: 12,
+		model.StateBlocked: 5,
+		model.StateSyscall: 0,
+	}
+	if got, want := formatStateCounts(counts), "running:12 blocked:5 waiting:340"; got != want {
+		t.Errorf("formatStateCounts() = %q, want %q", got, want)
+	}
+	if got := formatStateCounts(nil); got != "" {
+		t.Errorf("formatStateCounts(nil) = %q, want empty", got)
+	}
+}
+
+func TestFormatGoroutineIDs(t *testing.T) {
+	tests := []struct {
+		ids      []uint64
+		expected string
+	}{
+		{[]uint64{7}, "7"},
+		{[]uint64{1, 2, 3}, "1, 2, 3"},
+		{[]uint64{1, 2, 3, 4, 5}, "1, 2, 3, +2 more"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := formatGoroutineIDs(tt.ids, 3); got != tt.expected {
+				t.Errorf("formatGoroutineIDs(%v) = %q, want %q", tt.ids, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFramesColumn(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 9, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g2": {ID: "g2", State: model.StateRunning, Count: 1, Trace: model.StackTrace{
+				{Func: "main.walk"}, {Func: "main.walk"}, {Func: "main.walk"},
+			}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0, WithFramesColumn(true))
+	m.selectedHost = "host1"
+
+	rows := m.buildTableRows()
+	if len(rows[0]) != 7 || rows[0][6] != "1" {
+		t.Errorf("Expected frame count 1 in the last column, got %v", rows[0])
+	}
+
+	// Sorting cycles through frames after wait, deepest stacks first
+	for range 4 {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+		m = newModel.(Model)
+	}
+	if m.sortBy != "frames" {
+		t.Fatalf("Expected frames sort, got %s", m.sortBy)
+	}
+	rows = m.buildTableRows()
+	if rows[0][1] != "main.walk" || rows[0][6] != "3" {
+		t.Errorf("Expected deepest stack first, got %v", rows[0])
+	}
+
+	// Without the column the cycle skips frames
+	m = New(s, nil, 0)
+	for range 4 {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+		m = newModel.(Model)
+	}
+	if m.sortBy != "count" {
+		t.Errorf("Expected count sort, got %s", m.sortBy)
+	}
+}
+
+func TestMouseTable(t *testing.T) {
+	s := store.New()
+	groups := make(map[model.GroupID]*model.Group)
+	for i := range 30 {
+		id := model.GroupID(fmt.Sprintf("g%02d", i))
+		groups[id] = &model.Group{ID: id, State: model.StateWaiting, Count: 100 - i, Trace: model.StackTrace{
+			{Func: fmt.Sprintf("main.worker%02d", i)},
+		}}
+	}
+	s.UpdateSnapshot(&model.Snapshot{Host: "host1", Groups: groups}, nil)
+	s.UpdateSnapshot(&model.Snapshot{Host: "host2", Groups: map[model.GroupID]*model.Group{}}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	press := func(msg tea.Msg) {
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	click := func(y int) {
+		press(tea.MouseMsg{X: 5, Y: y, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress})
+	}
+	press(tea.WindowSizeMsg{Width: 200, Height: 20})
+	m.table.SetRows(m.buildTableRows())
+
+	// lineOf finds the screen line showing a function
+	lineOf := func(fn string) int {
+		for i, l := range strings.Split(m.View(), "\n") {
+			if strings.Contains(l, fn) {
+				return i
+			}
+		}
+		t.Fatalf("Expected %s on screen", fn)
+		return -1
+	}
+
+	click(lineOf("main.worker03"))
+	if m.table.Cursor() != 3 || m.showDetails {
+		t.Fatalf("Expected a click to select row 3, got cursor %d details %v", m.table.Cursor(), m.showDetails)
+	}
+	click(lineOf("main.worker03"))
+	if !m.showDetails || m.selectedGroup.ID != "g03" {
+		t.Fatalf("Expected a second click to open the details of g03, got %v", m.selectedGroup)
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+
+	// Clicks land on the right row once the table has scrolled
+	for range 20 {
+		press(tea.MouseMsg{Button: tea.MouseButtonWheelDown, Action: tea.MouseActionPress})
+	}
+	if m.table.Cursor() != 23 {
+		t.Fatalf("Expected the wheel to move the cursor to 23, got %d", m.table.Cursor())
+	}
+	click(lineOf("main.worker20"))
+	if m.table.Cursor() != 20 {
+		t.Errorf("Expected a click to select row 20 in the scrolled table, got %d", m.table.Cursor())
+	}
+	line := lineOf("main.worker20")
+	press(tea.MouseMsg{Button: tea.MouseButtonWheelUp, Action: tea.MouseActionPress})
+	if m.table.Cursor() != 19 {
+		t.Errorf("Expected the wheel to move the cursor up to 19, got %d", m.table.Cursor())
+	}
+	if got := lineOf("main.worker20"); got != line {
+		t.Errorf("Expected the table to stay put while the cursor is in view, row 20 moved from line %d to %d", line, got)
+	}
+
+	// Clicks outside the rows don't move the cursor
+	click(lineOf("Function") - 1)
+	if m.table.Cursor() != 19 || m.showDetails {
+		t.Errorf("Expected a click above the table to be ignored, got cursor %d", m.table.Cursor())
+	}
+
+	// Clicking the host indicator moves to the next host
+	click(headerHostLine)
+	if m.selectedHost != "host2" {
+		t.Errorf("Expected a click on the host to select host2, got %s", m.selectedHost)
+	}
+}
+
+func TestKeyBindings(t *testing.T) {
+	// Every action the config accepts has a binding
+	actions := defaultKeys.actions()
+	if len(actions) != len(config.KeyActions) {
+		t.Errorf("Expected %d actions, got %d", len(config.KeyActions), len(actions))
+	}
+	for _, name := range config.KeyActions {
+		if _, ok := actions[name]; !ok {
+			t.Errorf("Expected a binding for action %s", name)
+		}
+	}
+
+	s := store.New()
+	groups := make(map[model.GroupID]*model.Group)
+	for i := range 5 {
+		id := model.GroupID(fmt.Sprintf("g%d", i))
+		groups[id] = &model.Group{ID: id, State: model.StateWaiting, Count: 10 - i, Trace: model.StackTrace{{Func: "main.worker"}}}
+	}
+	s.UpdateSnapshot(&model.Snapshot{Host: "host1", Groups: groups}, nil)
+
+	m := New(s, nil, 0, WithKeyBindings(map[string][]string{
+		"filter": {"/"},
+		"up":     {"up"},
+		"down":   {"down"},
+		"bottom": {"G"},
+	}))
+	m.selectedHost = "host1"
+	press := func(r rune) {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+	}
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 200, Height: 40})
+	m = newModel.(Model)
+	m.table.SetRows(m.buildTableRows())
+
+	// j no longer moves down, the remapped bottom key still works
+	press('j')
+	if m.table.Cursor() != 0 {
+		t.Errorf("Expected j to be unbound, got cursor %d", m.table.Cursor())
+	}
+	press('G')
+	if m.table.Cursor() != 4 {
+		t.Errorf("Expected G to go to the last row, got cursor %d", m.table.Cursor())
+	}
+
+	// f no longer filters but / does
+	press('f')
+	if m.filterMode {
+		t.Error("Expected f to be unbound")
+	}
+	press('/')
+	if !m.filterMode {
+		t.Error("Expected / to start filtering")
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+
+	// The footer shows the active bindings, unchanged ones keep their keys
+	footer := m.renderFooter()
+	for _, want := range []string{"/: Filter", "↑/↓: Navigate", "s: Sort"} {
+		if !strings.Contains(footer, want) {
+			t.Errorf("Expected %q in the footer, got %s", want, footer)
+		}
+	}
+	if strings.Contains(footer, "f: Filter") {
+		t.Errorf("Expected the default filter key to be gone from the footer, got %s", footer)
+	}
+	if got := m.keys.Filter.Help(); got.Key != "/" || got.Desc != "filter" {
+		t.Errorf("Expected help / filter, got %+v", got)
+	}
+}
+
+func TestHelpOverlay(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 1, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0, WithKeyBindings(map[string][]string{"filter": {"/"}}))
+	m.selectedHost = "host1"
+	press := func(msg tea.Msg) {
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	press(tea.WindowSizeMsg{Width: 120, Height: 200})
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	if !m.showHelp {
+		t.Fatal("Expected ? to open the help overlay")
+	}
+	view := m.View()
+	for _, want := range []string{"filter", "toggle since-start diff", "blocked", "Details view"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the help overlay", want)
+		}
+	}
+	// Bindings show their active keys
+	if !strings.Contains(view, "/             filter") {
+		t.Errorf("Expected the remapped filter key in the help overlay, got:\n%s", view)
+	}
+
+	// Keys don't reach the table while the overlay is open
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'/'}})
+	if m.filterMode || !m.showHelp {
+		t.Error("Expected keys other than Esc and ? to stay in the help overlay")
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	if m.showHelp {
+		t.Error("Expected ? to close the help overlay")
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.showHelp {
+		t.Error("Expected Esc to close the help overlay")
+	}
+}
+
+func TestStatePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goru", "state.json")
+
+	// A missing file gives the defaults
+	st, err := LoadState(path)
+	if err != nil || !reflect.DeepEqual(st, State{}) {
+		t.Fatalf("Expected the zero state for a missing file, got %+v, %v", st, err)
+	}
+
+	want := State{SortBy: "wait", Filter: "re:worker", Host: "host2", Notes: map[model.GroupID]string{"g1": "websocket reader leak"}}
+	if err := SaveState(path, want); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	if st, err = LoadState(path); err != nil || !reflect.DeepEqual(st, want) {
+		t.Fatalf("Expected %+v, got %+v, %v", want, st, err)
+	}
+
+	// The restored host is selected once it registers
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{Host: "host1", Groups: map[model.GroupID]*model.Group{}}, nil)
+	m := New(s, nil, 0, WithState(st))
+	if m.sortBy != "wait" || m.filter != "re:worker" || m.filterRe == nil {
+		t.Errorf("Expected the sort mode and filter restored, got %s %q", m.sortBy, m.filter)
+	}
+	if m.notes["g1"] != "websocket reader leak" {
+		t.Errorf("Expected the note restored, got %v", m.notes)
+	}
+	if m.selectedHost != "host1" {
+		t.Errorf("Expected host1 until host2 shows up, got %s", m.selectedHost)
+	}
+	if got := m.State(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the restored host to be kept while it's missing, got %+v", got)
+	}
+	s.UpdateSnapshot(&model.Snapshot{Host: "host2", Groups: map[model.GroupID]*model.Group{}}, nil)
+	m.buildTableRows()
+	if m.selectedHost != "host2" {
+		t.Errorf("Expected host2 once it shows up, got %s", m.selectedHost)
+	}
+
+	// Unknown sort modes fall back to the default
+	if m := New(s, nil, 0, WithState(State{SortBy: "frames"})); m.sortBy != "count" {
+		t.Errorf("Expected count without the frames column, got %s", m.sortBy)
+	}
+
+	// A corrupt file gives the defaults and an error
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if st, err = LoadState(path); err == nil || !reflect.DeepEqual(st, State{}) {
+		t.Errorf("Expected an error and the zero state for a corrupt file, got %+v, %v", st, err)
+	}
+}
+
+func TestStateColors(t *testing.T) {
+	m := New(store.New(), nil, 0, WithStateColors(map[string]string{"running": "33", "syscall": ""}))
+	if m.stateColors[model.StateRunning] != "33" || m.stateColors[model.StateSyscall] != "" {
+		t.Errorf("Expected the overrides in the palette, got %v", m.stateColors)
+	}
+	if m.stateColors[model.StateBlocked] != defaultStateColors[model.StateBlocked] {
+		t.Errorf("Expected the default color for blocked, got %q", m.stateColors[model.StateBlocked])
+	}
+	if defaultStateColors[model.StateRunning] != "42" {
+		t.Error("Expected the option to leave the defaults alone")
+	}
+
+	// States are colored in the State column by their rows, markers and all,
+	// and the column makes room for the escape codes
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateRunning, Count: 2, Trace: model.StackTrace{{Func: "main.run"}}},
+			"g2": {ID: "g2", State: model.StateSyscall, Count: 1, Trace: model.StackTrace{{Func: "main.read"}}},
+		},
+	}, nil)
+	m = New(s, nil, 0, WithStateColors(map[string]string{"running": "33", "syscall": ""}))
+	m.selected["g1"] = true
+	rows := m.buildTableRows()
+	if want := lipgloss.NewStyle().Foreground(lipgloss.Color("33")).Render("● running"); len(rows) != 2 || rows[0][0] != want {
+		t.Errorf("Expected the state cell %q, got %v", want, rows)
+	}
+	if len(rows) == 2 && rows[1][0] != "syscall" {
+		t.Errorf("Expected the syscall cell plain, got %q", rows[1][0])
+	}
+	if got := m.tableColumns()[0].Width; got < stateWidth {
+		t.Errorf("State column width = %d, want at least %d", got, stateWidth)
+	}
+	if got := m.formatState("absent", "absent"); got != "absent" {
+		t.Errorf("formatState(absent) = %q, want it plain", got)
+	}
+
+	// The selected row drops the state colors under its highlight
+	selected := m.tableStyles().Selected.Render("\x1b[38;5;42mrunning\x1b[0m main.worker")
+	if strings.Contains(selected, "38;5;42") {
+		t.Errorf("Expected the state color stripped from the selected row, got %q", selected)
+	}
+}
+
+func TestCountThresholds(t *testing.T) {
+	m := New(store.New(), nil, 0, WithCountThresholds(1000, 5000))
+	tests := []struct {
+		count int
+		color lipgloss.Color
+	}{
+		{10, ""},
+		{1000, ""},
+		{1001, countWarnColor},
+		{5000, countWarnColor},
+		{5001, countCritColor},
+	}
+	for _, tt := range tests {
+		if got := m.countColor(tt.count); got != tt.color {
+			t.Errorf("countColor(%d) = %q, want %q", tt.count, got, tt.color)
+		}
+	}
+
+	// Only the warn threshold set
+	m = New(store.New(), nil, 0, WithCountThresholds(1000, 0))
+	if got := m.countColor(100000); got != countWarnColor {
+		t.Errorf("Expected the warn color without a crit threshold, got %q", got)
+	}
+
+	// Counts are colored in the Count column by their rows, which leaves the
+	// Frames column plain however large, and the column makes room for the
+	// escape codes
+	s := store.New()
+	frames := make(model.StackTrace, 2000)
+	for i := range frames {
+		frames[i] = model.StackFrame{Func: "main.recurse"}
+	}
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 6000, Trace: frames},
+		},
+	}, nil)
+	m = New(s, nil, 0, WithCountThresholds(1000, 5000), WithFramesColumn(true))
+	rows := m.buildTableRows()
+	if want := lipgloss.NewStyle().Foreground(countCritColor).Render("6000"); len(rows) != 1 || rows[0][3] != want {
+		t.Errorf("Expected the count cell %q, got %v", want, rows)
+	}
+	if rows[0][6] != "2000" {
+		t.Errorf("Expected the frames cell plain, got %q", rows[0][6])
+	}
+	if got := m.tableColumns()[3].Width; got != countWidth+countColorWidth {
+		t.Errorf("Count column width = %d, want %d", got, countWidth+countColorWidth)
+	}
+
+	// Off by default
+	m = New(store.New(), nil, 0)
+	if got := m.countColor(100000); got != "" {
+		t.Errorf("Expected no color without thresholds, got %q", got)
+	}
+	if got := m.formatCount(100000); got != "100000" {
+		t.Errorf("formatCount(100000) = %q, want it plain", got)
+	}
+	if got := m.tableColumns()[3].Width; got != countWidth {
+		t.Errorf("Count column width = %d, want %d", got, countWidth)
+	}
+}
+
+func TestTreeView(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 10, Trace: model.StackTrace{{Func: "net/http.(*conn).serve"}}},
+			"g2": {ID: "g2", State: model.StateWaiting, Count: 5, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g3": {ID: "g3", State: model.StateRunning, Count: 2, Trace: model.StackTrace{{Func: "net/http.(*Server).Serve"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	press := func(msg tea.Msg) {
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+		m.table.SetRows(m.buildTableRows())
+	}
+	press(tea.WindowSizeMsg{Width: 200, Height: 40})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+
+	// Packages start collapsed, in the order of their biggest group
+	rows := m.table.Rows()
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 package rows, got %v", rows)
+	}
+	if rows[0][0] != "▸ 2 groups" || rows[0][1] != "net/http" || rows[0][3] != "12" {
+		t.Errorf("Expected net/http with 2 groups and 12 goroutines, got %v", rows[0])
+	}
+	if rows[1][0] != "▸ 1 group" || rows[1][1] != "main" || rows[1][3] != "5" {
+		t.Errorf("Expected main with 1 group and 5 goroutines, got %v", rows[1])
+	}
+	if m.shownGroups != 3 {
+		t.Errorf("Expected collapsed groups to still be counted, got %d", m.shownGroups)
+	}
+
+	// Package rows can't be selected
+	press(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if len(m.selected) != 0 {
+		t.Errorf("Expected nothing selected on a package row, got %v", m.selected)
+	}
+
+	// Enter expands a package, revealing its groups indented
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	rows = m.table.Rows()
+	if len(rows) != 4 || rows[0][0] != "▾ 2 groups" || rows[1][1] != "  net/http.(*conn).serve" || rows[2][1] != "  net/http.(*Server).Serve" {
+		t.Fatalf("Expected net/http expanded, got %v", rows)
+	}
+
+	// Enter on a group opens its details
+	m.table.SetCursor(2)
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if !m.showDetails || m.selectedGroup.ID != "g3" {
+		t.Fatalf("Expected the details of g3, got %v", m.selectedGroup)
+	}
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+
+	// ← collapses the package and moves to its row, → expands it again
+	press(tea.KeyMsg{Type: tea.KeyLeft})
+	if len(m.table.Rows()) != 2 || m.table.Cursor() != 0 || m.selectedHost != "host1" {
+		t.Errorf("Expected net/http collapsed with the cursor on it, got %d rows, cursor %d", len(m.table.Rows()), m.table.Cursor())
+	}
+	press(tea.KeyMsg{Type: tea.KeyRight})
+	if len(m.table.Rows()) != 4 || m.selectedHost != "host1" {
+		t.Errorf("Expected → to expand net/http, got %d rows", len(m.table.Rows()))
+	}
+
+	// The flat view is back with the same key
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'v'}})
+	if rows := m.table.Rows(); len(rows) != 3 || rows[0][1] != "net/http.(*conn).serve" {
+		t.Errorf("Expected the flat view, got %v", rows)
+	}
+}
+
+func TestCreatorTree(t *testing.T) {
+	s := store.New()
+	spawner := &model.StackFrame{Func: "main.(*Server).accept"}
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 8, Trace: model.StackTrace{{Func: "main.reader"}}, CreatedBy: spawner},
+			"g2": {ID: "g2", State: model.StateWaiting, Count: 6, Trace: model.StackTrace{{Func: "main.writer"}}, CreatedBy: spawner},
+			"g3": {ID: "g3", State: model.StateRunning, Count: 10, Trace: model.StackTrace{{Func: "main.poll"}}, CreatedBy: &model.StackFrame{Func: "main.start"}},
+			"g4": {ID: "g4", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	press := func(r rune) {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+		m.table.SetRows(m.buildTableRows())
+	}
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 200, Height: 40})
+	m = newModel.(Model)
+	press('V')
+
+	// Creators are summed across their groups, the busiest first, though
+	// main.start has the biggest group
+	rows := m.table.Rows()
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 creator rows, got %v", rows)
+	}
+	if rows[0][0] != "▸ 2 groups" || rows[0][1] != "main.(*Server).accept" || rows[0][3] != "14" {
+		t.Errorf("Expected main.(*Server).accept with 2 groups and 14 goroutines, got %v", rows[0])
+	}
+	if rows[1][1] != "main.start" || rows[1][3] != "10" {
+		t.Errorf("Expected main.start with 10 goroutines, got %v", rows[1])
+	}
+	if rows[2][1] != noCreator || rows[2][3] != "1" {
+		t.Errorf("Expected main's goroutine under %s, got %v", noCreator, rows[2])
+	}
+
+	m.expandedPackages["main.(*Server).accept"] = true
+	m.table.SetRows(m.buildTableRows())
+	if rows := m.table.Rows(); len(rows) != 5 || rows[1][1] != "  main.reader" || rows[2][1] != "  main.writer" {
+		t.Fatalf("Expected the accept loop's groups expanded, got %v", rows)
+	}
+
+	// v switches to the package tree, collapsing everything
+	press('v')
+	if rows := m.table.Rows(); len(rows) != 1 || rows[0][1] != "main" || len(m.expandedPackages) != 0 {
+		t.Errorf("Expected a collapsed package tree, got %v", rows)
+	}
+	press('v')
+	if rows := m.table.Rows(); len(rows) != 4 {
+		t.Errorf("Expected the flat view, got %v", rows)
+	}
+}
+
+func TestEmptyTrace(t *testing.T) {
+	// Feeds and hand-written dumps can carry groups without frames
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 3},
+			"g2": {ID: "g2", State: model.StateWaiting, Count: 2, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+	}, nil)
+
+	m := New(s, nil, 0)
+	m.selectedHost = "host1"
+	m.width, m.height = 200, 40
+	for _, by := range analysis.SortOrders {
+		m.sortBy = by
+		rows := m.buildTableRows()
+		if len(rows) != 2 {
+			t.Fatalf("Sorting by %s: expected 2 rows, got %v", by, rows)
+		}
+	}
+
+	m.sortBy = analysis.SortCount
+	if rows := m.buildTableRows(); rows[0][1] != model.NoStack {
+		t.Errorf("Expected %q for the empty trace, got %v", model.NoStack, rows[0])
+	}
+	m.treeView = true
+	if rows := m.buildTableRows(); len(rows) != 2 || rows[0][1] != model.NoStack {
+		t.Errorf("Expected the empty trace under its own tree row, got %v", rows)
+	}
+
+	m.openDetails(s.GetSnapshot("host1").Groups["g1"])
+	if content := m.renderDetailsContent(); !strings.Contains(content, model.NoStack) {
+		t.Errorf("Expected the details to say %q, got %s", model.NoStack, content)
+	}
+}
+
+func TestMissedUpdates(t *testing.T) {
+	s := store.New()
+	m := New(s, nil, 0)
+	m.width = 200
+	if strings.Contains(m.renderHeader(), "Missed updates") {
+		t.Error("Expected no missed updates before any were dropped")
+	}
+
+	// Nothing reads the model's channel, so it fills up
+	for i := range cap(m.updates) + 3 {
+		s.UpdateSnapshot(&model.Snapshot{Host: fmt.Sprintf("host%d", i)}, nil)
+	}
+	if header := m.renderHeader(); !strings.Contains(header, "Missed updates: 3") {
+		t.Errorf("Expected 3 missed updates in the header, got %s", header)
+	}
+}
+
+func TestFormatWaitRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		waits    []string
+		expected string
+	}{
+		{"single", []string{"5 minutes", "5 minutes"}, "5 mins"},
+		{"minutes", []string{"3 minutes", "12 minutes"}, "3-12min"},
+		{"hours", []string{"2 hours"}, "2h"},
+		{"seconds", []string{"90 seconds"}, "90s"},
+		{"mixed", []string{"90 seconds", "2 hours", "5 minutes"}, "90s-2h"},
+		{"seconds and minutes", []string{"90 seconds", "5 minutes"}, "90s-5 mins"},
+		{"hours and minutes", []string{"30 minutes", "90 minutes"}, "30 mins-1h30m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &model.Group{WaitDurations: tt.waits}
+			if got := formatWaitRange(g); got != tt.expected {
+				t.Errorf("formatWaitRange(%v) = %q, want %q", tt.waits, got, tt.expected)
+			}
+		})
+	}
+}
+
+// fakeReplayer records what the replay keys ask for
+type fakeReplayer struct {
+	frame   int
+	total   int
+	takenAt time.Time
+	playing bool
+	seek    time.Time
+}
+
+func (r *fakeReplayer) Step(n int) {
+	r.playing = false
+	r.frame = min(max(r.frame+n, 1), r.total)
+}
+func (r *fakeReplayer) SetPlaying(playing bool) { r.playing = playing }
+func (r *fakeReplayer) Seek(t time.Time)        { r.seek = t }
+func (r *fakeReplayer) Position() (int, int, time.Time, bool) {
+	return r.frame, r.total, r.takenAt, r.playing
+}
+
+func TestReplayKeys(t *testing.T) {
+	r := &fakeReplayer{frame: 1, total: 5, takenAt: time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC), playing: true}
+	m := New(store.New(), nil, 0, WithReplay(r))
+	newModel, _ := m.Update(tea.WindowSizeMsg{Width: 200, Height: 40})
+	m = newModel.(Model)
+	press := func(keys ...tea.KeyMsg) {
+		for _, k := range keys {
+			newModel, _ := m.Update(k)
+			m = newModel.(Model)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	if header := m.renderHeader(); !strings.Contains(header, "REPLAY 1/5 10:00:00 ▶") {
+		t.Errorf("Expected the replay position in the header, got %s", header)
+	}
+
+	// The pause key pauses playback, the brackets step
+	press(runes("p"))
+	if r.playing {
+		t.Error("Expected the pause key to pause playback")
+	}
+	press(runes("]"), runes("]"), runes("["))
+	if r.frame != 2 {
+		t.Errorf("Expected frame 2, got %d", r.frame)
+	}
+	if footer := m.renderFooter(); !strings.Contains(footer, "[/]: Step") || strings.Contains(footer, "Refresh") {
+		t.Errorf("Expected replay keys in place of refresh in the footer, got %s", footer)
+	}
+
+	// A clock time jumps on the shown frame's day
+	press(runes("J"))
+	if !m.jumpMode {
+		t.Fatal("Expected J to open the jump prompt")
+	}
+	press(runes("10:05:30"), tea.KeyMsg{Type: tea.KeyEnter})
+	if want := time.Date(2024, 1, 31, 10, 5, 30, 0, time.UTC); !r.seek.Equal(want) {
+		t.Errorf("Expected a seek to %v, got %v", want, r.seek)
+	}
+	if m.jumpMode {
+		t.Error("Expected Enter to close the jump prompt")
+	}
+
+	// A bad time leaves the replay where it is
+	r.seek = time.Time{}
+	press(runes("J"), runes("soon"), tea.KeyMsg{Type: tea.KeyEnter})
+	if !r.seek.IsZero() || !strings.Contains(m.statusMsg, "invalid time") {
+		t.Errorf("Expected an invalid time to be reported, got seek %v, status %q", r.seek, m.statusMsg)
+	}
+}
+
+func TestParseJumpTime(t *testing.T) {
+	ref := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"10:05", time.Date(2024, 1, 31, 10, 5, 0, 0, time.UTC)},
+		{"10:05:30", time.Date(2024, 1, 31, 10, 5, 30, 0, time.UTC)},
+		{"2024-02-01 08:00:00", time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)},
+		{"2024-02-01T08:00:00Z", time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseJumpTime(tt.in, ref)
+		if err != nil {
+			t.Errorf("parseJumpTime(%q) failed: %v", tt.in, err)
+		} else if !got.Equal(tt.want) {
+			t.Errorf("parseJumpTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if _, err := parseJumpTime("25:00", ref); err == nil {
+		t.Error("Expected an error for 25:00")
+	}
+}
+
+type fakeTargets struct {
+	added, removed []string
+}
+
+func (f *fakeTargets) AddTarget(target string) error {
+	if target == "bad" {
+		return fmt.Errorf("invalid target %s", target)
+	}
+	f.added = append(f.added, target)
+	return nil
+}
+
+func (f *fakeTargets) RemoveTarget(target string) error {
+	f.removed = append(f.removed, target)
+	return nil
+}
+
+func TestTargetEditor(t *testing.T) {
+	s := store.New()
+	s.RegisterHosts([]string{"a:1"})
+	editor := &fakeTargets{}
+	m := New(s, nil, 0, WithTargetEditor(editor))
+	m.selectedHost = "a:1"
+	send := func(msg tea.KeyMsg) {
+		newModel, _ := m.Update(msg)
+		m = newModel.(Model)
+	}
+	typeTarget := func(target string) {
+		send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'+'}})
+		send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(target)})
+		send(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+
+	// A refused target keeps the input open with the reason
+	typeTarget("bad")
+	if !m.targetMode || m.targetErr == nil {
+		t.Fatal("Expected the input to stay open with an error")
+	}
+	send(tea.KeyMsg{Type: tea.KeyEsc})
+
+	typeTarget("b:2")
+	if m.targetMode {
+		t.Error("Expected the input closed after adding")
+	}
+	if !slices.Equal(editor.added, []string{"b:2"}) || m.preferredHost != "b:2" {
+		t.Errorf("Expected b:2 added and preferred, got %v and %q", editor.added, m.preferredHost)
+	}
+
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'-'}})
+	if !slices.Equal(editor.removed, []string{"a:1"}) {
+		t.Errorf("Expected the selected host removed, got %v", editor.removed)
+	}
+
+	// Nothing to remove in the all-hosts view
+	m.selectedHost = allHosts
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'-'}})
+	if len(editor.removed) != 1 {
+		t.Errorf("Expected nothing removed for all hosts, got %v", editor.removed)
+	}
+}
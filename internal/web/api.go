@@ -0,0 +1,251 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anyproto/goru/internal/analysis"
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// apiError is the JSON body of failed API requests
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// groupPage is the answer to a groups request: the groups of a snapshot
+// that pass its filters, sorted, from offset up to limit of them
+type groupPage struct {
+	Host    string         `json:"host"`
+	TakenAt time.Time      `json:"taken_at"`
+	Total   int            `json:"total"` // groups passing the filters, before paging
+	Groups  []*model.Group `json:"groups"`
+}
+
+// states are the goroutine states a groups request can filter by
+var states = []model.GoroutineState{
+	model.StateRunning,
+	model.StateRunnable,
+	model.StateSyscall,
+	model.StateWaiting,
+	model.StateBlocked,
+	model.StateUnknown,
+}
+
+// hostStatus is where a host's collection stands, for telling live data
+// from data the watchdog flagged stale
+type hostStatus struct {
+	Host        string            `json:"host"`
+	Phase       string            `json:"phase"`
+	Error       string            `json:"error,omitempty"`
+	LastSuccess *time.Time        `json:"last_success,omitempty"`
+	Stale       bool              `json:"stale"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// registerAPI adds the JSON endpoints under /api
+func (srv *Server) registerAPI() {
+	srv.mux.HandleFunc("GET /api/hosts", srv.handleHosts)
+	srv.mux.HandleFunc("GET /api/hosts/{host}/snapshot", srv.handleSnapshot)
+	srv.mux.HandleFunc("GET /api/hosts/{host}/groups", srv.handleGroups)
+	srv.mux.HandleFunc("GET /api/hosts/{host}/changes", srv.handleChanges)
+	srv.mux.HandleFunc("GET /api/hosts/{host}/status", srv.handleStatus)
+	srv.mux.HandleFunc("GET /api/stream", srv.handleStream)
+}
+
+func (srv *Server) handleHosts(w http.ResponseWriter, r *http.Request) {
+	hosts := srv.store.GetAllHosts()
+	sort.Strings(hosts)
+
+	// ?label=env=prod keeps the hosts whose target has that label
+	if label := r.URL.Query().Get("label"); label != "" {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			srv.writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("invalid label %q (must be key=value)", label)})
+			return
+		}
+		hosts = slices.DeleteFunc(hosts, func(host string) bool {
+			v, ok := srv.store.GetLabels(host)[key]
+			return !ok || v != value
+		})
+	}
+	srv.writeJSON(w, http.StatusOK, hosts)
+}
+
+func (srv *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, ok := srv.latestSnapshot(w, r)
+	if !ok {
+		return
+	}
+	srv.writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handleGroups serves a page of a snapshot's groups, for hosts with too many
+// to fetch the snapshot whole
+func (srv *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
+	snapshot, ok := srv.latestSnapshot(w, r)
+	if !ok {
+		return
+	}
+	page, err := pageGroups(snapshot, r.URL.Query())
+	if err != nil {
+		srv.writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	srv.writeJSON(w, http.StatusOK, page)
+}
+
+// latestSnapshot returns the requested host's snapshot, flagging it when
+// stale, or answers the request with an error
+func (srv *Server) latestSnapshot(w http.ResponseWriter, r *http.Request) (*model.Snapshot, bool) {
+	host, ok := srv.checkHost(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	snapshot := srv.store.GetSnapshot(host)
+	if snapshot == nil {
+		srv.writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("no snapshot yet for %s", host)})
+		return nil, false
+	}
+
+	// Data that stopped updating is still served, flagged
+	if srv.store.IsStale(host) {
+		w.Header().Set("X-Goru-Stale", "true")
+	}
+	return snapshot, true
+}
+
+// pageGroups applies a groups query: state and func (a case-insensitive
+// substring of any frame's function) filter the groups, sort orders them
+// as the TUI does, and offset and limit page through them
+func pageGroups(snapshot *model.Snapshot, query url.Values) (groupPage, error) {
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = analysis.SortCount
+	}
+	if !slices.Contains(analysis.SortOrders, sortBy) {
+		return groupPage{}, fmt.Errorf("invalid sort %s (must be one of %s)", sortBy, strings.Join(analysis.SortOrders, ", "))
+	}
+	offset, err := queryInt(query.Get("offset"))
+	if err != nil {
+		return groupPage{}, fmt.Errorf("invalid offset: %w", err)
+	}
+	limit, err := queryInt(query.Get("limit"))
+	if err != nil {
+		return groupPage{}, fmt.Errorf("invalid limit: %w", err)
+	}
+
+	state := model.GoroutineState(query.Get("state"))
+	if state != "" && !slices.Contains(states, state) {
+		return groupPage{}, fmt.Errorf("invalid state %s (must be one of running, runnable, syscall, waiting, blocked, unknown)", state)
+	}
+	function := strings.ToLower(query.Get("func"))
+	groups := make([]*model.Group, 0, len(snapshot.Groups))
+	for _, g := range snapshot.Groups {
+		if state != "" && g.State != state {
+			continue
+		}
+		if function != "" && !slices.ContainsFunc(g.Trace, func(frame model.StackFrame) bool {
+			return strings.Contains(strings.ToLower(frame.Func), function)
+		}) {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	analysis.SortGroups(groups, sortBy, model.DefaultSkipPackages, false)
+
+	total := len(groups)
+	groups = groups[min(offset, total):]
+	if limit > 0 && len(groups) > limit {
+		groups = groups[:limit]
+	}
+	return groupPage{
+		Host:    snapshot.Host,
+		TakenAt: snapshot.TakenAt,
+		Total:   total,
+		Groups:  groups,
+	}, nil
+}
+
+// queryInt parses a non-negative query parameter, 0 when it's missing
+func queryInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%d is negative", n)
+	}
+	return n, nil
+}
+
+func (srv *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	host, ok := srv.checkHost(w, r)
+	if !ok {
+		return
+	}
+
+	changes := srv.store.GetChangeSet(host)
+	if changes == nil {
+		changes = model.NewChangeSet(host)
+	}
+	srv.writeJSON(w, http.StatusOK, changes)
+}
+
+func (srv *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	// Failing hosts have a status too, so checkHost's 503 doesn't apply
+	host := r.PathValue("host")
+	if !slices.Contains(srv.store.GetAllHosts(), host) {
+		srv.writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown host %s", host)})
+		return
+	}
+
+	status := hostStatus{
+		Host:   host,
+		Phase:  string(srv.store.GetPhase(host)),
+		Stale:  srv.store.IsStale(host),
+		Labels: srv.store.GetLabels(host),
+	}
+	if err, ok := srv.store.GetErrors()[host]; ok {
+		status.Error = err.Error()
+	}
+	if t := srv.store.LastSuccess(host); !t.IsZero() {
+		status.LastSuccess = &t
+	}
+	srv.writeJSON(w, http.StatusOK, status)
+}
+
+// checkHost resolves the host in the request path, writing a 404 for unknown
+// hosts and a 503 for hosts whose last collection failed
+func (srv *Server) checkHost(w http.ResponseWriter, r *http.Request) (string, bool) {
+	host := r.PathValue("host")
+	if !slices.Contains(srv.store.GetAllHosts(), host) {
+		srv.writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown host %s", host)})
+		return "", false
+	}
+	if err, ok := srv.store.GetErrors()[host]; ok {
+		srv.writeJSON(w, http.StatusServiceUnavailable, apiError{Error: err.Error()})
+		return "", false
+	}
+	return host, true
+}
+
+func (srv *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		srv.logger.Error("encoding API response", telemetry.Error(err))
+	}
+}
@@ -0,0 +1,278 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+func newAPITestServer() *Server {
+	s := store.New()
+	s.RegisterHosts([]string{"localhost:8080", "localhost:8081", "localhost:8082"})
+
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "localhost:8080",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 10, Trace: model.StackTrace{{Func: "main.worker"}}},
+		},
+	}, &model.ChangeSet{
+		Host:    "localhost:8080",
+		Updated: map[model.GroupID]int{"g1": 4},
+	})
+	s.UpdateError("localhost:8081", errors.New("connection refused"))
+
+	return New(s, "localhost", 0, telemetry.NewLogger("error", false))
+}
+
+func TestAPI(t *testing.T) {
+	srv := newAPITestServer()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		check      func(t *testing.T, body []byte)
+	}{
+		{
+			name:       "hosts",
+			path:       "/api/hosts",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var hosts []string
+				if err := json.Unmarshal(body, &hosts); err != nil {
+					t.Fatal(err)
+				}
+				if len(hosts) != 3 || hosts[0] != "localhost:8080" {
+					t.Errorf("Unexpected hosts %v", hosts)
+				}
+			},
+		},
+		{
+			name:       "snapshot",
+			path:       "/api/hosts/localhost:8080/snapshot",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var snapshot model.Snapshot
+				if err := json.Unmarshal(body, &snapshot); err != nil {
+					t.Fatal(err)
+				}
+				if g := snapshot.Groups["g1"]; g == nil || g.Count != 10 {
+					t.Errorf("Unexpected snapshot groups %v", snapshot.Groups)
+				}
+			},
+		},
+		{
+			name:       "changes",
+			path:       "/api/hosts/localhost:8080/changes",
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, body []byte) {
+				var changes model.ChangeSet
+				if err := json.Unmarshal(body, &changes); err != nil {
+					t.Fatal(err)
+				}
+				if changes.Updated["g1"] != 4 {
+					t.Errorf("Unexpected changes %v", changes.Updated)
+				}
+			},
+		},
+		{
+			name:       "changes before any diff",
+			path:       "/api/hosts/localhost:8082/changes",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "snapshot before first collection",
+			path:       "/api/hosts/localhost:8082/snapshot",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown host",
+			path:       "/api/hosts/nowhere:1/snapshot",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "host in error",
+			path:       "/api/hosts/localhost:8081/changes",
+			wantStatus: http.StatusServiceUnavailable,
+			check: func(t *testing.T, body []byte) {
+				var apiErr apiError
+				if err := json.Unmarshal(body, &apiErr); err != nil {
+					t.Fatal(err)
+				}
+				if apiErr.Error != "connection refused" {
+					t.Errorf("Error = %q, want %q", apiErr.Error, "connection refused")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			if tt.check != nil {
+				tt.check(t, rec.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestAPIStale(t *testing.T) {
+	srv := newAPITestServer()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	status := func(host string) hostStatus {
+		rec := get("/api/hosts/" + host + "/status")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var st hostStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	st := status("localhost:8080")
+	if st.Stale || st.LastSuccess == nil {
+		t.Errorf("Expected a fresh host with a last success, got %+v", st)
+	}
+	if rec := get("/api/hosts/localhost:8080/snapshot"); rec.Header().Get("X-Goru-Stale") != "" {
+		t.Error("Fresh snapshot carries X-Goru-Stale")
+	}
+
+	srv.store.MarkStale(time.Now().Add(time.Hour), func(string) time.Duration { return time.Minute })
+	if st := status("localhost:8080"); !st.Stale {
+		t.Errorf("Expected the host flagged stale, got %+v", st)
+	}
+	if rec := get("/api/hosts/localhost:8080/snapshot"); rec.Header().Get("X-Goru-Stale") != "true" {
+		t.Errorf("X-Goru-Stale = %q, want true", rec.Header().Get("X-Goru-Stale"))
+	}
+
+	// A failing host has a status but no snapshot to be stale
+	if st := status("localhost:8081"); st.Error != "connection refused" || st.Stale || st.LastSuccess != nil {
+		t.Errorf("Unexpected status for the failing host %+v", st)
+	}
+	if rec := get("/api/hosts/nowhere:1/status"); rec.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAPIHostLabels(t *testing.T) {
+	srv := newAPITestServer()
+	srv.store.SetLabels("localhost:8080", map[string]string{"env": "prod", "region": "us"})
+	srv.store.SetLabels("localhost:8081", map[string]string{"env": "staging"})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	var hosts []string
+	rec := get("/api/hosts?label=env=prod")
+	if err := json.Unmarshal(rec.Body.Bytes(), &hosts); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(hosts, []string{"localhost:8080"}) {
+		t.Errorf("Hosts = %v, want [localhost:8080]", hosts)
+	}
+	if rec := get("/api/hosts?label=env"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	var status hostStatus
+	if err := json.Unmarshal(get("/api/hosts/localhost:8080/status").Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Labels["region"] != "us" {
+		t.Errorf("Expected the host's labels in its status, got %+v", status)
+	}
+}
+
+func TestAPISnapshotQuery(t *testing.T) {
+	s := store.New()
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "localhost:8080",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 10, Trace: model.StackTrace{{Func: "main.worker"}}},
+			"g2": {ID: "g2", State: model.StateWaiting, Count: 30, Trace: model.StackTrace{{Func: "net/http.(*conn).serve"}}},
+			"g3": {ID: "g3", State: model.StateBlocked, Count: 20, Trace: model.StackTrace{{Func: "main.Worker2"}}},
+			"g4": {ID: "g4", State: model.StateBlocked, Count: 5, Trace: model.StackTrace{{Func: "main.reader"}}},
+		},
+	}, nil)
+	srv := New(s, "localhost", 0, telemetry.NewLogger("error", false))
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantTotal  int
+		wantIDs    []model.GroupID
+	}{
+		{"sort=count", http.StatusOK, 4, []model.GroupID{"g2", "g3", "g1", "g4"}},
+		{"state=blocked", http.StatusOK, 3, []model.GroupID{"g3", "g1", "g4"}},
+		{"func=WORKER&sort=function", http.StatusOK, 2, []model.GroupID{"g3", "g1"}},
+		{"state=blocked&limit=2", http.StatusOK, 3, []model.GroupID{"g3", "g1"}},
+		{"limit=2&offset=3", http.StatusOK, 4, []model.GroupID{"g4"}},
+		{"offset=9", http.StatusOK, 4, []model.GroupID{}},
+		{"sort=size", http.StatusBadRequest, 0, nil},
+		{"limit=-1", http.StatusBadRequest, 0, nil},
+		{"state=sleeping", http.StatusBadRequest, 0, nil},
+		{"", http.StatusOK, 4, []model.GroupID{"g2", "g3", "g1", "g4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/hosts/localhost:8080/groups?"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var page groupPage
+			if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			ids := []model.GroupID{}
+			for _, g := range page.Groups {
+				ids = append(ids, g.ID)
+			}
+			if page.Total != tt.wantTotal || !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("Got %v of %d, want %v of %d", ids, page.Total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+
+	// The snapshot endpoint sends the snapshot whole whatever the query
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/hosts/localhost:8080/snapshot?limit=1", nil))
+	var snapshot model.Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(snapshot.Groups) != 4 {
+		t.Errorf("Got status %d with %d groups, want the whole snapshot", rec.Code, len(snapshot.Groups))
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// SetBasicAuth requires HTTP basic auth with user and password for every
+// page and API endpoint. It must be called before Start.
+func (srv *Server) SetBasicAuth(user, password string) {
+	srv.authUser = user
+	srv.authPass = password
+}
+
+// SetToken requires token for every page and API endpoint, either as a
+// bearer token or, for browsers, as the basic auth password with any user.
+// It can be combined with SetBasicAuth and must be called before Start.
+func (srv *Server) SetToken(token string) {
+	srv.authToken = token
+}
+
+// authenticate wraps next in a check of the configured credentials,
+// answering 401 with a basic auth challenge when they're missing or wrong.
+// Without credentials configured next is returned as is.
+func (srv *Server) authenticate(next http.Handler) http.Handler {
+	if srv.authUser == "" && srv.authToken == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !srv.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="goru", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized reports whether the request carries the configured credentials
+func (srv *Server) authorized(r *http.Request) bool {
+	if srv.authToken != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			return secretEqual(token, srv.authToken)
+		}
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	if srv.authUser != "" {
+		// Both are compared either way so a wrong user takes as long as a
+		// wrong password
+		userOK := secretEqual(user, srv.authUser)
+		passOK := secretEqual(pass, srv.authPass)
+		if userOK && passOK {
+			return true
+		}
+	}
+	return srv.authToken != "" && secretEqual(pass, srv.authToken)
+}
+
+// secretEqual compares got with want in constant time. Both are hashed
+// first, so the time taken doesn't give away the secret's length either.
+func secretEqual(got, want string) bool {
+	g := sha256.Sum256([]byte(got))
+	w := sha256.Sum256([]byte(want))
+	return subtle.ConstantTimeCompare(g[:], w[:]) == 1
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+func TestAuth(t *testing.T) {
+	srv := New(store.New(), "localhost", 0, telemetry.NewLogger("error", false))
+	srv.SetBasicAuth("admin", "secret")
+	srv.SetToken("t0ken")
+
+	tests := []struct {
+		name      string
+		path      string
+		authorize func(r *http.Request)
+		want      int
+	}{
+		{"no credentials", "/", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"API without credentials", "/api/hosts", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"basic auth", "/", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, http.StatusOK},
+		{"wrong password", "/", func(r *http.Request) { r.SetBasicAuth("admin", "guess") }, http.StatusUnauthorized},
+		{"wrong user", "/", func(r *http.Request) { r.SetBasicAuth("root", "secret") }, http.StatusUnauthorized},
+		{"bearer token", "/api/hosts", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }, http.StatusOK},
+		{"wrong bearer token", "/api/hosts", func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") }, http.StatusUnauthorized},
+		{"token as password", "/", func(r *http.Request) { r.SetBasicAuth("anyone", "t0ken") }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			tt.authorize(req)
+			rec := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("Status = %d, want %d", rec.Code, tt.want)
+			}
+			if challenge := rec.Header().Get("WWW-Authenticate"); (challenge != "") != (tt.want == http.StatusUnauthorized) {
+				t.Errorf("WWW-Authenticate = %q with status %d", challenge, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	srv := New(store.New(), "localhost", 0, telemetry.NewLogger("error", false))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status = %d, want %d without credentials configured", rec.Code, http.StatusOK)
+	}
+}
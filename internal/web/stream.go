@@ -0,0 +1,85 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+// streamEvent is the JSON payload of each Server-Sent Event
+type streamEvent struct {
+	Host    string                `json:"host"`
+	Phase   store.Phase           `json:"phase"`
+	Removed bool                  `json:"removed,omitempty"` // every host when host is empty
+	Error   string                `json:"error,omitempty"`
+	Total   int                   `json:"total"`
+	Counts  map[model.GroupID]int `json:"counts,omitempty"`
+	Changes *model.ChangeSet      `json:"changes,omitempty"`
+}
+
+// newStreamEvent flattens a store update into the event sent to browsers
+func newStreamEvent(update store.Update) streamEvent {
+	event := streamEvent{
+		Host:    update.Host,
+		Phase:   update.Phase,
+		Removed: update.Removed,
+		Changes: update.ChangeSet,
+	}
+	if update.Error != nil {
+		event.Error = update.Error.Error()
+	}
+	if update.Snapshot != nil {
+		event.Total = update.Snapshot.TotalGoroutines()
+		event.Counts = make(map[model.GroupID]int, len(update.Snapshot.Groups))
+		for id, g := range update.Snapshot.Groups {
+			event.Counts[id] = g.Count
+		}
+	}
+	return event
+}
+
+// handleStream pushes every store update to the client as an SSE data frame
+// until the client disconnects
+func (srv *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// The store drops updates for subscribers that aren't keeping up, so a
+	// slow client only misses events rather than stalling collection
+	updates := make(chan store.Update, 100)
+	srv.store.Subscribe(updates)
+	defer srv.store.Unsubscribe(updates)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// A comment line lets the client know the stream is live
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update := <-updates:
+			data, err := json.Marshal(newStreamEvent(update))
+			if err != nil {
+				srv.logger.Error("encoding stream event", telemetry.Error(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
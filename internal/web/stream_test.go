@@ -0,0 +1,86 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+func TestStream(t *testing.T) {
+	s := store.New()
+	srv := New(s, "localhost", 0, telemetry.NewLogger("error", false))
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	readLine := func() string {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(line)
+	}
+
+	// Wait for the subscription before publishing
+	if line := readLine(); line != ": connected" {
+		t.Fatalf("First line = %q, want the connected comment", line)
+	}
+
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateRunning, Count: 3},
+			"g2": {ID: "g2", State: model.StateWaiting, Count: 2},
+		},
+	}, &model.ChangeSet{Host: "host1", Updated: map[model.GroupID]int{"g1": 1}})
+
+	readLine() // blank line after the comment
+	line := readLine()
+	if !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("Expected a data frame, got %q", line)
+	}
+
+	var event streamEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Host != "host1" || event.Total != 5 || event.Counts["g2"] != 2 {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if event.Changes == nil || event.Changes.Updated["g1"] != 1 {
+		t.Errorf("Expected changeset deltas in event, got %+v", event.Changes)
+	}
+
+	// Disconnecting removes the subscriber
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for s.GetStats().SubscriberCount != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Subscriber not removed after client disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
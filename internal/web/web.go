@@ -0,0 +1,275 @@
+package web
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"maps"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+//go:embed templates/*.html
+var templates embed.FS
+
+var indexTmpl = template.Must(template.ParseFS(templates, "templates/index.html"))
+
+// Server serves a browser view of the hosts and groups in a store
+type Server struct {
+	store   *store.Store
+	logger  telemetry.Logger
+	addr    string
+	tlsCert string
+	tlsKey  string
+	mux     *http.ServeMux
+
+	// Credentials required by every handler, see SetBasicAuth and SetToken
+	authUser  string
+	authPass  string
+	authToken string
+
+	// Time of the last store update, shown on the page
+	mu         sync.RWMutex
+	lastUpdate time.Time
+}
+
+// New creates a web server for the store listening on host:port
+func New(s *store.Store, host string, port int, logger telemetry.Logger) *Server {
+	srv := &Server{
+		store:  s,
+		logger: logger,
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+		mux:    http.NewServeMux(),
+	}
+	srv.mux.HandleFunc("GET /{$}", srv.handleIndex)
+	srv.registerAPI()
+	return srv
+}
+
+// SetTLS serves HTTPS with the given certificate and key files. It must be
+// called before Start.
+func (srv *Server) SetTLS(certFile, keyFile string) {
+	srv.tlsCert = certFile
+	srv.tlsKey = keyFile
+}
+
+// Handler returns the server's HTTP handler, behind the configured
+// authentication
+func (srv *Server) Handler() http.Handler {
+	return srv.authenticate(srv.mux)
+}
+
+// Start listens on the configured address and serves in the background until
+// ctx is cancelled. It returns the URL the server is reachable at.
+func (srv *Server) Start(ctx context.Context) (string, error) {
+	ln, err := net.Listen("tcp", srv.addr)
+	if err != nil {
+		return "", fmt.Errorf("listening on %s: %w", srv.addr, err)
+	}
+
+	scheme := "http"
+	if srv.tlsCert != "" {
+		scheme = "https"
+	}
+	url := serverURL(scheme, ln.Addr())
+
+	server := &http.Server{Handler: srv.Handler()}
+
+	// Track store updates for the page's "updated" time
+	updates := make(chan store.Update, 10)
+	srv.store.Subscribe(updates)
+
+	go func() {
+		defer srv.store.Unsubscribe(updates)
+		for {
+			select {
+			case <-ctx.Done():
+				server.Close()
+				return
+			case <-updates:
+				srv.mu.Lock()
+				srv.lastUpdate = time.Now()
+				srv.mu.Unlock()
+			}
+		}
+	}()
+
+	go func() {
+		var err error
+		if srv.tlsCert != "" {
+			err = server.ServeTLS(ln, srv.tlsCert, srv.tlsKey)
+		} else {
+			err = server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			srv.logger.Error("web server error", telemetry.Error(err))
+		}
+	}()
+
+	srv.logger.Info("Started web server", telemetry.String("url", url))
+	return url, nil
+}
+
+// serverURL returns the URL a server listening on addr is reached at. A
+// server listening on every interface is reached through localhost, as
+// browsers can't open the unspecified address.
+func serverURL(scheme string, addr net.Addr) string {
+	host := addr.String()
+	if tcp, ok := addr.(*net.TCPAddr); ok && tcp.IP.IsUnspecified() {
+		host = net.JoinHostPort("localhost", strconv.Itoa(tcp.Port))
+	}
+	return fmt.Sprintf("%s://%s/", scheme, host)
+}
+
+// OpenBrowser opens url in the user's default browser
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// The opener exits once the browser has the URL; reap it
+	go cmd.Wait()
+	return nil
+}
+
+type pageView struct {
+	Updated    string
+	Goroutines int // across all hosts
+	Hosts      []hostView
+}
+
+type hostView struct {
+	Name       string
+	Labels     string // the labels given for the host's target, as key=value pairs
+	Phase      string
+	Error      string
+	Partial    bool
+	Stale      string // when the last collection succeeded, if that's too long ago
+	HasData    bool
+	Goroutines int
+	Groups     []groupView
+}
+
+type groupView struct {
+	State     string
+	Function  string
+	CreatedBy string
+	Count     int
+	Wait      string
+}
+
+func (srv *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	srv.mu.RLock()
+	updated := srv.lastUpdate
+	srv.mu.RUnlock()
+
+	page := pageView{Updated: "never", Goroutines: srv.store.GetStats().TotalGoroutines}
+	if !updated.IsZero() {
+		page.Updated = updated.Format("15:04:05")
+	}
+
+	hosts := srv.store.GetAllHosts()
+	sort.Strings(hosts)
+	errors := srv.store.GetErrors()
+
+	for _, host := range hosts {
+		hv := hostView{
+			Name:  host,
+			Phase: string(srv.store.GetPhase(host)),
+		}
+		if labels := srv.store.GetLabels(host); len(labels) > 0 {
+			var pairs []string
+			for _, key := range slices.Sorted(maps.Keys(labels)) {
+				pairs = append(pairs, key+"="+labels[key])
+			}
+			hv.Labels = strings.Join(pairs, " ")
+		}
+		if err, ok := errors[host]; ok {
+			hv.Error = err.Error()
+		}
+		if snapshot := srv.store.GetSnapshot(host); snapshot != nil {
+			hv.HasData = true
+			hv.Partial = snapshot.Partial
+			if srv.store.IsStale(host) {
+				hv.Stale = srv.store.LastSuccess(host).Format("15:04:05")
+			}
+			hv.Goroutines = snapshot.TotalGoroutines()
+			hv.Groups = groupViews(snapshot)
+		}
+		page.Hosts = append(page.Hosts, hv)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTmpl.Execute(w, page); err != nil {
+		srv.logger.Error("rendering index", telemetry.Error(err))
+	}
+}
+
+// groupViews returns a snapshot's groups ordered by count like the TUI table
+func groupViews(snapshot *model.Snapshot) []groupView {
+	groups := make([]*model.Group, 0, len(snapshot.Groups))
+	for _, g := range snapshot.Groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].ID < groups[j].ID
+	})
+
+	views := make([]groupView, 0, len(groups))
+	for _, g := range groups {
+		gv := groupView{
+			State: string(g.State),
+			Count: g.Count,
+			Wait:  longestWait(g),
+		}
+		if len(g.Trace) > 0 {
+			gv.Function = g.Trace[0].Func
+		} else {
+			gv.Function = model.NoStack
+		}
+		if g.CreatedBy != nil {
+			gv.CreatedBy = g.CreatedBy.Func
+		}
+		views = append(views, gv)
+	}
+	return views
+}
+
+// longestWait returns the longest of the group's wait annotations as written
+// in the dump, or an empty string if there are none
+func longestWait(g *model.Group) string {
+	longest, index := time.Duration(-1), -1
+	for i, d := range g.WaitTimes() {
+		if d > longest {
+			longest, index = d, i
+		}
+	}
+	if index < 0 {
+		return ""
+	}
+	return g.WaitDurations[index]
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anyproto/goru/internal/telemetry"
+	"github.com/anyproto/goru/pkg/model"
+	"github.com/anyproto/goru/pkg/store"
+)
+
+func TestIndex(t *testing.T) {
+	s := store.New()
+	s.RegisterHosts([]string{"host1", "host2", "host3"})
+
+	s.UpdateSnapshot(&model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateBlocked, Count: 10, Trace: model.StackTrace{{Func: "main.worker"}},
+				CreatedBy: &model.StackFrame{Func: "main.startWorkers"}, WaitDurations: []string{"2 minutes", "15 minutes"}},
+			"g2": {ID: "g2", State: model.StateRunning, Count: 1, Trace: model.StackTrace{{Func: "main.main"}}},
+		},
+	}, nil)
+	s.UpdateError("host2", errors.New("connection refused"))
+
+	srv := New(s, "localhost", 0, telemetry.NewLogger("error", false))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	out := rec.Body.String()
+
+	for _, want := range []string{
+		"3 host(s) | 11 goroutines |",
+		"<h2>host1</h2>",
+		"11 goroutines in 2 groups",
+		"<td>blocked</td><td>main.worker</td><td>main.startWorkers</td><td class=\"count\">10</td><td>15 minutes</td>",
+		"Error: connection refused",
+		"No data yet (registered)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Page missing %q:\n%s", want, out)
+		}
+	}
+
+	// Groups are ordered by count
+	if strings.Index(out, "main.worker") > strings.Index(out, "main.main") {
+		t.Error("Expected main.worker before main.main")
+	}
+}
+
+func TestIndexNotFound(t *testing.T) {
+	srv := New(store.New(), "localhost", 0, telemetry.NewLogger("error", false))
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := New(store.New(), "localhost", 0, telemetry.NewLogger("error", false))
+	url, err := srv.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "Goroutine Explorer") {
+		t.Errorf("Unexpected page:\n%s", body)
+	}
+}
+
+func TestServerURL(t *testing.T) {
+	tests := []struct {
+		scheme string
+		addr   net.Addr
+		want   string
+	}{
+		{"http", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}, "http://127.0.0.1:8080/"},
+		{"https", &net.TCPAddr{IP: net.IPv4zero, Port: 8443}, "https://localhost:8443/"},
+		{"http", &net.TCPAddr{IP: net.IPv6unspecified, Port: 8080}, "http://localhost:8080/"},
+	}
+	for _, tt := range tests {
+		if got := serverURL(tt.scheme, tt.addr); got != tt.want {
+			t.Errorf("serverURL(%s, %s) = %q, want %q", tt.scheme, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestLongestWait(t *testing.T) {
+	tests := []struct {
+		input    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"1 minutes"}, "1 minutes"},
+		{[]string{"3 minutes", "12 minutes", "5 minutes"}, "12 minutes"},
+		{[]string{"garbage", "2 minutes"}, "2 minutes"},
+		{[]string{"1 minute"}, "1 minute"},
+		{[]string{"90 minutes", "2 hours", "45 seconds"}, "2 hours"},
+	}
+
+	for _, tt := range tests {
+		g := &model.Group{WaitDurations: tt.input}
+		if got := longestWait(g); got != tt.expected {
+			t.Errorf("longestWait(%v) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
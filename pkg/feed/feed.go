@@ -0,0 +1,103 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/anyproto/goru/internal/collector"
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// FeedSource is a push-driven source for snapshots produced outside of goru's
+// own collectors, e.g. synthetic snapshots in tests or custom ingestion code
+type FeedSource struct {
+	name      string
+	snapshots chan *model.Snapshot
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// New creates a new feed source buffering up to buffer pushed snapshots
+func New(name string, buffer int) *FeedSource {
+	return &FeedSource{
+		name:      name,
+		snapshots: make(chan *model.Snapshot, buffer),
+		done:      make(chan struct{}),
+	}
+}
+
+// Name returns the name of this source
+func (f *FeedSource) Name() string {
+	return f.name
+}
+
+// Push queues a snapshot for collection. It blocks while the buffer is full
+// and fails once the context is canceled or the feed is closed.
+func (f *FeedSource) Push(ctx context.Context, snapshot *model.Snapshot) error {
+	select {
+	case <-f.done:
+		return fmt.Errorf("feed %s is closed", f.name)
+	default:
+	}
+
+	select {
+	case f.snapshots <- snapshot:
+		return nil
+	case <-f.done:
+		return fmt.Errorf("feed %s is closed", f.name)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the feed; snapshots already pushed are still delivered
+func (f *FeedSource) Close() {
+	f.closeOnce.Do(func() {
+		close(f.done)
+	})
+}
+
+// Drain closes the feed, so orchestrator shutdown delivers what was pushed
+func (f *FeedSource) Drain() {
+	f.Close()
+}
+
+// Collect forwards pushed snapshots until the feed is closed or the context
+// is canceled
+func (f *FeedSource) Collect(ctx context.Context, snapshots chan<- *model.Snapshot) error {
+	defer close(snapshots)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case snapshot := <-f.snapshots:
+			select {
+			case snapshots <- snapshot:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case <-f.done:
+			// Drain whatever was pushed before closing
+			for {
+				select {
+				case snapshot := <-f.snapshots:
+					select {
+					case snapshots <- snapshot:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				default:
+					return nil
+				}
+			}
+		}
+	}
+}
+
+var (
+	_ collector.Source  = (*FeedSource)(nil)
+	_ collector.Drainer = (*FeedSource)(nil)
+)
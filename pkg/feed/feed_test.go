@@ -0,0 +1,46 @@
+package feed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+func TestFeedSourceCollect(t *testing.T) {
+	source := New("synthetic", 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, host := range []string{"host1", "host2"} {
+		if err := source.Push(ctx, model.NewSnapshot(host)); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+	source.Close()
+
+	snapshots := make(chan *model.Snapshot, 10)
+	if err := source.Collect(ctx, snapshots); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	var hosts []string
+	for snapshot := range snapshots {
+		hosts = append(hosts, snapshot.Host)
+	}
+
+	if len(hosts) != 2 || hosts[0] != "host1" || hosts[1] != "host2" {
+		t.Errorf("Expected snapshots for host1, host2 in order, got %v", hosts)
+	}
+}
+
+func TestFeedSourcePushAfterClose(t *testing.T) {
+	source := New("synthetic", 1)
+	source.Close()
+
+	if err := source.Push(context.Background(), model.NewSnapshot("host1")); err == nil {
+		t.Error("Expected error pushing to a closed feed")
+	}
+}
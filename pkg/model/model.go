@@ -4,6 +4,10 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,8 +16,19 @@ type StackFrame struct {
 	Func string `json:"func"`
 	File string `json:"file"`
 	Line int    `json:"line,omitempty"`
+	// Args is the frame's argument list with addresses and numbers
+	// normalized, kept only when goroutines are grouped by their arguments
+	Args string `json:"args,omitempty"`
 }
 
+// ElidedFrames is the function name of the marker frame standing in for
+// frames the runtime left out of a very deep stack
+const ElidedFrames = "...additional frames elided..."
+
+// NoStack stands in for the function of a group whose trace is empty, such
+// as one built from a malformed dump or a feed
+const NoStack = "<no stack>"
+
 type StackTrace []StackFrame
 
 func (s StackTrace) String() string {
@@ -23,6 +38,7 @@ func (s StackTrace) String() string {
 			b.WriteString("\n")
 		}
 		b.WriteString(frame.Func)
+		b.WriteString(frame.Args)
 		if frame.File != "" {
 			b.WriteString(fmt.Sprintf(" %s:%d", frame.File, frame.Line))
 		}
@@ -30,24 +46,219 @@ func (s StackTrace) String() string {
 	return b.String()
 }
 
+// FramePackage returns the import path of the package a function belongs to,
+// e.g. "net/http" for "net/http.(*conn).serve"
+func FramePackage(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	dot := strings.Index(fn[slash+1:], ".")
+	if dot < 0 {
+		return fn
+	}
+	return fn[:slash+1+dot]
+}
+
+// StripTypeArgs replaces the type arguments of generic functions and types
+// with "[...]", as the runtime's own tracebacks print them, so every
+// instantiation reads the same: "foo.Map[go.shape.int].func1" becomes
+// "foo.Map[...].func1". Nested brackets are taken as part of the arguments.
+func StripTypeArgs(fn string) string {
+	if !strings.Contains(fn, "[") {
+		return fn
+	}
+
+	var b strings.Builder
+	depth := 0
+	for _, r := range fn {
+		switch {
+		case r == '[':
+			if depth == 0 {
+				b.WriteString("[...]")
+			}
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// WithoutTypeArgs returns a copy of the trace with StripTypeArgs applied to
+// its functions
+func (s StackTrace) WithoutTypeArgs() StackTrace {
+	stripped := make(StackTrace, len(s))
+	for i, frame := range s {
+		frame.Func = StripTypeArgs(frame.Func)
+		stripped[i] = frame
+	}
+	return stripped
+}
+
+// DefaultSkipPackages are the packages TopFrame looks past: the Go runtime
+// internals that sit on top of nearly every parked goroutine's stack
+var DefaultSkipPackages = []string{"runtime", "internal"}
+
+// inPackages reports whether pkg is one of the packages or below one of them
+func inPackages(pkg string, packages []string) bool {
+	for _, p := range packages {
+		if pkg == p || strings.HasPrefix(pkg, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isStdlibPackage reports whether pkg belongs to the standard library, whose
+// import paths have no dot in their first element
+func isStdlibPackage(pkg string) bool {
+	first, _, _ := strings.Cut(pkg, "/")
+	return pkg != "main" && !strings.Contains(first, ".")
+}
+
+// IsAppPackage reports whether pkg is application code: under one of the
+// prefixes, or outside the standard library when there are none
+func IsAppPackage(pkg string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return !isStdlibPackage(pkg)
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TopFrame returns the innermost frame outside the runtime, falling back to
+// the innermost frame. It returns nil for an empty trace.
+func (s StackTrace) TopFrame() *StackFrame {
+	return s.PrimaryFrame(DefaultSkipPackages)
+}
+
+// PrimaryFrame returns the innermost frame outside the skipped packages and
+// the packages below them, falling back to the innermost frame. It returns
+// nil for an empty trace.
+func (s StackTrace) PrimaryFrame(skip []string) *StackFrame {
+	for i := range s {
+		if s[i].Func != ElidedFrames && !inPackages(FramePackage(s[i].Func), skip) {
+			return &s[i]
+		}
+	}
+	if len(s) == 0 {
+		return nil
+	}
+	return &s[0]
+}
+
+// AppFrame returns the innermost frame outside the standard library, falling
+// back to TopFrame when the whole stack is standard library code
+func (s StackTrace) AppFrame() *StackFrame {
+	for i := range s {
+		if s[i].Func != ElidedFrames && !isStdlibPackage(FramePackage(s[i].Func)) {
+			return &s[i]
+		}
+	}
+	return s.TopFrame()
+}
+
 type GroupID string
 
 type GoroutineState string
 
+const (
+	StateRunning  GoroutineState = "running"
+	StateRunnable GoroutineState = "runnable"
+	StateSyscall  GoroutineState = "syscall"
+	StateWaiting  GoroutineState = "waiting"
+	StateBlocked  GoroutineState = "blocked"
+	StateUnknown  GoroutineState = "unknown" // formats without per-goroutine state, e.g. debug=1
+)
 
 type Group struct {
-	ID            GroupID        `json:"id"`
-	State         GoroutineState `json:"state"`
-	Count         int            `json:"count"`
-	WaitDurations []string       `json:"wait_durations,omitempty"`
-	Trace         StackTrace     `json:"trace"`
-	CreatedBy     *StackFrame    `json:"created_by,omitempty"`
+	ID            GroupID         `json:"id"`
+	State         GoroutineState  `json:"state"`
+	Count         int             `json:"count"`
+	WaitDurations []string        `json:"wait_durations,omitempty"` // as written in the dump, e.g. "5 minutes"
+	Waits         []time.Duration `json:"waits,omitempty"`          // WaitDurations parsed, index for index
+	IDs           []uint64        `json:"ids,omitempty"`            // goroutine numbers merged into the group
+	Trace         StackTrace      `json:"trace"`
+	CreatedBy     *StackFrame     `json:"created_by,omitempty"`
+	// CreatedByGoroutine is the number of the goroutine that spawned the
+	// group's goroutines, from "created by ... in goroutine N". It is 0 when
+	// unknown or when they were spawned by different goroutines.
+	CreatedByGoroutine uint64 `json:"created_by_goroutine,omitempty"`
+	// BlockReason is what the goroutines wait on, as the dump's header puts
+	// it: "sync.Mutex.Lock", "chan receive", "IO wait" and the like. It is
+	// empty for goroutines that aren't waiting, and groups keep the reason
+	// of their first goroutine.
+	BlockReason string `json:"block_reason,omitempty"`
+	// Labels are the pprof labels every goroutine of the group carries.
+	// Labels only some of them carry are dropped, unless the snapshot groups
+	// by labels, which keeps goroutines with different labels apart.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
+// WaitTimes returns the group's wait durations. Groups built without parsed
+// Waits, such as ones loaded from older JSON, have their raw strings parsed.
+func (g *Group) WaitTimes() []time.Duration {
+	if len(g.Waits) == len(g.WaitDurations) {
+		return g.Waits
+	}
+	waits := make([]time.Duration, len(g.WaitDurations))
+	for i, raw := range g.WaitDurations {
+		waits[i], _ = ParseWait(raw)
+	}
+	return waits
+}
+
+// ParseWait parses a wait annotation from a goroutine header, such as
+// "5 minutes", "1 hour" or "45 seconds". Go duration strings like "5m" are
+// accepted too.
+func ParseWait(s string) (time.Duration, bool) {
+	fields := strings.Fields(s)
+	if len(fields) == 2 {
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return 0, false
+		}
+		switch strings.TrimSuffix(fields[1], "s") {
+		case "second":
+			return time.Duration(n) * time.Second, true
+		case "minute":
+			return time.Duration(n) * time.Minute, true
+		case "hour":
+			return time.Duration(n) * time.Hour, true
+		}
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
+
+// GenerateID hashes the group's state and trace, including the file and
+// line of every frame, so call sites in the same functions get IDs of their
+// own
 func (g *Group) GenerateID() GroupID {
+	return g.GenerateIDDepth(0)
+}
+
+// GenerateIDDepth is like GenerateID but only hashes the top depth frames of
+// the trace, so goroutines that diverge deeper in the stack share an ID. A
+// depth of 0 hashes the full trace.
+func (g *Group) GenerateIDDepth(depth int) GroupID {
+	trace := g.Trace
+	if depth > 0 && len(trace) > depth {
+		trace = trace[:depth]
+	}
+
 	h := sha256.New()
 	h.Write([]byte(g.State))
-	h.Write([]byte(g.Trace.String()))
+	h.Write([]byte(trace.String()))
 	return GroupID(hex.EncodeToString(h.Sum(nil))[:16])
 }
 
@@ -55,6 +266,37 @@ type Snapshot struct {
 	Host    string             `json:"host"`
 	TakenAt time.Time          `json:"taken_at"`
 	Groups  map[GroupID]*Group `json:"groups"`
+
+	// GroupDepth is the number of top frames goroutines are grouped by, 0 for
+	// the full trace. Groups keep the full trace of their first goroutine.
+	GroupDepth int `json:"group_depth,omitempty"`
+	// NormalizeGenerics groups goroutines by their traces with type
+	// arguments stripped, so instantiations of the same generic code share
+	// a group. Groups keep the full names of their first goroutine.
+	NormalizeGenerics bool `json:"normalize_generics,omitempty"`
+	// GroupByLabels groups goroutines by their pprof labels as well as their
+	// traces, so each group's goroutines share all their labels
+	GroupByLabels bool `json:"group_by_labels,omitempty"`
+	// Partial is set when the dump was cut short (e.g. the connection dropped
+	// mid-response), so counts may be lower than on the host
+	Partial bool `json:"partial,omitempty"`
+	// Truncated is set when goru stopped reading the dump at a configured
+	// limit, so the rest of the goroutines are missing
+	Truncated bool `json:"truncated,omitempty"`
+	// Warnings are what the parser had to skip or guess at, such as a final
+	// goroutine cut off mid-stack or header lines it couldn't read. A
+	// snapshot with warnings may be missing goroutines or frames.
+	Warnings []string `json:"warnings,omitempty"`
+	// MemStats holds key runtime memory figures scraped alongside the dump,
+	// if enabled and available
+	MemStats *MemStats `json:"memstats,omitempty"`
+}
+
+// MemStats is the subset of runtime.MemStats shown next to goroutine counts
+type MemStats struct {
+	HeapAlloc uint64 `json:"heap_alloc"`
+	Sys       uint64 `json:"sys"`
+	NumGC     uint32 `json:"num_gc"`
 }
 
 func NewSnapshot(host string) *Snapshot {
@@ -65,29 +307,131 @@ func NewSnapshot(host string) *Snapshot {
 	}
 }
 
-func (s *Snapshot) AddGoroutine(state GoroutineState, trace StackTrace, waitDuration string, createdBy *StackFrame) {
+// AddGoroutine merges goroutine number id into the group matching its state
+// and trace. The number is recorded on the group but doesn't affect grouping.
+func (s *Snapshot) AddGoroutine(id uint64, state GoroutineState, trace StackTrace, waitDuration string, createdBy *StackFrame) {
+	s.AddLabeledGoroutine(id, state, trace, waitDuration, createdBy, 0, nil)
+}
+
+// AddLabeledGoroutine is AddGoroutine for a goroutine carrying pprof labels
+// and the number of the parent goroutine that spawned it (0 if unknown). Like
+// the goroutine's own number, the parent is recorded but doesn't affect
+// grouping. It returns the group the goroutine was merged into.
+func (s *Snapshot) AddLabeledGoroutine(id uint64, state GoroutineState, trace StackTrace, waitDuration string, createdBy *StackFrame, parent uint64, labels map[string]string) *Group {
 	g := &Group{
-		State:     state,
-		Count:     1,
-		Trace:     trace,
-		CreatedBy: createdBy,
+		State:              state,
+		Count:              1,
+		Trace:              trace,
+		CreatedBy:          createdBy,
+		CreatedByGoroutine: parent,
+		IDs:                []uint64{id},
+		Labels:             maps.Clone(labels),
 	}
 	if waitDuration != "" {
+		wait, _ := ParseWait(waitDuration)
 		g.WaitDurations = []string{waitDuration}
+		g.Waits = []time.Duration{wait}
 	}
 
-	g.ID = g.GenerateID()
+	g.ID = s.groupID(g)
 
 	if existing, ok := s.Groups[g.ID]; ok {
 		existing.Count++
+		existing.IDs = append(existing.IDs, id)
+		if existing.CreatedByGoroutine != parent {
+			existing.CreatedByGoroutine = 0
+		}
 		if waitDuration != "" {
-			existing.WaitDurations = append(existing.WaitDurations, waitDuration)
+			existing.WaitDurations = append(existing.WaitDurations, g.WaitDurations...)
+			existing.Waits = append(existing.Waits, g.Waits...)
 		}
+		existing.Labels = commonLabels(existing.Labels, labels)
+		return existing
+	}
+	s.Groups[g.ID] = g
+	return g
+}
+
+// AddGroup merges count goroutines sharing a state and trace at once, for
+// formats that report aggregated counts instead of individual goroutines
+func (s *Snapshot) AddGroup(count int, state GoroutineState, trace StackTrace) {
+	s.AddLabeledGroup(count, state, trace, nil)
+}
+
+// AddLabeledGroup is AddGroup for goroutines carrying pprof labels
+func (s *Snapshot) AddLabeledGroup(count int, state GoroutineState, trace StackTrace, labels map[string]string) {
+	g := &Group{
+		State:  state,
+		Count:  count,
+		Trace:  trace,
+		Labels: maps.Clone(labels),
+	}
+	g.ID = s.groupID(g)
+
+	if existing, ok := s.Groups[g.ID]; ok {
+		existing.Count += count
+		existing.Labels = commonLabels(existing.Labels, labels)
 	} else {
 		s.Groups[g.ID] = g
 	}
 }
 
+// groupID returns the ID g is grouped under in the snapshot
+func (s *Snapshot) groupID(g *Group) GroupID {
+	id := g.GenerateIDDepth(s.GroupDepth)
+	if s.NormalizeGenerics {
+		normalized := *g
+		normalized.Trace = g.Trace.WithoutTypeArgs()
+		id = normalized.GenerateIDDepth(s.GroupDepth)
+	}
+	if !s.GroupByLabels || len(g.Labels) == 0 {
+		return id
+	}
+
+	h := sha256.New()
+	h.Write([]byte(id))
+	for _, key := range slices.Sorted(maps.Keys(g.Labels)) {
+		fmt.Fprintf(h, "\x00%s=%s", key, g.Labels[key])
+	}
+	return GroupID(hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+// commonLabels returns the labels a and b share, reusing a
+func commonLabels(a, b map[string]string) map[string]string {
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			delete(a, key)
+		}
+	}
+	if len(a) == 0 {
+		return nil
+	}
+	return a
+}
+
+// Hash returns a digest of what the snapshot shows: its groups with their
+// counts, wait durations and labels, its memory stats and whether it is
+// complete. Dumps of an idle host hash the same from one collection to the
+// next, whenever they were taken.
+func (s *Snapshot) Hash() string {
+	ids := make([]GroupID, 0, len(s.Groups))
+	for id := range s.Groups {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	h := sha256.New()
+	for _, id := range ids {
+		g := s.Groups[id]
+		fmt.Fprintf(h, "%s %d %v %v\n", id, g.Count, g.WaitDurations, g.Labels)
+	}
+	if s.MemStats != nil {
+		fmt.Fprintf(h, "memstats %d %d %d\n", s.MemStats.HeapAlloc, s.MemStats.Sys, s.MemStats.NumGC)
+	}
+	fmt.Fprintf(h, "partial %t truncated %t warnings %q\n", s.Partial, s.Truncated, s.Warnings)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func (s *Snapshot) TotalGoroutines() int {
 	total := 0
 	for _, g := range s.Groups {
@@ -96,6 +440,230 @@ func (s *Snapshot) TotalGoroutines() int {
 	return total
 }
 
+// StateCounts sums goroutines by state. States without goroutines are
+// absent.
+func (s *Snapshot) StateCounts() map[GoroutineState]int {
+	counts := make(map[GoroutineState]int)
+	for _, g := range s.Groups {
+		counts[g.State] += g.Count
+	}
+	return counts
+}
+
+// StateCount returns the number of goroutines in the given state
+func (s *Snapshot) StateCount(state GoroutineState) int {
+	count := 0
+	for _, g := range s.Groups {
+		if g.State == state {
+			count += g.Count
+		}
+	}
+	return count
+}
+
+// MergeSnapshots combines snapshots from several hosts into one under the
+// given host name, taken at the latest of their times. Groups with the same
+// ID have their counts summed and wait durations concatenated. Goroutine IDs
+// are dropped, as they only mean something within one process. It returns
+// nil if there are no snapshots to merge.
+func MergeSnapshots(host string, snapshots ...*Snapshot) *Snapshot {
+	var merged *Snapshot
+	for _, snapshot := range snapshots {
+		if snapshot == nil {
+			continue
+		}
+		if merged == nil {
+			merged = NewSnapshot(host)
+			merged.TakenAt = snapshot.TakenAt
+			merged.GroupDepth = snapshot.GroupDepth
+			merged.NormalizeGenerics = snapshot.NormalizeGenerics
+			merged.GroupByLabels = snapshot.GroupByLabels
+		}
+		if snapshot.TakenAt.After(merged.TakenAt) {
+			merged.TakenAt = snapshot.TakenAt
+		}
+		merged.Partial = merged.Partial || snapshot.Partial
+		merged.Truncated = merged.Truncated || snapshot.Truncated
+		for _, warning := range snapshot.Warnings {
+			merged.Warnings = append(merged.Warnings, snapshot.Host+": "+warning)
+		}
+
+		for id, g := range snapshot.Groups {
+			existing, ok := merged.Groups[id]
+			if !ok {
+				// Copy so the source snapshots are left untouched
+				groupCopy := *g
+				groupCopy.IDs = nil
+				groupCopy.CreatedByGoroutine = 0 // numbers are per host
+				groupCopy.WaitDurations = append([]string(nil), g.WaitDurations...)
+				groupCopy.Waits = append([]time.Duration(nil), g.WaitTimes()...)
+				groupCopy.Labels = maps.Clone(g.Labels)
+				merged.Groups[id] = &groupCopy
+				continue
+			}
+			existing.Count += g.Count
+			existing.Labels = commonLabels(existing.Labels, g.Labels)
+			existing.WaitDurations = append(existing.WaitDurations, g.WaitDurations...)
+			existing.Waits = append(existing.Waits, g.WaitTimes()...)
+		}
+	}
+	return merged
+}
+
+// CreatedBySite is a goroutine creation site with every group it spawned
+type CreatedBySite struct {
+	Func   string   `json:"func"`
+	Count  int      `json:"count"`
+	Groups []*Group `json:"groups"`
+}
+
+// AggregateCreatedBy sums group counts by CreatedBy function across the given
+// snapshots. Sites are ordered by total count, largest first, and each site's
+// groups likewise. Groups without a creator (e.g. main) are skipped.
+func AggregateCreatedBy(snapshots ...*Snapshot) []*CreatedBySite {
+	byFunc := make(map[string]*CreatedBySite)
+	for _, snapshot := range snapshots {
+		if snapshot == nil {
+			continue
+		}
+		for _, g := range snapshot.Groups {
+			if g.CreatedBy == nil {
+				continue
+			}
+			site, ok := byFunc[g.CreatedBy.Func]
+			if !ok {
+				site = &CreatedBySite{Func: g.CreatedBy.Func}
+				byFunc[g.CreatedBy.Func] = site
+			}
+			site.Count += g.Count
+			site.Groups = append(site.Groups, g)
+		}
+	}
+
+	sites := make([]*CreatedBySite, 0, len(byFunc))
+	for _, site := range byFunc {
+		sort.Slice(site.Groups, func(i, j int) bool {
+			if site.Groups[i].Count != site.Groups[j].Count {
+				return site.Groups[i].Count > site.Groups[j].Count
+			}
+			return site.Groups[i].ID < site.Groups[j].ID
+		})
+		sites = append(sites, site)
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Count != sites[j].Count {
+			return sites[i].Count > sites[j].Count
+		}
+		return sites[i].Func < sites[j].Func
+	})
+	return sites
+}
+
+// PackageCount is the number of goroutines whose chosen frame is in a package
+type PackageCount struct {
+	Package string `json:"package"`
+	Count   int    `json:"count"`
+	Groups  int    `json:"groups"`
+}
+
+// AggregatePackages sums group counts by the package of each group's
+// TopFrame, or AppFrame when appFrames is set, across the given snapshots.
+// Packages are ordered by total count, largest first.
+func AggregatePackages(appFrames bool, snapshots ...*Snapshot) []*PackageCount {
+	byPackage := make(map[string]*PackageCount)
+	for _, snapshot := range snapshots {
+		if snapshot == nil {
+			continue
+		}
+		for _, g := range snapshot.Groups {
+			frame := g.Trace.TopFrame()
+			if appFrames {
+				frame = g.Trace.AppFrame()
+			}
+			if frame == nil {
+				continue
+			}
+			pkg := FramePackage(frame.Func)
+			pc, ok := byPackage[pkg]
+			if !ok {
+				pc = &PackageCount{Package: pkg}
+				byPackage[pkg] = pc
+			}
+			pc.Count += g.Count
+			pc.Groups++
+		}
+	}
+
+	packages := make([]*PackageCount, 0, len(byPackage))
+	for _, pc := range byPackage {
+		packages = append(packages, pc)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Count != packages[j].Count {
+			return packages[i].Count > packages[j].Count
+		}
+		return packages[i].Package < packages[j].Package
+	})
+	return packages
+}
+
+// LabelCount is the number of goroutines carrying a value of a label
+type LabelCount struct {
+	Value  string `json:"value"` // empty for goroutines without the label
+	Count  int    `json:"count"`
+	Groups int    `json:"groups"`
+}
+
+// LabelKeys returns the pprof label keys of the groups across the given
+// snapshots, in order
+func LabelKeys(snapshots ...*Snapshot) []string {
+	keys := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		if snapshot == nil {
+			continue
+		}
+		for _, g := range snapshot.Groups {
+			for key := range g.Labels {
+				keys[key] = true
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(keys))
+}
+
+// AggregateLabel sums group counts by the value of the label key across the
+// given snapshots. Values are ordered by total count, largest first.
+func AggregateLabel(key string, snapshots ...*Snapshot) []*LabelCount {
+	byValue := make(map[string]*LabelCount)
+	for _, snapshot := range snapshots {
+		if snapshot == nil {
+			continue
+		}
+		for _, g := range snapshot.Groups {
+			value := g.Labels[key]
+			lc, ok := byValue[value]
+			if !ok {
+				lc = &LabelCount{Value: value}
+				byValue[value] = lc
+			}
+			lc.Count += g.Count
+			lc.Groups++
+		}
+	}
+
+	values := make([]*LabelCount, 0, len(byValue))
+	for _, lc := range byValue {
+		values = append(values, lc)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values
+}
+
 type ChangeType string
 
 const (
@@ -104,6 +672,13 @@ const (
 	ChangeUpdated ChangeType = "updated"
 )
 
+// Transition links a removed group to an added group with the same trace but
+// a different state, i.e. goroutines that moved from one state to another
+type Transition struct {
+	From *Group `json:"from"` // group in the old snapshot
+	To   *Group `json:"to"`   // group in the new snapshot
+}
+
 type Change struct {
 	Type       ChangeType `json:"type"`
 	Group      *Group     `json:"group"`
@@ -116,6 +691,10 @@ type ChangeSet struct {
 	Added     []*Group        `json:"added,omitempty"`
 	Removed   []*Group        `json:"removed,omitempty"`
 	Updated   map[GroupID]int `json:"updated,omitempty"`
+
+	// Transitioned pairs up entries of Removed and Added that differ only in
+	// state. Those groups are still listed in Removed and Added.
+	Transitioned []Transition `json:"transitioned,omitempty"`
 }
 
 func NewChangeSet(host string) *ChangeSet {
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"slices"
 	"testing"
 	"time"
 )
@@ -58,10 +59,10 @@ func TestSnapshotAddGoroutine(t *testing.T) {
 	trace1 := StackTrace{{Func: "main.worker"}}
 	trace2 := StackTrace{{Func: "main.handler"}}
 
-	s.AddGoroutine(StateRunning, trace1, "")
-	s.AddGoroutine(StateRunning, trace1, "")
-	s.AddGoroutine(StateWaiting, trace1, "5m")
-	s.AddGoroutine(StateWaiting, trace2, "10s")
+	s.AddGoroutine(1, StateRunning, trace1, "", nil)
+	s.AddGoroutine(7, StateRunning, trace1, "", nil)
+	s.AddGoroutine(8, StateWaiting, trace1, "5m", nil)
+	s.AddGoroutine(9, StateWaiting, trace2, "10s", nil)
 
 	if len(s.Groups) != 3 {
 		t.Errorf("Expected 3 groups, got %d", len(s.Groups))
@@ -83,19 +84,44 @@ func TestSnapshotAddGoroutine(t *testing.T) {
 		t.Errorf("Expected count 2, got %d", runningWorker.Count)
 	}
 
+	if len(runningWorker.IDs) != 2 || runningWorker.IDs[0] != 1 || runningWorker.IDs[1] != 7 {
+		t.Errorf("Expected goroutine IDs [1 7], got %v", runningWorker.IDs)
+	}
+
 	total := s.TotalGoroutines()
 	if total != 4 {
 		t.Errorf("Expected total 4 goroutines, got %d", total)
 	}
 }
 
+func TestSnapshotStateCounts(t *testing.T) {
+	s := NewSnapshot("test-host")
+
+	s.AddGoroutine(1, StateRunning, StackTrace{{Func: "main.main"}}, "", nil)
+	s.AddGoroutine(2, StateWaiting, StackTrace{{Func: "main.worker"}}, "", nil)
+	s.AddGoroutine(3, StateWaiting, StackTrace{{Func: "main.worker"}}, "", nil)
+	s.AddGoroutine(4, StateWaiting, StackTrace{{Func: "main.handler"}}, "", nil)
+
+	counts := s.StateCounts()
+	if len(counts) != 2 || counts[StateRunning] != 1 || counts[StateWaiting] != 3 {
+		t.Errorf("StateCounts() = %v, want running:1 waiting:3", counts)
+	}
+
+	if got := s.StateCount(StateWaiting); got != 3 {
+		t.Errorf("StateCount(waiting) = %d, want 3", got)
+	}
+	if got := s.StateCount(StateBlocked); got != 0 {
+		t.Errorf("StateCount(blocked) = %d, want 0", got)
+	}
+}
+
 func TestSnapshotWaitDurations(t *testing.T) {
 	s := NewSnapshot("test-host")
 	trace := StackTrace{{Func: "main.waiter"}}
 
-	s.AddGoroutine(StateWaiting, trace, "1m")
-	s.AddGoroutine(StateWaiting, trace, "2m")
-	s.AddGoroutine(StateWaiting, trace, "")
+	s.AddGoroutine(1, StateWaiting, trace, "1m", nil)
+	s.AddGoroutine(2, StateWaiting, trace, "2m", nil)
+	s.AddGoroutine(3, StateWaiting, trace, "", nil)
 
 	var group *Group
 	for _, g := range s.Groups {
@@ -118,6 +144,63 @@ func TestSnapshotWaitDurations(t *testing.T) {
 	}
 }
 
+func TestParseWait(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"1 minute", time.Minute, true},
+		{"5 minutes", 5 * time.Minute, true},
+		{"2 hours", 2 * time.Hour, true},
+		{"90 seconds", 90 * time.Second, true},
+		{"5m", 5 * time.Minute, true},
+		{"5 fortnights", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, ok := ParseWait(tt.input)
+			if got != tt.expected || ok != tt.ok {
+				t.Errorf("ParseWait(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGroupWaitTimes(t *testing.T) {
+	s := NewSnapshot("test-host")
+	trace := StackTrace{{Func: "main.waiter"}}
+
+	// Mixed units in one group
+	s.AddGoroutine(1, StateWaiting, trace, "2 hours", nil)
+	s.AddGoroutine(2, StateWaiting, trace, "90 seconds", nil)
+	s.AddGoroutine(3, StateWaiting, trace, "5 minutes", nil)
+
+	var group *Group
+	for _, g := range s.Groups {
+		group = g
+	}
+
+	want := []time.Duration{2 * time.Hour, 90 * time.Second, 5 * time.Minute}
+	waits := group.WaitTimes()
+	if len(waits) != len(want) {
+		t.Fatalf("Expected %d waits, got %d", len(want), len(waits))
+	}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Errorf("WaitTimes()[%d] = %v, want %v", i, waits[i], want[i])
+		}
+	}
+
+	// Groups without parsed waits fall back to their raw strings
+	raw := &Group{WaitDurations: []string{"3 hours"}}
+	if got := raw.WaitTimes(); len(got) != 1 || got[0] != 3*time.Hour {
+		t.Errorf("WaitTimes() = %v, want [3h0m0s]", got)
+	}
+}
+
 func TestChangeSetIsEmpty(t *testing.T) {
 	c := NewChangeSet("test-host")
 
@@ -158,3 +241,320 @@ func TestNewSnapshot(t *testing.T) {
 		t.Error("Groups map should be empty")
 	}
 }
+
+func TestMergeSnapshots(t *testing.T) {
+	trace := StackTrace{{Func: "main.worker"}}
+
+	a := NewSnapshot("host1")
+	a.AddGoroutine(1, StateWaiting, trace, "5 minutes", nil)
+	a.AddGoroutine(2, StateWaiting, trace, "", nil)
+	a.AddGoroutine(3, StateRunning, StackTrace{{Func: "main.main"}}, "", nil)
+
+	b := NewSnapshot("host2")
+	b.AddGoroutine(1, StateWaiting, trace, "1 minutes", nil)
+	b.Partial = true
+	b.Warnings = []string{"1 stack frame line(s) couldn't be read"}
+
+	merged := MergeSnapshots("all", a, nil, b)
+	if merged.Host != "all" || !merged.Partial {
+		t.Errorf("Expected partial snapshot for host all, got %q partial=%v", merged.Host, merged.Partial)
+	}
+	if len(merged.Warnings) != 1 || merged.Warnings[0] != "host2: 1 stack frame line(s) couldn't be read" {
+		t.Errorf("Expected host2's warning, got %q", merged.Warnings)
+	}
+	if len(merged.Groups) != 2 || merged.TotalGoroutines() != 4 {
+		t.Fatalf("Expected 2 groups and 4 goroutines, got %d and %d", len(merged.Groups), merged.TotalGoroutines())
+	}
+
+	worker := merged.Groups[(&Group{State: StateWaiting, Trace: trace}).GenerateID()]
+	if worker == nil || worker.Count != 3 {
+		t.Fatalf("Expected merged worker group with count 3, got %+v", worker)
+	}
+	if len(worker.WaitDurations) != 2 || len(worker.Waits) != 2 || worker.IDs != nil {
+		t.Errorf("Expected 2 waits and no IDs, got %v, %v, %v", worker.WaitDurations, worker.Waits, worker.IDs)
+	}
+
+	// The source snapshots are untouched
+	if a.Groups[worker.ID].Count != 2 || len(a.Groups[worker.ID].WaitDurations) != 1 {
+		t.Errorf("Merging modified the source group: %+v", a.Groups[worker.ID])
+	}
+
+	if MergeSnapshots("all", nil) != nil {
+		t.Error("Expected nil when merging no snapshots")
+	}
+}
+
+func TestAggregateCreatedBy(t *testing.T) {
+	spawner := &StackFrame{Func: "main.startWorkers"}
+	other := &StackFrame{Func: "net/http.(*Server).Serve"}
+
+	s1 := NewSnapshot("host1")
+	s1.Groups["a"] = &Group{ID: "a", Count: 3, CreatedBy: spawner}
+	s1.Groups["b"] = &Group{ID: "b", Count: 5, CreatedBy: spawner}
+	s1.Groups["c"] = &Group{ID: "c", Count: 4, CreatedBy: other}
+	s1.Groups["d"] = &Group{ID: "d", Count: 100} // no creator
+
+	s2 := NewSnapshot("host2")
+	s2.Groups["e"] = &Group{ID: "e", Count: 2, CreatedBy: other}
+
+	sites := AggregateCreatedBy(s1, nil)
+	if len(sites) != 2 {
+		t.Fatalf("Expected 2 sites, got %d", len(sites))
+	}
+	if sites[0].Func != "main.startWorkers" || sites[0].Count != 8 {
+		t.Errorf("Expected main.startWorkers with 8 first, got %s with %d", sites[0].Func, sites[0].Count)
+	}
+	if len(sites[0].Groups) != 2 || sites[0].Groups[0].ID != "b" {
+		t.Errorf("Expected site groups ordered by count, got %v", sites[0].Groups)
+	}
+
+	// Across hosts the counts add up
+	sites = AggregateCreatedBy(s1, s2)
+	if sites[1].Func != "net/http.(*Server).Serve" || sites[1].Count != 6 {
+		t.Errorf("Expected net/http.(*Server).Serve with 6, got %s with %d", sites[1].Func, sites[1].Count)
+	}
+}
+
+func TestFramePackage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"main.main", "main"},
+		{"runtime.gopark", "runtime"},
+		{"net/http.(*conn).serve", "net/http"},
+		{"database/sql.(*DB).connectionOpener", "database/sql"},
+		{"github.com/org/project/worker.(*Pool).run", "github.com/org/project/worker"},
+		{"github.com/org/project/worker.New.func1", "github.com/org/project/worker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := FramePackage(tt.input); got != tt.expected {
+				t.Errorf("FramePackage(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsAppPackage(t *testing.T) {
+	tests := []struct {
+		pkg      string
+		prefixes []string
+		expected bool
+	}{
+		{"main", nil, true},
+		{"runtime", nil, false},
+		{"net/http", nil, false},
+		{"github.com/org/project/worker", nil, true},
+		{"github.com/org/project/worker", []string{"github.com/org/project"}, true},
+		{"github.com/other/lib", []string{"github.com/org/project"}, false},
+		{"main", []string{"github.com/org/project", "main"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := IsAppPackage(tt.pkg, tt.prefixes); got != tt.expected {
+			t.Errorf("IsAppPackage(%q, %v) = %v, want %v", tt.pkg, tt.prefixes, got, tt.expected)
+		}
+	}
+}
+
+func TestStripTypeArgs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"main.main", "main.main"},
+		{"foo.Map[go.shape.int].func1", "foo.Map[...].func1"},
+		{"foo.Map[go.shape.string].func1", "foo.Map[...].func1"},
+		{"foo.Map[...].func1", "foo.Map[...].func1"},
+		{"main.(*List[go.shape.int]).Push", "main.(*List[...]).Push"},
+		{"foo.Pair[go.shape.[]uint8,go.shape.map[string]int]", "foo.Pair[...]"},
+		{"foo.Apply[go.shape.struct { X []int }].func2", "foo.Apply[...].func2"},
+		{"foo.Outer[go.shape.int].Inner[go.shape.bool]", "foo.Outer[...].Inner[...]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := StripTypeArgs(tt.input); got != tt.expected {
+				t.Errorf("StripTypeArgs(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSnapshotNormalizeGenerics(t *testing.T) {
+	intTrace := StackTrace{{Func: "foo.Map[go.shape.int].func1", File: "/app/foo.go", Line: 12}}
+	stringTrace := StackTrace{{Func: "foo.Map[go.shape.string].func1", File: "/app/foo.go", Line: 12}}
+
+	s := NewSnapshot("host")
+	s.AddGoroutine(1, StateWaiting, intTrace, "", nil)
+	s.AddGoroutine(2, StateWaiting, stringTrace, "", nil)
+	if len(s.Groups) != 2 {
+		t.Errorf("Expected instantiations in 2 groups by default, got %d", len(s.Groups))
+	}
+
+	s = NewSnapshot("host")
+	s.NormalizeGenerics = true
+	s.AddGoroutine(1, StateWaiting, intTrace, "", nil)
+	s.AddGoroutine(2, StateWaiting, stringTrace, "", nil)
+	s.AddGroup(3, StateWaiting, stringTrace)
+	if len(s.Groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(s.Groups))
+	}
+	for _, g := range s.Groups {
+		if g.Count != 5 {
+			t.Errorf("Count = %d, want 5", g.Count)
+		}
+		// The trace keeps the names as dumped
+		if g.Trace[0].Func != "foo.Map[go.shape.int].func1" {
+			t.Errorf("Trace = %v, want the first goroutine's full names", g.Trace)
+		}
+	}
+}
+
+func TestSnapshotLabels(t *testing.T) {
+	trace := StackTrace{{Func: "main.handle", File: "/app/main.go", Line: 20}}
+	acme := map[string]string{"tenant": "acme", "handler": "/api"}
+	globex := map[string]string{"tenant": "globex", "handler": "/api"}
+
+	// By default the group keeps only the labels all its goroutines share
+	s := NewSnapshot("host")
+	s.AddLabeledGoroutine(1, StateWaiting, trace, "", nil, 0, acme)
+	s.AddLabeledGoroutine(2, StateWaiting, trace, "", nil, 0, globex)
+	if len(s.Groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(s.Groups))
+	}
+	for _, g := range s.Groups {
+		if len(g.Labels) != 1 || g.Labels["handler"] != "/api" {
+			t.Errorf("Labels = %v, want only handler=/api", g.Labels)
+		}
+	}
+	if acme["tenant"] != "acme" {
+		t.Error("Expected the goroutine's labels to be left alone")
+	}
+
+	// Grouping by labels keeps tenants apart, and unlabeled goroutines keep
+	// the plain trace ID
+	s = NewSnapshot("host")
+	s.GroupByLabels = true
+	s.AddLabeledGoroutine(1, StateWaiting, trace, "", nil, 0, acme)
+	s.AddLabeledGoroutine(2, StateWaiting, trace, "", nil, 0, globex)
+	s.AddLabeledGroup(2, StateWaiting, trace, acme)
+	unlabeled := s.AddLabeledGoroutine(3, StateWaiting, trace, "", nil, 0, nil)
+	if len(s.Groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %d", len(s.Groups))
+	}
+	if unlabeled.ID != (&Group{State: StateWaiting, Trace: trace}).GenerateID() {
+		t.Errorf("Expected the unlabeled group to keep its trace ID, got %s", unlabeled.ID)
+	}
+
+	if keys := LabelKeys(s); !slices.Equal(keys, []string{"handler", "tenant"}) {
+		t.Errorf("LabelKeys = %v, want [handler tenant]", keys)
+	}
+	tenants := AggregateLabel("tenant", s)
+	want := []LabelCount{{"acme", 3, 1}, {"", 1, 1}, {"globex", 1, 1}}
+	if len(tenants) != len(want) {
+		t.Fatalf("Expected %d tenant values, got %d", len(want), len(tenants))
+	}
+	for i, lc := range tenants {
+		if *lc != want[i] {
+			t.Errorf("Value %d = %+v, want %+v", i, *lc, want[i])
+		}
+	}
+}
+
+func TestPrimaryFrame(t *testing.T) {
+	trace := StackTrace{
+		{Func: "runtime.gopark"},
+		{Func: "runtime.chanrecv1"},
+		{Func: "sync.(*WaitGroup).Wait"},
+		{Func: "main.run"},
+	}
+	tests := []struct {
+		skip     []string
+		expected string
+	}{
+		{DefaultSkipPackages, "sync.(*WaitGroup).Wait"},
+		{[]string{"runtime", "sync"}, "main.run"},
+		{nil, "runtime.gopark"},
+		{[]string{"runtime", "sync", "main"}, "runtime.gopark"},
+	}
+	for _, tt := range tests {
+		if got := trace.PrimaryFrame(tt.skip); got == nil || got.Func != tt.expected {
+			t.Errorf("PrimaryFrame(%v) = %v, want %s", tt.skip, got, tt.expected)
+		}
+	}
+	if got := (StackTrace{}).PrimaryFrame(DefaultSkipPackages); got != nil {
+		t.Errorf("PrimaryFrame of an empty trace = %v, want nil", got)
+	}
+}
+
+func TestAggregatePackages(t *testing.T) {
+	s := NewSnapshot("host1")
+	s.Groups["a"] = &Group{ID: "a", Count: 4, Trace: StackTrace{
+		{Func: "runtime.gopark"},
+		{Func: "net/http.(*conn).serve"},
+		{Func: "github.com/org/app/api.(*Server).handle"},
+	}}
+	s.Groups["b"] = &Group{ID: "b", Count: 3, Trace: StackTrace{
+		{Func: "net.(*netFD).Read"},
+		{Func: "net/http.(*connReader).Read"},
+	}}
+	s.Groups["c"] = &Group{ID: "c", Count: 2, Trace: StackTrace{
+		{Func: "github.com/org/app/worker.run"},
+	}}
+
+	packages := AggregatePackages(false, s)
+	if len(packages) != 3 {
+		t.Fatalf("Expected 3 packages, got %d", len(packages))
+	}
+	if packages[0].Package != "net/http" || packages[0].Count != 4 {
+		t.Errorf("Expected net/http with 4 first, got %s with %d", packages[0].Package, packages[0].Count)
+	}
+
+	// By application frame, the stdlib-only stack falls back to its top frame
+	packages = AggregatePackages(true, s)
+	want := map[string]int{"github.com/org/app/api": 4, "net": 3, "github.com/org/app/worker": 2}
+	for _, pc := range packages {
+		if want[pc.Package] != pc.Count {
+			t.Errorf("Package %s count = %d, want %d", pc.Package, pc.Count, want[pc.Package])
+		}
+	}
+}
+
+func TestSnapshotHash(t *testing.T) {
+	build := func(taken time.Time, count int, wait string) *Snapshot {
+		s := NewSnapshot("host")
+		s.TakenAt = taken
+		trace := StackTrace{{Func: "main.worker", File: "/app/main.go", Line: 10}}
+		for i := range count {
+			s.AddGoroutine(uint64(i+1), StateWaiting, trace, wait, nil)
+		}
+		s.AddGoroutine(100, StateRunning, StackTrace{{Func: "main.main"}}, "", nil)
+		return s
+	}
+	now := time.Now()
+	base := build(now, 3, "5 minutes")
+
+	// The time taken and the goroutine numbers don't count
+	if base.Hash() != build(now.Add(time.Minute), 3, "5 minutes").Hash() {
+		t.Error("Expected snapshots differing only in time to hash the same")
+	}
+
+	tests := []struct {
+		name    string
+		changed *Snapshot
+	}{
+		{"count", build(now, 4, "5 minutes")},
+		{"wait", build(now, 3, "6 minutes")},
+		{"partial", func() *Snapshot { s := build(now, 3, "5 minutes"); s.Partial = true; return s }()},
+		{"warnings", func() *Snapshot { s := build(now, 3, "5 minutes"); s.Warnings = []string{"cut off"}; return s }()},
+		{"memstats", func() *Snapshot { s := build(now, 3, "5 minutes"); s.MemStats = &MemStats{NumGC: 1}; return s }()},
+	}
+	for _, tt := range tests {
+		if base.Hash() == tt.changed.Hash() {
+			t.Errorf("Expected a different %s to change the hash", tt.name)
+		}
+	}
+}
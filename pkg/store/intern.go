@@ -0,0 +1,136 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// minPrune is how many traces the interner holds before it starts dropping
+// the ones no stored snapshot uses any more
+const minPrune = 1024
+
+// interner hands out one shared copy of every distinct stack trace, so the
+// groups of many hosts running the same binary, and of a host's history,
+// don't each hold their own. Frames of different traces share their function
+// and file strings too. Groups aren't modified once parsed, which is what
+// makes the sharing safe.
+type interner struct {
+	mu      sync.Mutex
+	traces  map[string]model.StackTrace // keyed by StackTrace.String
+	strings map[string]string
+	live    int   // traces held after the last prune
+	bytes   int64 // estimated size of the traces held
+	lookups uint64
+	hits    uint64
+}
+
+func newInterner() *interner {
+	return &interner{
+		traces:  make(map[string]model.StackTrace),
+		strings: make(map[string]string),
+	}
+}
+
+// intern replaces the trace of every group in snapshot with the shared copy
+// of an equal trace, making its own copy the shared one when there's none.
+// It must run before the snapshot is handed to readers.
+func (in *interner) intern(snapshot *model.Snapshot) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	for _, g := range snapshot.Groups {
+		if len(g.Trace) == 0 {
+			continue
+		}
+		in.lookups++
+		key := g.Trace.String()
+		if trace, ok := in.traces[key]; ok {
+			in.hits++
+			g.Trace = trace
+			continue
+		}
+		for i := range g.Trace {
+			g.Trace[i].Func = in.internString(g.Trace[i].Func)
+			g.Trace[i].File = in.internString(g.Trace[i].File)
+		}
+		in.traces[key] = g.Trace
+		in.bytes += traceSize(g.Trace)
+	}
+}
+
+func (in *interner) internString(s string) string {
+	if shared, ok := in.strings[s]; ok {
+		return shared
+	}
+	in.strings[s] = s
+	return s
+}
+
+// prune drops the traces none of the snapshots in data use once the interner
+// holds twice as many as after the last prune, so traces of goroutines long
+// gone don't stay in memory. It's called with the store's write lock held.
+func (in *interner) prune(data *storeData) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if len(in.traces) < minPrune || len(in.traces) < 2*in.live {
+		return
+	}
+
+	// Shared traces are told apart by their backing array
+	used := make(map[*model.StackFrame]bool)
+	mark := func(snapshot *model.Snapshot) {
+		if snapshot == nil {
+			return
+		}
+		for _, g := range snapshot.Groups {
+			if len(g.Trace) > 0 {
+				used[&g.Trace[0]] = true
+			}
+		}
+	}
+	for _, snapshot := range data.snapshots {
+		mark(snapshot)
+	}
+	for _, snapshot := range data.baselines {
+		mark(snapshot)
+	}
+	for _, snapshot := range data.pinned {
+		mark(snapshot)
+	}
+	for _, history := range data.history {
+		for _, snapshot := range history {
+			mark(snapshot)
+		}
+	}
+
+	in.strings = make(map[string]string)
+	in.bytes = 0
+	for key, trace := range in.traces {
+		if !used[&trace[0]] {
+			delete(in.traces, key)
+			continue
+		}
+		in.bytes += traceSize(trace)
+		for _, frame := range trace {
+			in.strings[frame.Func] = frame.Func
+			in.strings[frame.File] = frame.File
+		}
+	}
+	in.live = len(in.traces)
+}
+
+// size returns the estimated size of the traces held
+func (in *interner) size() int64 {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.bytes
+}
+
+// counts returns how many traces are held, looked up and found shared
+func (in *interner) counts() (traces int, lookups, hits uint64) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return len(in.traces), in.lookups, in.hits
+}
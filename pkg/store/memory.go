@@ -0,0 +1,229 @@
+package store
+
+import (
+	"slices"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// mapEntryBytes is roughly what a map entry costs beyond its key and value
+const mapEntryBytes = 48
+
+// Sizes of the fixed parts of what a snapshot holds, for estimating its size
+var (
+	snapshotBytes = int64(unsafe.Sizeof(model.Snapshot{}))
+	groupBytes    = int64(unsafe.Sizeof(model.Group{})) + mapEntryBytes
+	frameBytes    = int64(unsafe.Sizeof(model.StackFrame{}))
+	stringBytes   = int64(unsafe.Sizeof(""))
+)
+
+// snapshotSize estimates the bytes a snapshot holds on the heap. Trace
+// frames are left out unless traces is set, for when the interner holds
+// them instead.
+func snapshotSize(snapshot *model.Snapshot, traces bool) int64 {
+	size := snapshotBytes + int64(len(snapshot.Host))
+	for _, warning := range snapshot.Warnings {
+		size += stringBytes + int64(len(warning))
+	}
+	if snapshot.MemStats != nil {
+		size += int64(unsafe.Sizeof(model.MemStats{}))
+	}
+	for _, g := range snapshot.Groups {
+		size += groupBytes + int64(len(g.ID)) + int64(len(g.BlockReason))
+		for _, wait := range g.WaitDurations {
+			size += stringBytes + int64(len(wait))
+		}
+		size += 8*int64(len(g.Waits)) + 8*int64(len(g.IDs))
+		for k, v := range g.Labels {
+			size += mapEntryBytes + int64(len(k)) + int64(len(v))
+		}
+		if g.CreatedBy != nil {
+			size += frameSize(*g.CreatedBy)
+		}
+		if traces {
+			size += traceSize(g.Trace)
+		}
+	}
+	return size
+}
+
+func traceSize(trace model.StackTrace) int64 {
+	var size int64
+	for _, frame := range trace {
+		size += frameSize(frame)
+	}
+	return size
+}
+
+func frameSize(frame model.StackFrame) int64 {
+	return frameBytes + int64(len(frame.Func)) + int64(len(frame.File)) + int64(len(frame.Args))
+}
+
+// SetMaxMemory sets a soft budget in bytes for the snapshots the store
+// keeps, 0 for none. Over it, the oldest history entries of all hosts are
+// dropped first, but never a host's latest snapshot or its baselines, so the
+// estimate can stay above a budget too small for those.
+func (s *Store) SetMaxMemory(bytes int64) {
+	s.writeMu.Lock()
+	s.maxMemory = bytes
+	s.writeMu.Unlock()
+
+	s.mutate(func(data *storeData) bool {
+		return s.evict(data)
+	})
+}
+
+// usage counts the places in the store data referring to each snapshot kept
+// and, when traces are shared, the groups of those snapshots using each
+// shared trace, so the estimate follows what the store keeps without
+// rescanning it. Everything but the total is guarded by the store's write
+// lock; the total is what readers load.
+type usage struct {
+	shared    bool // traces are counted apart from their snapshots
+	snapshots map[*model.Snapshot]int
+	traces    map[*model.StackFrame]int // keyed by the trace's backing array
+	bytes     atomic.Int64
+}
+
+// recount resets the usage to the snapshots in data, counting traces apart
+// when shared is set. It's called with the store's write lock held.
+func (s *Store) recount(data *storeData, shared bool) {
+	s.usage.shared = shared
+	s.usage.snapshots = make(map[*model.Snapshot]int)
+	s.usage.traces = make(map[*model.StackFrame]int)
+	s.usage.bytes.Store(0)
+	for _, snapshot := range data.snapshots {
+		s.hold(snapshot)
+	}
+	for _, snapshot := range data.baselines {
+		s.hold(snapshot)
+	}
+	for _, snapshot := range data.pinned {
+		s.hold(snapshot)
+	}
+	for _, history := range data.history {
+		for _, snapshot := range history {
+			s.hold(snapshot)
+		}
+	}
+}
+
+// hold counts one more reference to snapshot, adding its size on the first.
+// With shared traces, a trace's size is added when the first snapshot kept
+// uses it.
+func (s *Store) hold(snapshot *model.Snapshot) {
+	if snapshot == nil {
+		return
+	}
+	u := &s.usage
+	u.snapshots[snapshot]++
+	if u.snapshots[snapshot] > 1 {
+		return
+	}
+	size := snapshotSize(snapshot, !u.shared)
+	if u.shared {
+		for _, g := range snapshot.Groups {
+			if len(g.Trace) == 0 {
+				continue
+			}
+			u.traces[&g.Trace[0]]++
+			if u.traces[&g.Trace[0]] == 1 {
+				size += traceSize(g.Trace)
+			}
+		}
+	}
+	u.bytes.Add(size)
+}
+
+// release undoes hold, taking off the sizes of the snapshot and of the
+// traces nothing kept uses any more once its last reference goes.
+func (s *Store) release(snapshot *model.Snapshot) {
+	if snapshot == nil {
+		return
+	}
+	u := &s.usage
+	u.snapshots[snapshot]--
+	if u.snapshots[snapshot] > 0 {
+		return
+	}
+	delete(u.snapshots, snapshot)
+	size := snapshotSize(snapshot, !u.shared)
+	if u.shared {
+		for _, g := range snapshot.Groups {
+			if len(g.Trace) == 0 {
+				continue
+			}
+			u.traces[&g.Trace[0]]--
+			if u.traces[&g.Trace[0]] == 0 {
+				delete(u.traces, &g.Trace[0])
+				size += traceSize(g.Trace)
+			}
+		}
+	}
+	u.bytes.Add(-size)
+}
+
+// setSnapshot sets the host's snapshot in m, nil to delete it, keeping the
+// usage in step. It's called with the store's write lock held.
+func (s *Store) setSnapshot(m map[string]*model.Snapshot, host string, snapshot *model.Snapshot) {
+	s.hold(snapshot)
+	s.release(m[host])
+	if snapshot == nil {
+		delete(m, host)
+		return
+	}
+	m[host] = snapshot
+}
+
+// setHistory replaces the host's history, keeping the usage in step. It's
+// called with the store's write lock held.
+func (s *Store) setHistory(data *storeData, host string, history []*model.Snapshot) {
+	for _, snapshot := range history {
+		s.hold(snapshot)
+	}
+	for _, snapshot := range data.history[host] {
+		s.release(snapshot)
+	}
+	data.history[host] = history
+}
+
+// evict drops the oldest history entries across all hosts until the
+// estimate is within the memory budget, and reports whether it dropped any.
+// It's called with the store's write lock held.
+func (s *Store) evict(data *storeData) bool {
+	if s.maxMemory <= 0 {
+		return false
+	}
+
+	evicted := false
+	for s.usage.bytes.Load() > s.maxMemory {
+		// The host whose oldest entry is the oldest of all, as long as it
+		// isn't the host's latest snapshot
+		oldest := ""
+		for host, history := range data.history {
+			if len(history) < 2 {
+				continue
+			}
+			if oldest == "" {
+				oldest = host
+				continue
+			}
+			a, b := history[0].TakenAt, data.history[oldest][0].TakenAt
+			if a.Before(b) || (a.Equal(b) && host < oldest) {
+				oldest = host
+			}
+		}
+		if oldest == "" {
+			break
+		}
+
+		// Copy so the dropped snapshot isn't pinned by the backing array.
+		// Baselines keep their snapshot in memory, which the usage counts.
+		s.setHistory(data, oldest, slices.Clone(data.history[oldest][1:]))
+		s.evicted.Add(1)
+		evicted = true
+	}
+	return evicted
+}
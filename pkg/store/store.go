@@ -0,0 +1,879 @@
+package store
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anyproto/goru/internal/diff"
+	"github.com/anyproto/goru/pkg/model"
+)
+
+// Store manages snapshots and change notifications
+type Store struct {
+	// Atomic pointer for lock-free reads
+	current atomic.Pointer[storeData]
+
+	// Serializes writers so concurrent copy-on-write updates aren't lost
+	writeMu sync.Mutex
+
+	// Computes changesets for Ingest
+	diff *diff.Diff
+
+	// Number of snapshots kept per host in the history, guarded by writeMu
+	historyDepth int
+
+	// Subscribers for changes, and the hooks seeing every snapshot
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	hooks       []*snapshotHook
+
+	// Updates dropped because a subscriber's channel was full, including
+	// subscribers since removed
+	dropped atomic.Uint64
+
+	// Snapshots left out because they were the same as the stored ones
+	unchanged atomic.Uint64
+
+	// When each host was last collected from successfully. It changes on
+	// every collection, so it's kept out of the copy-on-write data to leave
+	// MarkUnchanged cheap.
+	successMu sync.Mutex
+	succeeded map[string]time.Time
+
+	// Shares equal stack traces between the stored groups, nil unless
+	// SetInterning turned it on
+	interner atomic.Pointer[interner]
+
+	// Memory budget in bytes, 0 for none, guarded by writeMu, and the
+	// history entries dropped to stay within it
+	maxMemory int64
+	evicted   atomic.Uint64
+
+	// Estimated size of the snapshots kept, see hold
+	usage usage
+}
+
+// subscriber is a channel receiving updates and how many it missed
+type subscriber struct {
+	ch      chan<- Update
+	dropped atomic.Uint64
+}
+
+// snapshotHook is a function added by OnSnapshot
+type snapshotHook struct {
+	fn func(*model.Snapshot)
+}
+
+// Phase describes where a host is in its collection lifecycle
+type Phase string
+
+const (
+	PhaseRegistered Phase = "registered" // known but never attempted
+	PhaseInFlight   Phase = "in-flight"  // a collection attempt is running
+	PhaseSucceeded  Phase = "succeeded"  // the last attempt produced a snapshot
+	PhaseFailed     Phase = "failed"     // the last attempt failed
+)
+
+// DefaultHistoryDepth is the number of snapshots kept per host unless
+// SetHistoryDepth says otherwise
+const DefaultHistoryDepth = 60
+
+type storeData struct {
+	hosts     map[string]bool              // all registered hosts
+	phases    map[string]Phase             // collection phase per host
+	snapshots map[string]*model.Snapshot   // keyed by host
+	baselines map[string]*model.Snapshot   // first snapshot seen per host
+	pinned    map[string]*model.Snapshot   // baseline pinned per host, see PinBaselines
+	changes   map[string]*model.ChangeSet  // latest changes per host
+	history   map[string][]*model.Snapshot // recent snapshots per host, oldest first
+	errors    map[string]error             // latest error per host (nil = no error)
+	failures  map[string]int               // failed collections per host
+	stale     map[string]bool              // hosts flagged by MarkStale since their last success
+	labels    map[string]map[string]string // labels given per host, see SetLabels
+	removed   map[string]bool              // hosts dropped by RemoveHosts until registered again
+}
+
+func newStoreData() *storeData {
+	return &storeData{
+		hosts:     make(map[string]bool),
+		phases:    make(map[string]Phase),
+		snapshots: make(map[string]*model.Snapshot),
+		baselines: make(map[string]*model.Snapshot),
+		pinned:    make(map[string]*model.Snapshot),
+		changes:   make(map[string]*model.ChangeSet),
+		history:   make(map[string][]*model.Snapshot),
+		errors:    make(map[string]error),
+		failures:  make(map[string]int),
+		stale:     make(map[string]bool),
+		labels:    make(map[string]map[string]string),
+		removed:   make(map[string]bool),
+	}
+}
+
+// clone returns a shallow copy of the data for copy-on-write updates
+func (d *storeData) clone() *storeData {
+	c := &storeData{
+		hosts:     make(map[string]bool, len(d.hosts)),
+		phases:    make(map[string]Phase, len(d.phases)),
+		snapshots: make(map[string]*model.Snapshot, len(d.snapshots)),
+		baselines: make(map[string]*model.Snapshot, len(d.baselines)),
+		pinned:    make(map[string]*model.Snapshot, len(d.pinned)),
+		changes:   make(map[string]*model.ChangeSet, len(d.changes)),
+		history:   make(map[string][]*model.Snapshot, len(d.history)),
+		errors:    make(map[string]error, len(d.errors)),
+		failures:  make(map[string]int, len(d.failures)),
+		stale:     make(map[string]bool, len(d.stale)),
+		labels:    make(map[string]map[string]string, len(d.labels)),
+		removed:   make(map[string]bool, len(d.removed)),
+	}
+	for k, v := range d.hosts {
+		c.hosts[k] = v
+	}
+	for k, v := range d.phases {
+		c.phases[k] = v
+	}
+	for k, v := range d.snapshots {
+		c.snapshots[k] = v
+	}
+	for k, v := range d.baselines {
+		c.baselines[k] = v
+	}
+	for k, v := range d.pinned {
+		c.pinned[k] = v
+	}
+	for k, v := range d.changes {
+		c.changes[k] = v
+	}
+	// History slices are never modified in place, so they can be shared
+	for k, v := range d.history {
+		c.history[k] = v
+	}
+	for k, v := range d.errors {
+		c.errors[k] = v
+	}
+	for k, v := range d.failures {
+		c.failures[k] = v
+	}
+	for k, v := range d.stale {
+		c.stale[k] = v
+	}
+	// Label maps are replaced rather than modified, so they can be shared
+	for k, v := range d.labels {
+		c.labels[k] = v
+	}
+	for k, v := range d.removed {
+		c.removed[k] = v
+	}
+	return c
+}
+
+// Update represents a store update event
+type Update struct {
+	Host      string
+	Snapshot  *model.Snapshot
+	ChangeSet *model.ChangeSet
+	Error     error
+	Phase     Phase
+	// Removed is set when the host was removed from the store, or every
+	// host was when Host is empty
+	Removed bool
+	// Stale is set when MarkStale flagged the host
+	Stale bool
+}
+
+// New creates a new store
+func New() *Store {
+	s := &Store{diff: diff.New(), historyDepth: DefaultHistoryDepth, succeeded: make(map[string]time.Time)}
+	s.current.Store(newStoreData())
+	s.recount(s.current.Load(), false)
+	return s
+}
+
+// mutate applies fn to a copy of the current data and atomically swaps it in.
+// If fn returns false the copy is discarded and the store is left untouched.
+func (s *Store) mutate(fn func(data *storeData) bool) bool {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	// Create new data (copy-on-write)
+	newData := s.current.Load().clone()
+	if !fn(newData) {
+		return false
+	}
+
+	// Atomic swap
+	s.current.Store(newData)
+	return true
+}
+
+// SetHistoryDepth sets how many recent snapshots are kept per host, trimming
+// existing histories that are longer. A depth of 0 disables the history.
+func (s *Store) SetHistoryDepth(depth int) {
+	s.writeMu.Lock()
+	s.historyDepth = depth
+	s.writeMu.Unlock()
+
+	s.mutate(func(data *storeData) bool {
+		for host, history := range data.history {
+			s.setHistory(data, host, appendHistory(history, nil, depth))
+		}
+		return true
+	})
+}
+
+// SetInterning turns sharing equal stack traces between stored snapshots on
+// or off. With many hosts running the same binary most groups have a twin on
+// another host, or in the host's own history, and interning keeps one copy
+// of their traces. Snapshots put in the store afterwards have their groups'
+// traces replaced by the shared copies, so callers must not modify them or
+// still be reading them elsewhere. Turning it off forgets the shared traces
+// and the counts in Stats.
+func (s *Store) SetInterning(on bool) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if !on {
+		s.interner.Store(nil)
+	} else {
+		s.interner.CompareAndSwap(nil, newInterner())
+	}
+	// Shared traces are counted once however many snapshots use them
+	s.recount(s.current.Load(), on)
+}
+
+// appendHistory returns history with snapshot (if not nil) appended, keeping
+// only the last depth entries. The input slice is never modified since older
+// store data may still reference it.
+func appendHistory(history []*model.Snapshot, snapshot *model.Snapshot, depth int) []*model.Snapshot {
+	if snapshot != nil {
+		// The full slice expression forces append to copy
+		history = append(history[:len(history):len(history)], snapshot)
+	}
+	if len(history) > depth {
+		// Copy the retained window so it doesn't pin the old backing array
+		history = append([]*model.Snapshot(nil), history[len(history)-depth:]...)
+	}
+	return history
+}
+
+// RegisterHosts registers a list of hosts that will be monitored
+// This ensures the store knows about all configured hosts even before they connect
+func (s *Store) RegisterHosts(hosts []string) {
+	s.mutate(func(data *storeData) bool {
+		// Register all hosts
+		for _, host := range hosts {
+			delete(data.removed, host)
+			data.hosts[host] = true
+			if _, ok := data.phases[host]; !ok {
+				data.phases[host] = PhaseRegistered
+			}
+		}
+		return true
+	})
+}
+
+// SetLabels sets the labels describing a host, such as the environment its
+// target is given in the config. Nil or empty labels remove the host's.
+func (s *Store) SetLabels(host string, labels map[string]string) {
+	s.mutate(func(data *storeData) bool {
+		if len(labels) == 0 {
+			delete(data.labels, host)
+		} else {
+			data.labels[host] = maps.Clone(labels)
+		}
+		return true
+	})
+}
+
+// GetLabels returns the labels set for a host, nil when it has none. The
+// map must not be modified.
+func (s *Store) GetLabels(host string) map[string]string {
+	return s.current.Load().labels[host]
+}
+
+// Ingest stores a snapshot for its host and returns the changes relative to
+// the snapshot it replaces (all groups are added for a host's first
+// snapshot), or nil when the host was removed. It is the supported way to
+// feed snapshots that don't come from a collector, such as synthetic or
+// externally parsed ones.
+func (s *Store) Ingest(snapshot *model.Snapshot) *model.ChangeSet {
+	var changeSet *model.ChangeSet
+	s.putSnapshot(snapshot, func(previous *model.Snapshot) *model.ChangeSet {
+		changeSet = s.diff.Compare(previous, snapshot)
+		return changeSet
+	})
+	return changeSet
+}
+
+// UpdateSnapshot replaces the snapshot for a host with a changeset the caller
+// has already computed. A nil or empty changeset leaves the host's latest
+// changeset untouched. Any error recorded for the host is cleared, and
+// subscribers are notified even when nothing changed.
+func (s *Store) UpdateSnapshot(snapshot *model.Snapshot, changeSet *model.ChangeSet) {
+	s.putSnapshot(snapshot, func(*model.Snapshot) *model.ChangeSet {
+		return changeSet
+	})
+}
+
+// putSnapshot stores a snapshot along with the changeset computed from the
+// snapshot it replaces, then notifies subscribers. Snapshots of removed
+// hosts are dropped.
+func (s *Store) putSnapshot(snapshot *model.Snapshot, changes func(previous *model.Snapshot) *model.ChangeSet) {
+	in := s.interner.Load()
+	if in != nil {
+		in.intern(snapshot)
+	}
+	var changeSet *model.ChangeSet
+	stored := s.mutate(func(data *storeData) bool {
+		if data.removed[snapshot.Host] {
+			return false
+		}
+		s.markSucceeded(snapshot.Host)
+		changeSet = changes(data.snapshots[snapshot.Host])
+
+		// Hosts that were never registered (e.g. file sources) become known
+		// on their first snapshot
+		data.hosts[snapshot.Host] = true
+		data.phases[snapshot.Host] = PhaseSucceeded
+		s.setSnapshot(data.snapshots, snapshot.Host, snapshot)
+		if _, ok := data.baselines[snapshot.Host]; !ok {
+			s.setSnapshot(data.baselines, snapshot.Host, snapshot)
+		}
+		if changeSet != nil && !changeSet.IsEmpty() {
+			data.changes[snapshot.Host] = changeSet
+		}
+		s.setHistory(data, snapshot.Host, appendHistory(data.history[snapshot.Host], snapshot, s.historyDepth))
+		// Clear any previous error for this host since we got a snapshot
+		data.errors[snapshot.Host] = nil
+		delete(data.stale, snapshot.Host)
+		s.evict(data)
+		if in != nil {
+			in.prune(data)
+		}
+		return true
+	})
+	if !stored {
+		return
+	}
+	s.runHooks(snapshot)
+
+	// Notify subscribers
+	s.notifySubscribers(Update{
+		Host:      snapshot.Host,
+		Snapshot:  snapshot,
+		ChangeSet: changeSet,
+		Phase:     PhaseSucceeded,
+	})
+}
+
+// MarkUnchanged records a collection whose snapshot is the same as the one
+// already stored for the host. The host's phase is set to succeeded and any
+// error cleared, but the snapshot, changeset and history are left as they
+// are, and subscribers only hear of the phase or error changing.
+func (s *Store) MarkUnchanged(host string) {
+	s.unchanged.Add(1)
+	changed := s.mutate(func(data *storeData) bool {
+		if data.removed[host] {
+			return false
+		}
+		s.markSucceeded(host)
+		if data.phases[host] == PhaseSucceeded && data.errors[host] == nil && !data.stale[host] {
+			return false
+		}
+		data.phases[host] = PhaseSucceeded
+		data.errors[host] = nil
+		delete(data.stale, host)
+		return true
+	})
+	if !changed {
+		return
+	}
+
+	s.notifySubscribers(Update{
+		Host:  host,
+		Phase: PhaseSucceeded,
+	})
+}
+
+// GetSnapshot returns the current snapshot for a host
+func (s *Store) GetSnapshot(host string) *model.Snapshot {
+	data := s.current.Load()
+	return data.snapshots[host]
+}
+
+// GetAllSnapshots returns all current snapshots
+func (s *Store) GetAllSnapshots() map[string]*model.Snapshot {
+	data := s.current.Load()
+	// Return a copy to prevent external modification
+	result := make(map[string]*model.Snapshot)
+	for k, v := range data.snapshots {
+		result[k] = v
+	}
+	return result
+}
+
+// GetBaseline returns the first snapshot received for a host since the
+// store was created, for "since start" comparisons
+func (s *Store) GetBaseline(host string) *model.Snapshot {
+	data := s.current.Load()
+	return data.baselines[host]
+}
+
+// PinBaselines pins every host's latest snapshot as its baseline, replacing
+// any pinned before, so diffs can compare against a chosen moment rather
+// than the first snapshot. Hosts without a snapshot yet get no baseline.
+func (s *Store) PinBaselines() {
+	s.mutate(func(data *storeData) bool {
+		pinned := make(map[string]*model.Snapshot, len(data.snapshots))
+		for host, snapshot := range data.snapshots {
+			s.hold(snapshot)
+			pinned[host] = snapshot
+		}
+		for _, snapshot := range data.pinned {
+			s.release(snapshot)
+		}
+		data.pinned = pinned
+		return true
+	})
+}
+
+// UnpinBaselines drops the baselines pinned by PinBaselines
+func (s *Store) UnpinBaselines() {
+	s.mutate(func(data *storeData) bool {
+		if len(data.pinned) == 0 {
+			return false
+		}
+		for _, snapshot := range data.pinned {
+			s.release(snapshot)
+		}
+		data.pinned = make(map[string]*model.Snapshot)
+		return true
+	})
+}
+
+// GetPinnedBaseline returns the snapshot pinned as the host's baseline, or
+// nil when none is
+func (s *Store) GetPinnedBaseline(host string) *model.Snapshot {
+	data := s.current.Load()
+	return data.pinned[host]
+}
+
+// GetHistory returns the host's recent snapshots, oldest first, up to the
+// configured history depth. The latest snapshot is the last entry.
+func (s *Store) GetHistory(host string) []*model.Snapshot {
+	data := s.current.Load()
+	// Return a copy to prevent external modification
+	return append([]*model.Snapshot(nil), data.history[host]...)
+}
+
+// GetChangeSet returns the latest changeset for a host
+func (s *Store) GetChangeSet(host string) *model.ChangeSet {
+	data := s.current.Load()
+	return data.changes[host]
+}
+
+// UpdateError updates the error status for a host. Every non-nil error
+// counts as a failed collection, even when it repeats the previous one.
+func (s *Store) UpdateError(host string, err error) {
+	changed := false
+	s.mutate(func(data *storeData) bool {
+		if data.removed[host] {
+			return false
+		}
+		if err != nil {
+			data.failures[host]++
+			// A repeated error still ends the attempt marked in flight
+			if data.phases[host] != PhaseFailed {
+				data.phases[host] = PhaseFailed
+				changed = true
+			}
+		}
+
+		// Check if error actually changed
+		currentErr, exists := data.errors[host]
+		if exists && currentErr != nil && err != nil && currentErr.Error() == err.Error() {
+			// Same error, only the failure count and maybe the phase changed
+			return true
+		}
+		if currentErr == nil && err == nil {
+			// No error before, no error now, no change needed
+			return false
+		}
+
+		data.errors[host] = err
+		changed = true
+		return true
+	})
+	if !changed {
+		return
+	}
+
+	// Notify subscribers only when there's an actual change
+	s.notifySubscribers(Update{
+		Host:  host,
+		Error: err,
+		Phase: s.GetPhase(host),
+	})
+}
+
+// GetErrors returns all current errors
+func (s *Store) GetErrors() map[string]error {
+	data := s.current.Load()
+	// Return a copy to prevent external modification
+	result := make(map[string]error)
+	for k, v := range data.errors {
+		// Only include hosts with actual errors (not nil)
+		if v != nil {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// RemoveHost forgets a host entirely: its registration, snapshots, changes
+// and errors. Unknown hosts are ignored.
+func (s *Store) RemoveHost(host string) {
+	s.RemoveHosts([]string{host})
+}
+
+// RemoveHosts forgets hosts entirely, e.g. targets dropped by a config
+// reload, so they no longer show up as pending or failed. Snapshots, errors
+// and attempts still arriving for them from collections under way are
+// ignored until they're registered again.
+func (s *Store) RemoveHosts(hosts []string) {
+	var removed []string
+	s.mutate(func(data *storeData) bool {
+		for _, host := range hosts {
+			data.removed[host] = true
+			if !data.hosts[host] {
+				continue
+			}
+			delete(data.hosts, host)
+			delete(data.phases, host)
+			s.setSnapshot(data.snapshots, host, nil)
+			s.setSnapshot(data.baselines, host, nil)
+			s.setSnapshot(data.pinned, host, nil)
+			delete(data.changes, host)
+			s.setHistory(data, host, nil)
+			delete(data.history, host)
+			delete(data.errors, host)
+			delete(data.failures, host)
+			delete(data.stale, host)
+			delete(data.labels, host)
+			removed = append(removed, host)
+		}
+		return len(hosts) > 0
+	})
+
+	s.successMu.Lock()
+	for _, host := range removed {
+		delete(s.succeeded, host)
+	}
+	s.successMu.Unlock()
+
+	for _, host := range removed {
+		s.notifySubscribers(Update{Host: host, Removed: true})
+	}
+}
+
+// Clear forgets every host, leaving the store as new. Subscribers stay
+// subscribed and get a single removal update without a host. The dropped
+// and skipped update counters keep counting.
+func (s *Store) Clear() {
+	changed := s.mutate(func(data *storeData) bool {
+		if len(data.hosts) == 0 && len(data.snapshots) == 0 {
+			return false
+		}
+		*data = *newStoreData()
+		s.recount(data, s.usage.shared)
+		return true
+	})
+	if !changed {
+		return
+	}
+	s.successMu.Lock()
+	clear(s.succeeded)
+	s.successMu.Unlock()
+
+	s.notifySubscribers(Update{Removed: true})
+}
+
+func (s *Store) markSucceeded(host string) {
+	s.successMu.Lock()
+	s.succeeded[host] = time.Now()
+	s.successMu.Unlock()
+}
+
+// LastSuccess returns when a snapshot of the host was last stored or found
+// unchanged, the zero time if never
+func (s *Store) LastSuccess(host string) time.Time {
+	s.successMu.Lock()
+	defer s.successMu.Unlock()
+	return s.succeeded[host]
+}
+
+// MarkStale flags the hosts with a snapshot whose last successful collection
+// is more than after(host) before now, so their data isn't taken for live.
+// The flag stays until the next success. Subscribers hear of hosts as
+// they're flagged, and those are returned. after is called without holding
+// the store's locks, so it may call into what feeds the store.
+func (s *Store) MarkStale(now time.Time, after func(host string) time.Duration) []string {
+	s.successMu.Lock()
+	hosts := slices.Collect(maps.Keys(s.succeeded))
+	s.successMu.Unlock()
+	thresholds := make(map[string]time.Duration, len(hosts))
+	for _, host := range hosts {
+		thresholds[host] = after(host)
+	}
+
+	var flagged []string
+	s.mutate(func(data *storeData) bool {
+		// Successes are read again under writeMu: a host stored since they
+		// were listed would otherwise be flagged on its older time
+		s.successMu.Lock()
+		defer s.successMu.Unlock()
+		for host, threshold := range thresholds {
+			t, ok := s.succeeded[host]
+			if ok && now.Sub(t) > threshold && data.snapshots[host] != nil && !data.stale[host] {
+				data.stale[host] = true
+				flagged = append(flagged, host)
+			}
+		}
+		return len(flagged) > 0
+	})
+
+	sort.Strings(flagged)
+	for _, host := range flagged {
+		s.notifySubscribers(Update{Host: host, Stale: true})
+	}
+	return flagged
+}
+
+// IsStale reports whether MarkStale flagged the host since its last
+// successful collection
+func (s *Store) IsStale(host string) bool {
+	return s.current.Load().stale[host]
+}
+
+// GetFailureCounts returns the number of failed collections per host since
+// the store was created
+func (s *Store) GetFailureCounts() map[string]int {
+	data := s.current.Load()
+	result := make(map[string]int, len(data.failures))
+	for k, v := range data.failures {
+		result[k] = v
+	}
+	return result
+}
+
+// GetAllHosts returns all registered hosts
+func (s *Store) GetAllHosts() []string {
+	data := s.current.Load()
+	hosts := make([]string, 0, len(data.hosts))
+	for host := range data.hosts {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// MarkInFlight records that a collection attempt for a host has started
+func (s *Store) MarkInFlight(host string) {
+	changed := s.mutate(func(data *storeData) bool {
+		if data.phases[host] == PhaseInFlight || data.removed[host] {
+			return false
+		}
+		data.hosts[host] = true
+		data.phases[host] = PhaseInFlight
+		return true
+	})
+	if !changed {
+		return
+	}
+
+	s.notifySubscribers(Update{
+		Host:  host,
+		Phase: PhaseInFlight,
+	})
+}
+
+// GetPhase returns the collection phase of a host, or an empty phase if the
+// host is unknown
+func (s *Store) GetPhase(host string) Phase {
+	data := s.current.Load()
+	return data.phases[host]
+}
+
+// GetPhases returns the collection phase of every known host
+func (s *Store) GetPhases() map[string]Phase {
+	data := s.current.Load()
+	// Return a copy to prevent external modification
+	result := make(map[string]Phase, len(data.phases))
+	for k, v := range data.phases {
+		result[k] = v
+	}
+	return result
+}
+
+// GetFetchingHosts returns hosts whose first collection is currently in flight
+func (s *Store) GetFetchingHosts() map[string]bool {
+	data := s.current.Load()
+	result := make(map[string]bool)
+
+	for host, phase := range data.phases {
+		if _, hasSnapshot := data.snapshots[host]; phase == PhaseInFlight && !hasSnapshot {
+			result[host] = true
+		}
+	}
+
+	return result
+}
+
+// Subscribe registers a channel to receive updates
+func (s *Store) Subscribe(ch chan<- Update) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, &subscriber{ch: ch})
+}
+
+// Unsubscribe removes a channel from receiving updates
+func (s *Store) Unsubscribe(ch chan<- Update) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, sub := range s.subscribers {
+		if sub.ch == ch {
+			// Remove by swapping with last and truncating
+			s.subscribers[i] = s.subscribers[len(s.subscribers)-1]
+			s.subscribers = s.subscribers[:len(s.subscribers)-1]
+			break
+		}
+	}
+}
+
+// OnSnapshot calls fn with every snapshot stored from now on, until the
+// returned function is called. Unlike subscribers, fn never misses a
+// snapshot: it's called by the goroutine storing it, which waits for fn
+// before notifying subscribers.
+func (s *Store) OnSnapshot(fn func(*model.Snapshot)) (remove func()) {
+	hook := &snapshotHook{fn: fn}
+	s.mu.Lock()
+	s.hooks = append(s.hooks, hook)
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// runHooks iterates a slice it took, so it's replaced
+		s.hooks = slices.DeleteFunc(slices.Clone(s.hooks), func(h *snapshotHook) bool {
+			return h == hook
+		})
+	}
+}
+
+func (s *Store) runHooks(snapshot *model.Snapshot) {
+	s.mu.RLock()
+	hooks := s.hooks
+	s.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook.fn(snapshot)
+	}
+}
+
+func (s *Store) notifySubscribers(update Update) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sub := range s.subscribers {
+		// Non-blocking send
+		select {
+		case sub.ch <- update:
+		default:
+			// Subscriber is not ready, count the update it missed
+			sub.dropped.Add(1)
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns how many updates a subscriber missed because its channel
+// was full, 0 for channels that aren't subscribed
+func (s *Store) Dropped(ch chan<- Update) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sub := range s.subscribers {
+		if sub.ch == ch {
+			return sub.dropped.Load()
+		}
+	}
+	return 0
+}
+
+// Stats returns statistics about the store
+type Stats struct {
+	Hosts           int
+	TotalGroups     int
+	TotalGoroutines int
+	SubscriberCount int
+	DroppedUpdates  uint64 // updates missed by subscribers with a full channel
+	SkippedUpdates  uint64 // collections skipped as unchanged, see MarkUnchanged
+	// GoroutinesByState sums the goroutines of every host by state; states
+	// without goroutines are absent
+	GoroutinesByState map[model.GoroutineState]int
+	// Traces interning shares, how many group traces it has looked up and
+	// how many of those it found an equal trace for, all 0 unless
+	// SetInterning is on
+	InternedTraces int
+	InternLookups  uint64
+	InternHits     uint64
+	// EstimatedBytes is roughly the memory the kept snapshots take, and
+	// EvictedSnapshots the history entries dropped to stay within the
+	// budget, see SetMaxMemory
+	EstimatedBytes   int64
+	EvictedSnapshots uint64
+}
+
+// DedupRatio returns the share of interned group traces that were found
+// shared, 0 before any were looked up
+func (s Stats) DedupRatio() float64 {
+	if s.InternLookups == 0 {
+		return 0
+	}
+	return float64(s.InternHits) / float64(s.InternLookups)
+}
+
+// GetStats returns current store statistics
+func (s *Store) GetStats() Stats {
+	data := s.current.Load()
+
+	stats := Stats{
+		Hosts:             len(data.snapshots),
+		GoroutinesByState: make(map[model.GoroutineState]int),
+	}
+
+	for _, snapshot := range data.snapshots {
+		stats.TotalGroups += len(snapshot.Groups)
+		for _, g := range snapshot.Groups {
+			stats.TotalGoroutines += g.Count
+			stats.GoroutinesByState[g.State] += g.Count
+		}
+	}
+
+	s.mu.RLock()
+	stats.SubscriberCount = len(s.subscribers)
+	s.mu.RUnlock()
+	stats.DroppedUpdates = s.dropped.Load()
+	stats.SkippedUpdates = s.unchanged.Load()
+	if in := s.interner.Load(); in != nil {
+		stats.InternedTraces, stats.InternLookups, stats.InternHits = in.counts()
+	}
+	stats.EstimatedBytes = s.usage.bytes.Load()
+	stats.EvictedSnapshots = s.evicted.Load()
+
+	return stats
+}
@@ -0,0 +1,995 @@
+package store
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/anyproto/goru/pkg/model"
+)
+
+func TestStoreUpdateSnapshot(t *testing.T) {
+	store := New()
+
+	snapshot := &model.Snapshot{
+		Host:    "test-host",
+		TakenAt: time.Now(),
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", Count: 5},
+			"g2": {ID: "g2", Count: 3},
+		},
+	}
+
+	changeSet := &model.ChangeSet{
+		Host:  "test-host",
+		Added: []*model.Group{{ID: "g1", Count: 5}},
+	}
+
+	store.UpdateSnapshot(snapshot, changeSet)
+
+	// Verify snapshot is stored
+	retrieved := store.GetSnapshot("test-host")
+	if retrieved == nil {
+		t.Fatal("Snapshot not found")
+	}
+
+	if retrieved.Host != "test-host" {
+		t.Errorf("Host = %q, want %q", retrieved.Host, "test-host")
+	}
+
+	if len(retrieved.Groups) != 2 {
+		t.Errorf("Groups count = %d, want 2", len(retrieved.Groups))
+	}
+
+	// Verify changeset is stored
+	changes := store.GetChangeSet("test-host")
+	if changes == nil {
+		t.Fatal("ChangeSet not found")
+	}
+
+	if len(changes.Added) != 1 {
+		t.Errorf("Added count = %d, want 1", len(changes.Added))
+	}
+}
+
+func TestStoreGetAllSnapshots(t *testing.T) {
+	store := New()
+
+	// Add multiple snapshots
+	hosts := []string{"host1", "host2", "host3"}
+	for _, host := range hosts {
+		snapshot := &model.Snapshot{
+			Host:    host,
+			TakenAt: time.Now(),
+			Groups:  make(map[model.GroupID]*model.Group),
+		}
+		store.UpdateSnapshot(snapshot, nil)
+	}
+
+	all := store.GetAllSnapshots()
+	if len(all) != 3 {
+		t.Errorf("Expected 3 snapshots, got %d", len(all))
+	}
+
+	for _, host := range hosts {
+		if _, exists := all[host]; !exists {
+			t.Errorf("Missing snapshot for host %s", host)
+		}
+	}
+}
+
+func TestStoreSubscriptions(t *testing.T) {
+	store := New()
+
+	// Create subscriber
+	ch := make(chan Update, 1)
+	store.Subscribe(ch)
+
+	// Send update
+	snapshot := &model.Snapshot{
+		Host:    "test-host",
+		TakenAt: time.Now(),
+		Groups:  make(map[model.GroupID]*model.Group),
+	}
+	changeSet := &model.ChangeSet{
+		Host:  "test-host",
+		Added: []*model.Group{{ID: "g1"}},
+	}
+
+	store.UpdateSnapshot(snapshot, changeSet)
+
+	// Check notification received
+	select {
+	case update := <-ch:
+		if update.Host != "test-host" {
+			t.Errorf("Update host = %q, want %q", update.Host, "test-host")
+		}
+		if update.Snapshot == nil {
+			t.Error("Update snapshot is nil")
+		}
+		if update.ChangeSet == nil {
+			t.Error("Update changeset is nil")
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("No update received")
+	}
+
+	// Unsubscribe
+	store.Unsubscribe(ch)
+
+	// Send another update
+	store.UpdateSnapshot(snapshot, changeSet)
+
+	// Should not receive notification
+	select {
+	case <-ch:
+		t.Error("Received update after unsubscribe")
+	case <-time.After(50 * time.Millisecond):
+		// Expected
+	}
+}
+
+func TestStoreConcurrentAccess(t *testing.T) {
+	store := New()
+
+	var wg sync.WaitGroup
+
+	// Multiple writers
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				snapshot := &model.Snapshot{
+					Host:    fmt.Sprintf("host%d", id),
+					TakenAt: time.Now(),
+					Groups: map[model.GroupID]*model.Group{
+						model.GroupID(fmt.Sprintf("g%d", j)): {
+							ID:    model.GroupID(fmt.Sprintf("g%d", j)),
+							Count: j,
+						},
+					},
+				}
+				store.UpdateSnapshot(snapshot, nil)
+			}
+		}(i)
+	}
+
+	// Multiple readers
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = store.GetSnapshot(fmt.Sprintf("host%d", id))
+				_ = store.GetAllSnapshots()
+				_ = store.GetStats()
+			}
+		}(i)
+	}
+
+	// Multiple subscribers
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ch := make(chan Update, 10)
+			store.Subscribe(ch)
+
+			// Read some updates
+			timeout := time.After(100 * time.Millisecond)
+			for {
+				select {
+				case <-ch:
+					// Got update
+				case <-timeout:
+					store.Unsubscribe(ch)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// Verify final state
+	stats := store.GetStats()
+	if stats.Hosts != 10 {
+		t.Errorf("Expected 10 hosts, got %d", stats.Hosts)
+
+		// Debug: print all hosts
+		all := store.GetAllSnapshots()
+		t.Logf("Hosts found: %v", len(all))
+		for host := range all {
+			t.Logf("  - %s", host)
+		}
+	}
+}
+
+func TestStoreStats(t *testing.T) {
+	store := New()
+
+	// Add subscribers
+	ch1 := make(chan Update)
+	ch2 := make(chan Update)
+	store.Subscribe(ch1)
+	store.Subscribe(ch2)
+
+	// Add snapshots
+	snapshot1 := &model.Snapshot{
+		Host: "host1",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", State: model.StateWaiting, Count: 5},
+			"g2": {ID: "g2", State: model.StateRunning, Count: 3},
+		},
+	}
+
+	snapshot2 := &model.Snapshot{
+		Host: "host2",
+		Groups: map[model.GroupID]*model.Group{
+			"g3": {ID: "g3", State: model.StateWaiting, Count: 10},
+		},
+	}
+
+	store.UpdateSnapshot(snapshot1, nil)
+	store.UpdateSnapshot(snapshot2, nil)
+
+	stats := store.GetStats()
+
+	if stats.Hosts != 2 {
+		t.Errorf("Hosts = %d, want 2", stats.Hosts)
+	}
+
+	if stats.TotalGroups != 3 {
+		t.Errorf("TotalGroups = %d, want 3", stats.TotalGroups)
+	}
+
+	if stats.TotalGoroutines != 18 {
+		t.Errorf("TotalGoroutines = %d, want 18", stats.TotalGoroutines)
+	}
+
+	want := map[model.GoroutineState]int{model.StateWaiting: 15, model.StateRunning: 3}
+	if !maps.Equal(stats.GoroutinesByState, want) {
+		t.Errorf("GoroutinesByState = %v, want %v", stats.GoroutinesByState, want)
+	}
+
+	if stats.SubscriberCount != 2 {
+		t.Errorf("SubscriberCount = %d, want 2", stats.SubscriberCount)
+	}
+}
+
+func TestStoreDroppedUpdates(t *testing.T) {
+	store := New()
+
+	full := make(chan Update, 1)
+	roomy := make(chan Update, 10)
+	store.Subscribe(full)
+	store.Subscribe(roomy)
+
+	for i := range 3 {
+		store.UpdateSnapshot(&model.Snapshot{Host: fmt.Sprintf("host%d", i)}, nil)
+	}
+
+	if got := store.Dropped(full); got != 2 {
+		t.Errorf("Dropped(full) = %d, want 2", got)
+	}
+	if got := store.Dropped(roomy); got != 0 {
+		t.Errorf("Dropped(roomy) = %d, want 0", got)
+	}
+
+	// The total outlives the subscriber
+	store.Unsubscribe(full)
+	if got := store.Dropped(full); got != 0 {
+		t.Errorf("Dropped after Unsubscribe = %d, want 0", got)
+	}
+	if got := store.GetStats().DroppedUpdates; got != 2 {
+		t.Errorf("DroppedUpdates = %d, want 2", got)
+	}
+}
+
+func TestStoreOnSnapshot(t *testing.T) {
+	store := New()
+
+	// Hooks see every snapshot, however many, before subscribers hear of it
+	ch := make(chan Update, 1)
+	store.Subscribe(ch)
+	var hosts []string
+	remove := store.OnSnapshot(func(snapshot *model.Snapshot) {
+		if len(ch) != 0 {
+			t.Errorf("Subscriber heard of %s before the hook", snapshot.Host)
+		}
+		hosts = append(hosts, snapshot.Host)
+	})
+	for i := range 3 {
+		store.UpdateSnapshot(&model.Snapshot{Host: fmt.Sprintf("host%d", i)}, nil)
+		<-ch
+	}
+	store.MarkUnchanged("host0")
+	if want := []string{"host0", "host1", "host2"}; !slices.Equal(hosts, want) {
+		t.Errorf("Hook saw %v, want %v", hosts, want)
+	}
+
+	remove()
+	store.UpdateSnapshot(&model.Snapshot{Host: "host3"}, nil)
+	if len(hosts) != 3 {
+		t.Errorf("Hook saw %v after being removed", hosts)
+	}
+}
+
+func TestStoreEmptyChangeSet(t *testing.T) {
+	store := New()
+
+	snapshot := &model.Snapshot{
+		Host:    "test-host",
+		TakenAt: time.Now(),
+		Groups:  make(map[model.GroupID]*model.Group),
+	}
+
+	// Empty changeset should not be stored
+	emptyChangeSet := &model.ChangeSet{
+		Host: "test-host",
+	}
+
+	store.UpdateSnapshot(snapshot, emptyChangeSet)
+
+	// Should not find changeset
+	changes := store.GetChangeSet("test-host")
+	if changes != nil {
+		t.Error("Empty changeset should not be stored")
+	}
+}
+
+func TestStoreBaseline(t *testing.T) {
+	store := New()
+
+	first := &model.Snapshot{
+		Host:   "test-host",
+		Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", Count: 1}},
+	}
+	second := &model.Snapshot{
+		Host:   "test-host",
+		Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", Count: 4}},
+	}
+
+	if store.GetBaseline("test-host") != nil {
+		t.Error("Baseline should be nil before any snapshot")
+	}
+
+	store.UpdateSnapshot(first, nil)
+	store.UpdateSnapshot(second, nil)
+
+	if got := store.GetBaseline("test-host"); got != first {
+		t.Error("Baseline should remain the first snapshot")
+	}
+	if got := store.GetSnapshot("test-host"); got != second {
+		t.Error("Current snapshot should be the latest one")
+	}
+}
+
+func TestStorePinnedBaselines(t *testing.T) {
+	store := New()
+	first := &model.Snapshot{
+		Host:   "test-host",
+		Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", Count: 1}},
+	}
+	second := &model.Snapshot{
+		Host:   "test-host",
+		Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", Count: 4}},
+	}
+	third := &model.Snapshot{
+		Host:   "test-host",
+		Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", Count: 9}},
+	}
+
+	store.UpdateSnapshot(first, nil)
+	if store.GetPinnedBaseline("test-host") != nil {
+		t.Error("Expected no pinned baseline before pinning")
+	}
+
+	store.UpdateSnapshot(second, nil)
+	store.PinBaselines()
+	store.UpdateSnapshot(third, nil)
+	if got := store.GetPinnedBaseline("test-host"); got != second {
+		t.Error("Pinned baseline should be the snapshot current when pinning")
+	}
+	if got := store.GetBaseline("test-host"); got != first {
+		t.Error("Pinning should leave the first snapshot baseline alone")
+	}
+
+	// Pinning again moves the baseline forward
+	store.PinBaselines()
+	if got := store.GetPinnedBaseline("test-host"); got != third {
+		t.Error("Pinning again should pin the latest snapshot")
+	}
+
+	store.UnpinBaselines()
+	if store.GetPinnedBaseline("test-host") != nil {
+		t.Error("Expected no pinned baseline after unpinning")
+	}
+
+	// Removed hosts lose their pinned baseline
+	store.PinBaselines()
+	store.RemoveHost("test-host")
+	if store.GetPinnedBaseline("test-host") != nil {
+		t.Error("Expected a removed host's pinned baseline dropped")
+	}
+}
+
+func TestStorePhases(t *testing.T) {
+	store := New()
+	store.RegisterHosts([]string{"host1", "host2"})
+
+	if phase := store.GetPhase("host1"); phase != PhaseRegistered {
+		t.Errorf("Phase = %q, want %q", phase, PhaseRegistered)
+	}
+	if len(store.GetFetchingHosts()) != 0 {
+		t.Error("Registered hosts should not be reported as fetching")
+	}
+
+	store.MarkInFlight("host1")
+	store.MarkInFlight("host2")
+	if fetching := store.GetFetchingHosts(); !fetching["host1"] || !fetching["host2"] {
+		t.Errorf("Expected both hosts fetching, got %v", fetching)
+	}
+
+	store.UpdateSnapshot(&model.Snapshot{Host: "host1", Groups: make(map[model.GroupID]*model.Group)}, nil)
+	store.UpdateError("host2", fmt.Errorf("connection refused"))
+
+	if phase := store.GetPhase("host1"); phase != PhaseSucceeded {
+		t.Errorf("Phase = %q, want %q", phase, PhaseSucceeded)
+	}
+	if phase := store.GetPhase("host2"); phase != PhaseFailed {
+		t.Errorf("Phase = %q, want %q", phase, PhaseFailed)
+	}
+
+	// A retry after failure is in flight again, but a host with a snapshot
+	// isn't treated as fetching for the first time
+	store.MarkInFlight("host1")
+	store.MarkInFlight("host2")
+	fetching := store.GetFetchingHosts()
+	if fetching["host1"] {
+		t.Error("Host with a snapshot should not be reported as fetching")
+	}
+	if !fetching["host2"] {
+		t.Error("Retrying host without a snapshot should be reported as fetching")
+	}
+
+	// Re-registering must not reset the phase of known hosts
+	store.RegisterHosts([]string{"host1"})
+	if phase := store.GetPhase("host1"); phase != PhaseInFlight {
+		t.Errorf("Phase = %q, want %q", phase, PhaseInFlight)
+	}
+}
+
+func TestStoreRepeatedFailure(t *testing.T) {
+	store := New()
+	store.RegisterHosts([]string{"host1"})
+	updates := make(chan Update, 10)
+	store.Subscribe(updates)
+
+	store.MarkInFlight("host1")
+	store.UpdateError("host1", fmt.Errorf("connection refused"))
+	store.MarkInFlight("host1")
+	for range 3 {
+		<-updates
+	}
+
+	// The same error again still ends the attempt
+	store.UpdateError("host1", fmt.Errorf("connection refused"))
+	if phase := store.GetPhase("host1"); phase != PhaseFailed {
+		t.Errorf("Phase = %q, want %q", phase, PhaseFailed)
+	}
+	if fetching := store.GetFetchingHosts(); fetching["host1"] {
+		t.Error("Host whose retry failed should not be reported as fetching")
+	}
+	select {
+	case update := <-updates:
+		if update.Phase != PhaseFailed {
+			t.Errorf("Update phase = %q, want %q", update.Phase, PhaseFailed)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected an update for the phase change")
+	}
+}
+
+func TestStoreIngest(t *testing.T) {
+	store := New()
+
+	first := &model.Snapshot{
+		Host: "test-host",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", Count: 2},
+		},
+	}
+	changes := store.Ingest(first)
+	if len(changes.Added) != 1 {
+		t.Errorf("First ingest should add 1 group, got %d", len(changes.Added))
+	}
+
+	second := &model.Snapshot{
+		Host: "test-host",
+		Groups: map[model.GroupID]*model.Group{
+			"g1": {ID: "g1", Count: 5},
+			"g2": {ID: "g2", Count: 1},
+		},
+	}
+	changes = store.Ingest(second)
+	if len(changes.Added) != 1 {
+		t.Errorf("Second ingest should add 1 group, got %d", len(changes.Added))
+	}
+	if delta := changes.Updated["g1"]; delta != 3 {
+		t.Errorf("Expected delta +3 for g1, got %d", delta)
+	}
+
+	if store.GetSnapshot("test-host") != second {
+		t.Error("Ingest should store the snapshot")
+	}
+	if store.GetChangeSet("test-host") != changes {
+		t.Error("Ingest should store the computed changeset")
+	}
+}
+
+func TestStoreHistory(t *testing.T) {
+	store := New()
+	store.SetHistoryDepth(3)
+
+	var snapshots []*model.Snapshot
+	for i := 1; i <= 5; i++ {
+		snapshot := &model.Snapshot{
+			Host:   "test-host",
+			Groups: map[model.GroupID]*model.Group{"g1": {ID: "g1", Count: i}},
+		}
+		snapshots = append(snapshots, snapshot)
+		store.UpdateSnapshot(snapshot, nil)
+	}
+
+	history := store.GetHistory("test-host")
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 snapshots in history, got %d", len(history))
+	}
+	for i, snapshot := range history {
+		if snapshot != snapshots[i+2] {
+			t.Errorf("History[%d] should be snapshot %d", i, i+3)
+		}
+	}
+
+	// Earlier reads are unaffected by later updates
+	store.UpdateSnapshot(&model.Snapshot{Host: "test-host"}, nil)
+	if history[0] != snapshots[2] || history[2] != snapshots[4] {
+		t.Error("Returned history should not change after an update")
+	}
+
+	// Lowering the depth trims existing histories
+	store.SetHistoryDepth(1)
+	if got := store.GetHistory("test-host"); len(got) != 1 || got[0] != store.GetSnapshot("test-host") {
+		t.Errorf("Expected only the latest snapshot after trimming, got %d", len(got))
+	}
+
+	if got := store.GetHistory("unknown"); len(got) != 0 {
+		t.Errorf("Expected no history for unknown host, got %d", len(got))
+	}
+}
+
+func TestStoreFailureCounts(t *testing.T) {
+	store := New()
+	store.RegisterHosts([]string{"host1", "host2"})
+
+	ch := make(chan Update, 10)
+	store.Subscribe(ch)
+	defer store.Unsubscribe(ch)
+
+	store.UpdateError("host1", fmt.Errorf("connection refused"))
+	store.UpdateError("host1", fmt.Errorf("connection refused"))
+	store.UpdateError("host1", nil)
+	store.UpdateError("host2", nil)
+
+	counts := store.GetFailureCounts()
+	if counts["host1"] != 2 {
+		t.Errorf("host1 failures = %d, want 2", counts["host1"])
+	}
+	if counts["host2"] != 0 {
+		t.Errorf("host2 failures = %d, want 0", counts["host2"])
+	}
+
+	// A repeated error is counted but not announced again
+	if len(ch) != 2 {
+		t.Errorf("Got %d updates, want 2", len(ch))
+	}
+}
+
+func TestStoreRemoveHosts(t *testing.T) {
+	store := New()
+	store.RegisterHosts([]string{"host1", "host2"})
+	store.UpdateSnapshot(&model.Snapshot{Host: "host1", Groups: make(map[model.GroupID]*model.Group)}, nil)
+	store.UpdateError("host2", fmt.Errorf("connection refused"))
+
+	ch := make(chan Update, 10)
+	store.Subscribe(ch)
+	defer store.Unsubscribe(ch)
+
+	store.RemoveHosts([]string{"host2", "unknown"})
+
+	if hosts := store.GetAllHosts(); len(hosts) != 1 || hosts[0] != "host1" {
+		t.Errorf("Hosts = %v, want [host1]", hosts)
+	}
+	if _, ok := store.GetErrors()["host2"]; ok {
+		t.Error("Removed host should not report an error")
+	}
+	if _, ok := store.GetPhases()["host2"]; ok {
+		t.Error("Removed host should have no phase")
+	}
+	if store.GetFailureCounts()["host2"] != 0 {
+		t.Error("Removed host should have no failures")
+	}
+	if len(ch) != 1 {
+		t.Fatalf("Got %d updates, want 1 for the removed host", len(ch))
+	}
+	if update := <-ch; update.Host != "host2" || !update.Removed {
+		t.Errorf("Update = %+v, want the removal of host2", update)
+	}
+
+	store.RemoveHost("host1")
+	if hosts := store.GetAllHosts(); len(hosts) != 0 {
+		t.Errorf("Hosts = %v, want none", hosts)
+	}
+	if store.GetSnapshot("host1") != nil || store.GetHistory("host1") != nil {
+		t.Error("Removed host should have no snapshots")
+	}
+
+	// Collections still under way when the host was removed don't bring it
+	// back, until it's registered again
+	store.MarkInFlight("host1")
+	store.UpdateError("host1", fmt.Errorf("timeout"))
+	if cs := store.Ingest(&model.Snapshot{Host: "host1", Groups: make(map[model.GroupID]*model.Group)}); cs != nil {
+		t.Errorf("Ingest = %+v, want nil for a removed host", cs)
+	}
+	store.MarkUnchanged("host1")
+	if hosts := store.GetAllHosts(); len(hosts) != 0 || store.GetPhase("host1") != "" || !store.LastSuccess("host1").IsZero() {
+		t.Errorf("Hosts = %v, want the late updates of host1 ignored", hosts)
+	}
+	if len(ch) != 1 {
+		t.Errorf("Got %d updates, want only the removal of host1", len(ch))
+	}
+	store.RegisterHosts([]string{"host1"})
+	store.UpdateSnapshot(&model.Snapshot{Host: "host1", Groups: make(map[model.GroupID]*model.Group)}, nil)
+	if store.GetSnapshot("host1") == nil {
+		t.Error("Expected a snapshot once host1 is registered again")
+	}
+}
+
+func TestStoreClear(t *testing.T) {
+	store := New()
+	store.RegisterHosts([]string{"host1", "host2"})
+	store.UpdateSnapshot(&model.Snapshot{Host: "host1", Groups: make(map[model.GroupID]*model.Group)}, nil)
+	store.UpdateError("host2", fmt.Errorf("connection refused"))
+
+	ch := make(chan Update, 10)
+	store.Subscribe(ch)
+	defer store.Unsubscribe(ch)
+
+	store.Clear()
+	if hosts := store.GetAllHosts(); len(hosts) != 0 {
+		t.Errorf("Hosts = %v, want none", hosts)
+	}
+	if stats := store.GetStats(); stats.Hosts != 0 || stats.SubscriberCount != 1 {
+		t.Errorf("Stats = %+v, want no hosts and the subscriber kept", stats)
+	}
+	if len(store.GetErrors()) != 0 || len(store.GetPhases()) != 0 {
+		t.Error("Expected no errors or phases after clearing")
+	}
+
+	// One update for all hosts, and none for clearing an empty store
+	store.Clear()
+	if len(ch) != 1 {
+		t.Fatalf("Got %d updates, want 1", len(ch))
+	}
+	if update := <-ch; update.Host != "" || !update.Removed {
+		t.Errorf("Update = %+v, want the removal of every host", update)
+	}
+}
+
+func TestStoreMarkUnchanged(t *testing.T) {
+	store := New()
+	updates := make(chan Update, 10)
+	store.Subscribe(updates)
+
+	snapshot := &model.Snapshot{Host: "host1", Groups: make(map[model.GroupID]*model.Group)}
+	store.Ingest(snapshot)
+	store.UpdateError("host1", fmt.Errorf("connection refused"))
+	store.MarkInFlight("host1")
+	for len(updates) > 0 {
+		<-updates
+	}
+
+	// The host settles and its error clears, the snapshot stays
+	store.MarkUnchanged("host1")
+	if phase := store.GetPhase("host1"); phase != PhaseSucceeded {
+		t.Errorf("Phase = %q, want %q", phase, PhaseSucceeded)
+	}
+	if err := store.GetErrors()["host1"]; err != nil {
+		t.Errorf("Expected the error to be cleared, got %v", err)
+	}
+	if got := store.GetSnapshot("host1"); got != snapshot {
+		t.Error("Expected the stored snapshot to be kept")
+	}
+	if len(store.GetHistory("host1")) != 1 {
+		t.Errorf("Expected the history to be left alone, got %d snapshots", len(store.GetHistory("host1")))
+	}
+	if update := <-updates; update.Snapshot != nil || update.Phase != PhaseSucceeded {
+		t.Errorf("Expected a phase-only update, got %+v", update)
+	}
+
+	// Nothing left to change, nobody is told
+	store.MarkUnchanged("host1")
+	if len(updates) != 0 {
+		t.Errorf("Expected no update, got %d", len(updates))
+	}
+	if got := store.GetStats().SkippedUpdates; got != 2 {
+		t.Errorf("SkippedUpdates = %d, want 2", got)
+	}
+}
+
+func TestStoreMarkStale(t *testing.T) {
+	store := New()
+	store.RegisterHosts([]string{"host1", "host2", "never"})
+	store.UpdateSnapshot(&model.Snapshot{Host: "host1", Groups: make(map[model.GroupID]*model.Group)}, nil)
+	store.UpdateSnapshot(&model.Snapshot{Host: "host2", Groups: make(map[model.GroupID]*model.Group)}, nil)
+	if store.LastSuccess("host1").IsZero() || !store.LastSuccess("never").IsZero() {
+		t.Fatal("Expected a last success for host1 only")
+	}
+
+	updates := make(chan Update, 10)
+	store.Subscribe(updates)
+	minute := func(string) time.Duration { return time.Minute }
+
+	// Nothing is old yet
+	if flagged := store.MarkStale(time.Now(), minute); len(flagged) != 0 {
+		t.Errorf("Expected nothing stale, got %v", flagged)
+	}
+
+	// Hosts without a snapshot have nothing to be stale
+	later := time.Now().Add(2 * time.Minute)
+	if flagged := store.MarkStale(later, minute); !slices.Equal(flagged, []string{"host1", "host2"}) {
+		t.Errorf("Expected host1 and host2 stale, got %v", flagged)
+	}
+	if update := <-updates; !update.Stale || update.Host != "host1" {
+		t.Errorf("Expected a stale update for host1, got %+v", update)
+	}
+	<-updates
+	if !store.IsStale("host1") || store.IsStale("never") {
+		t.Error("Expected host1 flagged and never not")
+	}
+
+	// Flagged once until the next success, which clears it
+	if flagged := store.MarkStale(later, minute); len(flagged) != 0 {
+		t.Errorf("Expected no repeat flags, got %v", flagged)
+	}
+	store.MarkUnchanged("host1")
+	store.UpdateSnapshot(&model.Snapshot{Host: "host2", Groups: make(map[model.GroupID]*model.Group)}, nil)
+	if store.IsStale("host1") || store.IsStale("host2") {
+		t.Error("Expected a success to clear the flag")
+	}
+
+	// Each host is held to its own threshold
+	perHost := func(host string) time.Duration {
+		if host == "host2" {
+			return 5 * time.Minute
+		}
+		return time.Minute
+	}
+	if flagged := store.MarkStale(later, perHost); !slices.Equal(flagged, []string{"host1"}) {
+		t.Errorf("Expected only host1 stale with host2 given longer, got %v", flagged)
+	}
+
+	// Thresholds may come from what feeds the store, such as a source
+	// reporting to it while looking up a target's interval
+	feeding := func(host string) time.Duration {
+		store.MarkInFlight(host)
+		return time.Minute
+	}
+	if flagged := store.MarkStale(later.Add(time.Hour), feeding); !slices.Equal(flagged, []string{"host2"}) {
+		t.Errorf("Expected host2 stale, got %v", flagged)
+	}
+
+	store.RemoveHost("host1")
+	if !store.LastSuccess("host1").IsZero() {
+		t.Error("Expected the last success forgotten with the host")
+	}
+}
+
+func TestStoreInterning(t *testing.T) {
+	store := New()
+	store.SetInterning(true)
+
+	// Each snapshot gets its own copies, as parsing two dumps would
+	snapshot := func(host string, funcs ...string) *model.Snapshot {
+		s := &model.Snapshot{Host: host, Groups: make(map[model.GroupID]*model.Group)}
+		for _, fn := range funcs {
+			id := model.GroupID(fn)
+			s.Groups[id] = &model.Group{ID: id, Count: 1, Trace: model.StackTrace{
+				{Func: strings.Clone(fn), File: "main.go", Line: 10},
+				{Func: strings.Clone("runtime.goexit"), File: "asm.s", Line: 1},
+			}}
+		}
+		return s
+	}
+
+	store.UpdateSnapshot(snapshot("host1", "main.worker", "main.reader"), nil)
+	store.UpdateSnapshot(snapshot("host2", "main.worker", "main.writer"), nil)
+
+	g1 := store.GetSnapshot("host1").Groups["main.worker"]
+	g2 := store.GetSnapshot("host2").Groups["main.worker"]
+	if &g1.Trace[0] != &g2.Trace[0] {
+		t.Error("Expected equal traces on two hosts to share storage")
+	}
+	// Distinct traces still share their common frames' strings
+	reader := store.GetSnapshot("host1").Groups["main.reader"].Trace[1].Func
+	writer := store.GetSnapshot("host2").Groups["main.writer"].Trace[1].Func
+	if unsafe.StringData(reader) != unsafe.StringData(writer) {
+		t.Error("Expected equal function names to share storage")
+	}
+
+	stats := store.GetStats()
+	if stats.InternedTraces != 3 || stats.InternLookups != 4 || stats.InternHits != 1 {
+		t.Errorf("Unexpected intern counts %+v", stats)
+	}
+	if ratio := stats.DedupRatio(); ratio != 0.25 {
+		t.Errorf("DedupRatio() = %v, want 0.25", ratio)
+	}
+
+	store.SetInterning(false)
+	if stats := store.GetStats(); stats.InternLookups != 0 || stats.DedupRatio() != 0 {
+		t.Errorf("Expected no intern counts when off, got %+v", stats)
+	}
+}
+
+func TestStoreInterningPrune(t *testing.T) {
+	store := New()
+	store.SetInterning(true)
+	store.SetHistoryDepth(0)
+
+	funcs := func(prefix string) []string {
+		var fns []string
+		for i := range minPrune {
+			fns = append(fns, fmt.Sprintf("%s.f%d", prefix, i))
+		}
+		return fns
+	}
+	put := func(fns []string) {
+		s := &model.Snapshot{Host: "host1", Groups: make(map[model.GroupID]*model.Group)}
+		for _, fn := range fns {
+			s.Groups[model.GroupID(fn)] = &model.Group{ID: model.GroupID(fn), Count: 1, Trace: model.StackTrace{{Func: fn}}}
+		}
+		store.UpdateSnapshot(s, nil)
+	}
+
+	// The baseline keeps its trace for as long as the host is around
+	put([]string{"main.main"})
+	put(funcs("a"))
+	put(funcs("b"))
+	put(funcs("c"))
+
+	// Only the baseline's and the latest snapshot's traces are still used
+	if got := store.GetStats().InternedTraces; got != minPrune+1 {
+		t.Errorf("InternedTraces = %d, want %d", got, minPrune+1)
+	}
+}
+
+func TestStoreLabels(t *testing.T) {
+	store := New()
+	store.RegisterHosts([]string{"host1", "host2"})
+
+	labels := map[string]string{"env": "prod"}
+	store.SetLabels("host1", labels)
+	labels["env"] = "changed"
+	if got := store.GetLabels("host1"); got["env"] != "prod" {
+		t.Errorf("Expected the labels copied, got %v", got)
+	}
+	if got := store.GetLabels("host2"); got != nil {
+		t.Errorf("Expected no labels for host2, got %v", got)
+	}
+
+	store.SetLabels("host1", nil)
+	if got := store.GetLabels("host1"); got != nil {
+		t.Errorf("Expected nil labels to remove them, got %v", got)
+	}
+
+	store.SetLabels("host2", map[string]string{"env": "staging"})
+	store.RemoveHost("host2")
+	if got := store.GetLabels("host2"); got != nil {
+		t.Errorf("Expected the labels forgotten with the host, got %v", got)
+	}
+}
+
+func TestStoreMaxMemory(t *testing.T) {
+	store := New()
+	snapshot := func(host string, at time.Time) *model.Snapshot {
+		s := &model.Snapshot{Host: host, TakenAt: at, Groups: make(map[model.GroupID]*model.Group)}
+		for i := range 20 {
+			id := model.GroupID(fmt.Sprintf("g%d", i))
+			s.Groups[id] = &model.Group{ID: id, Count: 1, Trace: model.StackTrace{{Func: fmt.Sprintf("main.worker%d", i), File: "/app/main.go"}}}
+		}
+		return s
+	}
+
+	// Every host gets a baseline and four more snapshots, interleaved in time
+	start := time.Now()
+	for i := range 5 {
+		for j, host := range []string{"host1", "host2"} {
+			store.UpdateSnapshot(snapshot(host, start.Add(time.Duration(2*i+j)*time.Second)), nil)
+		}
+	}
+	one := snapshotSize(store.GetSnapshot("host1"), true)
+	if got := store.GetStats().EstimatedBytes; got != 10*one {
+		t.Fatalf("EstimatedBytes = %d, want %d", got, 10*one)
+	}
+
+	// Room for the baselines, the latest snapshots and two more. The oldest
+	// entries go first, host by host; the baselines leave the history too but
+	// stay in memory, so four more have to go.
+	store.SetMaxMemory(6 * one)
+	stats := store.GetStats()
+	if stats.EstimatedBytes > 6*one || stats.EvictedSnapshots != 6 {
+		t.Errorf("Expected 6 snapshots evicted to fit, got %+v", stats)
+	}
+	for _, host := range []string{"host1", "host2"} {
+		history := store.GetHistory(host)
+		if len(history) != 2 || history[1] != store.GetSnapshot(host) {
+			t.Errorf("Expected %s's latest two snapshots kept, got %d", host, len(history))
+		}
+	}
+
+	// A budget too small for the latest snapshots keeps them anyway
+	store.SetMaxMemory(1)
+	for _, host := range []string{"host1", "host2"} {
+		if history := store.GetHistory(host); len(history) != 1 || history[0] != store.GetSnapshot(host) {
+			t.Errorf("Expected only %s's latest snapshot kept, got %d", host, len(history))
+		}
+	}
+	store.UpdateSnapshot(snapshot("host1", start.Add(time.Minute)), nil)
+	if history := store.GetHistory("host1"); len(history) != 1 {
+		t.Errorf("Expected new snapshots to push out the previous ones, got %d", len(history))
+	}
+}
+
+func TestStoreMaxMemoryInterning(t *testing.T) {
+	store := New()
+	store.SetInterning(true)
+	snapshot := func(i int) *model.Snapshot {
+		s := &model.Snapshot{Host: "host1", TakenAt: time.Unix(int64(i), 0), Groups: make(map[model.GroupID]*model.Group)}
+		for j := range 20 {
+			id := model.GroupID(fmt.Sprintf("g%02d", j))
+			s.Groups[id] = &model.Group{ID: id, Count: 1, Trace: model.StackTrace{{Func: fmt.Sprintf("main.worker%d_%02d", i, j), File: "/app/main.go"}}}
+		}
+		return s
+	}
+	for i := range 5 {
+		store.UpdateSnapshot(snapshot(i), nil)
+	}
+	// No two snapshots share a trace, so the interner saves nothing
+	one := snapshotSize(store.GetSnapshot("host1"), true)
+	if got := store.GetStats().EstimatedBytes; got != 5*one {
+		t.Fatalf("EstimatedBytes = %d, want %d", got, 5*one)
+	}
+
+	// Dropping a snapshot frees the traces only it used, so room for the
+	// baseline and three more keeps the latest three in the history
+	store.SetMaxMemory(4 * one)
+	stats := store.GetStats()
+	if stats.EstimatedBytes != 4*one || stats.EvictedSnapshots != 2 {
+		t.Errorf("Expected 2 snapshots evicted to fit, got %+v", stats)
+	}
+	if history := store.GetHistory("host1"); len(history) != 3 {
+		t.Errorf("Expected the latest three snapshots kept, got %d", len(history))
+	}
+
+	store.Clear()
+	if got := store.GetStats().EstimatedBytes; got != 0 {
+		t.Errorf("Expected nothing estimated once cleared, got %d", got)
+	}
+}